@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// NewConfigCmd returns the config command group.
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Load and validate the config, then print the effective config as YAML",
+		Long: "Load and validate the config with --env and --set applied, without touching the database. " +
+			"On success the effective config, with defaults filled in and secrets masked, is printed as YAML; " +
+			"otherwise every problem is listed and the exit code is non-zero.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := LoadConfig()
+			if err != nil {
+				return err
+			}
+			enc := yaml.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent(2)
+			if err := enc.Encode(cfg.Redacted()); err != nil {
+				return err
+			}
+			return enc.Close()
+		},
+	})
+	return cmd
+}
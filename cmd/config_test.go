@@ -0,0 +1,65 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+)
+
+func runConfigValidate(t *testing.T, content string, args ...string) (string, error) {
+	t.Helper()
+	cfgPath := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	root := appcmd.NewRootCmd()
+	root.AddCommand(appcmd.NewConfigCmd())
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetErr(&out)
+	root.SetArgs(append([]string{"config", "validate", "--config", cfgPath}, args...))
+	err := root.Execute()
+	return out.String(), err
+}
+
+func TestConfigValidatePrintsEffectiveConfig(t *testing.T) {
+	out, err := runConfigValidate(t, "database:\n  dsn: postgres://app:s3cret@db:5432/app\n", "--set", "database.max_retries=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Env      string
+		Database struct {
+			Driver     string
+			Dsn        string
+			MaxRetries int `yaml:"max_retries"`
+		}
+	}
+	if err := yaml.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("output is not YAML: %v\n%s", err, out)
+	}
+	if got.Env != "development" || got.Database.Driver != "postgres" || got.Database.MaxRetries != 5 {
+		t.Fatalf("defaults or overrides missing: %+v", got)
+	}
+	if strings.Contains(out, "s3cret") || got.Database.Dsn != "postgres://app:xxxxx@db:5432/app" {
+		t.Fatalf("password not masked: dsn = %q", got.Database.Dsn)
+	}
+}
+
+func TestConfigValidateFailsOnProblems(t *testing.T) {
+	_, err := runConfigValidate(t, "env: prod\nlogging:\n  driver: rabbitmq\n")
+	if err == nil {
+		t.Fatal("expected invalid config to fail")
+	}
+	for _, want := range []string{"env: unknown environment", "database.dsn is required", "logging.rabbitmq.url is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeadlineError is returned when --deadline expires before a command
+// finishes.
+type DeadlineError struct {
+	Deadline time.Duration
+	// LastApplied is the last version known to be applied, or empty when
+	// unknown.
+	LastApplied string
+}
+
+func (e *DeadlineError) Error() string {
+	last := e.LastApplied
+	if last == "" {
+		last = "unknown"
+	}
+	return fmt.Sprintf("deadline of %s exceeded; last applied version %s", e.Deadline, last)
+}
+
+func (e *DeadlineError) Unwrap() error { return context.DeadlineExceeded }
+
+// Deadline returns the --deadline duration, or 0 when unset.
+func Deadline() time.Duration { return deadlineFlag }
+
+// Remaining returns the time left before --deadline expires, counted from
+// the start of the command; ok is false without a deadline.
+func Remaining() (left time.Duration, ok bool) {
+	if deadlineAt.IsZero() {
+		return 0, false
+	}
+	return time.Until(deadlineAt), true
+}
+
+// DeadlineExpired reports whether --deadline has passed. The database may
+// still be busy with an abandoned statement then, so callers skip whatever
+// would wait for it, such as closing the Manager or querying its status.
+func DeadlineExpired() bool {
+	left, ok := Remaining()
+	return ok && left <= 0
+}
+
+// RunWithDeadline runs fn with a context ending at --deadline. When the
+// deadline passes first it returns a *DeadlineError at once instead of
+// waiting for fn: a statement stuck in the database is abandoned and the
+// server rolls it back when the process exits. lastApplied reports the
+// version to name in the error; it must not block on the database.
+func RunWithDeadline(fn func(ctx context.Context) error, lastApplied func() string) error {
+	if deadlineAt.IsZero() {
+		return fn(context.Background())
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadlineAt)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+	select {
+	case err := <-done:
+		if ctx.Err() == nil {
+			return err
+		}
+	case <-ctx.Done():
+	}
+	return &DeadlineError{Deadline: deadlineFlag, LastApplied: lastApplied()}
+}
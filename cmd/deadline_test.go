@@ -0,0 +1,95 @@
+package cmd_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+)
+
+func TestDeadlineAbortsStuckCommand(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	root.SetOut(io.Discard)
+	root.SetErr(io.Discard)
+	stuck := make(chan struct{})
+	defer close(stuck)
+	canceled := make(chan struct{})
+	root.AddCommand(&cobra.Command{
+		Use: "up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return appcmd.RunWithDeadline(func(ctx context.Context) error {
+				<-ctx.Done()
+				close(canceled)
+				<-stuck // an in-flight statement that ignores cancellation
+				return nil
+			}, func() string { return "3" })
+		},
+	})
+	root.SetArgs([]string{"up", "--deadline", "50ms"})
+
+	start := time.Now()
+	err := root.Execute()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("command took %s, want abort at the deadline", elapsed)
+	}
+	var de *appcmd.DeadlineError
+	if !errors.As(err, &de) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want a DeadlineError", err)
+	}
+	if !strings.Contains(err.Error(), "last applied version 3") {
+		t.Fatalf("err = %q, want the last applied version", err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("operation context was not canceled")
+	}
+}
+
+func TestRunWithDeadlineWithoutFlagRunsToCompletion(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	root.SetOut(io.Discard)
+	root.AddCommand(&cobra.Command{
+		Use: "up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return appcmd.RunWithDeadline(func(ctx context.Context) error {
+				if _, ok := ctx.Deadline(); ok {
+					return errors.New("unexpected deadline")
+				}
+				return nil
+			}, func() string { return "" })
+		},
+	})
+	root.SetArgs([]string{"up"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeadlineExpired(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	root.SetOut(io.Discard)
+	var before, after bool
+	root.AddCommand(&cobra.Command{
+		Use: "up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			before = appcmd.DeadlineExpired()
+			time.Sleep(60 * time.Millisecond)
+			after = appcmd.DeadlineExpired()
+			return nil
+		},
+	})
+	root.SetArgs([]string{"up", "--deadline", "30ms"})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if before || !after {
+		t.Fatalf("expired before = %v, after = %v; want false, true", before, after)
+	}
+}
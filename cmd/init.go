@@ -12,7 +12,8 @@ import (
 // NewInitCmd returns a command that creates config and migration templates.
 func NewInitCmd() *cobra.Command {
 	var cfgPath string
-	var migrationsDir string
+	var migrationsDir, driver string
+	var configOnly, migrationsOnly, force bool
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Generate config file and migrations directory",
@@ -23,40 +24,76 @@ func NewInitCmd() *cobra.Command {
 			if migrationsDir == "" {
 				migrationsDir = "migrations"
 			}
-			if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
-				return err
-			}
-			if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
-				if err := os.WriteFile(cfgPath, []byte(templates.DefaultConfig), 0o644); err != nil {
+			if !migrationsOnly {
+				if err := initConfig(cmd, cfgPath, force); err != nil {
 					return err
 				}
-				cmd.Printf("created config at %s\n", cfgPath)
-			} else if err == nil {
-				cmd.Printf("config already exists at %s\n", cfgPath)
-			} else {
-				return err
-			}
-
-			if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
-				return err
 			}
-			up := filepath.Join(migrationsDir, "000001_init.up.sql")
-			down := filepath.Join(migrationsDir, "000001_init.down.sql")
-			if _, err := os.Stat(up); os.IsNotExist(err) {
-				if err := os.WriteFile(up, []byte(templates.InitUp), 0o644); err != nil {
+			if !configOnly {
+				if err := initMigrations(cmd, migrationsDir, driver); err != nil {
 					return err
 				}
 			}
-			if _, err := os.Stat(down); os.IsNotExist(err) {
-				if err := os.WriteFile(down, []byte(templates.InitDown), 0o644); err != nil {
-					return err
-				}
-			}
-			cmd.Printf("initialized migrations at %s\n", migrationsDir)
 			return nil
 		},
 	}
 	cmd.Flags().StringVar(&cfgPath, "config_path", "configs/config.yml", "path to config file")
 	cmd.Flags().StringVar(&migrationsDir, "migrations", "migrations", "migrations directory")
+	cmd.Flags().StringVar(&driver, "driver", "postgres", "database the sample migration is written for: postgres, mysql or sqlite")
+	cmd.Flags().BoolVar(&configOnly, "config-only", false, "only generate the config file")
+	cmd.Flags().BoolVar(&migrationsOnly, "migrations-only", false, "only generate the migrations directory")
+	cmd.Flags().BoolVar(&force, "force", false, "overwrite an existing config file")
+	cmd.MarkFlagsMutuallyExclusive("config-only", "migrations-only")
 	return cmd
 }
+
+// initConfig writes the default config to cfgPath unless it already exists
+// and force is false.
+func initConfig(cmd *cobra.Command, cfgPath string, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
+		return err
+	}
+	_, err := os.Stat(cfgPath)
+	switch {
+	case err == nil && !force:
+		cmd.Printf("config already exists at %s\n", cfgPath)
+		return nil
+	case err != nil && !os.IsNotExist(err):
+		return err
+	}
+	if err := os.WriteFile(cfgPath, []byte(templates.DefaultConfig), 0o644); err != nil {
+		return err
+	}
+	if err == nil {
+		cmd.Printf("overwrote config at %s\n", cfgPath)
+	} else {
+		cmd.Printf("created config at %s\n", cfgPath)
+	}
+	return nil
+}
+
+// initMigrations creates migrationsDir with a sample migration pair for
+// driver, leaving existing files untouched.
+func initMigrations(cmd *cobra.Command, migrationsDir, driver string) error {
+	initUp, err := templates.InitUp(driver)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(migrationsDir, 0o755); err != nil {
+		return err
+	}
+	up := filepath.Join(migrationsDir, "000001_init.up.sql")
+	down := filepath.Join(migrationsDir, "000001_init.down.sql")
+	if _, err := os.Stat(up); os.IsNotExist(err) {
+		if err := os.WriteFile(up, []byte(initUp), 0o644); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(down); os.IsNotExist(err) {
+		if err := os.WriteFile(down, []byte(templates.InitDown), 0o644); err != nil {
+			return err
+		}
+	}
+	cmd.Printf("initialized migrations at %s\n", migrationsDir)
+	return nil
+}
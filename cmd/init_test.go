@@ -0,0 +1,122 @@
+package cmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	"github.com/lenhattri/kaeshi-migrate/internal/templates"
+)
+
+func initPaths(t *testing.T) (cfgPath, migDir string) {
+	t.Helper()
+	dir := t.TempDir()
+	return filepath.Join(dir, "configs", "config.yml"), filepath.Join(dir, "migrations")
+}
+
+func execInit(t *testing.T, cfgPath, migDir string, args ...string) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	c := appcmd.NewInitCmd()
+	c.SetOut(&buf)
+	c.SetErr(&buf)
+	c.SetArgs(append([]string{"--config_path", cfgPath, "--migrations", migDir}, args...))
+	err := c.Execute()
+	return buf.String(), err
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestInitFlags(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantConfig bool
+		wantMig    bool
+		wantErr    bool
+	}{
+		{name: "default", wantConfig: true, wantMig: true},
+		{name: "config only", args: []string{"--config-only"}, wantConfig: true},
+		{name: "migrations only", args: []string{"--migrations-only"}, wantMig: true},
+		{name: "config only with force", args: []string{"--config-only", "--force"}, wantConfig: true},
+		{name: "both only flags", args: []string{"--config-only", "--migrations-only"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgPath, migDir := initPaths(t)
+			_, err := execInit(t, cfgPath, migDir, tt.args...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := exists(cfgPath); got != tt.wantConfig {
+				t.Fatalf("config exists = %v, want %v", got, tt.wantConfig)
+			}
+			if got := exists(filepath.Join(migDir, "000001_init.up.sql")); got != tt.wantMig {
+				t.Fatalf("migrations exist = %v, want %v", got, tt.wantMig)
+			}
+		})
+	}
+}
+
+func TestInitForceOverwritesConfig(t *testing.T) {
+	cfgPath, migDir := initPaths(t)
+	if err := os.MkdirAll(filepath.Dir(cfgPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cfgPath, []byte("env: custom\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := execInit(t, cfgPath, migDir, "--config-only")
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if !strings.Contains(out, "already exists") {
+		t.Fatalf("expected already exists message, got %q", out)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != "env: custom\n" {
+		t.Fatalf("config overwritten without --force")
+	}
+
+	out, err = execInit(t, cfgPath, migDir, "--config-only", "--force")
+	if err != nil {
+		t.Fatalf("init --force: %v", err)
+	}
+	if !strings.Contains(out, "overwrote config") {
+		t.Fatalf("expected overwrite message, got %q", out)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != templates.DefaultConfig {
+		t.Fatalf("config not overwritten with --force")
+	}
+}
+
+func TestInitWritesMigrationForDriver(t *testing.T) {
+	for driver, want := range map[string]string{
+		"postgres": "id SERIAL PRIMARY KEY",
+		"mysql":    "id BIGINT AUTO_INCREMENT PRIMARY KEY",
+		"sqlite":   "id INTEGER PRIMARY KEY AUTOINCREMENT",
+	} {
+		cfgPath, migDir := initPaths(t)
+		if _, err := execInit(t, cfgPath, migDir, "--migrations-only", "--driver", driver); err != nil {
+			t.Fatalf("%s: %v", driver, err)
+		}
+		data, err := os.ReadFile(filepath.Join(migDir, "000001_init.up.sql"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("%s migration lacks %q:\n%s", driver, want, data)
+		}
+	}
+
+	cfgPath, migDir := initPaths(t)
+	if _, err := execInit(t, cfgPath, migDir, "--driver", "oracle"); err == nil || exists(migDir) {
+		t.Fatalf("unknown driver: err = %v, want an error and no migrations", err)
+	}
+}
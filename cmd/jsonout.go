@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// SelfJSON is the annotation key of commands that print their own JSON
+// document with --output json instead of a CommandResult.
+const SelfJSON = "kaeshi.self-json"
+
+// CommandResult is what a command prints instead of its human output with
+// --output json: on stdout when it succeeds, on stderr when it fails. Versions
+// and the pending count are null when no database is open or nothing has
+// been applied.
+type CommandResult struct {
+	Command     string `json:"command"`
+	FromVersion *uint  `json:"from_version"`
+	ToVersion   *uint  `json:"to_version"`
+	Pending     *int   `json:"pending"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ReportedError is a command error already printed as a CommandResult; the
+// caller only needs to set the exit code.
+type ReportedError struct{ Err error }
+
+func (e *ReportedError) Error() string { return e.Err.Error() }
+
+func (e *ReportedError) Unwrap() error { return e.Err }
+
+// JSONOutput reports whether --output json is selected.
+func JSONOutput() bool { return outputFlag == "json" }
+
+// LogOutput returns where log entries belong: stderr with --output json, so
+// stdout carries nothing but the JSON document, and stdout otherwise.
+func LogOutput() io.Writer {
+	if JSONOutput() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// WriteJSON writes v to w as one line of JSON.
+func WriteJSON(w io.Writer, v any) error { return json.NewEncoder(w).Encode(v) }
+
+// WithJSONResults wraps the RunE of root and every command below it so that
+// with --output json their human output is discarded and a CommandResult is
+// printed instead. state reports the current version and pending count; it
+// is called before and after the command runs.
+func WithJSONResults(root *cobra.Command, state func() (version *uint, pending *int)) {
+	for _, c := range root.Commands() {
+		WithJSONResults(c, state)
+	}
+	run := root.RunE
+	if run == nil || root.Annotations[SelfJSON] != "" {
+		return
+	}
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		if !JSONOutput() {
+			return run(cmd, args)
+		}
+		res := CommandResult{Command: cmd.Name()}
+		res.FromVersion, _ = state()
+		stdout, stderr := cmd.OutOrStdout(), cmd.ErrOrStderr()
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		err := run(cmd, args)
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		res.ToVersion, res.Pending = state()
+		if err != nil {
+			res.Error = err.Error()
+			_ = WriteJSON(stderr, res)
+			return &ReportedError{Err: err}
+		}
+		return WriteJSON(stdout, res)
+	}
+}
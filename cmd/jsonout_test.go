@@ -0,0 +1,129 @@
+package cmd_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	"github.com/lenhattri/kaeshi-migrate/pkg/logger"
+)
+
+func TestJSONOutputReplacesHumanOutput(t *testing.T) {
+	version, pending := uint(2), 3
+	state := func() (*uint, *int) {
+		v, p := version, pending
+		return &v, &p
+	}
+
+	for _, c := range []struct {
+		name    string
+		err     error
+		wantOut bool
+	}{
+		{"up", nil, true},
+		{"down", errors.New("boom"), false},
+	} {
+		root := appcmd.NewRootCmd()
+		root.AddCommand(&cobra.Command{
+			Use: c.name,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cmd.Println("✅ Migrations applied successfully.")
+				version = 5
+				return c.err
+			},
+		})
+		appcmd.WithJSONResults(root, state)
+		var stdout, stderr bytes.Buffer
+		root.SetOut(&stdout)
+		root.SetErr(&stderr)
+		root.SetArgs([]string{c.name, "--output", "json"})
+		version = 2
+		err := root.Execute()
+
+		var reported *appcmd.ReportedError
+		if (c.err != nil) != errors.As(err, &reported) {
+			t.Fatalf("%s: err = %v", c.name, err)
+		}
+		raw := stderr.Bytes()
+		if c.wantOut {
+			raw = stdout.Bytes()
+		}
+		var res appcmd.CommandResult
+		if err := json.Unmarshal(raw, &res); err != nil {
+			t.Fatalf("%s: output %q is not a CommandResult: %v", c.name, raw, err)
+		}
+		if res.Command != c.name || *res.FromVersion != 2 || *res.ToVersion != 5 || *res.Pending != 3 {
+			t.Fatalf("%s: result = %+v", c.name, res)
+		}
+		if c.err != nil && res.Error != "boom" {
+			t.Fatalf("%s: error = %q, want boom", c.name, res.Error)
+		}
+		if c.err != nil && stdout.Len() != 0 {
+			t.Fatalf("%s: stdout = %q, want nothing", c.name, stdout.String())
+		}
+	}
+}
+
+func TestJSONOutputKeepsLogsOffStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdout := os.Stdout
+	os.Stdout = w
+	t.Cleanup(func() { os.Stdout = oldStdout })
+
+	root := appcmd.NewRootCmd()
+	root.AddCommand(&cobra.Command{
+		Use: "up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.New("info", "", nil, "", "", "", "", "json")
+			log.SetOutput(appcmd.LogOutput())
+			log.WithFields(logrus.Fields{"version": 1}).Info("migration applied")
+			return nil
+		},
+	})
+	appcmd.WithJSONResults(root, func() (*uint, *int) { return nil, nil })
+	root.SetOut(w)
+	root.SetArgs([]string{"up", "--output", "json"})
+	stderr, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stderr.Close()
+	oldStderr := os.Stderr
+	os.Stderr = stderr
+	err = root.Execute()
+	os.Stderr = oldStderr
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	lines := 0
+	for sc.Scan() {
+		lines++
+		dec := json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		dec.DisallowUnknownFields()
+		var res appcmd.CommandResult
+		if err := dec.Decode(&res); err != nil {
+			t.Fatalf("stdout line %d %q is not a CommandResult: %v", lines, sc.Text(), err)
+		}
+	}
+	if lines != 1 {
+		t.Fatalf("stdout has %d lines, want only the result:\n%s", lines, out)
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/metrics"
+)
+
+// InstrumentCommands wraps the RunE of root and every subcommand so each
+// invocation records its duration in h, labeled with the command path and
+// "success" or "failure".
+func InstrumentCommands(root *cobra.Command, h *metrics.HistogramVec) {
+	for _, c := range root.Commands() {
+		InstrumentCommands(c, h)
+	}
+	run := root.RunE
+	if run == nil {
+		return
+	}
+	root.RunE = func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		err := run(cmd, args)
+		outcome := "success"
+		if err != nil {
+			outcome = "failure"
+		}
+		h.Observe(time.Since(start).Seconds(), commandName(cmd), outcome)
+		return err
+	}
+}
+
+// commandName is the command path without the binary name, e.g. "up".
+func commandName(cmd *cobra.Command) string {
+	if cmd.HasParent() {
+		return cmd.CommandPath()[len(cmd.Root().Name())+1:]
+	}
+	return cmd.Name()
+}
+
+// WriteMetricsFile writes reg to the --metrics-file path, if one was given.
+func WriteMetricsFile(reg metrics.Registry) error {
+	if metricsFileFlag == "" {
+		return nil
+	}
+	return reg.WriteFile(metricsFileFlag)
+}
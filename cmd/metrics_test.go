@@ -0,0 +1,87 @@
+package cmd_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	"github.com/lenhattri/kaeshi-migrate/internal/metrics"
+)
+
+func TestInstrumentCommandsRecordsEachInvocation(t *testing.T) {
+	h := metrics.NewHistogramVec("test_command_duration_seconds", "test", "command", "outcome")
+	root := &cobra.Command{Use: "kaeshi", SilenceErrors: true, SilenceUsage: true}
+	root.AddCommand(
+		&cobra.Command{Use: "status", RunE: func(*cobra.Command, []string) error { return nil }},
+		&cobra.Command{Use: "up", RunE: func(*cobra.Command, []string) error { return errors.New("boom") }},
+	)
+	appcmd.InstrumentCommands(root, h)
+
+	for _, args := range [][]string{{"status"}, {"status"}, {"up"}} {
+		root.SetArgs(args)
+		_ = root.Execute()
+	}
+
+	if got := h.Count("status", "success"); got != 2 {
+		t.Fatalf("status/success = %d, want 2", got)
+	}
+	if got := h.Count("up", "failure"); got != 1 {
+		t.Fatalf("up/failure = %d, want 1", got)
+	}
+
+	var buf bytes.Buffer
+	if err := h.WriteText(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE test_command_duration_seconds histogram",
+		`test_command_duration_seconds_bucket{command="up",outcome="failure",le="+Inf"} 1`,
+		`test_command_duration_seconds_count{command="status",outcome="success"} 2`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("exposition missing %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteMetricsFileAfterCommand(t *testing.T) {
+	h := metrics.NewHistogramVec("test_file_command_duration_seconds", "test", "command", "outcome")
+	root := appcmd.NewRootCmd()
+	root.AddCommand(&cobra.Command{Use: "status", RunE: func(*cobra.Command, []string) error { return nil }})
+	appcmd.InstrumentCommands(root, h)
+
+	path := filepath.Join(t.TempDir(), "metrics.prom")
+	root.SetArgs([]string{"status", "--metrics-file", path})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if err := appcmd.WriteMetricsFile(metrics.Registry{h}); err != nil {
+		t.Fatalf("write metrics file: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read metrics file: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE test_file_command_duration_seconds histogram",
+		`test_file_command_duration_seconds_count{command="status",outcome="success"} 1`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("metrics file missing %q:\n%s", want, data)
+		}
+	}
+
+	// without the flag nothing is written
+	root = appcmd.NewRootCmd()
+	root.SetArgs([]string{})
+	_ = root.Execute()
+	if err := appcmd.WriteMetricsFile(metrics.Registry{h}); err != nil {
+		t.Fatalf("no-op write: %v", err)
+	}
+}
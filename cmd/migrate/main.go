@@ -1,22 +1,36 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
 	"github.com/spf13/cobra"
 
 	"github.com/lenhattri/kaeshi-migrate/internal/config"
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
+	"github.com/lenhattri/kaeshi-migrate/internal/metrics"
 	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
 	mgmt "github.com/lenhattri/kaeshi-migrate/internal/migrate/manager"
 	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+	"github.com/lenhattri/kaeshi-migrate/internal/output"
 	"github.com/lenhattri/kaeshi-migrate/pkg/logger"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/confirm"
+	_ "github.com/lenhattri/kaeshi-migrate/pkg/validate/mysql"
+	_ "github.com/lenhattri/kaeshi-migrate/pkg/validate/sqlite"
 	"github.com/sirupsen/logrus"
 )
 
@@ -40,22 +54,63 @@ func main() {
 	rootCmd := appcmd.NewRootCmd()
 
 	var (
-		userFlag string
-		cfg      *config.Config
-		mgr      *mgmt.Manager
-		backend  mgmt.DBBackend
+		userFlag     string
+		resume       bool
+		progressFile string
+		cfg          *config.Config
+		cfgPath      string
+		mgr          *mgmt.Manager
+		backend      mgmt.DBBackend
+		openManager  func(dsn string, logger *logrus.Entry, extra ...mgmt.Option) (*mgmt.Manager, error)
+		msgs         = messages.Default
 	)
 
+	// tablePrefix prefers --table-prefix over database.table_prefix.
+	tablePrefix := func() string {
+		if p := appcmd.TablePrefix(); p != "" || cfg == nil {
+			return p
+		}
+		return cfg.Database.TablePrefix
+	}
+
+	// tableNames applies database.migrations_table and history_table over the
+	// prefixed defaults.
+	tableNames := func() migration.TableNames {
+		t := migration.PrefixedTables(tablePrefix())
+		if cfg != nil && cfg.Database.MigrationsTable != "" {
+			t.Migrations = cfg.Database.MigrationsTable
+		}
+		if cfg != nil && cfg.Database.HistoryTable != "" {
+			t.History = cfg.Database.HistoryTable
+		}
+		return t
+	}
+
+	// migrationDirs returns the directories to merge: a comma-separated
+	// --migrations list, else migrations_dirs. Nil means the single
+	// --migrations directory.
+	migrationDirs := func() []string {
+		if dirs := appcmd.MigrationsDirs(); len(dirs) > 1 {
+			return dirs
+		}
+		if cfg == nil {
+			return nil
+		}
+		return cfg.MigrationDirs()
+	}
+
 	rootCmd.PersistentFlags().StringVar(&userFlag, "user", "", "name executing the command")
 	rootCmd.AddCommand(appcmd.NewInitCmd())
+	rootCmd.AddCommand(appcmd.NewSelftestCmd())
+	rootCmd.AddCommand(appcmd.NewConfigCmd())
 
-	// initApp lazily loads configuration and initializes the manager
-	initApp := func() error {
-		if mgr != nil {
+	// loadApp lazily loads configuration and prepares openManager
+	loadApp := func() error {
+		if openManager != nil {
 			return nil
 		}
 		var err error
-		cfg, err = config.Load(appcmd.ConfigPath())
+		cfg, cfgPath, err = appcmd.LoadConfig()
 		if err != nil {
 			return err
 		}
@@ -64,39 +119,220 @@ func main() {
 		}
 		log = logger.New(
 			cfg.Logging.Level,
-			cfg.Env,
 			cfg.Logging.Driver,
 			cfg.Logging.Kafka.Brokers,
 			cfg.Logging.Kafka.Topic,
 			cfg.Logging.RabbitMQ.URL,
 			cfg.Logging.RabbitMQ.Queue,
-			cfg.Logging.File,
+			cfg.LogFile(),
+			cfg.Logging.Format,
 		)
+		log.SetOutput(appcmd.LogOutput())
 		var ok bool
 		backend, ok = mgmt.GetBackend(cfg.Database.Driver)
 		if !ok {
 			return fmt.Errorf("unknown database driver: %s", cfg.Database.Driver)
 		}
+		lockID := cfg.Database.AdvisoryLockID
+		if id, set := appcmd.LockID(); set {
+			lockID = id
+		}
+		if lockID != 0 {
+			pg, ok := backend.(mgmt.PostgresBackend)
+			if !ok {
+				return fmt.Errorf("advisory lock id is only supported by the postgres driver, not %s", cfg.Database.Driver)
+			}
+			pg.AdvisoryLockID = lockID
+			backend = pg
+		}
+		if msgs, err = messages.New(cfg.Messages); err != nil {
+			return err
+		}
 		noteCfg := cfg.Notifier
+		noteCfg.Messages = msgs
 		if appcmd.NoNotify() {
 			noteCfg.Enabled = false
 		}
 		notifierInst := notifier.NewNotifier(noteCfg)
-		mgr, err = mgmt.NewManager(backend, cfg.Database.Dsn, appcmd.MigrationsDir(), 3, log.WithField("component", "migrate"), userFlag, cfg.Env == "production", appcmd.AskConfirmation, notifierInst)
+		opts := []mgmt.Option{
+			mgmt.WithEnv(cfg.Env),
+			mgmt.WithDownLint(cfg.Validation.DownLint),
+			mgmt.WithLockOrderLint(cfg.Validation.LockOrderLint),
+			mgmt.WithRollbackCheck(cfg.Validation.RollbackCheck),
+			mgmt.WithSchemaSnapshot(cfg.Validation.SchemaSnapshot),
+			mgmt.WithEmptyMigrations(cfg.Validation.EmptyMigrations),
+			mgmt.WithNormalizedHash(cfg.Validation.NormalizeHash),
+			mgmt.WithStripLoggedComments(cfg.Logging.StripSQLComments),
+			mgmt.WithLockWaitThreshold(cfg.Database.LockWaitThreshold),
+			mgmt.WithLockTimeout(cfg.Database.LockTimeout),
+			mgmt.WithHeartbeatInterval(cfg.Logging.HeartbeatInterval),
+			mgmt.WithDDLLockTimeout(cfg.Database.DDLLockTimeout, cfg.Database.DDLLockAttempts),
+			mgmt.WithStrictOrder(appcmd.StrictOrder()),
+			mgmt.WithDeniedStatements(cfg.DeniedStatements(), appcmd.AllowDenied()),
+			mgmt.WithValidation(cfg.Validation.Enabled && !appcmd.NoValidate()),
+			mgmt.WithTemplateVars(cfg.MigrationVars()),
+			mgmt.WithDryRun(appcmd.DryRun()),
+		}
+		archive := appcmd.ArchivePath()
+		if archive == "" {
+			archive = cfg.MigrationsArchive
+		}
+		if archive != "" {
+			fsys, err := mgmt.OpenArchive(archive)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, mgmt.WithFS(fsys))
+		}
+		if dirs := migrationDirs(); len(dirs) > 0 {
+			if archive != "" {
+				return fmt.Errorf("several migrations directories cannot be combined with a migrations archive")
+			}
+			fsys, err := mgmt.MergeDirs(dirs...)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, mgmt.WithFS(fsys))
+		}
+		if v := cfg.Validation; len(v.SignatureKeys) > 0 || v.RequireSignatures {
+			keys, err := mgmt.LoadSignatureKeys(v.SignatureKeys...)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, mgmt.WithSignatureKeys(keys, v.RequireSignatures))
+		}
+		if cfg.SourceURL != "" {
+			opts = append(opts, mgmt.WithSourceURL(cfg.SourceURL))
+		}
+		if cfg.Database.Pooler != "" {
+			opts = append(opts, mgmt.WithPooler(cfg.Database.Pooler))
+		}
+		if prefix := tablePrefix(); prefix != "" {
+			opts = append(opts, mgmt.WithTablePrefix(prefix))
+		}
+		if db := cfg.Database; db.MigrationsTable != "" || db.HistoryTable != "" {
+			opts = append(opts, mgmt.WithTableNames(db.MigrationsTable, db.HistoryTable))
+		}
+		validateTimeout := cfg.Validation.Timeout
+		if t := appcmd.ValidateTimeout(); t > 0 {
+			validateTimeout = t
+		}
+		opts = append(opts, mgmt.WithValidateTimeout(validateTimeout))
+		retries := cfg.Database.MaxRetries
+		r := cfg.Database.Retry
+		opts = append(opts, mgmt.WithBackoff(mgmt.Backoff{Base: r.Base, Max: r.Max, Multiplier: r.Multiplier, Jitter: r.Jitter}))
+		if r := appcmd.MaxRetries(); r >= 0 {
+			retries = r
+		}
+		confirmFn := appcmd.AskConfirmation
+		if p := cfg.Validation.ConfirmPolicy; p.URL != "" {
+			confirmFn = confirm.HTTPPolicy{URL: p.URL, Headers: p.Headers, Timeout: p.Timeout}.Confirm
+		}
+		wait := cfg.Database.WaitForDB
+		if w := appcmd.WaitForDB(); w > 0 {
+			wait = w
+		}
+		openManager = func(dsn string, logger *logrus.Entry, extra ...mgmt.Option) (*mgmt.Manager, error) {
+			if left, ok := appcmd.Remaining(); ok && wait > left {
+				wait = left
+			}
+			if wait > 0 {
+				if err := mgmt.WaitForDB(backend, dsn, wait, logger); err != nil {
+					return nil, err
+				}
+			}
+			return mgmt.NewManager(backend, dsn, appcmd.MigrationsDir(), retries, logger, userFlag, cfg.Env == "production", confirmFn, notifierInst, slices.Concat(opts, extra)...)
+		}
+		return nil
+	}
+
+	// openApp loads configuration and initializes the manager for the
+	// configured DSN, with extra options such as mgmt.WithReadOnly.
+	openApp := func(extra ...mgmt.Option) error {
+		if mgr != nil {
+			return nil
+		}
+		if err := loadApp(); err != nil {
+			return err
+		}
+		var err error
+		mgr, err = openManager(cfg.Database.Dsn, log.WithField("component", "migrate"), extra...)
+		return err
+	}
+
+	// initApp is openApp with the default, read-write Manager.
+	initApp := func() error { return openApp() }
+
+	// say prints the message template key for a finished command. Failure
+	// messages go to stderr; empty messages print nothing.
+	say := func(cmd *cobra.Command, key string, r messages.Result, err error) {
+		out, sym := cmd.OutOrStdout(), outSym(cmd)
 		if err != nil {
+			out, sym = cmd.ErrOrStderr(), errSym(cmd)
+			r.Status, r.Error = "fail", err.Error()
+		} else if r.Status == "" {
+			r.Status = "success"
+		}
+		r.OK, r.Fail, r.User = sym.OK, sym.Fail, userFlag
+		if cfg != nil {
+			r.DB = cfg.Database.Driver
+		}
+		if r.Version == "" && mgr != nil {
+			if v, _, verr := mgr.Version(); verr == nil {
+				r.Version = strconv.FormatUint(uint64(v), 10)
+			}
+		}
+		if msg := msgs.Render(key, r); msg != "" {
+			fmt.Fprintln(out, msg)
+		}
+	}
+
+	// withReport wraps a RunE that moves versions so the --report artifact
+	// is written once it returns.
+	withReport := func(run func(*cobra.Command, []string) error) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			err := run(cmd, args)
+			if appcmd.ReportPath() == "" || mgr == nil {
+				return err
+			}
+			var result mgmt.RunResult
+			var deadlineErr *appcmd.DeadlineError
+			if errors.As(err, &deadlineErr) {
+				// Up may still be running, so LastRun cannot be read.
+				result.Operation = "up"
+				result.To, _ = mgr.LastApplied()
+			} else {
+				result = mgr.LastRun()
+			}
+			if werr := appcmd.WriteReport(appcmd.NewReport(cmd.Name(), userFlag, cfg.Env, result, err)); werr != nil {
+				cmd.PrintErrf("%s write report: %v\n", errSym(cmd).Warn, werr)
+			}
 			return err
 		}
-		return nil
 	}
 
-	defer func() {
+	// cleanup closes the manager and flushes the log hooks. main also calls
+	// it before os.Exit, which skips deferred calls.
+	cleanup := func() {
 		if mgr != nil {
-			_ = mgr.Close()
+			// Close waits for in-flight queries; after the deadline the
+			// process exits instead and the server rolls them back.
+			if !appcmd.DeadlineExpired() {
+				_ = mgr.Close()
+			}
+			mgr = nil
 		}
-	}()
+		if log != nil {
+			if err := logger.Close(log); err != nil {
+				fmt.Fprintln(os.Stderr, "[WARN] close log hooks:", err)
+			}
+		}
+	}
+	defer cleanup()
 
 	// ---- CREATE
-	rootCmd.AddCommand(&cobra.Command{
+	var createTemplate string
+	createCmd := &cobra.Command{
 		Use:   "create [name]",
 		Short: "Generate new migration files",
 		Args:  cobra.ExactArgs(1),
@@ -112,7 +348,10 @@ func main() {
 				return err
 			}
 			defer db.Close()
-			file, err := migration.Generate(appcmd.MigrationsDir(), args[0], userFlag, db)
+			file, err := migration.Generate(appcmd.MigrationsDir(), args[0], userFlag, db,
+				migration.WithStrictOrder(appcmd.StrictOrder()), migration.WithTableNames(tableNames()),
+				migration.WithVersionDirs(migrationDirs()...), migration.WithTemplate(createTemplate),
+				migration.WithDialect(cfg.Database.Driver))
 			if err != nil {
 				log.WithError(err).Error("generate migration file")
 				return err
@@ -127,122 +366,765 @@ func main() {
 				return fmt.Errorf("migration version %d has been committed; cannot modify committed migrations", ver)
 			}
 			cmd.Println(file)
+			if cfg.Validation.DownLint {
+				up, _ := os.ReadFile(filepath.Join(appcmd.MigrationsDir(), file+".up.sql"))
+				down, _ := os.ReadFile(filepath.Join(appcmd.MigrationsDir(), file+".down.sql"))
+				for _, w := range migration.LintDown(up, down) {
+					cmd.PrintErrf("%s %s.down.sql: %s\n", errSym(cmd).Warn, file, w)
+				}
+			}
 			return nil
 		},
-	})
+	}
+	createCmd.Flags().StringVar(&createTemplate, "template", "", "pre-fill up and down with a reversible skeleton: "+strings.Join(migration.Templates(), ", "))
+	rootCmd.AddCommand(createCmd)
 
-	// ---- UP
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "up",
-		Short: "Apply all pending migrations",
+	// ---- GENERATE-FROM-DB
+	var introspectSchema string
+	genFromDBCmd := &cobra.Command{
+		Use:   "generate-from-db [name]",
+		Short: "Generate a baseline migration from the current database schema (Postgres, review required)",
+		Args:  cobra.MaximumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := mgr.Up()
+			if backend.DriverName() != "postgres" {
+				return fmt.Errorf("generate-from-db supports postgres only, not %s", backend.DriverName())
+			}
+			name := "baseline"
+			if len(args) == 1 {
+				name = args[0]
+			}
+			db, err := sql.Open(backend.DriverName(), cfg.Database.Dsn)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			file, err := migration.GenerateFromDB(appcmd.MigrationsDir(), name, userFlag, db, introspectSchema,
+				migration.WithTableNames(tableNames()), migration.WithVersionDirs(migrationDirs()...))
+			if err != nil {
+				log.WithError(err).Error("generate migration from database")
+				return err
+			}
+			cmd.Println(file)
+			cmd.PrintErrln(errSym(cmd).Warn + " Generated SQL is best-effort; review it before committing.")
+			return nil
+		},
+	}
+	genFromDBCmd.Flags().StringVar(&introspectSchema, "schema", "public", "database schema to introspect")
+	rootCmd.AddCommand(genFromDBCmd)
+
+	// ---- REBASELINE
+	var rebaselineSchema string
+	rebaselineCmd := &cobra.Command{
+		Use:   "rebaseline",
+		Short: "Replace all migrations with one 000001_baseline of the current schema and reset history (dev only)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.Env == "production" {
+				return fmt.Errorf("rebaseline is refused in production")
+			}
+			if backend.DriverName() != "postgres" {
+				return fmt.Errorf("rebaseline supports postgres only, not %s", backend.DriverName())
+			}
+			db, err := sql.Open(backend.DriverName(), cfg.Database.Dsn)
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+			s, err := migration.IntrospectPostgres(db, rebaselineSchema, tableNames())
+			if err != nil {
+				return err
+			}
+			if len(s.Tables) == 0 {
+				return fmt.Errorf("no tables found in schema %q", rebaselineSchema)
+			}
+			cur, _, _ := mgr.Version()
+			ok, err := appcmd.AskTypedConfirmation(fmt.Sprintf(
+				"This DELETES every migration file in %s, writes %s from schema %q,\n"+
+					"resets %s from version %d to 1 and wipes %s.",
+				appcmd.MigrationsDir(), mgmt.BaselineName, rebaselineSchema, mgr.MigrationsTable(), cur, mgr.HistoryTable()), "rebaseline")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("aborted by user")
+			}
+			header := fmt.Sprintf("-- Author: %s\n-- Migration: baseline\n-- Version: 000001\n"+
+				"-- Rebaselined from version %d of schema %q.\n"+
+				"-- REVIEW REQUIRED: views, CHECK constraints, triggers, functions and grants are not included.\n\n",
+				userFlag, cur, rebaselineSchema)
+			removed, err := mgr.Rebaseline(header+s.UpSQL(), header+s.DownSQL())
+			if err != nil {
+				log.WithError(err).Error("rebaseline failed")
+				return err
+			}
+			cmd.Printf("%s Rebaselined version %d to %s; removed %d file(s).\n", outSym(cmd).OK, cur, mgmt.BaselineName, len(removed))
+			cmd.PrintErrln(errSym(cmd).Warn + " Review the baseline and commit the directory change; other databases must be rebaselined too.")
+			return nil
+		},
+	}
+	rebaselineCmd.Flags().StringVar(&rebaselineSchema, "schema", "public", "database schema to introspect")
+	rootCmd.AddCommand(rebaselineCmd)
+
+	// ---- UP
+	var continueOnError, allTenants bool
+	var upFrom, upTo uint
+	upCmd := &cobra.Command{
+		Use:         "up",
+		Annotations: map[string]string{appcmd.DryRunnable: "true"},
+		Short:       "Apply all pending migrations",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if allTenants {
+				return loadApp()
+			}
+			return initApp()
+		},
+		RunE: withReport(func(cmd *cobra.Command, args []string) error {
+			if appcmd.DryRun() && (allTenants || continueOnError) {
+				return fmt.Errorf("--dry-run cannot be combined with --all-tenants or --continue-on-error")
+			}
+			if (upTo > 0 || upFrom > 0) && (allTenants || continueOnError) {
+				return fmt.Errorf("--to and --from cannot be combined with --all-tenants or --continue-on-error")
+			}
+			if upFrom > 0 && upTo == 0 {
+				return fmt.Errorf("--from needs --to")
+			}
+			if allTenants {
+				if continueOnError {
+					return fmt.Errorf("--continue-on-error cannot be combined with --all-tenants")
+				}
+				progress, err := mgmt.OpenProgress(progressFile, "up --all-tenants", resume)
+				if err != nil {
+					return err
+				}
+				return upAllTenants(cmd, cfg, progress, func(t mgmt.Tenant) (*mgmt.Manager, error) {
+					return openManager(t.DSN, log.WithFields(logrus.Fields{"component": "migrate", "tenant": t.Name}))
+				})
+			}
+			if resume {
+				return fmt.Errorf("--resume applies to --all-tenants; a plain up already continues from the current version")
+			}
+			if continueOnError {
+				if cfg.Env == "production" {
+					return fmt.Errorf("--continue-on-error is not allowed in production")
+				}
+				failures, err := mgr.UpContinueOnError()
+				if err != nil {
+					log.WithError(err).Error("migration up failed")
+					return err
+				}
+				if len(failures) == 0 {
+					say(cmd, messages.UpSuccess, messages.Result{}, nil)
+					return nil
+				}
+				cmd.Printf("%s %d migration(s) failed and were skipped:\n", outSym(cmd).Fail, len(failures))
+				for _, f := range failures {
+					cmd.Printf("  - version %d (%s): %v\n", f.Version, f.File, f.Err)
+				}
+				return fmt.Errorf("%d migration(s) failed", len(failures))
+			}
+			run := mgr.UpContext
+			if upTo > 0 {
+				if upFrom > 0 {
+					pending, err := mgr.Pending()
+					if err != nil {
+						return err
+					}
+					if len(pending) == 0 || pending[0].Version != upFrom {
+						return fmt.Errorf("--from %d is not the next pending version; up never skips a pending migration", upFrom)
+					}
+				}
+				run = func(ctx context.Context) error { return mgr.UpToContext(ctx, upTo) }
+			}
+			err := appcmd.RunWithDeadline(run, func() string {
+				if v, ok := mgr.LastApplied(); ok {
+					return strconv.FormatUint(uint64(v), 10)
+				}
+				return ""
+			})
+			var deadlineErr *appcmd.DeadlineError
 			switch {
+			case errors.As(err, &deadlineErr):
+				// The database may still be busy; report without querying it.
+				log.WithError(err).Error("migration up aborted at deadline")
+				return err
+			case err == nil && appcmd.DryRun():
+				printPlan(cmd, "apply", mgr.LastRun().Planned)
+				return nil
 			case err == nil:
-				cmd.Println("✅ Migrations applied successfully.")
+				say(cmd, messages.UpSuccess, messages.Result{}, nil)
 				return nil
 			case err == migrate.ErrNoChange:
-				cmd.Println("✅ No new migrations to apply.")
+				say(cmd, messages.UpNoChange, messages.Result{}, nil)
 				return nil
 			default:
 				log.WithError(err).Error("migration up failed")
+				say(cmd, messages.UpFailure, messages.Result{}, err)
 				return err
 			}
+		}),
+	}
+	upCmd.Flags().UintVar(&upTo, "to", 0, "apply pending migrations only up to and including this version")
+	upCmd.Flags().UintVar(&upFrom, "from", 0, "with --to, assert that this is the next pending version")
+	upCmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "skip failed migrations and continue (development only)")
+	upCmd.Flags().BoolVar(&allTenants, "all-tenants", false, "apply to every database in database.tenants, continuing past failed tenants")
+	upCmd.Flags().BoolVar(&resume, "resume", false, "with --all-tenants, skip tenants an interrupted run recorded as completed in --progress-file")
+	upCmd.Flags().StringVar(&progressFile, "progress-file", mgmt.DefaultProgressFile, "file recording the progress of the run")
+	rootCmd.AddCommand(upCmd)
+
+	// ---- APPLY --tag
+	var applyTag string
+	applyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply pending migrations with a tag, up to the first one without it (development only)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
 		},
-	})
+		RunE: withReport(func(cmd *cobra.Command, args []string) error {
+			applied, blockedBy, err := mgr.ApplyTag(applyTag)
+			if err != nil {
+				log.WithError(err).Error("migration apply failed")
+				return err
+			}
+			for _, f := range applied {
+				cmd.Printf("%s applied %s\n", outSym(cmd).OK, f)
+			}
+			if len(applied) == 0 {
+				cmd.Printf("%s No pending migrations tagged %s to apply.\n", outSym(cmd).OK, applyTag)
+			}
+			if blockedBy != "" {
+				cmd.Printf("%s stopped at %s: not tagged %s; apply it with up to keep versions sequential\n", outSym(cmd).Warn, blockedBy, applyTag)
+			}
+			return nil
+		}),
+	}
+	applyCmd.Flags().StringVar(&applyTag, "tag", "", "apply only migrations carrying this kaeshi:tags tag")
+	_ = applyCmd.MarkFlagRequired("tag")
+	rootCmd.AddCommand(applyCmd)
 
 	// ---- DOWN
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "down",
-		Short: "Rollback all migrations (danger: prod)",
+	downCmd := &cobra.Command{
+		Use:         "down",
+		Annotations: map[string]string{appcmd.DryRunnable: "true"},
+		Short:       "Rollback all migrations (danger: prod)",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: withReport(func(cmd *cobra.Command, args []string) error {
 			err := mgr.Down()
 			if err != nil {
 				log.WithError(err).Error("migration down failed")
+				say(cmd, messages.DownFailure, messages.Result{}, err)
+				return err
 			}
-			return err
-		},
-	})
+			if appcmd.DryRun() {
+				printPlan(cmd, "roll back", mgr.LastRun().Planned)
+				return nil
+			}
+			say(cmd, messages.DownSuccess, messages.Result{}, nil)
+			return nil
+		}),
+	}
+	rootCmd.AddCommand(downCmd)
 
 	// ---- ROLLBACK
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "rollback",
-		Short: "Rollback one migration step",
+	rollbackCmd := &cobra.Command{
+		Use:         "rollback",
+		Annotations: map[string]string{appcmd.DryRunnable: "true"},
+		Short:       "Rollback one migration step",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: withReport(func(cmd *cobra.Command, args []string) error {
 			err := mgr.Steps(-1)
 			if err != nil {
 				log.WithError(err).Error("rollback step failed")
+				say(cmd, messages.RollbackFailure, messages.Result{}, err)
+				return err
 			}
-			return err
+			if appcmd.DryRun() {
+				printPlan(cmd, "roll back", mgr.LastRun().Planned)
+				return nil
+			}
+			say(cmd, messages.RollbackSuccess, messages.Result{}, nil)
+			return nil
+		}),
+	}
+	rootCmd.AddCommand(rollbackCmd)
+
+	// ---- REDO
+	var redoCount int
+	redoCmd := &cobra.Command{
+		Use:   "redo",
+		Short: "Roll back and re-apply the latest migration(s) to re-test them",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			progress, err := mgmt.OpenProgress(progressFile, "redo", resume)
+			if err != nil {
+				return err
+			}
+			before, resumed := progress.Target()
+			if resumed {
+				cmd.Printf("Resuming redo towards version %d\n", before)
+			} else {
+				if redoCount < 1 {
+					return fmt.Errorf("--count must be at least 1")
+				}
+				versions, err := mgr.AppliedVersions(redoCount)
+				if err != nil {
+					return err
+				}
+				if len(versions) < redoCount {
+					return fmt.Errorf("cannot redo %d migration(s): only %d applied", redoCount, len(versions))
+				}
+				for _, v := range versions {
+					committed, err := mgr.VersionCommitted(v)
+					if err != nil {
+						return err
+					}
+					if committed {
+						return fmt.Errorf("migration version %d has been committed; cannot redo committed migrations", v)
+					}
+				}
+				before, _, _ = mgr.Version()
+				cmd.Printf("Version before redo: %d\n", before)
+				if err := progress.SetTarget(before); err != nil {
+					return err
+				}
+			}
+			if _, done := progress.Done("rollback"); !done {
+				if err := mgr.Steps(-redoCount); err != nil {
+					log.WithError(err).Error("redo rollback failed")
+					return fmt.Errorf("redo: rollback: %w", err)
+				}
+				mid, _, _ := mgr.Version()
+				cmd.Printf("Rolled back to version %d\n", mid)
+				if err := progress.Complete("rollback", mid); err != nil {
+					return err
+				}
+			}
+			mid, _, _ := mgr.Version()
+			err = mgr.Goto(before)
+			after, dirty, _ := mgr.Version()
+			if dirty {
+				log.WithError(err).Error("redo re-apply left database dirty")
+				return fmt.Errorf("redo: re-apply left the database dirty at version %d; fix the migration and clear it with safe-force: %v", after, err)
+			}
+			if err != nil {
+				log.WithError(err).Error("redo re-apply failed")
+				return fmt.Errorf("redo: re-apply from version %d: %w", mid, err)
+			}
+			if err := progress.Finish(); err != nil {
+				return err
+			}
+			cmd.Printf("%s Redo complete: version %d -> %d\n", outSym(cmd).OK, before, after)
+			return nil
 		},
+	}
+	redoCmd.Flags().IntVar(&redoCount, "count", 1, "number of latest migrations to redo")
+	redoCmd.Flags().BoolVar(&resume, "resume", false, "continue an interrupted redo recorded in --progress-file")
+	redoCmd.Flags().StringVar(&progressFile, "progress-file", mgmt.DefaultProgressFile, "file recording the progress of the run")
+	rootCmd.AddCommand(redoCmd)
+
+	// ---- GOTO
+	rootCmd.AddCommand(&cobra.Command{
+		Use:         "goto [version]",
+		Annotations: map[string]string{appcmd.DryRunnable: "true"},
+		Short:       "Migrate up or down to exactly the given version",
+		Args:        cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: withReport(func(cmd *cobra.Command, args []string) error {
+			target, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version: %w", err)
+			}
+			before, _, _ := mgr.Version()
+			if err := mgr.Goto(uint(target)); err != nil {
+				log.WithError(err).Error("goto failed")
+				return err
+			}
+			if appcmd.DryRun() {
+				verb := "apply"
+				if uint(target) < before {
+					verb = "roll back"
+				}
+				printPlan(cmd, verb, mgr.LastRun().Planned)
+				return nil
+			}
+			after, _, _ := mgr.Version()
+			cmd.Printf("%s Migrated from version %d to %d\n", outSym(cmd).OK, before, after)
+			return nil
+		}),
 	})
 
 	// ---- COMMIT
-	rootCmd.AddCommand(&cobra.Command{
-		Use:   "commit",
-		Short: "Mark all applied migrations as committed",
+	var commitThrough uint
+	commitCmd := &cobra.Command{
+		Use:         "commit [version]",
+		Annotations: map[string]string{appcmd.DryRunnable: "true"},
+		Short:       "Mark applied migrations as committed (all, one version, or --through a version)",
+		Args:        cobra.MaximumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := mgr.CommitAll(); err != nil {
-				log.WithError(err).Error("commit failed")
-				return err
+			through := cmd.Flags().Changed("through")
+			if len(args) == 1 && through {
+				return fmt.Errorf("use either a version argument or --through, not both")
+			}
+			if appcmd.DryRun() {
+				return printCommitPlan(cmd, mgr, args, through, commitThrough)
+			}
+			switch {
+			case len(args) == 1:
+				v, err := strconv.ParseUint(args[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("invalid version: %w", err)
+				}
+				r := messages.Result{Scope: "version", Version: strconv.FormatUint(v, 10)}
+				if err := mgr.Commit(uint(v)); err != nil {
+					log.WithError(err).Error("commit failed")
+					say(cmd, messages.CommitFailure, r, err)
+					return err
+				}
+				say(cmd, messages.CommitSuccess, r, nil)
+			case through:
+				r := messages.Result{Scope: "through", Version: strconv.FormatUint(uint64(commitThrough), 10)}
+				if err := mgr.CommitThrough(commitThrough); err != nil {
+					log.WithError(err).Error("commit failed")
+					say(cmd, messages.CommitFailure, r, err)
+					return err
+				}
+				say(cmd, messages.CommitSuccess, r, nil)
+			default:
+				r := messages.Result{Scope: "all"}
+				if err := mgr.CommitAll(); err != nil {
+					log.WithError(err).Error("commit failed")
+					say(cmd, messages.CommitFailure, r, err)
+					return err
+				}
+				say(cmd, messages.CommitSuccess, r, nil)
 			}
-			cmd.Println("✅ All applied migrations have been committed; strict hash checking is now enforced.")
 			return nil
 		},
-	})
+	}
+	commitCmd.Flags().UintVar(&commitThrough, "through", 0, "commit every applied version up to and including this one")
+	rootCmd.AddCommand(commitCmd)
 
 	// ---- STATUS
-	rootCmd.AddCommand(&cobra.Command{
+	var statusTag string
+	statusCmd := &cobra.Command{
 		Use:   "status",
 		Short: "Show migration status",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if statusTag != "" {
+				return printTaggedStatus(cmd, mgr, statusTag)
+			}
 			v, pending, err := mgr.Status()
 			if err != nil {
 				log.WithError(err).Error("get status failed")
 				return err
 			}
-			cmd.Printf("Current version: %d\nPending migrations: %d\n", v, pending)
+			if appcmd.OutputFormat() == "table" {
+				tbl := output.NewTable(cmd.OutOrStdout(), !appcmd.Styled(cmd.OutOrStdout()))
+				tbl.Header("CURRENT VERSION", "PENDING")
+				tbl.Row(v, pending)
+				if err := tbl.Flush(); err != nil {
+					return err
+				}
+			} else {
+				cmd.Printf("Current version: %d\nPending migrations: %d\n", v, pending)
+			}
+			unpaired, err := mgr.CheckPairs()
+			if err != nil {
+				return err
+			}
+			for _, u := range unpaired {
+				cmd.PrintErrf("%s %s\n", errSym(cmd).Warn, u)
+			}
+			st, err := mgr.Dirty()
+			if err != nil {
+				return err
+			}
+			if st != nil {
+				cmd.Printf("\n%s Database is DIRTY at version %d", outSym(cmd).Warn, st.Version)
+				if st.File != "" {
+					cmd.Printf(" (%s was mid-flight)", st.File)
+				}
+				cmd.Println()
+				if st.LastAction != "" {
+					cmd.Printf("Last recorded action: %s version %s by %s at %s\n", st.LastAction, st.LastVersion, st.LastActor, st.LastAt)
+				}
+				cmd.Printf("Recovery: %s\n", st.Recovery)
+			}
 			return nil
 		},
+	}
+	statusCmd.Flags().StringVar(&statusTag, "tag", "", "only list pending migrations carrying this kaeshi:tags tag")
+	rootCmd.AddCommand(statusCmd)
+
+	// ---- PLAN
+	var planJSON bool
+	planCmd := &cobra.Command{
+		Use:         "plan",
+		Annotations: map[string]string{appcmd.SelfJSON: "plan"},
+		Short:       "List pending up migrations in apply order with their hashes (read-only)",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return openApp(mgmt.WithReadOnly())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			plan, err := mgr.Plan()
+			if err != nil {
+				log.WithError(err).Error("plan failed")
+				return err
+			}
+			if planJSON || appcmd.JSONOutput() {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				return enc.Encode(plan)
+			}
+			if len(plan) == 0 {
+				cmd.Println(outSym(cmd).OK + " No pending migrations.")
+				return nil
+			}
+			tbl := output.NewTable(cmd.OutOrStdout(), !appcmd.Styled(cmd.OutOrStdout()))
+			tbl.Header("VERSION", "FILE", "SHA256", "IN HISTORY", "COMMITTED")
+			for _, p := range plan {
+				tbl.Row(p.Version, p.File, shortHash(p.Hash), p.InHistory, p.Committed)
+			}
+			return tbl.Flush()
+		},
+	}
+	planCmd.Flags().BoolVar(&planJSON, "json", false, "print the plan as JSON")
+	rootCmd.AddCommand(planCmd)
+
+	// ---- HISTORY
+	var (
+		historyFilter mgmt.HistoryFilter
+		historyJSON   bool
+	)
+	historyCmd := &cobra.Command{
+		Use:         "history",
+		Annotations: map[string]string{appcmd.SelfJSON: "history"},
+		Short:       "List migrations_history entries, newest first",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			records, err := mgr.History(historyFilter)
+			if err != nil {
+				log.WithError(err).Error("read history failed")
+				return err
+			}
+			if historyJSON || appcmd.JSONOutput() {
+				enc := json.NewEncoder(cmd.OutOrStdout())
+				enc.SetIndent("", "  ")
+				if records == nil {
+					records = []mgmt.HistoryRecord{}
+				}
+				return enc.Encode(records)
+			}
+			if len(records) == 0 {
+				cmd.Println("No history entries.")
+				return nil
+			}
+			tbl := output.NewTable(cmd.OutOrStdout(), !appcmd.Styled(cmd.OutOrStdout()))
+			tbl.Header("ID", "ACTION", "VERSION", "BY", "SHA256", "COMMITTED", "AT", "DURATION")
+			for _, r := range records {
+				duration := ""
+				if r.DurationMs != nil {
+					duration = (time.Duration(*r.DurationMs) * time.Millisecond).String()
+				}
+				tbl.Row(r.ID, r.Action, r.Version, r.ExecutedBy, shortHash(r.SHA256), r.Committed, r.CreatedAt, duration)
+			}
+			return tbl.Flush()
+		},
+	}
+	historyCmd.Flags().IntVar(&historyFilter.Limit, "limit", 20, "maximum number of entries (0 = all)")
+	historyCmd.Flags().StringVar(&historyFilter.Action, "action", "", "only entries of this action (up, down, rollback, safe-force, ...)")
+	historyCmd.Flags().StringVar(&historyFilter.Version, "version", "", "only entries for this version")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "print entries as JSON")
+	rootCmd.AddCommand(historyCmd)
+
+	// ---- VERIFY
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "verify",
+		Short: "Check committed migration files on disk against the hashes in history",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mismatches, err := mgr.Verify()
+			if err != nil {
+				log.WithError(err).Error("verify failed")
+				return err
+			}
+			if len(mismatches) == 0 {
+				cmd.Println(outSym(cmd).OK + " All committed migrations match their recorded hashes.")
+				return nil
+			}
+			for _, m := range mismatches {
+				cmd.PrintErrf("%s %s\n", errSym(cmd).Fail, m)
+			}
+			return fmt.Errorf("%d committed migration(s) changed or missing on disk", len(mismatches))
+		},
 	})
 
-	// ---- VERSION
+	// ---- VALIDATE
+	var sinceVersion uint
+	var allDialects, cumulative bool
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate pending migrations without applying them",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if allDialects {
+				return nil
+			}
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if allDialects {
+				return checkAllDialects(cmd)
+			}
+			var warnings []string
+			var err error
+			if cumulative {
+				if cmd.Flags().Changed("since-version") {
+					return fmt.Errorf("--cumulative validates every pending migration; it cannot be combined with --since-version")
+				}
+				warnings, err = mgr.ValidateCumulative()
+			} else {
+				warnings, err = mgr.ValidateSince(sinceVersion)
+			}
+			for _, w := range warnings {
+				cmd.PrintErrf("%s %s\n", errSym(cmd).Warn, w)
+			}
+			if err != nil {
+				log.WithError(err).Error("validation failed")
+				return err
+			}
+			cmd.Println(outSym(cmd).OK + " Pending migrations are valid.")
+			return nil
+		},
+	}
+	validateCmd.Flags().UintVar(&sinceVersion, "since-version", 0, "only validate migrations with a version above this one")
+	validateCmd.Flags().BoolVar(&cumulative, "cumulative", false, "apply pending migrations in order in one rolled-back transaction, validating each against the schema of its predecessors")
+	validateCmd.Flags().BoolVar(&allDialects, "all-dialects", false, "parse every migration under each supported dialect, without a database")
+	rootCmd.AddCommand(validateCmd)
+
+	// ---- LINT
+	var lintDialect string
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Statically check every migration file without a database",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dialect := lintDialect
+			if dialect == "" {
+				c, _, err := appcmd.LoadConfig()
+				if err != nil {
+					return fmt.Errorf("lint: pass --dialect or a loadable config: %w", err)
+				}
+				dialect = c.Database.Driver
+			}
+			return lintMigrations(cmd, dialect)
+		},
+	}
+	lintCmd.Flags().StringVar(&lintDialect, "dialect", "", "dialect to lint under: postgres, mysql or sqlite (default: database.driver from config)")
+	rootCmd.AddCommand(lintCmd)
+
+	// ---- CHECK
+	var checkJSON bool
+	checkCmd := &cobra.Command{
+		Use:         "check",
+		Annotations: map[string]string{appcmd.SelfJSON: "check"},
+		Short:       "Preflight for deploy jobs: config, driver, connection, migration files, history table, dirty state and hashes, read-only",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var items []mgmt.CheckItem
+			if err := loadApp(); err != nil {
+				items = []mgmt.CheckItem{{Name: "config", Detail: err.Error()}}
+			} else if items = append([]mgmt.CheckItem{{Name: "config", OK: true, Detail: cfgPath}}, mgmt.Preflight(backend, cfg.Database.Dsn)...); items[len(items)-1].OK {
+				var err error
+				if mgr, err = openManager(cfg.Database.Dsn, log.WithField("component", "migrate"), mgmt.WithReadOnly()); err != nil {
+					items = append(items, mgmt.CheckItem{Name: "migrate driver", Detail: err.Error()})
+				} else {
+					items = append(items, mgmt.CheckItem{Name: "migrate driver", OK: true})
+					items = append(items, mgr.Check()...)
+				}
+			}
+			failures := 0
+			for _, it := range items {
+				if !it.OK {
+					failures++
+				}
+			}
+			if checkJSON || appcmd.JSONOutput() {
+				if err := appcmd.WriteJSON(cmd.OutOrStdout(), map[string]any{"ok": failures == 0, "items": items}); err != nil {
+					return err
+				}
+			} else {
+				for _, it := range items {
+					sym := outSym(cmd).OK
+					if !it.OK {
+						sym = outSym(cmd).Fail
+					}
+					cmd.Printf("%s %-15s %s\n", sym, it.Name, it.Detail)
+				}
+			}
+			if failures > 0 {
+				return fmt.Errorf("%d preflight check(s) failed", failures)
+			}
+			return nil
+		},
+	}
+	checkCmd.Flags().BoolVar(&checkJSON, "json", false, "print the report as JSON")
+	rootCmd.AddCommand(checkCmd)
+
+	// ---- DRIFT
 	rootCmd.AddCommand(&cobra.Command{
-		Use:   "version",
-		Short: "Print current migration version",
+		Use:   "drift",
+		Short: "Compare the live schema with the snapshot taken after the last up",
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			return initApp()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			v, dirty, err := mgr.Version()
+			report, err := mgr.Drift()
 			if err != nil {
-				log.WithError(err).Error("get version failed")
+				log.WithError(err).Error("drift check failed")
 				return err
 			}
-			cmd.Printf("Current version: %d", v)
-			if dirty {
-				cmd.Printf(" (DIRTY)")
+			if report.Drifted() {
+				return fmt.Errorf("schema drifted since the snapshot at version %d (recorded %s, live %s)", report.Version, report.Recorded, report.Live)
 			}
-			cmd.Println()
+			cmd.Printf("%s Schema matches the snapshot at version %d.\n", outSym(cmd).OK, report.Version)
 			return nil
 		},
 	})
 
+	// ---- SHOW
+	rootCmd.AddCommand(appcmd.NewShowCmd(initApp, func(v uint) (mgmt.MigrationDetail, error) {
+		return mgr.Show(v)
+	}))
+
+	// ---- VERSION
+	rootCmd.AddCommand(appcmd.NewVersionCmd(initApp, func() (uint, bool, error) {
+		v, dirty, err := mgr.Version()
+		if err != nil {
+			log.WithError(err).Error("get version failed")
+		}
+		return v, dirty, err
+	}))
+
 	// ---- SAFE-FORCE
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "safe-force [version]",
@@ -262,18 +1144,259 @@ func main() {
 				log.WithError(err).Error("safe-force failed")
 				return err
 			}
-			cmd.Printf("✅ Safe-forced database version to %d (dirty cleared)\n", v)
+			cmd.Printf("%s Safe-forced database version to %d (dirty cleared)\n", outSym(cmd).OK, v)
 			return nil
 		},
 	})
 
+	// ---- EXEC
+	var execSQL, execReason string
+	execCmd := &cobra.Command{
+		Use:   "exec",
+		Short: "Run one-off repair SQL and record it in history without changing the version",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return initApp()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if cfg.Env == "production" {
+				ok, err := appcmd.AskConfirmation("Run manual SQL against PRODUCTION?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return fmt.Errorf("aborted by user")
+				}
+			}
+			if err := mgr.Exec(execSQL, execReason); err != nil {
+				log.WithError(err).Error("manual exec failed")
+				return err
+			}
+			cmd.Println(outSym(cmd).OK + " Manual SQL executed and recorded in history.")
+			return nil
+		},
+	}
+	execCmd.Flags().StringVar(&execSQL, "sql", "", "SQL to execute")
+	execCmd.Flags().StringVar(&execReason, "reason", "", "why the manual change is needed (recorded in history)")
+	_ = execCmd.MarkFlagRequired("sql")
+	_ = execCmd.MarkFlagRequired("reason")
+	rootCmd.AddCommand(execCmd)
+
 	// ---- EXECUTE CLI
-	if err := rootCmd.Execute(); err != nil {
-		if strings.Contains(err.Error(), "unknown command") || strings.Contains(err.Error(), "unknown flag") {
+	appcmd.InstrumentCommands(rootCmd, metrics.CommandDuration)
+	appcmd.WithJSONResults(rootCmd, func() (*uint, *int) {
+		if mgr == nil || appcmd.DeadlineExpired() {
+			return nil, nil
+		}
+		v, pending, err := mgr.Status()
+		if err != nil {
+			return nil, nil
+		}
+		return &v, &pending
+	})
+	ran, err := rootCmd.ExecuteC()
+	if werr := appcmd.WriteMetricsFile(metrics.Default); werr != nil {
+		fmt.Fprintln(os.Stderr, "[WARN] write metrics file:", werr)
+	}
+	if err != nil {
+		cleanup()
+		var reported *appcmd.ReportedError
+		usage := strings.Contains(err.Error(), "unknown command") || strings.Contains(err.Error(), "unknown flag")
+		switch {
+		case errors.As(err, &reported):
+		case appcmd.JSONOutput():
+			_ = appcmd.WriteJSON(os.Stderr, appcmd.CommandResult{Command: ran.Name(), Error: err.Error()})
+		case usage:
 			fmt.Fprintln(os.Stderr, "[CLI] "+err.Error())
+		default:
+			fmt.Fprintln(os.Stderr, "[FATAL]", err.Error())
+		}
+		if usage {
 			os.Exit(3)
 		}
-		fmt.Fprintln(os.Stderr, "[FATAL]", err.Error())
 		os.Exit(2)
 	}
 }
+
+// printTaggedStatus lists the pending migrations carrying tag.
+func printTaggedStatus(cmd *cobra.Command, mgr *mgmt.Manager, tag string) error {
+	v, _, err := mgr.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return err
+	}
+	pending, err := mgr.PendingTagged(tag)
+	if err != nil {
+		return err
+	}
+	if appcmd.OutputFormat() == "table" {
+		tbl := output.NewTable(cmd.OutOrStdout(), !appcmd.Styled(cmd.OutOrStdout()))
+		tbl.Header("VERSION", "FILE", "TAGS")
+		for _, p := range pending {
+			tbl.Row(p.Version, p.File, strings.Join(p.Tags, ","))
+		}
+		return tbl.Flush()
+	}
+	cmd.Printf("Current version: %d\nPending migrations tagged %s: %d\n", v, tag, len(pending))
+	for _, p := range pending {
+		cmd.Printf("  - %s [%s]\n", p.File, strings.Join(p.Tags, ", "))
+	}
+	return nil
+}
+
+// upAllTenants applies pending migrations to every configured tenant and
+// prints a per-tenant summary. It fails when any tenant failed.
+func upAllTenants(cmd *cobra.Command, cfg *config.Config, progress *mgmt.Progress, open func(mgmt.Tenant) (*mgmt.Manager, error)) error {
+	if len(cfg.Database.Tenants) == 0 {
+		return fmt.Errorf("--all-tenants requires database.tenants in config")
+	}
+	tenants := make([]mgmt.Tenant, len(cfg.Database.Tenants))
+	for i, t := range cfg.Database.Tenants {
+		tenants[i] = mgmt.Tenant{Name: t.Name, DSN: t.Dsn}
+	}
+	results := mgmt.UpAllTenants(tenants, cfg.Database.TenantConcurrency, open, progress)
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			cmd.Printf("%s %s: %v\n", outSym(cmd).Fail, r.Tenant, r.Err)
+		case r.Resumed:
+			cmd.Printf("%s %s: completed by the interrupted run at version %d\n", outSym(cmd).OK, r.Tenant, r.After)
+		default:
+			cmd.Printf("%s %s: version %d -> %d\n", outSym(cmd).OK, r.Tenant, r.Before, r.After)
+		}
+	}
+	cmd.Printf("%d of %d tenant(s) migrated.\n", len(results)-failed, len(results))
+	if failed > 0 {
+		cmd.Println("Re-run with --resume to retry only the failed tenants.")
+		return fmt.Errorf("%d tenant(s) failed", failed)
+	}
+	return progress.Finish()
+}
+
+// printCommitPlan lists the versions commit would freeze for the given
+// arguments without committing anything.
+func printCommitPlan(cmd *cobra.Command, mgr *mgmt.Manager, args []string, through bool, throughV uint) error {
+	versions, err := mgr.UncommittedVersions()
+	if err != nil {
+		return err
+	}
+	limit, single := uint(0), false
+	switch {
+	case len(args) == 1:
+		v, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version: %w", err)
+		}
+		limit, single = uint(v), true
+	case through:
+		limit = throughV
+	}
+	var plan []string
+	for _, v := range versions {
+		if (single && v != limit) || (through && v > limit) {
+			continue
+		}
+		plan = append(plan, strconv.FormatUint(uint64(v), 10))
+	}
+	cmd.Printf("Dry run: %d version(s) would be committed\n", len(plan))
+	for _, v := range plan {
+		cmd.Printf("  - %s\n", v)
+	}
+	return nil
+}
+
+// checkAllDialects reports migration files that do not parse under one of the
+// registered dialects. It needs no configuration or database.
+func checkAllDialects(cmd *cobra.Command) error {
+	fsys, err := offlineFS()
+	if err != nil {
+		return err
+	}
+	problems, err := mgmt.CheckDialects(fsys)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		cmd.PrintErrf("%s %s [%s]: %v\n", errSym(cmd).Fail, p.File, p.Dialect, p.Err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d dialect parse problem(s)", len(problems))
+	}
+	var names []string
+	for _, d := range validate.Dialects() {
+		names = append(names, d.DriverName())
+	}
+	cmd.Printf("%s Migrations parse under %s.\n", outSym(cmd).OK, strings.Join(names, ", "))
+	return nil
+}
+
+// offlineFS opens the migrations named by --archive or --migrations for
+// commands that run without configuration or a database.
+func offlineFS() (fs.FS, error) {
+	if archive := appcmd.ArchivePath(); archive != "" {
+		return mgmt.OpenArchive(archive)
+	}
+	if dirs := appcmd.MigrationsDirs(); len(dirs) > 1 {
+		return mgmt.MergeDirs(dirs...)
+	}
+	return os.DirFS(appcmd.MigrationsDir()), nil
+}
+
+// lintMigrations prints the lint findings of the migrations under dialect,
+// failing when any of them is an error.
+func lintMigrations(cmd *cobra.Command, dialect string) error {
+	backend, ok := mgmt.GetBackend(dialect)
+	if !ok {
+		return fmt.Errorf("unknown dialect %q", dialect)
+	}
+	fsys, err := offlineFS()
+	if err != nil {
+		return err
+	}
+	findings, err := mgmt.Lint(fsys, backend.Validator())
+	if err != nil {
+		return err
+	}
+	errs := 0
+	for _, f := range findings {
+		sym := outSym(cmd).Warn
+		if f.Severity == validate.LintError {
+			sym = outSym(cmd).Fail
+			errs++
+		}
+		cmd.Printf("%s %s\n", sym, f)
+	}
+	if errs > 0 {
+		return fmt.Errorf("lint found %d error(s)", errs)
+	}
+	if len(findings) == 0 {
+		cmd.Printf("%s No lint findings under %s.\n", outSym(cmd).OK, dialect)
+	}
+	return nil
+}
+
+// printPlan lists the files a dry run would have executed.
+func printPlan(cmd *cobra.Command, verb string, files []string) {
+	if len(files) == 0 {
+		cmd.Printf("%s Dry run: nothing to %s.\n", outSym(cmd).OK, verb)
+		return
+	}
+	cmd.Printf("%s Dry run: checks passed; would %s %d file(s) in this order:\n", outSym(cmd).OK, verb, len(files))
+	for _, f := range files {
+		cmd.Printf("  %s\n", f)
+	}
+}
+
+// shortHash abbreviates a SHA256 for table output.
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// outSym and errSym return the status markers for a command's stdout and
+// stderr, honouring --color.
+func outSym(cmd *cobra.Command) output.Symbols { return appcmd.Symbols(cmd.OutOrStdout()) }
+
+func errSym(cmd *cobra.Command) output.Symbols { return appcmd.Symbols(cmd.ErrOrStderr()) }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	mgmt "github.com/lenhattri/kaeshi-migrate/internal/migrate/manager"
+)
+
+// Report is the JSON document written to --report after up, apply, down,
+// rollback and goto.
+type Report struct {
+	Timestamp   time.Time         `json:"timestamp"`
+	Actor       string            `json:"actor"`
+	Env         string            `json:"env"`
+	Command     string            `json:"command"`
+	FromVersion uint              `json:"from_version"`
+	ToVersion   uint              `json:"to_version"`
+	Applied     []ReportMigration `json:"applied"`
+	RolledBack  []uint            `json:"rolled_back"`
+	DurationMS  int64             `json:"duration_ms"`
+	Warnings    []string          `json:"warnings"`
+	// Outcome is success, failure or timeout (--deadline).
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReportMigration is one applied file in a Report.
+type ReportMigration struct {
+	Version    uint   `json:"version"`
+	File       string `json:"file"`
+	DurationMS int64  `json:"duration_ms"`
+	Skipped    string `json:"skipped,omitempty"`
+}
+
+// ReportPath returns the --report path, or "" when unset.
+func ReportPath() string { return reportFlag }
+
+// NewReport builds the report of command from the Manager's run summary and
+// the command's error.
+func NewReport(command, actor, env string, run mgmt.RunResult, err error) Report {
+	r := Report{
+		Timestamp:   time.Now().UTC(),
+		Actor:       actor,
+		Env:         env,
+		Command:     command,
+		FromVersion: run.From,
+		ToVersion:   run.To,
+		Applied:     []ReportMigration{},
+		RolledBack:  run.RolledBack,
+		DurationMS:  run.Duration.Milliseconds(),
+		Warnings:    run.Warnings,
+		Outcome:     "success",
+	}
+	for _, a := range run.Applied {
+		r.Applied = append(r.Applied, ReportMigration{Version: a.Version, File: a.File, DurationMS: a.Duration.Milliseconds(), Skipped: a.Skipped})
+	}
+	if r.RolledBack == nil {
+		r.RolledBack = []uint{}
+	}
+	if r.Warnings == nil {
+		r.Warnings = []string{}
+	}
+	var deadline *DeadlineError
+	switch {
+	case errors.As(err, &deadline):
+		r.Outcome, r.Error = "timeout", err.Error()
+	case err != nil:
+		r.Outcome, r.Error = "failure", err.Error()
+	}
+	return r
+}
+
+// WriteReport writes r as indented JSON to the --report path, if one was
+// given.
+func WriteReport(r Report) error {
+	if reportFlag == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportFlag, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,72 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	mgmt "github.com/lenhattri/kaeshi-migrate/internal/migrate/manager"
+)
+
+func TestReportWrittenForSuccessfulUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	run := mgmt.RunResult{
+		Operation: "up",
+		From:      1,
+		To:        3,
+		Applied: []mgmt.AppliedMigration{
+			{Version: 2, File: "0002_b.up.sql", Duration: 1500 * time.Millisecond},
+			{Version: 3, File: "0003_c.up.sql", Duration: 20 * time.Millisecond, Skipped: "skipped: empty migration"},
+		},
+		Warnings: []string{"0003_c.up.sql: empty migration"},
+		Duration: 2 * time.Second,
+	}
+	root := appcmd.NewRootCmd()
+	root.AddCommand(&cobra.Command{
+		Use: "up",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return appcmd.WriteReport(appcmd.NewReport(cmd.Name(), "alice", "staging", run, nil))
+		},
+	})
+	root.SetArgs([]string{"up", "--report", path})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got appcmd.Report
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parse report: %v\n%s", err, data)
+	}
+	if got.Timestamp.IsZero() {
+		t.Fatal("report has no timestamp")
+	}
+	got.Timestamp = time.Time{}
+	want := appcmd.Report{
+		Actor:       "alice",
+		Env:         "staging",
+		Command:     "up",
+		FromVersion: 1,
+		ToVersion:   3,
+		Applied: []appcmd.ReportMigration{
+			{Version: 2, File: "0002_b.up.sql", DurationMS: 1500},
+			{Version: 3, File: "0003_c.up.sql", DurationMS: 20, Skipped: "skipped: empty migration"},
+		},
+		RolledBack: []uint{},
+		DurationMS: 2000,
+		Warnings:   []string{"0003_c.up.sql: empty migration"},
+		Outcome:    "success",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("report = %+v\nwant     %+v", got, want)
+	}
+}
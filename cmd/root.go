@@ -2,17 +2,44 @@ package cmd
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/config"
+	"github.com/lenhattri/kaeshi-migrate/internal/output"
 )
 
 var (
-	yesFlag        bool
-	configPathFlag string
-	migrationsFlag string
-	noNotifyFlag   bool
-	rootCmd        *cobra.Command
+	yesFlag         bool
+	configPathFlag  string
+	migrationsFlag  string
+	noNotifyFlag    bool
+	outputFlag      string
+	noColorFlag     bool
+	colorFlag       output.ColorMode
+	archiveFlag     string
+	maxRetriesFlag  int
+	strictOrderFlag bool
+	allowDeniedFlag bool
+	noValidateFlag  bool
+	dryRunFlag      bool
+	tablePrefixFlag string
+	envFlag         string
+	metricsFileFlag string
+	reportFlag      string
+	waitForDBFlag   time.Duration
+	validateTimeout time.Duration
+	setFlags        []string
+	lockIDFlag      int64
+	deadlineFlag    time.Duration
+	deadlineAt      time.Time
+	rootCmd         *cobra.Command
 )
 
 // NewRootCmd builds the top-level command with global flags.
@@ -22,34 +49,215 @@ func NewRootCmd() *cobra.Command {
 		Short:         "Database migration manager",
 		SilenceUsage:  true,
 		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if dryRunFlag && cmd.Annotations[DryRunnable] == "" {
+				return fmt.Errorf("%s does not support --dry-run", cmd.CommandPath())
+			}
+			if deadlineFlag > 0 {
+				deadlineAt = time.Now().Add(deadlineFlag)
+			}
+			return nil
+		},
 	}
+	deadlineAt = time.Time{}
 	rootCmd.PersistentFlags().BoolVarP(&yesFlag, "yes", "y", false, "automatic yes to prompts")
-	rootCmd.PersistentFlags().StringVar(&configPathFlag, "config", "configs/config.yml", "config file path")
-	rootCmd.PersistentFlags().StringVar(&migrationsFlag, "migrations", "migrations", "migrations directory")
+	rootCmd.PersistentFlags().StringVar(&configPathFlag, "config", "configs/config.yml", "config file path; YAML, TOML or JSON by extension (without it, the first of configs/config.yml, .yaml, .toml, .json)")
+	rootCmd.PersistentFlags().StringVar(&migrationsFlag, "migrations", "migrations", "migrations directory, or a comma-separated list merged by version")
 	rootCmd.PersistentFlags().BoolVar(&noNotifyFlag, "no-notify", false, "disable notifications")
+	rootCmd.PersistentFlags().StringVar(&outputFlag, "output", "text", "output format: text|table|json")
+	rootCmd.PersistentFlags().BoolVar(&noColorFlag, "no-color", false, "disable colored output")
+	rootCmd.PersistentFlags().BoolVar(&noColorFlag, "plain", false, "alias for --no-color")
+	colorFlag = output.ColorAuto
+	rootCmd.PersistentFlags().Var(&colorFlag, "color", "styling and emoji markers: always|auto|never (auto styles terminals only)")
+	rootCmd.PersistentFlags().IntVar(&maxRetriesFlag, "max-retries", -1, "retries after a failed migration operation (0 = fail fast; default from config)")
+	rootCmd.PersistentFlags().StringVar(&archiveFlag, "archive", "", "read migrations from a .zip or .tar.gz artifact")
+	rootCmd.PersistentFlags().BoolVar(&allowDeniedFlag, "allow-denied", false, "run statements refused by validation.deny_statements for this env")
+	rootCmd.PersistentFlags().BoolVar(&noValidateFlag, "no-validate", false, "apply migrations without dry-running their SQL first (logged at WARN; hash checks and the deny policy still apply)")
+	rootCmd.PersistentFlags().BoolVar(&dryRunFlag, "dry-run", false, "run all checks and list what would change, without changing anything (up, down, rollback, goto, commit)")
+	rootCmd.PersistentFlags().BoolVar(&strictOrderFlag, "strict-order", false, "reject new migrations at or below the highest applied version (create, validate)")
+	rootCmd.PersistentFlags().StringVar(&tablePrefixFlag, "table-prefix", "", "prefix for the schema_migrations and migrations_history tables (default from config)")
+	rootCmd.PersistentFlags().StringVar(&metricsFileFlag, "metrics-file", "", "write Prometheus text metrics to this file when the command finishes")
+	rootCmd.PersistentFlags().StringVar(&reportFlag, "report", "", "write a JSON summary of up, apply, down, rollback or goto to this file")
+	rootCmd.PersistentFlags().DurationVar(&waitForDBFlag, "wait-for-db", 0, "wait up to this long for the database to accept connections before starting (default from config)")
+	rootCmd.PersistentFlags().Int64Var(&lockIDFlag, "lock-id", 0, "PostgreSQL advisory lock key for the migration lock (default from config, derived from the database)")
+	rootCmd.PersistentFlags().DurationVar(&validateTimeout, "validate-timeout", 0, "bound each statement's dry run during validation (default from config, 4s)")
+	rootCmd.PersistentFlags().DurationVar(&deadlineFlag, "deadline", 0, "abort the whole command, including waits and retries, after this long (up)")
+	rootCmd.PersistentFlags().StringArrayVar(&setFlags, "set", nil, "override a config value, e.g. --set database.driver=mysql (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&envFlag, "env", "", "environment to run in; also selects the DSN from database.dsns (default from config)")
 	return rootCmd
 }
 
+// ErrNotInteractive is returned by AskConfirmation when a prompt is needed but
+// stdin is not a terminal, so nobody can answer it.
+var ErrNotInteractive = errors.New("confirmation required but stdin is not interactive; pass --yes or run interactively")
+
 // askConfirmation prints msg and waits for user to type y/yes.
 func AskConfirmation(msg string) (bool, error) {
 	if yesFlag {
 		return true, nil
 	}
-	rootCmd.Print(msg + " [y/N]: ")
-	reader := bufio.NewReader(rootCmd.InOrStdin())
-	line, err := reader.ReadString('\n')
+	ans, err := readAnswer(msg + " [y/N]: ")
 	if err != nil {
 		return false, err
 	}
-	ans := strings.ToLower(strings.TrimSpace(line))
+	ans = strings.ToLower(ans)
 	return ans == "y" || ans == "yes", nil
 }
 
+// AskTypedConfirmation prints msg and proceeds only when the user types word
+// exactly, for operations too destructive for a y/N prompt. --yes still
+// skips the prompt.
+func AskTypedConfirmation(msg, word string) (bool, error) {
+	if yesFlag {
+		return true, nil
+	}
+	ans, err := readAnswer(fmt.Sprintf("%s\nType %q to continue: ", msg, word))
+	if err != nil {
+		return false, err
+	}
+	return ans == word, nil
+}
+
+// readAnswer prints prompt and returns the trimmed line read from stdin.
+func readAnswer(prompt string) (string, error) {
+	in := rootCmd.InOrStdin()
+	if f, ok := in.(*os.File); ok && !terminal(f) {
+		return "", ErrNotInteractive
+	}
+	rootCmd.Print(prompt)
+	reader := bufio.NewReader(in)
+	line, err := reader.ReadString('\n')
+	if errors.Is(err, io.EOF) && strings.TrimSpace(line) == "" {
+		return "", ErrNotInteractive
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// terminal reports whether f is a terminal. Answers piped or redirected
+// into stdin, as in CI, are refused: an unattended run must pass --yes.
+func terminal(f *os.File) bool {
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// AllowDenied reports whether --allow-denied was given.
+func AllowDenied() bool { return allowDeniedFlag }
+
+// NoValidate reports whether --no-validate was given.
+func NoValidate() bool { return noValidateFlag }
+
+// DryRunnable is the annotation key of commands that honor --dry-run; the
+// flag is rejected on every other command rather than silently ignored.
+const DryRunnable = "kaeshi.dry-run"
+
+// DryRun reports whether --dry-run was given.
+func DryRun() bool { return dryRunFlag }
+
+// StrictOrder reports whether --strict-order was given.
+func StrictOrder() bool { return strictOrderFlag }
+
+// TablePrefix returns the table prefix from the global flag.
+func TablePrefix() string { return tablePrefixFlag }
+
+// Env returns the environment override from the global flag.
+func Env() string { return envFlag }
+
+// Overrides returns the --set values keyed by config path.
+func Overrides() (map[string]string, error) {
+	if len(setFlags) == 0 {
+		return nil, nil
+	}
+	set := make(map[string]string, len(setFlags))
+	for _, kv := range setFlags {
+		key, value, ok := strings.Cut(kv, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("--set %q: want key=value", kv)
+		}
+		set[key] = value
+	}
+	return set, nil
+}
+
+// LoadConfig loads the --config file for --env with the --set overrides.
+// Without --config, the first of configs/config.yml, .yaml, .toml and .json
+// is read. The path of the file actually read is returned with the config.
+func LoadConfig() (*config.Config, string, error) {
+	set, err := Overrides()
+	if err != nil {
+		return nil, "", err
+	}
+	path := ConfigPath()
+	if !rootCmd.PersistentFlags().Changed("config") {
+		path = ""
+	}
+	if path, err = config.Resolve(path); err != nil {
+		return nil, "", fmt.Errorf("read config: %w", err)
+	}
+	cfg, err := config.LoadWithOverrides(path, Env(), set)
+	return cfg, path, err
+}
+
 // ConfigPath returns the config file path from the global flag.
 func ConfigPath() string { return configPathFlag }
 
-// MigrationsDir returns the migrations directory from the global flag.
-func MigrationsDir() string { return migrationsFlag }
+// MigrationsDir returns the migrations directory from the global flag. When
+// the flag lists several directories it is the first one, where new
+// migrations are written.
+func MigrationsDir() string { return MigrationsDirs()[0] }
+
+// MigrationsDirs returns every directory listed, comma-separated, by the
+// global migrations flag.
+func MigrationsDirs() []string {
+	var dirs []string
+	for _, d := range strings.Split(migrationsFlag, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			dirs = append(dirs, d)
+		}
+	}
+	if len(dirs) == 0 {
+		return []string{migrationsFlag}
+	}
+	return dirs
+}
 
 // NoNotify returns whether notifications are disabled by flag.
 func NoNotify() bool { return noNotifyFlag }
+
+// OutputFormat returns the output format selected by flag.
+func OutputFormat() string { return outputFlag }
+
+// Color returns the color mode selected by --color; --no-color and --plain
+// force never.
+func Color() output.ColorMode {
+	if noColorFlag {
+		return output.ColorNever
+	}
+	return colorFlag
+}
+
+// Styled reports whether output written to w may use ANSI styling and emoji.
+func Styled(w io.Writer) bool { return Color().Styled(w) }
+
+// Symbols returns the status markers to use for output written to w.
+func Symbols(w io.Writer) output.Symbols { return output.SymbolsFor(Styled(w)) }
+
+// ArchivePath returns the migrations archive path from the global flag.
+func ArchivePath() string { return archiveFlag }
+
+// WaitForDB returns the --wait-for-db timeout, or 0 when unset.
+func WaitForDB() time.Duration { return waitForDBFlag }
+
+// ValidateTimeout returns the --validate-timeout bound, or 0 when unset.
+func ValidateTimeout() time.Duration { return validateTimeout }
+
+// LockID returns the --lock-id advisory lock key and whether it was given.
+func LockID() (int64, bool) {
+	return lockIDFlag, rootCmd.PersistentFlags().Changed("lock-id")
+}
+
+// MaxRetries returns the retry count from the global flag, or -1 when unset.
+func MaxRetries() int { return maxRetriesFlag }
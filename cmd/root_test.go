@@ -0,0 +1,161 @@
+package cmd_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	"github.com/spf13/cobra"
+)
+
+// askWithStdin runs AskConfirmation with in as stdin and fails the test if it
+// does not return promptly.
+func askWithStdin(t *testing.T, in io.Reader) (bool, error) {
+	t.Helper()
+	root := appcmd.NewRootCmd()
+	root.SetIn(in)
+	root.SetOut(io.Discard)
+
+	type result struct {
+		ok  bool
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ok, err := appcmd.AskConfirmation("continue?")
+		done <- result{ok, err}
+	}()
+	select {
+	case r := <-done:
+		return r.ok, r.err
+	case <-time.After(2 * time.Second):
+		t.Fatal("AskConfirmation blocked on non-interactive stdin")
+		return false, nil
+	}
+}
+
+func TestAskConfirmationNonInteractive(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	defer r.Close()
+
+	piped, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pw.WriteString("yes\n"); err != nil {
+		t.Fatal(err)
+	}
+	pw.Close()
+	defer piped.Close()
+
+	empty := filepath.Join(t.TempDir(), "stdin")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for name, in := range map[string]io.Reader{
+		"empty reader": strings.NewReader(""),
+		"closed pipe":  r,
+		"empty file":   f,
+		"piped answer": piped,
+	} {
+		t.Run(name, func(t *testing.T) {
+			ok, err := askWithStdin(t, in)
+			if ok || !errors.Is(err, appcmd.ErrNotInteractive) {
+				t.Fatalf("got ok=%v err=%v, want ErrNotInteractive", ok, err)
+			}
+		})
+	}
+}
+
+func TestAskConfirmationPipedAnswer(t *testing.T) {
+	ok, err := askWithStdin(t, strings.NewReader("yes\n"))
+	if err != nil || !ok {
+		t.Fatalf("got ok=%v err=%v, want confirmation", ok, err)
+	}
+}
+
+func TestAskTypedConfirmationNeedsExactWord(t *testing.T) {
+	for in, want := range map[string]bool{"rebaseline\n": true, "y\n": false, "REBASELINE\n": false} {
+		root := appcmd.NewRootCmd()
+		root.SetIn(strings.NewReader(in))
+		root.SetOut(io.Discard)
+		ok, err := appcmd.AskTypedConfirmation("reset everything?", "rebaseline")
+		if err != nil || ok != want {
+			t.Fatalf("input %q: got ok=%v err=%v, want %v", in, ok, err, want)
+		}
+	}
+}
+
+func TestMigrationsFlagAcceptsDirectoryList(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	if err := root.ParseFlags([]string{"--migrations", "core, billing,,search"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := appcmd.MigrationsDirs(); strings.Join(got, "|") != "core|billing|search" {
+		t.Fatalf("MigrationsDirs = %q", got)
+	}
+	if got := appcmd.MigrationsDir(); got != "core" {
+		t.Fatalf("MigrationsDir = %q, want the first directory", got)
+	}
+}
+
+func TestOverridesParseRepeatedSet(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	if err := root.ParseFlags([]string{"--set", "Database.Driver=mysql", "--set", "database.dsn=postgres://u@h/db?a=b"}); err != nil {
+		t.Fatal(err)
+	}
+	set, err := appcmd.Overrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set["database.driver"] != "mysql" || set["database.dsn"] != "postgres://u@h/db?a=b" {
+		t.Fatalf("overrides = %v", set)
+	}
+
+	root = appcmd.NewRootCmd()
+	if err := root.ParseFlags([]string{"--set", "database.driver"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := appcmd.Overrides(); err == nil {
+		t.Fatal("expected error for --set without =")
+	}
+}
+
+func TestDryRunOnlyOnCommandsThatHonorIt(t *testing.T) {
+	root := appcmd.NewRootCmd()
+	ran := ""
+	for _, c := range []*cobra.Command{
+		{Use: "up", Annotations: map[string]string{appcmd.DryRunnable: "true"}},
+		{Use: "force"},
+	} {
+		c.RunE = func(cmd *cobra.Command, args []string) error {
+			ran = cmd.Name()
+			return nil
+		}
+		root.AddCommand(c)
+	}
+	root.SetArgs([]string{"up", "--dry-run"})
+	if err := root.Execute(); err != nil || ran != "up" || !appcmd.DryRun() {
+		t.Fatalf("up --dry-run: err = %v, ran %q, DryRun %v", err, ran, appcmd.DryRun())
+	}
+	ran = ""
+	root.SetArgs([]string{"force", "--dry-run"})
+	if err := root.Execute(); err == nil || !strings.Contains(err.Error(), "does not support --dry-run") || ran != "" {
+		t.Fatalf("force --dry-run: err = %v, ran %q, want refused before running", err, ran)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+)
+
+func TestSelftestReportsEachPipeline(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev struct{ Status string }
+		_ = json.NewDecoder(r.Body).Decode(&ev)
+		mu.Lock()
+		statuses = append(statuses, ev.Status)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yml")
+	cfg := "database:\n  dsn: postgres://unused\nlogging:\n  file_enabled: false\n" +
+		"notifier:\n  enabled: true\n  type: webhook\n  webhook:\n    url: " + srv.URL + "\n"
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := appcmd.NewRootCmd()
+	root.AddCommand(appcmd.NewSelftestCmd())
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"selftest", "--config", cfgPath, "--color", "always"})
+	err := root.Execute()
+
+	// The default kafka driver has no brokers, so the logger check must fail while
+	// every notifier event succeeds.
+	if err == nil {
+		t.Fatalf("expected selftest to fail on the logger check:\n%s", out.String())
+	}
+	for _, want := range []string{"❌ logger (kafka)", "✅ notifier (webhook) start event", "✅ notifier (webhook) fail event"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, out.String())
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(statuses, ",") != "start,success,fail" {
+		t.Fatalf("webhook received %v", statuses)
+	}
+}
+
+func TestSelftestColorModes(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yml")
+	cfg := "database:\n  dsn: postgres://unused\nlogging:\n  file_enabled: false\nnotifier:\n  enabled: false\n"
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) (string, error) {
+		root := appcmd.NewRootCmd()
+		root.AddCommand(appcmd.NewSelftestCmd())
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetErr(&out)
+		root.SetArgs(append([]string{"selftest", "--config", cfgPath}, args...))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	// never, and auto on a non-terminal writer, must be pure ASCII
+	for _, args := range [][]string{{"--color", "never"}, {"--plain"}, nil} {
+		out, _ := run(args...)
+		for _, r := range out {
+			if r > 0x7f {
+				t.Fatalf("args %v: non-ASCII %q in output:\n%s", args, r, out)
+			}
+		}
+		if !strings.Contains(out, "[FAIL] logger (kafka)") || !strings.Contains(out, "[WARN] notifier: disabled") {
+			t.Fatalf("args %v: missing ASCII markers:\n%s", args, out)
+		}
+	}
+	if out, _ := run("--color", "always"); !strings.Contains(out, "❌ logger (kafka)") {
+		t.Fatalf("always mode should use emoji:\n%s", out)
+	}
+	if _, err := run("--color", "sometimes"); err == nil || !strings.Contains(err.Error(), "invalid color mode") {
+		t.Fatalf("expected invalid mode error, got %v", err)
+	}
+}
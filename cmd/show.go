@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	mgmt "github.com/lenhattri/kaeshi-migrate/internal/migrate/manager"
+)
+
+// ShowFunc returns the details of the migration with the given version.
+type ShowFunc func(v uint) (mgmt.MigrationDetail, error)
+
+// NewShowCmd returns the read-only show command. preRun prepares the
+// database connection used by show.
+func NewShowCmd(preRun func() error, show ShowFunc) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show [version]",
+		Short: "Print the SQL, statements, hash and state of one migration",
+		Args:  cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return preRun()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version: %w", err)
+			}
+			d, err := show(uint(v))
+			if err != nil {
+				return err
+			}
+			cmd.Printf("Version:    %d\n", d.Version)
+			cmd.Printf("Hash:       %s\n", d.Hash)
+			cmd.Printf("Applied:    %s\n", yesNo(d.Applied))
+			if d.AppliedBy != "" {
+				cmd.Printf("Applied by: %s\n", d.AppliedBy)
+			}
+			cmd.Printf("Committed:  %s\n", yesNo(d.Committed))
+			printShowFile(cmd, "Up", d.UpFile, d.UpSQL, d.UpStatements)
+			if d.DownFile == "" {
+				cmd.Println("\n== Down: none")
+				return nil
+			}
+			printShowFile(cmd, "Down", d.DownFile, d.DownSQL, d.DownStatements)
+			return nil
+		},
+	}
+}
+
+func printShowFile(cmd *cobra.Command, title, file, sql string, stmts []mgmt.TypedStatement) {
+	cmd.Printf("\n== %s: %s\n%s\n", title, filepath.Base(file), strings.TrimRight(sql, "\n"))
+	cmd.Printf("-- %d statement(s):\n", len(stmts))
+	for i, s := range stmts {
+		cmd.Printf("  %d. [%s] %s\n", i+1, s.Type, strings.Join(strings.Fields(s.SQL), " "))
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,65 @@
+package cmd_test
+
+import (
+	"bytes"
+	"testing"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+	mgmt "github.com/lenhattri/kaeshi-migrate/internal/migrate/manager"
+)
+
+func TestShowPrintsMigration(t *testing.T) {
+	detail := mgmt.MigrationDetail{
+		Version:  2,
+		UpFile:   "migrations/0002_add_email.up.sql",
+		DownFile: "migrations/0002_add_email.down.sql",
+		UpSQL:    "-- add email\nALTER TABLE users ADD COLUMN email TEXT;\nUPDATE users SET email = '';\n",
+		DownSQL:  "ALTER TABLE users DROP COLUMN email;\n",
+		Hash:     "abc123",
+		UpStatements: []mgmt.TypedStatement{
+			{Type: "DDL", SQL: "ALTER TABLE users ADD COLUMN email TEXT"},
+			{Type: "DML", SQL: "UPDATE users\nSET email = ''"},
+		},
+		DownStatements: []mgmt.TypedStatement{{Type: "DDL", SQL: "ALTER TABLE users DROP COLUMN email"}},
+		Applied:        true,
+		AppliedBy:      "alice",
+	}
+	var gotVersion uint
+	var buf bytes.Buffer
+	root := appcmd.NewRootCmd()
+	root.AddCommand(appcmd.NewShowCmd(func() error { return nil }, func(v uint) (mgmt.MigrationDetail, error) {
+		gotVersion = v
+		return detail, nil
+	}))
+	root.SetOut(&buf)
+	root.SetArgs([]string{"show", "2"})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("show: %v", err)
+	}
+	if gotVersion != 2 {
+		t.Fatalf("show asked for version %d, want 2", gotVersion)
+	}
+
+	want := `Version:    2
+Hash:       abc123
+Applied:    yes
+Applied by: alice
+Committed:  no
+
+== Up: 0002_add_email.up.sql
+-- add email
+ALTER TABLE users ADD COLUMN email TEXT;
+UPDATE users SET email = '';
+-- 2 statement(s):
+  1. [DDL] ALTER TABLE users ADD COLUMN email TEXT
+  2. [DML] UPDATE users SET email = ''
+
+== Down: 0002_add_email.down.sql
+ALTER TABLE users DROP COLUMN email;
+-- 1 statement(s):
+  1. [DDL] ALTER TABLE users DROP COLUMN email
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
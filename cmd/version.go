@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// VersionFunc returns the current migration version and dirty flag.
+type VersionFunc func() (uint, bool, error)
+
+// NewVersionCmd returns the version command. preRun prepares the database
+// connection used by current.
+func NewVersionCmd(preRun func() error, current VersionFunc) *cobra.Command {
+	var short bool
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print current migration version",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			return preRun()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v, dirty, err := current()
+			if err != nil {
+				return err
+			}
+			if short {
+				cmd.Println(v)
+				if dirty {
+					return fmt.Errorf("database is dirty at version %d", v)
+				}
+				return nil
+			}
+			cmd.Printf("Current version: %d", v)
+			if dirty {
+				cmd.Printf(" (DIRTY)")
+			}
+			cmd.Println()
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&short, "short", "s", false, "print only the version number; exit non-zero when dirty")
+	return cmd
+}
@@ -0,0 +1,41 @@
+package cmd_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	appcmd "github.com/lenhattri/kaeshi-migrate/cmd"
+)
+
+func execVersion(t *testing.T, v uint, dirty bool, args ...string) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	root := appcmd.NewRootCmd()
+	root.AddCommand(appcmd.NewVersionCmd(func() error { return nil }, func() (uint, bool, error) { return v, dirty, nil }))
+	root.SetOut(&buf)
+	root.SetErr(&bytes.Buffer{})
+	root.SetArgs(append([]string{"version"}, args...))
+	err := root.Execute()
+	return buf.String(), err
+}
+
+func TestVersionShort(t *testing.T) {
+	out, err := execVersion(t, 20240624, false, "--short")
+	if err != nil || out != "20240624\n" {
+		t.Fatalf("--short = %q, %v; want bare number", out, err)
+	}
+
+	out, err = execVersion(t, 7, true, "-s")
+	if out != "7\n" {
+		t.Fatalf("-s when dirty printed %q, want bare number", out)
+	}
+	if err == nil || !strings.Contains(err.Error(), "dirty") {
+		t.Fatalf("-s when dirty err = %v, want dirty error for a non-zero exit", err)
+	}
+
+	out, err = execVersion(t, 7, true)
+	if err != nil || out != "Current version: 7 (DIRTY)\n" {
+		t.Fatalf("default output = %q, %v", out, err)
+	}
+}
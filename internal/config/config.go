@@ -1,6 +1,12 @@
 package config
 
-import "github.com/lenhattri/kaeshi-migrate/internal/notifier"
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+)
 
 // Config represents application configuration loaded from file or environment.
 type Config struct {
@@ -9,12 +15,62 @@ type Config struct {
 	Database struct {
 		Driver string `mapstructure:"driver" yaml:"driver"`
 		Dsn    string `mapstructure:"dsn" yaml:"dsn"`
+		// DsnEnv names an environment variable and DsnFile a file, such as
+		// a mounted secret, holding the DSN. A non-empty value from DsnEnv
+		// wins over DsnFile, which wins over Dsn and Dsns.
+		DsnEnv  string `mapstructure:"dsn_env" yaml:"dsn_env"`
+		DsnFile string `mapstructure:"dsn_file" yaml:"dsn_file"`
+		// Dsns maps environment names to DSNs; the entry for the active env
+		// replaces Dsn when present.
+		Dsns              map[string]string `mapstructure:"dsns" yaml:"dsns"`
+		LockWaitThreshold time.Duration     `mapstructure:"lock_wait_threshold" yaml:"lock_wait_threshold"`
+		// LockTimeout makes migrating commands give up waiting for the
+		// migration lock after this long; zero waits indefinitely.
+		LockTimeout time.Duration `mapstructure:"lock_timeout" yaml:"lock_timeout"`
+		// AdvisoryLockID replaces the derived PostgreSQL advisory lock key
+		// so independent migration sets can run concurrently; zero keeps it.
+		AdvisoryLockID int64 `mapstructure:"advisory_lock_id" yaml:"advisory_lock_id"`
+		MaxRetries     int   `mapstructure:"max_retries" yaml:"max_retries"`
+		// Retry shapes the pauses between those retries; see
+		// manager.Backoff.
+		Retry struct {
+			Base       time.Duration `mapstructure:"base" yaml:"base"`
+			Max        time.Duration `mapstructure:"max" yaml:"max"`
+			Multiplier float64       `mapstructure:"multiplier" yaml:"multiplier"`
+			Jitter     float64       `mapstructure:"jitter" yaml:"jitter"`
+		} `mapstructure:"retry" yaml:"retry"`
+		TablePrefix string `mapstructure:"table_prefix" yaml:"table_prefix"`
+		// MigrationsTable and HistoryTable replace the version and history
+		// table names; empty keeps the defaults with TablePrefix applied.
+		MigrationsTable string        `mapstructure:"migrations_table" yaml:"migrations_table"`
+		HistoryTable    string        `mapstructure:"history_table" yaml:"history_table"`
+		Pooler          string        `mapstructure:"pooler" yaml:"pooler"`
+		WaitForDB       time.Duration `mapstructure:"wait_for_db" yaml:"wait_for_db"`
+		// Tenants lists the databases that up --all-tenants migrates,
+		// TenantConcurrency of them at a time.
+		Tenants []struct {
+			Name string `mapstructure:"name" yaml:"name"`
+			Dsn  string `mapstructure:"dsn" yaml:"dsn"`
+		} `mapstructure:"tenants" yaml:"tenants"`
+		TenantConcurrency int `mapstructure:"tenant_concurrency" yaml:"tenant_concurrency"`
+		// DDLLockTimeout bounds table lock waits of migrations containing
+		// ALTER TABLE, which are tried DDLLockAttempts times.
+		DDLLockTimeout  time.Duration `mapstructure:"ddl_lock_timeout" yaml:"ddl_lock_timeout"`
+		DDLLockAttempts int           `mapstructure:"ddl_lock_attempts" yaml:"ddl_lock_attempts"`
 	} `mapstructure:"database" yaml:"database"`
 	Logging struct {
 		Level  string `mapstructure:"level" yaml:"level"`
 		Driver string `mapstructure:"driver" yaml:"driver"`
+		// Format of console log lines: json (default) or text, colored on
+		// a terminal. MQ hooks always ship JSON.
+		Format string `mapstructure:"format" yaml:"format"`
 		File   string `mapstructure:"file" yaml:"file"`
-		Kafka  struct {
+		// FileEnabled appends log entries to File. It defaults to true
+		// outside production, and in production when File is set.
+		FileEnabled       bool          `mapstructure:"file_enabled" yaml:"file_enabled"`
+		StripSQLComments  bool          `mapstructure:"strip_sql_comments" yaml:"strip_sql_comments"`
+		HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval" yaml:"heartbeat_interval"`
+		Kafka             struct {
 			Brokers []string `mapstructure:"brokers" yaml:"brokers"`
 			Topic   string   `mapstructure:"topic" yaml:"topic"`
 		} `mapstructure:"kafka" yaml:"kafka"`
@@ -23,5 +79,92 @@ type Config struct {
 			Queue string `mapstructure:"queue" yaml:"queue"`
 		} `mapstructure:"rabbitmq" yaml:"rabbitmq"`
 	} `mapstructure:"logging" yaml:"logging"`
-	Notifier notifier.Config `mapstructure:"notifier" yaml:"notifier"`
+	Validation struct {
+		DownLint       bool   `mapstructure:"down_lint" yaml:"down_lint"`
+		LockOrderLint  bool   `mapstructure:"lock_order_lint" yaml:"lock_order_lint"`
+		RollbackCheck  string `mapstructure:"rollback_check" yaml:"rollback_check"`
+		SchemaSnapshot bool   `mapstructure:"schema_snapshot" yaml:"schema_snapshot"`
+		// Enabled=false applies migrations without dry-running them first;
+		// see manager.WithValidation.
+		Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+		// Timeout bounds the dry run of each statement; zero keeps the
+		// 4s default.
+		Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+		// EmptyMigrations is warn (default), skip or refuse; see
+		// manager.WithEmptyMigrations.
+		EmptyMigrations string `mapstructure:"empty_migrations" yaml:"empty_migrations"`
+		// NormalizeHash hashes migrations after dialect normalization
+		// instead of byte for byte; see manager.WithNormalizedHash.
+		NormalizeHash bool `mapstructure:"normalize_hash" yaml:"normalize_hash"`
+		// SignatureKeys are OpenPGP public key files; up files with a
+		// .sig detached signature are verified against them.
+		SignatureKeys     []string `mapstructure:"signature_keys" yaml:"signature_keys"`
+		RequireSignatures bool     `mapstructure:"require_signatures" yaml:"require_signatures"`
+		// DenyStatements maps environment names to statement types or
+		// leading keywords (e.g. "DROP TABLE", "TRUNCATE") refused there.
+		DenyStatements map[string][]string `mapstructure:"deny_statements" yaml:"deny_statements"`
+		ConfirmPolicy  struct {
+			URL     string            `mapstructure:"url" yaml:"url"`
+			Headers map[string]string `mapstructure:"headers" yaml:"headers"`
+			Timeout time.Duration     `mapstructure:"timeout" yaml:"timeout"`
+		} `mapstructure:"confirm_policy" yaml:"confirm_policy"`
+	} `mapstructure:"validation" yaml:"validation"`
+	Notifier          notifier.Config `mapstructure:"notifier" yaml:"notifier"`
+	MigrationsArchive string          `mapstructure:"migrations_archive" yaml:"migrations_archive"`
+	MigrationsDirs    []string        `mapstructure:"migrations_dirs" yaml:"migrations_dirs"`
+	SourceURL         string          `mapstructure:"source_url" yaml:"source_url"`
+	Migration         struct {
+		// Vars maps template variable names usable in migration SQL to
+		// the environment variables holding their values.
+		Vars map[string]string `mapstructure:"vars" yaml:"vars"`
+	} `mapstructure:"migration" yaml:"migration"`
+	// Messages overrides the Go templates of outcome messages by key; see
+	// the messages package.
+	Messages map[string]string `mapstructure:"messages" yaml:"messages"`
+}
+
+// MigrationDirs returns migrations_dirs with blank entries dropped, so a
+// comma-separated string such as "core, billing" works as well as a list.
+func (c *Config) MigrationDirs() []string {
+	var dirs []string
+	for _, d := range c.MigrationsDirs {
+		for _, part := range strings.Split(d, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				dirs = append(dirs, part)
+			}
+		}
+	}
+	return dirs
+}
+
+// MigrationVars resolves migration.vars from the environment, or returns nil
+// when none are configured. Variables whose environment variable is unset are
+// left out, so a migration using them fails to render instead of getting an
+// empty value.
+func (c *Config) MigrationVars() map[string]string {
+	if len(c.Migration.Vars) == 0 {
+		return nil
+	}
+	vars := map[string]string{}
+	for name, env := range c.Migration.Vars {
+		if v, ok := os.LookupEnv(env); ok {
+			vars[name] = v
+		}
+	}
+	return vars
+}
+
+// LogFile returns the file log entries are appended to, or "" when file
+// logging is disabled.
+func (c *Config) LogFile() string {
+	if !c.Logging.FileEnabled {
+		return ""
+	}
+	return c.Logging.File
+}
+
+// DeniedStatements returns the validation.deny_statements entry of the active
+// environment.
+func (c *Config) DeniedStatements() []string {
+	return c.Validation.DenyStatements[strings.ToLower(c.Env)]
 }
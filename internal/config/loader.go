@@ -2,55 +2,114 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/spf13/viper"
 )
 
+// configCandidates are the files looked for under ./configs, in order, when
+// no path is given.
+var configCandidates = []string{"config.yml", "config.yaml", "config.toml", "config.json"}
+
 // Load reads configuration from the given file path and environment variables.
-// If path is empty, it defaults to ./configs/config.yml.
+// The format follows the file extension: YAML, TOML or JSON. If path is
+// empty, the first of configCandidates found under ./configs is read.
 // Environment variables prefixed with KAESHI_ take precedence.
 func Load(path string) (*Config, error) {
+	return LoadForEnv(path, "")
+}
+
+// LoadForEnv is Load with env, when not empty, overriding the configured
+// environment. The DSN is picked from database.dsns for the resulting env,
+// falling back to database.dsn.
+func LoadForEnv(path, env string) (*Config, error) {
+	return LoadWithOverrides(path, env, nil)
+}
+
+// LoadWithOverrides is LoadForEnv with overrides, keyed by dotted config path
+// such as "database.driver", taking precedence over the file and the
+// environment. Values are strings converted to the type of their field;
+// keys that do not map to a field are rejected like misspelled file keys.
+func LoadWithOverrides(path, env string, overrides map[string]string) (*Config, error) {
 	v := viper.New()
-	if path != "" {
-		v.SetConfigFile(path)
-	} else {
-		v.SetConfigName("config")
-		v.SetConfigType("yaml")
-		v.AddConfigPath("./configs")
+	path, err := Resolve(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config: %w", err)
 	}
+	v.SetConfigFile(path)
 	v.AutomaticEnv()
 	v.SetEnvPrefix("KAESHI")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.SetDefault("validation.enabled", true)
+	v.SetDefault("validation.down_lint", true)
+	v.SetDefault("validation.lock_order_lint", true)
+	v.SetDefault("database.max_retries", 3)
+	v.SetDefault("database.retry.base", "1s")
+	v.SetDefault("database.retry.max", "30s")
+	v.SetDefault("database.retry.multiplier", 2)
+	v.SetDefault("database.retry.jitter", 0.5)
+	v.SetDefault("database.tenant_concurrency", 4)
+	v.SetDefault("database.ddl_lock_attempts", 5)
+	v.SetDefault("notifier.circuit_breaker.failures", 3)
+	v.SetDefault("notifier.circuit_breaker.cooldown", "1m")
 
 	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("read config: %w", err)
 	}
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+	if unknown := unknownKeys(v.AllKeys()); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown config key(s): %s (check for typos)", strings.Join(unknown, ", "))
+	}
 
 	var cfg Config
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 
-	if cfg.Database.Dsn == "" {
-		return nil, fmt.Errorf("database.dsn is required")
-	}
-	if cfg.Database.Driver == "" {
-		cfg.Database.Driver = "postgres"
+	if env != "" {
+		cfg.Env = env
 	}
 	if cfg.Env == "" {
 		cfg.Env = "development"
 	}
+	// viper lower-cases map keys
+	if dsn := cfg.Database.Dsns[strings.ToLower(cfg.Env)]; dsn != "" {
+		cfg.Database.Dsn = dsn
+	}
+	if err := dsnFromSources(&cfg); err != nil {
+		return nil, err
+	}
+	if err := resolveSecrets(&cfg); err != nil {
+		return nil, fmt.Errorf("resolve secrets: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Database.Driver == "" {
+		cfg.Database.Driver = "postgres"
+	}
 	if cfg.Logging.Level == "" {
 		cfg.Logging.Level = "info"
 	}
 	if cfg.Logging.Driver == "" {
 		cfg.Logging.Driver = "kafka"
 	}
+	if cfg.Logging.Format == "" {
+		cfg.Logging.Format = "json"
+	}
 	if cfg.Logging.Kafka.Topic == "" {
 		cfg.Logging.Kafka.Topic = "logging"
 	}
-	if cfg.Logging.File == "" && cfg.Env != "production" {
+	if !v.IsSet("logging.file_enabled") {
+		cfg.Logging.FileEnabled = cfg.Env != "production" || cfg.Logging.File != ""
+	}
+	if cfg.Logging.FileEnabled && cfg.Logging.File == "" {
 		cfg.Logging.File = "app.log"
 	}
 
@@ -60,3 +119,91 @@ func Load(path string) (*Config, error) {
 
 	return &cfg, nil
 }
+
+// Resolve returns the file Load reads for path: path itself when it is not
+// empty, or else the first of configCandidates found under ./configs.
+func Resolve(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	return discoverConfig("configs")
+}
+
+// discoverConfig returns the first of configCandidates present in dir.
+func discoverConfig(dir string) (string, error) {
+	for _, name := range configCandidates {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("no %s in %s", strings.Join(configCandidates, ", "), dir)
+}
+
+// dsnFromSources replaces the DSN with the value of database.dsn_env when
+// that variable is not empty, or else with the trimmed contents of
+// database.dsn_file when it has any.
+func dsnFromSources(cfg *Config) error {
+	db := &cfg.Database
+	if db.DsnEnv != "" {
+		if dsn := os.Getenv(db.DsnEnv); dsn != "" {
+			db.Dsn = dsn
+			return nil
+		}
+	}
+	if db.DsnFile != "" {
+		data, err := os.ReadFile(db.DsnFile)
+		if err != nil {
+			return fmt.Errorf("database.dsn_file: %w", err)
+		}
+		if dsn := strings.TrimSpace(string(data)); dsn != "" {
+			db.Dsn = dsn
+		}
+	}
+	return nil
+}
+
+// unknownKeys returns the keys that do not map to a Config field. Keys below
+// a map-typed field (such as webhook headers) are always accepted.
+func unknownKeys(keys []string) []string {
+	known := map[string]bool{}
+	open := map[string]bool{}
+	collectKeys(reflect.TypeOf(Config{}), "", known, open)
+
+	var unknown []string
+	for _, k := range keys {
+		if known[k] || underOpen(k, open) {
+			continue
+		}
+		unknown = append(unknown, k)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func collectKeys(t reflect.Type, prefix string, known, open map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("mapstructure"), ",")[0]
+		if name == "" {
+			continue
+		}
+		key := prefix + name
+		known[key] = true
+		switch f.Type.Kind() {
+		case reflect.Struct:
+			collectKeys(f.Type, key+".", known, open)
+		case reflect.Map:
+			open[key] = true
+		}
+	}
+}
+
+func underOpen(key string, open map[string]bool) bool {
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		if open[key[:i]] {
+			return true
+		}
+	}
+	return false
+}
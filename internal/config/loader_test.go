@@ -0,0 +1,278 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/config"
+	"github.com/lenhattri/kaeshi-migrate/internal/templates"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadRejectsMisspelledKeys(t *testing.T) {
+	p := writeConfig(t, "databse:\n  dsn: postgres://x\ndatabase:\n  dsn: postgres://y\n  max_retires: 2\n")
+	_, err := config.Load(p)
+	if err == nil {
+		t.Fatal("expected error for misspelled keys")
+	}
+	for _, key := range []string{"databse.dsn", "database.max_retires"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Fatalf("error %q does not name %s", err, key)
+		}
+	}
+}
+
+func TestLoadAcceptsDefaultTemplate(t *testing.T) {
+	p := writeConfig(t, templates.DefaultConfig)
+	p2 := writeConfig(t, strings.Replace(templates.DefaultConfig, "headers: {}", "headers:\n      X-Token: abc", 1))
+	for _, path := range []string{p, p2} {
+		if _, err := config.Load(path); err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+	}
+}
+
+func TestLoadSelectsDSNByEnv(t *testing.T) {
+	p := writeConfig(t, `env: staging
+database:
+  dsn: postgres://fallback
+  dsns:
+    staging: postgres://staging
+    Production: postgres://prod
+`)
+	cases := []struct{ env, want string }{
+		{"", "postgres://staging"},
+		{"production", "postgres://prod"},
+		{"development", "postgres://fallback"},
+	}
+	for _, c := range cases {
+		cfg, err := config.LoadForEnv(p, c.env)
+		if err != nil {
+			t.Fatalf("load env %q: %v", c.env, err)
+		}
+		if cfg.Database.Dsn != c.want {
+			t.Fatalf("env %q: dsn = %q, want %q", c.env, cfg.Database.Dsn, c.want)
+		}
+	}
+}
+
+func TestLoadRequiresDSNForEnvWithoutFallback(t *testing.T) {
+	p := writeConfig(t, "database:\n  dsns:\n    staging: postgres://staging\n")
+	_, err := config.LoadForEnv(p, "production")
+	if err == nil || !strings.Contains(err.Error(), `no DSN for env "production"`) {
+		t.Fatalf("err = %v, want missing DSN for production", err)
+	}
+	if cfg, err := config.LoadForEnv(p, "staging"); err != nil || cfg.Env != "staging" {
+		t.Fatalf("staging: cfg=%+v err=%v", cfg, err)
+	}
+}
+
+func TestLoadReadsDSNFromEnvThenFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db")
+	if err := os.WriteFile(secret, []byte("  postgres://from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p := writeConfig(t, "database:\n  dsn: postgres://literal\n  dsn_env: KAESHI_TEST_DSN\n  dsn_file: "+secret+"\n")
+	for _, c := range []struct{ env, want string }{
+		{"postgres://from-env", "postgres://from-env"},
+		{"", "postgres://from-file"},
+	} {
+		t.Setenv("KAESHI_TEST_DSN", c.env)
+		cfg, err := config.Load(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.Dsn != c.want {
+			t.Fatalf("env %q: dsn = %q, want %q", c.env, cfg.Database.Dsn, c.want)
+		}
+	}
+
+	if err := os.WriteFile(secret, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	empty := writeConfig(t, "database:\n  dsn_env: KAESHI_TEST_DSN\n  dsn_file: "+secret+"\n")
+	if _, err := config.Load(empty); err == nil || !strings.Contains(err.Error(), "no DSN") {
+		t.Fatalf("err = %v, want no DSN", err)
+	}
+}
+
+func TestDeniedStatementsFollowEnv(t *testing.T) {
+	p := writeConfig(t, `database:
+  dsn: postgres://x
+validation:
+  deny_statements:
+    production: ["DROP TABLE", "TRUNCATE"]
+`)
+	for env, want := range map[string]int{"production": 2, "development": 0} {
+		cfg, err := config.LoadForEnv(p, env)
+		if err != nil {
+			t.Fatalf("load env %q: %v", env, err)
+		}
+		if got := cfg.DeniedStatements(); len(got) != want {
+			t.Fatalf("env %q: denied = %v, want %d entries", env, got, want)
+		}
+	}
+}
+
+func TestMigrationDirsAcceptCommaSeparatedString(t *testing.T) {
+	p := writeConfig(t, "database:\n  dsn: postgres://x\nmigrations_dirs: \"core, billing\"\n")
+	cfg, err := config.LoadForEnv(p, "development")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.MigrationDirs(); strings.Join(got, "|") != "core|billing" {
+		t.Fatalf("MigrationDirs = %q", got)
+	}
+}
+
+func TestMigrationVarsReadWhitelistedEnv(t *testing.T) {
+	t.Setenv("KAESHI_TEST_SCHEMA", "tenant_a")
+	t.Setenv("KAESHI_TEST_SECRET", "hunter2")
+	p := writeConfig(t, "database:\n  dsn: postgres://x\nmigration:\n  vars:\n    schema: KAESHI_TEST_SCHEMA\n    tablespace: KAESHI_TEST_UNSET\n")
+	cfg, err := config.Load(p)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got := cfg.MigrationVars()
+	if len(got) != 1 || got["schema"] != "tenant_a" {
+		t.Fatalf("MigrationVars = %v, want only schema=tenant_a", got)
+	}
+}
+
+func TestLoadWithOverridesSetsNestedKeys(t *testing.T) {
+	p := writeConfig(t, "database:\n  driver: postgres\n  dsn: postgres://x\n")
+	cfg, err := config.LoadWithOverrides(p, "", map[string]string{
+		"database.driver":      "mysql",
+		"database.max_retries": "7",
+		"database.retry.base":  "250ms",
+		"notifier.enabled":     "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Driver != "mysql" || cfg.Database.MaxRetries != 7 || cfg.Database.Retry.Base != 250*time.Millisecond || !cfg.Notifier.Enabled {
+		t.Fatalf("overrides not applied: %+v", cfg.Database)
+	}
+	if _, err := config.LoadWithOverrides(p, "", map[string]string{"database.drivr": "mysql"}); err == nil || !strings.Contains(err.Error(), "database.drivr") {
+		t.Fatalf("err = %v, want unknown key database.drivr", err)
+	}
+}
+
+func TestLoadReadsTOMLAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yml":  "env: staging\ndatabase:\n  driver: mysql\n  dsn: mysql://x\n  retry:\n    base: 2s\nmigrations_dirs: [core, billing]\n",
+		"config.toml": "env = \"staging\"\nmigrations_dirs = [\"core\", \"billing\"]\n\n[database]\ndriver = \"mysql\"\ndsn = \"mysql://x\"\n\n[database.retry]\nbase = \"2s\"\n",
+		"config.json": `{"env": "staging", "database": {"driver": "mysql", "dsn": "mysql://x", "retry": {"base": "2s"}}, "migrations_dirs": ["core", "billing"]}`,
+	}
+	cfgs := map[string]*config.Config{}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := config.Load(p)
+		if err != nil {
+			t.Fatalf("load %s: %v", name, err)
+		}
+		cfgs[name] = cfg
+	}
+	for _, name := range []string{"config.toml", "config.json"} {
+		if !reflect.DeepEqual(cfgs[name], cfgs["config.yml"]) {
+			t.Fatalf("%s = %+v, want %+v", name, cfgs[name], cfgs["config.yml"])
+		}
+	}
+}
+
+func TestLoadDiscoversConfigByExtension(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("configs", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.Load(""); err == nil || !strings.Contains(err.Error(), "config.toml") {
+		t.Fatalf("err = %v, want the candidates listed", err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join("configs", name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("config.json", `{"database": {"dsn": "postgres://json"}}`)
+	write("config.toml", "[database]\ndsn = \"postgres://toml\"\n")
+	for _, want := range []string{"toml", "yaml"} {
+		cfg, err := config.Load("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.Dsn != "postgres://"+want {
+			t.Fatalf("dsn = %q, want postgres://%s", cfg.Database.Dsn, want)
+		}
+		if path, err := config.Resolve(""); err != nil || path != filepath.Join("configs", "config."+want) {
+			t.Fatalf("Resolve = %q, %v, want the %s file", path, err, want)
+		}
+		write("config.yaml", "database:\n  dsn: postgres://yaml\n")
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	p := writeConfig(t, "env: prod\nlogging:\n  driver: kafka\n")
+	_, err := config.Load(p)
+	var verr *config.ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want *config.ValidationError", err)
+	}
+	want := []string{"env: unknown environment \"prod\"", "database.dsn is required", "logging.kafka.brokers is required"}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("problems = %q, want %d", verr.Problems, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(verr.Problems[i], w) {
+			t.Fatalf("problem %d = %q, want %q", i, verr.Problems[i], w)
+		}
+	}
+
+	p = writeConfig(t, "database:\n  dsn: postgres://x\nlogging:\n  driver: syslog\n  rabbitmq:\n    url: amqp://x\n")
+	if _, err := config.Load(p); err == nil || !strings.Contains(err.Error(), `logging.driver: unknown driver "syslog"`) {
+		t.Fatalf("err = %v, want unknown logging driver", err)
+	}
+	p = writeConfig(t, "database:\n  dsn: postgres://x\nlogging:\n  driver: rabbitmq\n")
+	if _, err := config.Load(p); err == nil || !strings.Contains(err.Error(), "logging.rabbitmq.url is required") {
+		t.Fatalf("err = %v, want missing rabbitmq url", err)
+	}
+}
+
+func TestLoadEnablesFileLoggingPerEnv(t *testing.T) {
+	cases := []struct {
+		env, extra, want string
+	}{
+		{"development", "", "app.log"},
+		{"production", "", ""},
+		{"production", "logging:\n  file: /var/log/kaeshi.log\n", "/var/log/kaeshi.log"},
+		{"production", "logging:\n  file_enabled: true\n", "app.log"},
+		{"development", "logging:\n  file_enabled: false\n", ""},
+	}
+	for _, c := range cases {
+		p := writeConfig(t, "database:\n  dsn: postgres://x\n"+c.extra)
+		cfg, err := config.LoadForEnv(p, c.env)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cfg.LogFile(); got != c.want {
+			t.Fatalf("%s %q: LogFile = %q, want %q", c.env, c.extra, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+)
+
+// redactedValue stands in for secrets, matching url.URL.Redacted.
+const redactedValue = "xxxxx"
+
+var (
+	reDSNUserPassword = regexp.MustCompile(`^([^:/@]+):[^@]*@`)
+	reDSNPasswordKey  = regexp.MustCompile(`(?i)(\bpassword=)('[^']*'|\S+)`)
+)
+
+// Redacted returns a copy of c that is safe to print: passwords in DSNs and
+// the RabbitMQ URL are masked, and the notifier webhook and confirm policy
+// URLs and header values, which are credentials themselves, are replaced.
+func (c Config) Redacted() Config {
+	db := &c.Database
+	db.Dsn = redactDSN(db.Dsn)
+	db.Dsns = maps.Clone(db.Dsns)
+	for env, dsn := range db.Dsns {
+		db.Dsns[env] = redactDSN(dsn)
+	}
+	db.Tenants = slices.Clone(db.Tenants)
+	for i := range db.Tenants {
+		db.Tenants[i].Dsn = redactDSN(db.Tenants[i].Dsn)
+	}
+	c.Logging.RabbitMQ.URL = redactDSN(c.Logging.RabbitMQ.URL)
+
+	n, cp := &c.Notifier, &c.Validation.ConfirmPolicy
+	for _, p := range []*string{&n.Discord.WebhookURL, &n.Slack.WebhookURL, &n.Webhook.URL, &cp.URL} {
+		if *p != "" {
+			*p = redactedValue
+		}
+	}
+	n.Webhook.Headers = redactHeaders(n.Webhook.Headers)
+	cp.Headers = redactHeaders(cp.Headers)
+	return c
+}
+
+// redactHeaders returns a copy of headers with every value replaced.
+func redactHeaders(headers map[string]string) map[string]string {
+	headers = maps.Clone(headers)
+	for name := range headers {
+		headers[name] = redactedValue
+	}
+	return headers
+}
+
+// redactDSN masks the password of a URL, user:password@ or key=value DSN.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+		return dsn
+	}
+	dsn = reDSNUserPassword.ReplaceAllString(dsn, "${1}:"+redactedValue+"@")
+	return reDSNPasswordKey.ReplaceAllString(dsn, "${1}"+redactedValue)
+}
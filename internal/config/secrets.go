@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SecretResolver fetches a secret referenced from a config value. For
+// vault://kv/app/db#password, path is "kv/app/db" and key is "password".
+type SecretResolver interface {
+	ResolveSecret(path, key string) (string, error)
+}
+
+// SecretResolverFunc adapts a function to SecretResolver.
+type SecretResolverFunc func(path, key string) (string, error)
+
+// ResolveSecret calls f.
+func (f SecretResolverFunc) ResolveSecret(path, key string) (string, error) { return f(path, key) }
+
+var (
+	secretResolversMu sync.RWMutex
+	secretResolvers   = map[string]SecretResolver{
+		"env":  SecretResolverFunc(envSecret),
+		"file": SecretResolverFunc(fileSecret),
+	}
+)
+
+// builtinSchemes are only honoured inside ${secret:...}, so that ordinary
+// values such as a file: SQLite DSN are never mistaken for references.
+var builtinSchemes = map[string]bool{"env": true, "file": true}
+
+// RegisterSecretResolver registers the resolver for references with scheme,
+// e.g. "vault". Register before loading the config.
+func RegisterSecretResolver(scheme string, r SecretResolver) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	secretResolvers[strings.ToLower(scheme)] = r
+}
+
+// UnregisterSecretResolver removes the resolver registered for scheme.
+func UnregisterSecretResolver(scheme string) {
+	secretResolversMu.Lock()
+	defer secretResolversMu.Unlock()
+	delete(secretResolvers, strings.ToLower(scheme))
+}
+
+// secretResolver returns the resolver registered for scheme.
+func secretResolver(scheme string) (SecretResolver, bool) {
+	secretResolversMu.RLock()
+	defer secretResolversMu.RUnlock()
+	r, ok := secretResolvers[scheme]
+	return r, ok
+}
+
+var (
+	reSecretRef     = regexp.MustCompile(`\$\{secret:([^}]+)\}`)
+	reBareSecretRef = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9+.-]*)://(.*)$`)
+)
+
+// resolveSecrets replaces secret references in the DSN and notifier
+// credentials. Only the DSN of the active environment is resolved, and
+// notifier values only when notifications are enabled.
+func resolveSecrets(cfg *Config) error {
+	if err := resolveField("database.dsn", &cfg.Database.Dsn); err != nil {
+		return err
+	}
+	for i := range cfg.Database.Tenants {
+		t := &cfg.Database.Tenants[i]
+		if err := resolveField("database.tenants."+t.Name+".dsn", &t.Dsn); err != nil {
+			return err
+		}
+	}
+	n := &cfg.Notifier
+	if !n.Enabled {
+		return nil
+	}
+	for key, p := range map[string]*string{
+		"notifier.discord.webhook_url": &n.Discord.WebhookURL,
+		"notifier.slack.webhook_url":   &n.Slack.WebhookURL,
+		"notifier.webhook.url":         &n.Webhook.URL,
+	} {
+		if err := resolveField(key, p); err != nil {
+			return err
+		}
+	}
+	for name, v := range n.Webhook.Headers {
+		if err := resolveField("notifier.webhook.headers."+name, &v); err != nil {
+			return err
+		}
+		n.Webhook.Headers[name] = v
+	}
+	return nil
+}
+
+func resolveField(key string, value *string) error {
+	v, err := ResolveSecrets(*value)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*value = v
+	return nil
+}
+
+// ResolveSecrets returns value with its secret references substituted. A
+// reference is either embedded as ${secret:scheme:path#key} (for example
+// ${secret:env:DB_PASSWORD} or ${secret:vault://kv/db#password}) or is the
+// whole value written as scheme://path#key for a registered scheme other
+// than the built-in env and file.
+func ResolveSecrets(value string) (string, error) {
+	if m := reBareSecretRef.FindStringSubmatch(value); m != nil {
+		scheme := strings.ToLower(m[1])
+		if _, ok := secretResolver(scheme); ok && !builtinSchemes[scheme] {
+			return resolveRef(scheme, m[2])
+		}
+	}
+	var firstErr error
+	out := reSecretRef.ReplaceAllStringFunc(value, func(ref string) string {
+		scheme, rest, ok := strings.Cut(reSecretRef.FindStringSubmatch(ref)[1], ":")
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("malformed secret reference %q: want ${secret:scheme:path}", ref)
+			}
+			return ref
+		}
+		s, err := resolveRef(strings.ToLower(scheme), strings.TrimPrefix(rest, "//"))
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return s
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
+
+func resolveRef(scheme, ref string) (string, error) {
+	r, ok := secretResolver(scheme)
+	if !ok {
+		return "", fmt.Errorf("no secret resolver registered for scheme %q", scheme)
+	}
+	path, key, _ := strings.Cut(ref, "#")
+	s, err := r.ResolveSecret(path, key)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s secret %s: %w", scheme, path, err)
+	}
+	return s, nil
+}
+
+// envSecret reads the environment variable named by path.
+func envSecret(path, _ string) (string, error) {
+	v, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", path)
+	}
+	return v, nil
+}
+
+// fileSecret reads the file at path, such as a mounted Kubernetes or Docker
+// secret, without its trailing newline.
+func fileSecret(path, _ string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
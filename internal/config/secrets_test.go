@@ -0,0 +1,103 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/config"
+)
+
+func TestLoadResolvesSecretReferences(t *testing.T) {
+	config.RegisterSecretResolver("stub", config.SecretResolverFunc(func(path, key string) (string, error) {
+		if path == "missing" {
+			return "", fmt.Errorf("not found")
+		}
+		return path + "/" + key, nil
+	}))
+	t.Cleanup(func() { config.UnregisterSecretResolver("stub") })
+	secretFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_DB_PASSWORD", "pw")
+
+	p := writeConfig(t, `database:
+  dsn: postgres://app:${secret:env:TEST_DB_PASSWORD}@db/app
+  tenants:
+    - name: acme
+      dsn: stub://kv/acme#dsn
+notifier:
+  enabled: true
+  slack:
+    webhook_url: stub://kv/slack#url
+  webhook:
+    url: https://hooks.example/x
+    headers:
+      X-Token: ${secret:file:`+secretFile+`}
+`)
+	cfg, err := config.Load(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Database.Dsn; got != "postgres://app:pw@db/app" {
+		t.Fatalf("dsn = %q", got)
+	}
+	if got := cfg.Database.Tenants[0].Dsn; got != "kv/acme/dsn" {
+		t.Fatalf("tenant dsn = %q", got)
+	}
+	if got := cfg.Notifier.Slack.WebhookURL; got != "kv/slack/url" {
+		t.Fatalf("slack webhook = %q", got)
+	}
+	if got := cfg.Notifier.Webhook.Headers["x-token"]; got != "s3cret" {
+		t.Fatalf("webhook header = %q (headers %v)", got, cfg.Notifier.Webhook.Headers)
+	}
+	if got := cfg.Notifier.Webhook.URL; got != "https://hooks.example/x" {
+		t.Fatalf("webhook url changed to %q", got)
+	}
+
+	bad := writeConfig(t, "database:\n  dsn: postgres://app:${secret:stub:missing#pw}@db/app\n")
+	if _, err := config.Load(bad); err == nil || !strings.Contains(err.Error(), "database.dsn") {
+		t.Fatalf("err = %v, want resolution failure naming database.dsn", err)
+	}
+}
+
+func TestResolveSecretsLeavesPlainValues(t *testing.T) {
+	for _, v := range []string{"postgres://u:p@h/db", "file:///tmp/app.db", "env://NOT_A_REF"} {
+		got, err := config.ResolveSecrets(v)
+		if err != nil || got != v {
+			t.Fatalf("ResolveSecrets(%q) = %q, %v; want it unchanged", v, got, err)
+		}
+	}
+	if _, err := config.ResolveSecrets("${secret:nosuch:x}"); err == nil {
+		t.Fatal("expected an error for an unregistered scheme")
+	}
+}
+
+func TestRedactedMasksCredentials(t *testing.T) {
+	var cfg config.Config
+	cfg.Database.Dsn = "app:s3cret@tcp(db:3306)/app"
+	cfg.Database.Dsns = map[string]string{"staging": "host=db user=app password=s3cret dbname=app", "dev": "/tmp/dev.sqlite"}
+	cfg.Logging.RabbitMQ.URL = "amqp://guest:s3cret@mq:5672/"
+	cfg.Notifier.Slack.WebhookURL = "https://hooks.slack.com/services/T0/B0/s3cret"
+	cfg.Notifier.Webhook.Headers = map[string]string{"Authorization": "Bearer s3cret"}
+	cfg.Validation.ConfirmPolicy.URL = "https://policy.example.com/check?token=s3cret"
+	cfg.Validation.ConfirmPolicy.Headers = map[string]string{"X-Api-Key": "s3cret"}
+
+	r := cfg.Redacted()
+	for _, got := range []string{r.Database.Dsn, r.Database.Dsns["staging"], r.Logging.RabbitMQ.URL, r.Notifier.Slack.WebhookURL, r.Notifier.Webhook.Headers["Authorization"],
+		r.Validation.ConfirmPolicy.URL, r.Validation.ConfirmPolicy.Headers["X-Api-Key"]} {
+		if strings.Contains(got, "s3cret") || !strings.Contains(got, "xxxxx") {
+			t.Fatalf("not masked: %q", got)
+		}
+	}
+	if r.Database.Dsns["dev"] != "/tmp/dev.sqlite" || r.Database.Dsn != "app:xxxxx@tcp(db:3306)/app" {
+		t.Fatalf("dsns = %q, dsn = %q", r.Database.Dsns, r.Database.Dsn)
+	}
+	if cfg.Database.Dsns["staging"] != "host=db user=app password=s3cret dbname=app" || cfg.Notifier.Webhook.Headers["Authorization"] != "Bearer s3cret" ||
+		cfg.Validation.ConfirmPolicy.Headers["X-Api-Key"] != "s3cret" {
+		t.Fatal("Redacted modified the original config")
+	}
+}
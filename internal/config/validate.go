@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// KnownEnvs are the values accepted for env.
+var KnownEnvs = []string{"development", "test", "staging", "production"}
+
+// LoggingDrivers are the values accepted for logging.driver.
+var LoggingDrivers = []string{"kafka", "rabbitmq"}
+
+// LoggingFormats are the values accepted for logging.format.
+var LoggingFormats = []string{"json", "text"}
+
+// ValidationError lists every problem Validate found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks a config and reports all of its problems at once as a
+// *ValidationError. Load runs it before filling in defaults, so an unset
+// logging.driver is accepted: it means kafka without brokers, which logs
+// locally only.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) { problems = append(problems, fmt.Sprintf(format, args...)) }
+
+	if !slices.Contains(KnownEnvs, c.Env) {
+		add("env: unknown environment %q (want one of %s)", c.Env, strings.Join(KnownEnvs, ", "))
+	}
+	db := c.Database
+	if db.Dsn == "" {
+		switch {
+		case len(db.Dsns) > 0:
+			add("no DSN for env %q: set database.dsns.%s or database.dsn", c.Env, strings.ToLower(c.Env))
+		case db.DsnEnv != "" || db.DsnFile != "":
+			add("no DSN: database.dsn_env, database.dsn_file and database.dsn are all empty")
+		default:
+			add("database.dsn is required")
+		}
+	}
+	switch log := c.Logging; log.Driver {
+	case "":
+	case "kafka":
+		if len(log.Kafka.Brokers) == 0 {
+			add("logging.kafka.brokers is required when logging.driver is kafka")
+		}
+	case "rabbitmq":
+		if log.RabbitMQ.URL == "" {
+			add("logging.rabbitmq.url is required when logging.driver is rabbitmq")
+		}
+	default:
+		add("logging.driver: unknown driver %q (want one of %s)", log.Driver, strings.Join(LoggingDrivers, ", "))
+	}
+	if f := c.Logging.Format; f != "" && !slices.Contains(LoggingFormats, f) {
+		add("logging.format: unknown format %q (want one of %s)", f, strings.Join(LoggingFormats, ", "))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
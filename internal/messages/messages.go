@@ -0,0 +1,116 @@
+// Package messages renders the user-facing outcome messages of the CLI and
+// the notifiers from Go templates that teams can override in config.
+package messages
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Template keys accepted in the messages config block.
+const (
+	UpSuccess       = "up_success"
+	UpNoChange      = "up_no_change"
+	UpFailure       = "up_failure"
+	DownSuccess     = "down_success"
+	DownFailure     = "down_failure"
+	RollbackSuccess = "rollback_success"
+	RollbackFailure = "rollback_failure"
+	CommitSuccess   = "commit_success"
+	CommitFailure   = "commit_failure"
+	Notification    = "notification"
+)
+
+// defaults reproduce the built-in wording. An empty template prints nothing.
+var defaults = map[string]string{
+	UpSuccess:       `{{.OK}} Migrations applied successfully.`,
+	UpNoChange:      `{{.OK}} No new migrations to apply.`,
+	UpFailure:       ``,
+	DownSuccess:     ``,
+	DownFailure:     ``,
+	RollbackSuccess: ``,
+	RollbackFailure: ``,
+	CommitSuccess: `{{.OK}} {{if eq .Scope "all"}}All applied migrations have been committed; strict hash checking is now enforced.` +
+		`{{else if eq .Scope "through"}}Migrations through version {{.Version}} have been committed.` +
+		`{{else}}Migration version {{.Version}} has been committed.{{end}}`,
+	CommitFailure: ``,
+	Notification: `{{.Status}} migration{{if .Version}} version {{.Version}}{{end}}{{if .DB}} on {{.DB}}{{end}}` +
+		`{{if .User}} by {{.User}}{{end}}{{if .Tags}} [{{join .Tags ", "}}]{{end}}{{if .Error}}: {{.Error}}{{end}}`,
+}
+
+// Result is the data a template is executed with.
+type Result struct {
+	// OK and Fail are the status markers for the output being written,
+	// emoji or ASCII depending on --color.
+	OK, Fail string
+	Status   string // success, fail, rollback, ...
+	Version  string
+	// Scope says what commit covered: "version", "through" or "all".
+	Scope    string
+	User     string
+	DB       string
+	Tags     []string
+	Duration time.Duration
+	Error    string
+}
+
+// Templates holds one parsed template per key.
+type Templates struct {
+	t map[string]*template.Template
+}
+
+var funcs = template.FuncMap{"join": strings.Join}
+
+// Default renders the built-in messages.
+var Default = mustNew(nil)
+
+func mustNew(overrides map[string]string) *Templates {
+	t, err := New(overrides)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// New parses overrides on top of the defaults. Unknown keys and templates
+// that do not parse are errors, so typos surface when config is loaded.
+func New(overrides map[string]string) (*Templates, error) {
+	t := &Templates{t: map[string]*template.Template{}}
+	for key, text := range defaults {
+		t.t[key] = template.Must(template.New(key).Funcs(funcs).Parse(text))
+	}
+	var unknown []string
+	for key, text := range overrides {
+		if _, ok := defaults[key]; !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+		tmpl, err := template.New(key).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("messages.%s: %w", key, err)
+		}
+		t.t[key] = tmpl
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown message templates: %s", strings.Join(unknown, ", "))
+	}
+	return t, nil
+}
+
+// Render executes the template for key. A template that fails at run time
+// falls back to the default so an outcome is never lost.
+func (t *Templates) Render(key string, r Result) string {
+	if t == nil {
+		t = Default
+	}
+	var buf bytes.Buffer
+	if err := t.t[key].Execute(&buf, r); err != nil && t != Default {
+		return Default.Render(key, r)
+	}
+	return buf.String()
+}
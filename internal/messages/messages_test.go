@@ -0,0 +1,69 @@
+package messages_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
+)
+
+func TestRenderCustomTemplates(t *testing.T) {
+	tmpl, err := messages.New(map[string]string{
+		messages.UpSuccess:     `🚀 {{.User}} shipped {{.DB}} to v{{.Version}}`,
+		messages.DownSuccess:   `{{.OK}} rolled everything back from v{{.Version}}`,
+		messages.CommitSuccess: `🔒 frozen ({{.Scope}}{{if ne .Scope "all"}} {{.Version}}{{end}})`,
+		messages.UpFailure:     `💥 up failed: {{.Error}}`,
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	r := messages.Result{OK: "[OK]", User: "alice", DB: "postgres", Version: "7"}
+	cases := []struct {
+		key  string
+		r    messages.Result
+		want string
+	}{
+		{messages.UpSuccess, r, "🚀 alice shipped postgres to v7"},
+		{messages.DownSuccess, r, "[OK] rolled everything back from v7"},
+		{messages.CommitSuccess, messages.Result{Scope: "through", Version: "3"}, "🔒 frozen (through 3)"},
+		{messages.CommitSuccess, messages.Result{Scope: "all"}, "🔒 frozen (all)"},
+		{messages.UpFailure, messages.Result{Error: errors.New("boom").Error()}, "💥 up failed: boom"},
+		// Keys without an override keep the default wording.
+		{messages.UpNoChange, r, "[OK] No new migrations to apply."},
+	}
+	for _, c := range cases {
+		if got := tmpl.Render(c.key, c.r); got != c.want {
+			t.Errorf("%s = %q, want %q", c.key, got, c.want)
+		}
+	}
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	got := messages.Default.Render(messages.CommitSuccess, messages.Result{OK: "✅", Scope: "version", Version: "2"})
+	if got != "✅ Migration version 2 has been committed." {
+		t.Fatalf("commit default = %q", got)
+	}
+	if got := messages.Default.Render(messages.DownSuccess, messages.Result{OK: "✅"}); got != "" {
+		t.Fatalf("down default = %q, want nothing", got)
+	}
+}
+
+func TestNewRejectsBadTemplates(t *testing.T) {
+	if _, err := messages.New(map[string]string{"up_sucess": "x"}); err == nil || !strings.Contains(err.Error(), "up_sucess") {
+		t.Fatalf("err = %v, want unknown key", err)
+	}
+	if _, err := messages.New(map[string]string{messages.UpSuccess: "{{.Nope"}); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func TestRenderFallsBackOnExecError(t *testing.T) {
+	tmpl, err := messages.New(map[string]string{messages.UpSuccess: "{{.Missing}}"})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if got := tmpl.Render(messages.UpSuccess, messages.Result{OK: "[OK]"}); got != "[OK] Migrations applied successfully." {
+		t.Fatalf("got %q, want the default", got)
+	}
+}
@@ -0,0 +1,164 @@
+// Package metrics holds the CLI's Prometheus-style metrics. It implements the
+// small subset kaeshi needs (labeled histograms and the text exposition
+// format) without depending on client_golang.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets extends the usual Prometheus buckets with minute-scale bounds
+// because migrations can run far longer than a request.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	counts []uint64 // per bucket, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec creates a histogram named name with the given label names
+// and DefaultBuckets.
+func NewHistogramVec(name, help string, labels ...string) *HistogramVec {
+	return &HistogramVec{name: name, help: help, labels: labels, buckets: DefaultBuckets, series: map[string]*series{}}
+}
+
+// Observe records v for the series identified by values, which must match
+// the label names in order.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	if len(values) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.name, len(h.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.series[key]
+	if s == nil {
+		s = &series{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations for the series identified by values.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s := h.series[strings.Join(values, "\xff")]; s != nil {
+		return s.count
+	}
+	return 0
+}
+
+// WriteText writes the histogram in the Prometheus text exposition format.
+func (h *HistogramVec) WriteText(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		var cum uint64
+		for i, bound := range h.buckets {
+			cum += s.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%s} %d\n", h.name, h.labelPairs(s.values, strconv.FormatFloat(bound, 'g', -1, 64)), cum)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s} %d\n", h.name, h.labelPairs(s.values, "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %s\n", h.name, h.labelPairs(s.values, ""), strconv.FormatFloat(s.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", h.name, h.labelPairs(s.values, ""), s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (h *HistogramVec) labelPairs(values []string, le string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", h.labels[i], v))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=%q", le))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// CommandDuration tracks how long each CLI command takes and whether it
+// succeeded.
+var CommandDuration = NewHistogramVec(
+	"kaeshi_command_duration_seconds",
+	"Duration of kaeshi CLI commands.",
+	"command", "outcome",
+)
+
+// Collector is a metric that can write itself in the text exposition format.
+type Collector interface {
+	WriteText(w io.Writer) error
+}
+
+// Registry is a set of metrics exported together.
+type Registry []Collector
+
+// Default holds every metric the CLI records.
+var Default = Registry{CommandDuration}
+
+// WriteText writes each metric of r in the text exposition format.
+func (r Registry) WriteText(w io.Writer) error {
+	for _, c := range r {
+		if err := c.WriteText(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes r to path, replacing the file atomically so a collector
+// never reads a partial exposition.
+func (r Registry) WriteFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".metrics-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := r.WriteText(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
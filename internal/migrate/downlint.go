@@ -0,0 +1,37 @@
+package migration
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+var (
+	reCreateTable = regexp.MustCompile(`(?i)\bCREATE\s+TABLE\b`)
+	reDropTable   = regexp.MustCompile(`(?i)\bDROP\s+TABLE\b`)
+	reAddColumn   = regexp.MustCompile(`(?i)\bADD\s+COLUMN\b`)
+	reDropColumn  = regexp.MustCompile(`(?i)\bDROP\s+COLUMN\b`)
+)
+
+// LintDown applies heuristics that catch a down migration copied from its up
+// migration instead of reversing it. It returns human readable warnings; an
+// empty result means nothing suspicious was found.
+func LintDown(up, down []byte) []string {
+	body := validate.StripComments(string(down))
+	if strings.TrimSpace(body) == "" {
+		return nil
+	}
+	var warnings []string
+	if bytes.Equal(bytes.TrimSpace(up), bytes.TrimSpace(down)) {
+		warnings = append(warnings, "up and down files are identical")
+	}
+	if reCreateTable.MatchString(body) && !reDropTable.MatchString(body) {
+		warnings = append(warnings, "down file creates a table but drops none; it may recreate instead of revert")
+	}
+	if reAddColumn.MatchString(body) && !reDropColumn.MatchString(body) {
+		warnings = append(warnings, "down file adds a column but drops none; it may recreate instead of revert")
+	}
+	return warnings
+}
@@ -0,0 +1,42 @@
+package migration_test
+
+import (
+	"strings"
+	"testing"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+)
+
+func TestLintDownIdentical(t *testing.T) {
+	sql := []byte("CREATE TABLE users (id int);\n")
+	warnings := migration.LintDown(sql, sql)
+	if len(warnings) == 0 || !strings.Contains(warnings[0], "identical") {
+		t.Fatalf("expected identical warning, got %v", warnings)
+	}
+}
+
+func TestLintDownCreateInDown(t *testing.T) {
+	up := []byte("CREATE TABLE users (id int);")
+	warnings := migration.LintDown(up, []byte("CREATE TABLE users (id int, name text);"))
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "creates a table") {
+		t.Fatalf("expected create-in-down warning, got %v", warnings)
+	}
+	warnings = migration.LintDown([]byte("ALTER TABLE users ADD COLUMN age int;"), []byte("ALTER TABLE users ADD COLUMN age int NULL;"))
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "adds a column") {
+		t.Fatalf("expected add-column warning, got %v", warnings)
+	}
+}
+
+func TestLintDownClean(t *testing.T) {
+	cases := [][2]string{
+		{"CREATE TABLE users (id int);", "DROP TABLE users;"},
+		{"ALTER TABLE users ADD COLUMN age int;", "ALTER TABLE users DROP COLUMN age;"},
+		// freshly generated stubs only carry the comment header
+		{"-- Author: a\n-- Write your SQL here\n", "-- Author: a\n-- Write your SQL here\n"},
+	}
+	for _, c := range cases {
+		if w := migration.LintDown([]byte(c[0]), []byte(c[1])); len(w) != 0 {
+			t.Fatalf("unexpected warnings for %q: %v", c[1], w)
+		}
+	}
+}
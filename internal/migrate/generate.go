@@ -5,22 +5,40 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// TableNames are the names of the version table golang-migrate maintains and
+// of kaeshi's history table.
+type TableNames struct {
+	Migrations string
+	History    string
+}
+
+// PrefixedTables returns the default table names with prefix prepended.
+func PrefixedTables(prefix string) TableNames {
+	return TableNames{Migrations: prefix + "schema_migrations", History: prefix + "migrations_history"}
+}
+
 // nextVersion checks both the DB and filesystem to determine the next migration version number.
-func nextVersion(db *sql.DB, dir string) (int, error) {
+// Versions in extra directories are counted too, since they share one sequence.
+func nextVersion(db *sql.DB, dir string, tables TableNames, extra ...string) (int, error) {
 	maxDB := 0
 	if db != nil {
 		err := db.Ping()
 		if err == nil {
-			_ = db.QueryRow(`SELECT COALESCE(MAX(version::int),0) FROM schema_migrations`).Scan(&maxDB)
+			_ = db.QueryRow(`SELECT COALESCE(MAX(version::int),0) FROM ` + quoteIdent(tables.Migrations)).Scan(&maxDB)
 		}
 	}
 
 	maxFS := 0
-	files, _ := filepath.Glob(filepath.Join(dir, "*.up.sql"))
+	var files []string
+	for _, d := range append([]string{dir}, extra...) {
+		matches, _ := filepath.Glob(filepath.Join(d, "*.up.sql"))
+		files = append(files, matches...)
+	}
 	for _, f := range files {
 		base := filepath.Base(f)
 		num := strings.SplitN(base, "_", 2)[0]
@@ -36,27 +54,169 @@ func nextVersion(db *sql.DB, dir string) (int, error) {
 	return maxFS + 1, nil
 }
 
-// Generate creates empty up and down SQL files with a unique next version number.
-// The author will be recorded in the SQL comment header.
-func Generate(path, name, author string, db *sql.DB) (string, error) {
+// maxAppliedVersion returns the highest version recorded in schema_migrations
+// or as an "up" in migrations_history, which may exceed the current version
+// after a rollback. Missing tables count as nothing applied.
+func maxAppliedVersion(db *sql.DB, tables TableNames) (int, error) {
+	if err := db.Ping(); err != nil {
+		return 0, err
+	}
+	maxV := 0
+	for _, q := range []string{
+		`SELECT version FROM ` + quoteIdent(tables.Migrations),
+		`SELECT version FROM ` + quoteIdent(tables.History) + ` WHERE action = 'up'`,
+	} {
+		rows, err := db.Query(q)
+		if err != nil {
+			continue
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			if v, err := strconv.Atoi(s); err == nil && v > maxV {
+				maxV = v
+			}
+		}
+		rows.Close()
+	}
+	return maxV, nil
+}
+
+// GenerateOption customizes Generate.
+type GenerateOption func(*generateConfig)
+
+type generateConfig struct {
+	strictOrder bool
+	tables      TableNames
+	versionDirs []string
+	template    string
+	dialect     string
+}
+
+// scaffold is the up and down SQL a template pre-fills below the header.
+// dialectDown replaces down for the database drivers whose syntax differs.
+type scaffold struct {
+	up, down    string
+	dialectDown map[string]string
+}
+
+// templates are the reversible skeletons Generate can pre-fill, by name. Each
+// down undoes its up, so an empty down is a deliberate choice.
+var templates = map[string]scaffold{
+	"create-table": {
+		up:   "CREATE TABLE table_name (\n    id BIGINT PRIMARY KEY\n);\n",
+		down: "DROP TABLE table_name;\n",
+	},
+	"add-column": {
+		up:   "ALTER TABLE table_name ADD COLUMN column_name TEXT;\n",
+		down: "ALTER TABLE table_name DROP COLUMN column_name;\n",
+	},
+	"create-index": {
+		up:   "CREATE INDEX index_name ON table_name (column_name);\n",
+		down: "DROP INDEX index_name;\n",
+		dialectDown: map[string]string{
+			"mysql": "DROP INDEX index_name ON table_name;\n",
+		},
+	},
+}
+
+// Templates returns the names accepted by WithTemplate, sorted.
+func Templates() []string {
+	names := make([]string, 0, len(templates))
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithStrictOrder makes Generate refuse a version at or below the highest
+// version ever applied to the database.
+func WithStrictOrder(on bool) GenerateOption {
+	return func(c *generateConfig) { c.strictOrder = on }
+}
+
+// WithTablePrefix reads applied versions from tables carrying prefix, matching
+// a Manager configured with the same prefix.
+func WithTablePrefix(prefix string) GenerateOption {
+	return func(c *generateConfig) { c.tables = PrefixedTables(prefix) }
+}
+
+// WithTableNames reads applied versions from the given tables, matching a
+// Manager configured with the same names.
+func WithTableNames(tables TableNames) GenerateOption {
+	return func(c *generateConfig) { c.tables = tables }
+}
+
+// WithTemplate pre-fills the up and down files with the named skeleton from
+// Templates instead of an empty placeholder.
+func WithTemplate(name string) GenerateOption {
+	return func(c *generateConfig) { c.template = name }
+}
+
+// WithDialect writes template SQL for the database driver, e.g. "mysql",
+// where its syntax differs from the default.
+func WithDialect(driver string) GenerateOption {
+	return func(c *generateConfig) { c.dialect = driver }
+}
+
+// WithVersionDirs numbers the new migration after the versions found in dirs
+// as well, for projects that merge several migration directories.
+func WithVersionDirs(dirs ...string) GenerateOption {
+	return func(c *generateConfig) { c.versionDirs = dirs }
+}
+
+// Generate creates up and down SQL files with a unique next version number,
+// empty unless WithTemplate picks a skeleton. The author will be recorded in
+// the SQL comment header.
+func Generate(path, name, author string, db *sql.DB, opts ...GenerateOption) (string, error) {
 	if name == "" {
 		return "", fmt.Errorf("name is required")
 	}
 	if author == "" {
 		author = "unknown"
 	}
+	cfg := generateConfig{tables: PrefixedTables("")}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	body := scaffold{up: "-- Write your SQL here\n", down: "-- Write your SQL here\n"}
+	if cfg.template != "" {
+		var ok bool
+		if body, ok = templates[cfg.template]; !ok {
+			return "", fmt.Errorf("unknown template %q (available: %s)", cfg.template, strings.Join(Templates(), ", "))
+		}
+		if down, ok := body.dialectDown[cfg.dialect]; ok {
+			body.down = down
+		}
+	}
 
-	version, err := nextVersion(db, path)
+	version, err := nextVersion(db, path, cfg.tables, cfg.versionDirs...)
 	if err != nil {
 		return "", err
 	}
+	if cfg.strictOrder {
+		if db == nil {
+			return "", fmt.Errorf("strict-order requires a database connection")
+		}
+		maxApplied, err := maxAppliedVersion(db, cfg.tables)
+		if err != nil {
+			return "", fmt.Errorf("strict-order: read applied versions: %w", err)
+		}
+		if version <= maxApplied {
+			return "", fmt.Errorf("strict-order: new version %06d is not above the highest applied version %d", version, maxApplied)
+		}
+	}
 
 	baseName := fmt.Sprintf("%06d_%s", version, name)
 	upFile := filepath.Join(path, baseName+".up.sql")
 	downFile := filepath.Join(path, baseName+".down.sql")
 
-	upContent := fmt.Sprintf("-- Author: %s\n-- Migration: %s\n-- Version: %06d\n\n-- Write your SQL here\n", author, name, version)
-	downContent := fmt.Sprintf("-- Author: %s\n-- Migration: %s\n-- Version: %06d\n\n-- Write your SQL here\n", author, name, version)
+	header := fmt.Sprintf("-- Author: %s\n-- Migration: %s\n-- Version: %06d\n\n", author, name, version)
+	upContent, downContent := header+body.up, header+body.down
 
 	if err := os.WriteFile(upFile, []byte(upContent), 0o644); err != nil {
 		return "", err
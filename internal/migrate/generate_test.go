@@ -0,0 +1,112 @@
+package migration_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+)
+
+// rolledBackDB returns a database currently at version 3 whose history shows
+// version 5 was applied before being rolled back.
+func rolledBackDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "gen.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, q := range []string{
+		`CREATE TABLE schema_migrations (version INTEGER, dirty BOOLEAN)`,
+		`INSERT INTO schema_migrations VALUES (3, false)`,
+		`CREATE TABLE migrations_history (action TEXT, version TEXT)`,
+		`INSERT INTO migrations_history VALUES ('up', '4'), ('up', '5'), ('down', '5'), ('down', '4')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	return db
+}
+
+func TestGenerateStrictOrderRejectsLowVersion(t *testing.T) {
+	db := rolledBackDB(t)
+	dir := t.TempDir()
+	for _, f := range []string{"000001_a.up.sql", "000002_b.up.sql", "000003_c.up.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := migration.Generate(dir, "late", "alice", db, migration.WithStrictOrder(true)); err == nil {
+		t.Fatal("expected strict-order to reject version 4 below applied version 5")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "000004_*")); len(files) != 0 {
+		t.Fatalf("strict-order must not write files, found %v", files)
+	}
+
+	name, err := migration.Generate(dir, "late", "alice", db)
+	if err != nil {
+		t.Fatalf("Generate without strict-order: %v", err)
+	}
+	if name != "000004_late" {
+		t.Fatalf("name = %s, want 000004_late", name)
+	}
+}
+
+func TestGenerateNumbersAcrossVersionDirs(t *testing.T) {
+	core, billing := t.TempDir(), t.TempDir()
+	for dir, f := range map[string]string{core: "000002_users.up.sql", billing: "000007_invoices.up.sql"} {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	name, err := migration.Generate(core, "orders", "alice", nil, migration.WithVersionDirs(core, billing))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if name != "000008_orders" {
+		t.Fatalf("name = %s, want 000008_orders", name)
+	}
+}
+
+func TestGenerateTemplatePrefillsReversibleDown(t *testing.T) {
+	dir := t.TempDir()
+	name, err := migration.Generate(dir, "create_users", "alice", nil, migration.WithTemplate("create-table"))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	up, _ := os.ReadFile(filepath.Join(dir, name+".up.sql"))
+	down, _ := os.ReadFile(filepath.Join(dir, name+".down.sql"))
+	if !strings.Contains(string(up), "CREATE TABLE table_name") || !strings.Contains(string(down), "DROP TABLE table_name;") {
+		t.Fatalf("up = %q\ndown = %q", up, down)
+	}
+	if w := migration.LintDown(up, down); len(w) != 0 {
+		t.Fatalf("LintDown warnings for the scaffold: %v", w)
+	}
+
+	for dialect, want := range map[string]string{
+		"postgres": "DROP INDEX index_name;",
+		"mysql":    "DROP INDEX index_name ON table_name;",
+	} {
+		name, err := migration.Generate(dir, "add_index_"+dialect, "alice", nil,
+			migration.WithTemplate("create-index"), migration.WithDialect(dialect))
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		down, _ := os.ReadFile(filepath.Join(dir, name+".down.sql"))
+		if !strings.Contains(string(down), want) {
+			t.Fatalf("%s create-index down = %q, want %q", dialect, down, want)
+		}
+	}
+
+	if _, err := migration.Generate(dir, "x", "alice", nil, migration.WithTemplate("drop-everything")); err == nil || !strings.Contains(err.Error(), "add-column, create-index, create-table") {
+		t.Fatalf("unknown template err = %v", err)
+	}
+}
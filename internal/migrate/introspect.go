@@ -0,0 +1,343 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Column describes a table column as reported by information_schema.
+type Column struct {
+	Name     string
+	Type     string
+	Nullable bool
+	Default  string
+}
+
+// Table describes a base table and its key constraints.
+type Table struct {
+	Name       string
+	Columns    []Column
+	PrimaryKey []string
+	Unique     map[string][]string // constraint name -> columns
+}
+
+// ForeignKey describes a foreign key constraint.
+type ForeignKey struct {
+	Name       string
+	Table      string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+}
+
+// Index is a secondary index that does not back a constraint.
+type Index struct {
+	Name  string
+	Table string
+	Def   string
+}
+
+// Schema is a best-effort snapshot of a database schema.
+type Schema struct {
+	Name        string
+	Tables      []*Table
+	ForeignKeys []ForeignKey
+	Indexes     []Index
+}
+
+// kaeshiTable reports whether name is a table kaeshi itself creates, which is
+// never part of a baseline.
+func kaeshiTable(name string, tables TableNames) bool {
+	return name == tables.Migrations || name == tables.History
+}
+
+// IntrospectPostgres reads tables, columns, key constraints, foreign keys and
+// indexes of schema from a Postgres database. Views, CHECK constraints,
+// triggers and functions are not captured. Tables named after kaeshi's own,
+// as given by own, are skipped.
+func IntrospectPostgres(db *sql.DB, schema string, own TableNames) (*Schema, error) {
+	s := &Schema{Name: schema}
+	tables := map[string]*Table{}
+
+	rows, err := db.Query(`SELECT table_name FROM information_schema.tables
+WHERE table_schema = $1 AND table_type = 'BASE TABLE' ORDER BY table_name`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if !kaeshiTable(name, own) {
+			t := &Table{Name: name, Unique: map[string][]string{}}
+			tables[name] = t
+			s.Tables = append(s.Tables, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`SELECT table_name, column_name, data_type, udt_name, character_maximum_length,
+numeric_precision, numeric_scale, is_nullable, column_default
+FROM information_schema.columns WHERE table_schema = $1 ORDER BY table_name, ordinal_position`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("list columns: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table, name, dataType, udt, nullable string
+		var charLen, precision, scale sql.NullInt64
+		var def sql.NullString
+		if err := rows.Scan(&table, &name, &dataType, &udt, &charLen, &precision, &scale, &nullable, &def); err != nil {
+			return err
+		}
+		if t := tables[table]; t != nil {
+			typ, dflt := columnType(dataType, udt, charLen, precision, scale, def.String)
+			t.Columns = append(t.Columns, Column{Name: name, Type: typ, Nullable: nullable == "YES", Default: dflt})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`SELECT tc.table_name, tc.constraint_name, tc.constraint_type, kcu.column_name
+FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu
+  ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name
+WHERE tc.table_schema = $1 AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE')
+ORDER BY tc.table_name, tc.constraint_name, kcu.ordinal_position`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("list key constraints: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var table, name, typ, col string
+		if err := rows.Scan(&table, &name, &typ, &col); err != nil {
+			return err
+		}
+		if t := tables[table]; t != nil {
+			if typ == "PRIMARY KEY" {
+				t.PrimaryKey = append(t.PrimaryKey, col)
+			} else {
+				t.Unique[name] = append(t.Unique[name], col)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`SELECT kcu.table_name, kcu.constraint_name, kcu.column_name, rk.table_name, rk.column_name, rc.delete_rule
+FROM information_schema.referential_constraints rc
+JOIN information_schema.key_column_usage kcu
+  ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name
+JOIN information_schema.key_column_usage rk
+  ON rk.constraint_schema = rc.unique_constraint_schema AND rk.constraint_name = rc.unique_constraint_name
+ AND rk.ordinal_position = kcu.position_in_unique_constraint
+WHERE rc.constraint_schema = $1
+ORDER BY kcu.table_name, kcu.constraint_name, kcu.ordinal_position`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("list foreign keys: %w", err)
+	}
+	fks := map[string]*ForeignKey{}
+	var fkOrder []string
+	err = scanRows(rows, func() error {
+		var table, name, col, refTable, refCol, onDelete string
+		if err := rows.Scan(&table, &name, &col, &refTable, &refCol, &onDelete); err != nil {
+			return err
+		}
+		if tables[table] == nil {
+			return nil
+		}
+		key := table + "." + name
+		fk := fks[key]
+		if fk == nil {
+			fk = &ForeignKey{Name: name, Table: table, RefTable: refTable, OnDelete: onDelete}
+			fks[key] = fk
+			fkOrder = append(fkOrder, key)
+		}
+		fk.Columns = append(fk.Columns, col)
+		fk.RefColumns = append(fk.RefColumns, refCol)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range fkOrder {
+		s.ForeignKeys = append(s.ForeignKeys, *fks[key])
+	}
+
+	rows, err = db.Query(`SELECT tablename, indexname, indexdef FROM pg_indexes
+WHERE schemaname = $1 AND indexname NOT IN (
+  SELECT constraint_name FROM information_schema.table_constraints WHERE table_schema = $1)
+ORDER BY tablename, indexname`, schema)
+	if err != nil {
+		return nil, fmt.Errorf("list indexes: %w", err)
+	}
+	err = scanRows(rows, func() error {
+		var idx Index
+		if err := rows.Scan(&idx.Table, &idx.Name, &idx.Def); err != nil {
+			return err
+		}
+		if tables[idx.Table] != nil {
+			s.Indexes = append(s.Indexes, idx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func scanRows(rows *sql.Rows, fn func() error) error {
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// columnType renders an information_schema type as DDL. Integer columns fed
+// by a sequence become SERIAL/BIGSERIAL and lose the nextval default.
+func columnType(dataType, udt string, charLen, precision, scale sql.NullInt64, def string) (string, string) {
+	if strings.HasPrefix(def, "nextval(") {
+		switch dataType {
+		case "integer":
+			return "SERIAL", ""
+		case "bigint":
+			return "BIGSERIAL", ""
+		case "smallint":
+			return "SMALLSERIAL", ""
+		}
+	}
+	switch {
+	case (dataType == "character varying" || dataType == "character") && charLen.Valid:
+		return fmt.Sprintf("%s(%d)", dataType, charLen.Int64), def
+	case dataType == "numeric" && precision.Valid:
+		return fmt.Sprintf("numeric(%d,%d)", precision.Int64, scale.Int64), def
+	case dataType == "ARRAY":
+		return strings.TrimPrefix(udt, "_") + "[]", def
+	case dataType == "USER-DEFINED":
+		return udt, def
+	}
+	return dataType, def
+}
+
+// UpSQL renders the schema as a baseline migration: all tables first, then
+// foreign keys as separate ALTER statements so creation order never matters,
+// then secondary indexes.
+func (s *Schema) UpSQL() string {
+	var b strings.Builder
+	for _, t := range s.Tables {
+		var defs []string
+		for _, c := range t.Columns {
+			def := quoteIdent(c.Name) + " " + c.Type
+			if !c.Nullable {
+				def += " NOT NULL"
+			}
+			if c.Default != "" {
+				def += " DEFAULT " + c.Default
+			}
+			defs = append(defs, def)
+		}
+		if len(t.PrimaryKey) > 0 {
+			defs = append(defs, "PRIMARY KEY ("+quoteIdents(t.PrimaryKey)+")")
+		}
+		names := make([]string, 0, len(t.Unique))
+		for name := range t.Unique {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			defs = append(defs, fmt.Sprintf("CONSTRAINT %s UNIQUE (%s)", quoteIdent(name), quoteIdents(t.Unique[name])))
+		}
+		fmt.Fprintf(&b, "CREATE TABLE %s (\n    %s\n);\n\n", quoteIdent(t.Name), strings.Join(defs, ",\n    "))
+	}
+	for _, fk := range s.ForeignKeys {
+		fmt.Fprintf(&b, "ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (%s)",
+			quoteIdent(fk.Table), quoteIdent(fk.Name), quoteIdents(fk.Columns), quoteIdent(fk.RefTable), quoteIdents(fk.RefColumns))
+		if fk.OnDelete != "" && fk.OnDelete != "NO ACTION" {
+			b.WriteString(" ON DELETE " + fk.OnDelete)
+		}
+		b.WriteString(";\n")
+	}
+	if len(s.ForeignKeys) > 0 {
+		b.WriteString("\n")
+	}
+	for _, idx := range s.Indexes {
+		b.WriteString(idx.Def + ";\n")
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+// DownSQL drops the baseline tables in reverse order.
+func (s *Schema) DownSQL() string {
+	var b strings.Builder
+	for i := len(s.Tables) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "DROP TABLE IF EXISTS %s CASCADE;\n", quoteIdent(s.Tables[i].Name))
+	}
+	return b.String()
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = quoteIdent(n)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// GenerateFromDB introspects schema in the Postgres database db and writes it
+// as the next migration version in path. The files are marked as requiring
+// review because introspection is best-effort.
+func GenerateFromDB(path, name, author string, db *sql.DB, schema string, opts ...GenerateOption) (string, error) {
+	if name == "" {
+		name = "baseline"
+	}
+	if author == "" {
+		author = "unknown"
+	}
+	cfg := generateConfig{tables: PrefixedTables("")}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	s, err := IntrospectPostgres(db, schema, cfg.tables)
+	if err != nil {
+		return "", err
+	}
+	if len(s.Tables) == 0 {
+		return "", fmt.Errorf("no tables found in schema %q", schema)
+	}
+	version, err := nextVersion(db, path, cfg.tables, cfg.versionDirs...)
+	if err != nil {
+		return "", err
+	}
+
+	baseName := fmt.Sprintf("%06d_%s", version, name)
+	header := fmt.Sprintf("-- Author: %s\n-- Migration: %s\n-- Version: %06d\n"+
+		"-- Generated from schema %q by generate-from-db.\n"+
+		"-- REVIEW REQUIRED: views, CHECK constraints, triggers, functions and grants are not included.\n\n",
+		author, name, version, schema)
+	if err := os.WriteFile(filepath.Join(path, baseName+".up.sql"), []byte(header+s.UpSQL()), 0o644); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(path, baseName+".down.sql"), []byte(header+s.DownSQL()), 0o644); err != nil {
+		return "", err
+	}
+	return baseName, nil
+}
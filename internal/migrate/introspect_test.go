@@ -0,0 +1,86 @@
+package migration_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+)
+
+// expectShopSchema feeds introspection queries describing users and orders,
+// where orders references users.
+func expectShopSchema(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("FROM information_schema.tables").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name"}).
+			AddRow("orders").AddRow("schema_migrations").AddRow("users"))
+	mock.ExpectQuery("FROM information_schema.columns").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "column_name", "data_type", "udt_name", "character_maximum_length", "numeric_precision", "numeric_scale", "is_nullable", "column_default"}).
+			AddRow("orders", "id", "bigint", "int8", nil, 64, 0, "NO", "nextval('orders_id_seq'::regclass)").
+			AddRow("orders", "user_id", "integer", "int4", nil, 32, 0, "NO", nil).
+			AddRow("orders", "total", "numeric", "numeric", nil, 10, 2, "YES", nil).
+			AddRow("orders", "tags", "ARRAY", "_text", nil, nil, nil, "YES", nil).
+			AddRow("orders", "created_at", "timestamp without time zone", "timestamp", nil, nil, nil, "NO", "now()").
+			AddRow("schema_migrations", "version", "bigint", "int8", nil, 64, 0, "NO", nil).
+			AddRow("users", "id", "integer", "int4", nil, 32, 0, "NO", "nextval('users_id_seq'::regclass)").
+			AddRow("users", "email", "character varying", "varchar", 255, nil, nil, "NO", nil))
+	mock.ExpectQuery("FROM information_schema.table_constraints").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "constraint_name", "constraint_type", "column_name"}).
+			AddRow("orders", "orders_pkey", "PRIMARY KEY", "id").
+			AddRow("users", "users_email_key", "UNIQUE", "email").
+			AddRow("users", "users_pkey", "PRIMARY KEY", "id"))
+	mock.ExpectQuery("FROM information_schema.referential_constraints").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"table_name", "constraint_name", "column_name", "ref_table", "ref_column", "delete_rule"}).
+			AddRow("orders", "orders_user_id_fkey", "user_id", "users", "id", "CASCADE"))
+	mock.ExpectQuery("FROM pg_indexes").WithArgs("public").
+		WillReturnRows(sqlmock.NewRows([]string{"tablename", "indexname", "indexdef"}).
+			AddRow("orders", "orders_created_at_idx", "CREATE INDEX orders_created_at_idx ON public.orders USING btree (created_at)"))
+}
+
+func TestIntrospectPostgres(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db: %v", err)
+	}
+	defer db.Close()
+	expectShopSchema(mock)
+
+	s, err := migration.IntrospectPostgres(db, "public", migration.PrefixedTables(""))
+	if err != nil {
+		t.Fatalf("introspect: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+	up := s.UpSQL()
+
+	for _, want := range []string{
+		"CREATE TABLE \"users\" (\n    \"id\" SERIAL NOT NULL,\n    \"email\" character varying(255) NOT NULL,\n    PRIMARY KEY (\"id\"),\n    CONSTRAINT \"users_email_key\" UNIQUE (\"email\")\n);",
+		"\"id\" BIGSERIAL NOT NULL",
+		"\"total\" numeric(10,2),",
+		"\"tags\" text[],",
+		"\"created_at\" timestamp without time zone NOT NULL DEFAULT now()",
+		"ALTER TABLE \"orders\" ADD CONSTRAINT \"orders_user_id_fkey\" FOREIGN KEY (\"user_id\") REFERENCES \"users\" (\"id\") ON DELETE CASCADE;",
+		"CREATE INDEX orders_created_at_idx ON public.orders USING btree (created_at);",
+	} {
+		if !strings.Contains(up, want) {
+			t.Fatalf("up SQL missing %q:\n%s", want, up)
+		}
+	}
+	if strings.Contains(up, "schema_migrations") || strings.Contains(up, "nextval") {
+		t.Fatalf("up SQL must skip kaeshi tables and sequence defaults:\n%s", up)
+	}
+	lastTable := strings.LastIndex(up, "CREATE TABLE")
+	if fk := strings.Index(up, "FOREIGN KEY"); fk < lastTable {
+		t.Fatalf("foreign keys must follow all tables:\n%s", up)
+	}
+	if idx := strings.Index(up, "CREATE INDEX"); idx < strings.Index(up, "FOREIGN KEY") {
+		t.Fatalf("indexes must follow foreign keys:\n%s", up)
+	}
+
+	down := s.DownSQL()
+	if down != "DROP TABLE IF EXISTS \"users\" CASCADE;\nDROP TABLE IF EXISTS \"orders\" CASCADE;\n" {
+		t.Fatalf("down SQL = %q", down)
+	}
+}
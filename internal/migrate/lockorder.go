@@ -0,0 +1,93 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// reLockTarget matches statements that lock an existing table, capturing the
+// table name. CREATE TABLE is left out: a new table cannot be contended.
+var reLockTarget = regexp.MustCompile(`(?is)\b(?:` +
+	`ALTER\s+TABLE(?:\s+IF\s+EXISTS)?(?:\s+ONLY)?|` +
+	`DROP\s+TABLE(?:\s+IF\s+EXISTS)?|` +
+	`TRUNCATE(?:\s+TABLE)?(?:\s+ONLY)?|` +
+	`LOCK(?:\s+TABLE)?(?:\s+ONLY)?|` +
+	`INSERT\s+INTO|` +
+	`UPDATE(?:\s+ONLY)?|` +
+	`DELETE\s+FROM(?:\s+ONLY)?|` +
+	`REFERENCES|` +
+	`CREATE\s+(?:UNIQUE\s+)?INDEX\b[^;]*?\bON(?:\s+ONLY)?` +
+	`)\s+("(?:[^"]|"")+"(?:\."(?:[^"]|"")+")?|[A-Za-z_][\w$]*(?:\.[A-Za-z_][\w$]*)?)`)
+
+// notTables are words that follow UPDATE or REFERENCES in clauses such as
+// ON UPDATE CASCADE, FOR UPDATE SKIP LOCKED or DO UPDATE SET.
+var notTables = map[string]bool{
+	"set": true, "cascade": true, "restrict": true, "no": true,
+	"of": true, "skip": true, "nowait": true,
+}
+
+// LockedTables returns the existing tables a migration locks, in the order
+// their first lock is taken.
+func LockedTables(sqlText []byte) []string {
+	body := validate.StripComments(string(sqlText))
+	seen := map[string]bool{}
+	var order []string
+	for _, m := range reLockTarget.FindAllStringSubmatch(body, -1) {
+		name := strings.ToLower(strings.ReplaceAll(m[1], `"`, ""))
+		if notTables[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+	return order
+}
+
+// LintLockOrder warns when a migration locks two tables in the opposite order
+// of one of the recent migrations, keyed by file name. Two transactions doing
+// that concurrently can deadlock. It is a heuristic over statement text only.
+func LintLockOrder(sqlText []byte, recent map[string][]byte) []string {
+	order := LockedTables(sqlText)
+	if len(order) < 2 {
+		return nil
+	}
+	pos := map[string]int{}
+	for i, t := range order {
+		pos[t] = i
+	}
+	names := make([]string, 0, len(recent))
+	for name := range recent {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var warnings []string
+	for _, name := range names {
+		other := LockedTables(recent[name])
+		if a, b, ok := firstInversion(pos, other); ok {
+			warnings = append(warnings, fmt.Sprintf(
+				"locks %s before %s but %s locks them in the opposite order; concurrent runs could deadlock", a, b, name))
+		}
+	}
+	return warnings
+}
+
+// firstInversion finds tables x before y in other that come in the reverse
+// order in pos, and returns them in pos order.
+func firstInversion(pos map[string]int, other []string) (string, string, bool) {
+	for i, x := range other {
+		px, ok := pos[x]
+		if !ok {
+			continue
+		}
+		for _, y := range other[i+1:] {
+			if py, ok := pos[y]; ok && py < px {
+				return y, x, true
+			}
+		}
+	}
+	return "", "", false
+}
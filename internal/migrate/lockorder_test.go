@@ -0,0 +1,40 @@
+package migration_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+)
+
+func TestLockedTables(t *testing.T) {
+	sql := []byte(`-- touches orders first
+UPDATE orders SET total = 0;
+CREATE TABLE audit (id int REFERENCES "Users"(id) ON UPDATE CASCADE);
+INSERT INTO public.items (id) VALUES (1) ON CONFLICT (id) DO UPDATE SET id = 2;
+CREATE INDEX idx_orders ON orders (total);
+DELETE FROM items WHERE id = 3;`)
+	want := []string{"orders", "users", "public.items", "items"}
+	if got := migration.LockedTables(sql); !reflect.DeepEqual(got, want) {
+		t.Fatalf("LockedTables = %v, want %v", got, want)
+	}
+}
+
+func TestLintLockOrder(t *testing.T) {
+	recent := map[string][]byte{
+		"000001_a.up.sql": []byte("UPDATE accounts SET x = 1;\nUPDATE ledger SET y = 2;"),
+		"000002_b.up.sql": []byte("ALTER TABLE other ADD COLUMN z int;"),
+	}
+	warnings := migration.LintLockOrder([]byte("LOCK TABLE ledger;\nALTER TABLE accounts ADD COLUMN w int;"), recent)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "ledger before accounts") || !strings.Contains(warnings[0], "000001_a.up.sql") {
+		t.Fatalf("expected a single inversion warning, got %v", warnings)
+	}
+
+	if w := migration.LintLockOrder([]byte("UPDATE accounts SET x = 1;\nDELETE FROM ledger;"), recent); len(w) != 0 {
+		t.Fatalf("consistent order must not warn, got %v", w)
+	}
+	if w := migration.LintLockOrder([]byte("UPDATE ledger SET y = 2;"), recent); len(w) != 0 {
+		t.Fatalf("single-table migration must not warn, got %v", w)
+	}
+}
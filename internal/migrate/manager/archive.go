@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// WithFS makes the Manager read migrations from fsys instead of the
+// migrations directory.
+func WithFS(fsys fs.FS) Option {
+	return func(mgr *Manager) { mgr.fsys = fsys }
+}
+
+// OpenArchive loads a .zip, .tar.gz or .tgz migrations artifact from disk.
+func OpenArchive(file string) (fs.FS, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read archive: %w", err)
+	}
+	return ArchiveFS(file, data)
+}
+
+// ArchiveFS exposes the archive contents in data as an fs.FS. The format is
+// chosen from the extension of name. When the archive holds a single top-level
+// directory and no migrations at its root, that directory is used as the root.
+func ArchiveFS(name string, data []byte) (fs.FS, error) {
+	var fsys fs.FS
+	var err error
+	switch lower := strings.ToLower(name); {
+	case strings.HasSuffix(lower, ".zip"):
+		fsys, err = zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		fsys, err = tarGzFS(data)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open archive %s: %w", name, err)
+	}
+	return archiveRoot(fsys)
+}
+
+// tarGzFS repacks a gzip-compressed tarball into an in-memory zip so both
+// formats share the zip reader's fs.FS implementation.
+func tarGzFS(data []byte) (fs.FS, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		w, err := zw.Create(path.Clean(strings.TrimPrefix(hdr.Name, "./")))
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
+
+func archiveRoot(fsys fs.FS) (fs.FS, error) {
+	if files, _ := fs.Glob(fsys, "*.sql"); len(files) > 0 {
+		return fsys, nil
+	}
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return fs.Sub(fsys, entries[0].Name())
+	}
+	return fsys, nil
+}
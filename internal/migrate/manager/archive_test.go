@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"testing"
+)
+
+var archiveFiles = map[string]string{
+	"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+	"000001_a.down.sql": "DROP TABLE a;",
+	"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+	"000002_b.down.sql": "DROP TABLE b;",
+}
+
+func zipArchive(t *testing.T, prefix string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(prefix + name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		tw.WriteHeader(&tar.Header{Name: "./" + name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestArchiveFSFormats(t *testing.T) {
+	cases := map[string][]byte{
+		"migrations.zip":    zipArchive(t, "", archiveFiles),
+		"nested.zip":        zipArchive(t, "migrations/", archiveFiles),
+		"migrations.tar.gz": tarGzArchive(t, archiveFiles),
+	}
+	for name, data := range cases {
+		fsys, err := ArchiveFS(name, data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		ups, _ := fs.Glob(fsys, "*.up.sql")
+		if len(ups) != 2 {
+			t.Fatalf("%s: expected 2 up files, got %v", name, ups)
+		}
+	}
+	if _, err := ArchiveFS("migrations.rar", nil); err == nil {
+		t.Fatal("expected unsupported format error")
+	}
+}
+
+func TestUpFromArchive(t *testing.T) {
+	fsys, err := ArchiveFS("migrations.zip", zipArchive(t, "", archiveFiles))
+	if err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	mgr := newTestManager(t, nil, WithFS(fsys))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("expected version 2, got %d", v)
+	}
+	var hash string
+	if err := mgr.db.QueryRow(`SELECT sha256 FROM migrations_history WHERE version = '2'`).Scan(&hash); err != nil {
+		t.Fatalf("query hash: %v", err)
+	}
+	want := fmt.Sprintf("%x", sha256.Sum256([]byte(archiveFiles["000002_b.up.sql"])))
+	if hash != want {
+		t.Fatalf("hash = %s, want %s", hash, want)
+	}
+}
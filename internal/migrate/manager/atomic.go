@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// TransactionalDDLBackend is implemented by backends whose DDL is
+// transactional, so that a migration, its version row and its history row
+// can commit together.
+type TransactionalDDLBackend interface {
+	TransactionalDDL() bool
+}
+
+// beforeAtomicCommit, when set, runs just before applyAtomic commits. Tests
+// use it to simulate a crash between the migration and its commit.
+var beforeAtomicCommit func() error
+
+// historyInTransaction reports whether applied migrations are recorded in
+// their own transaction. Otherwise history is written after the migration
+// commits, and a crash in between leaves an applied version without a row.
+func (mgr *Manager) historyInTransaction() bool {
+	if !mgr.recordHist {
+		return false
+	}
+	td, ok := mgr.backend.(TransactionalDDLBackend)
+	return ok && td.TransactionalDDL()
+}
+
+// rolledBackError is a failure of atomicTx whose transaction was rolled back,
+// so nothing of the migration was applied.
+type rolledBackError struct{ err error }
+
+func (e *rolledBackError) Error() string { return e.err.Error() }
+
+func (e *rolledBackError) Unwrap() error { return e.err }
+
+// applyAtomic runs f, sets the schema version to v and inserts the history
+// row in one transaction: either all of them commit or none does. The version
+// is marked dirty beforehand, as golang-migrate does, so a crash before the
+// commit leaves the usual dirty state to repair; a failure whose rollback
+// succeeded restores the previous version instead. Without history only the
+// migration and version commit together. Under WithDDLLockTimeout a lock
+// timeout rolls the transaction back and tries it again; this loop is the
+// only retry layer for lock timeouts, so once its attempts run out the error
+// is a noRetryError. Callers hold the migration lock.
+func (mgr *Manager) applyAtomic(v uint, f string, run fileRun) (fileRun, error) {
+	content, err := fs.ReadFile(mgr.fsys, f)
+	if err != nil {
+		return run, fmt.Errorf("read %s: %w", f, err)
+	}
+	if mgr.recordHist {
+		mgr.ensureHistoryColumns()
+	}
+	if err := mgr.driver.SetVersion(int(v), true); err != nil {
+		return run, fmt.Errorf("mark version %d dirty: %w", v, err)
+	}
+	lockTimeout := mgr.ddlLockTimeoutFor(string(content))
+	for attempt := 1; ; attempt++ {
+		out, err := mgr.atomicTx(v, f, string(content), run, lockTimeout)
+		if err == nil {
+			return out, nil
+		}
+		if lockTimeout > 0 && mgr.retryLockTimeout(v, attempt, err) {
+			continue
+		}
+		rb := (*rolledBackError)(nil)
+		if !errors.As(err, &rb) {
+			return out, err
+		}
+		// Nothing was applied, so there is no dirty state to repair.
+		mgr.restoreVersion(run.tr.from)
+		if lockTimeout > 0 && mgr.lockTimeoutBackend().IsLockTimeout(rb.err) {
+			err := fmt.Errorf("migration %d could not get its table locks within %s in %d attempt(s): %w", v, lockTimeout, attempt, rb.err)
+			return out, &noRetryError{err, "lock timeouts were already retried"}
+		}
+		return out, rb.err
+	}
+}
+
+// restoreVersion sets the schema version back to from after a migration
+// marked dirty was rolled back.
+func (mgr *Manager) restoreVersion(from dbState) {
+	prev := database.NilVersion
+	if from.version.Valid {
+		prev = int(from.version.Int64)
+	}
+	if err := mgr.driver.SetVersion(prev, from.dirty); err != nil {
+		mgr.logger.WithError(err).Warn("cannot restore version after rollback")
+	}
+}
+
+// atomicTx is one attempt of applyAtomic. Failures before the commit are
+// rolledBackErrors once the rollback succeeded.
+func (mgr *Manager) atomicTx(v uint, f, content string, run fileRun, lockTimeout time.Duration) (_ fileRun, err error) {
+	tx, err := mgr.db.BeginTx(mgr.context(), nil)
+	if err != nil {
+		return run, &rolledBackError{fmt.Errorf("begin transaction for version %d: %w", v, err)}
+	}
+	committing := false
+	defer func() {
+		if err == nil || committing {
+			return
+		}
+		// ErrTxDone: the context ended and database/sql already rolled back.
+		if rerr := tx.Rollback(); rerr == nil || errors.Is(rerr, sql.ErrTxDone) {
+			err = &rolledBackError{err}
+		}
+	}()
+	if lockTimeout > 0 {
+		if _, err := tx.Exec(mgr.lockTimeoutBackend().LockTimeoutSQL(lockTimeout)); err != nil {
+			return run, fmt.Errorf("set lock timeout for version %d: %w", v, err)
+		}
+	}
+	if _, err := tx.ExecContext(mgr.context(), content); err != nil {
+		return run, fmt.Errorf("migration %d failed: %w", v, err)
+	}
+	versions := mgr.quote(mgr.MigrationsTable())
+	if _, err := tx.Exec(`DELETE FROM ` + versions); err != nil {
+		return run, fmt.Errorf("set version %d: %w", v, err)
+	}
+	if _, err := tx.Exec(mgr.rebind(`INSERT INTO `+versions+` (version, dirty) VALUES ($1, $2)`), int64(v), false); err != nil {
+		return run, fmt.Errorf("set version %d: %w", v, err)
+	}
+	run.tr.to = dbState{version: sql.NullInt64{Int64: int64(v), Valid: true}}
+	run.duration = time.Since(run.started)
+	var hash string
+	if mgr.recordHist {
+		if hash, err = mgr.insertApplied(tx, v, f, run); err != nil {
+			return run, fmt.Errorf("record history for version %d: %w", v, err)
+		}
+	}
+	if beforeAtomicCommit != nil {
+		if err := beforeAtomicCommit(); err != nil {
+			return run, err
+		}
+	}
+	committing = true
+	if err := tx.Commit(); err != nil {
+		return run, fmt.Errorf("commit version %d: %w", v, err)
+	}
+	if mgr.recordHist {
+		run.recorded = true
+		mgr.logRecorded(v, f, run, hash)
+	}
+	return run, nil
+}
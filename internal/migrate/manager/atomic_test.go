@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// transactionalSQLiteBackend opts the SQLite backend, whose DDL is
+// transactional like PostgreSQL's, into applyAtomic.
+type transactionalSQLiteBackend struct{ SQLiteBackend }
+
+func (transactionalSQLiteBackend) TransactionalDDL() bool { return true }
+
+var atomicMigrations = map[string]string{
+	"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);\nCREATE TABLE emails (id INTEGER PRIMARY KEY);",
+	"0001_create_users.down.sql": "DROP TABLE emails;\nDROP TABLE users;",
+}
+
+func TestAtomicApplyRecordsHistoryWithMigration(t *testing.T) {
+	mgr := newTestManager(t, atomicMigrations)
+	mgr.backend = transactionalSQLiteBackend{}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, dirty, err := mgr.Version(); err != nil || v != 1 || dirty {
+		t.Fatalf("version = %d dirty %v err %v, want clean 1", v, dirty, err)
+	}
+	if got := historyRows(t, mgr); len(got) != 1 || got[0] != "up:1" {
+		t.Fatalf("history = %v, want exactly one up:1 row", got)
+	}
+	var after int
+	var inTx bool
+	if err := mgr.db.QueryRow(`SELECT version_after, in_transaction FROM migrations_history`).Scan(&after, &inTx); err != nil {
+		t.Fatal(err)
+	}
+	if after != 1 || !inTx {
+		t.Fatalf("version_after = %d in_transaction = %v", after, inTx)
+	}
+}
+
+func TestAtomicApplyCrashBeforeCommit(t *testing.T) {
+	mgr := newTestManager(t, atomicMigrations)
+	mgr.backend = transactionalSQLiteBackend{}
+	crash := errors.New("simulated crash")
+	beforeAtomicCommit = func() error { return crash }
+	t.Cleanup(func() { beforeAtomicCommit = nil })
+
+	if err := mgr.Up(); !errors.Is(err, crash) {
+		t.Fatalf("Up err = %v, want simulated crash", err)
+	}
+	// Neither the migration nor its history row may survive.
+	if got := historyRows(t, mgr); len(got) != 0 {
+		t.Fatalf("history = %v, want none", got)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('users', 'emails')`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("users table exists = %d (%v), want rolled back", n, err)
+	}
+	// The rollback succeeded, so there is no dirty state to repair.
+	if _, dirty, err := mgr.Version(); !errors.Is(err, migrate.ErrNilVersion) || dirty {
+		t.Fatalf("version err = %v dirty %v, want no version", err, dirty)
+	}
+}
+
+func TestAtomicApplyRestoresPreviousVersionUnderLock(t *testing.T) {
+	files := map[string]string{
+		"0002_add_posts.up.sql":   "CREATE TABLE posts (id INTEGER PRIMARY KEY);",
+		"0002_add_posts.down.sql": "DROP TABLE posts;",
+	}
+	for name, sql := range atomicMigrations {
+		files[name] = sql
+	}
+	mgr := newTestManager(t, files)
+	mgr.backend = transactionalSQLiteBackend{}
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	crash := errors.New("simulated failure")
+	locked := false
+	beforeAtomicCommit = func() error {
+		locked = mgr.held.held.Load()
+		return crash
+	}
+	t.Cleanup(func() { beforeAtomicCommit = nil })
+
+	if err := mgr.Up(); !errors.Is(err, crash) {
+		t.Fatalf("Up err = %v, want simulated failure", err)
+	}
+	if !locked {
+		t.Fatal("migration ran without holding the migration lock")
+	}
+	if v, dirty, err := mgr.Version(); err != nil || v != 1 || dirty {
+		t.Fatalf("version = %d dirty %v err %v, want clean 1 restored", v, dirty, err)
+	}
+}
@@ -12,6 +12,9 @@ type DBBackend interface {
 	DriverName() string
 	NewDriver(db *sql.DB) (database.Driver, error)
 	Validator() validate.Dialect
+	// Placeholder returns the n-th bind parameter, counting from 1, e.g. $1
+	// for PostgreSQL or ? for MySQL and SQLite.
+	Placeholder(n int) string
 }
 
 var backends = map[string]DBBackend{}
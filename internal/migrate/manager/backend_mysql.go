@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/golang-migrate/migrate/v4/database"
+	mmysql "github.com/golang-migrate/migrate/v4/database/mysql"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	mysqldialect "github.com/lenhattri/kaeshi-migrate/pkg/validate/mysql"
+)
+
+// MySQLBackend implements DBBackend for MySQL and MariaDB. MySQL commits DDL
+// implicitly, so migrations never run atomically with their history row.
+// Migrations holding several statements need multiStatements=true in the DSN.
+type MySQLBackend struct{}
+
+func (MySQLBackend) DriverName() string { return "mysql" }
+
+func (MySQLBackend) NewDriver(db *sql.DB) (database.Driver, error) {
+	return mmysql.WithInstance(db, &mmysql.Config{})
+}
+
+// NewDriverWithTable is NewDriver tracking versions in table.
+func (MySQLBackend) NewDriverWithTable(db *sql.DB, table string) (database.Driver, error) {
+	return mmysql.WithInstance(db, &mmysql.Config{MigrationsTable: table})
+}
+
+func (MySQLBackend) Validator() validate.Dialect { return mysqldialect.Dialect{} }
+
+// QuoteIdent quotes with backticks, which work whatever the sql_mode.
+func (MySQLBackend) QuoteIdent(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// Placeholder returns MySQL's positional ? parameter.
+func (MySQLBackend) Placeholder(int) string { return "?" }
+
+// IsMissingTable reports MySQL's ER_NO_SUCH_TABLE error (1146).
+func (MySQLBackend) IsMissingTable(err error) bool {
+	var myErr *mysql.MySQLError
+	return errors.As(err, &myErr) && myErr.Number == 1146
+}
+
+func init() {
+	RegisterBackend("mysql", MySQLBackend{})
+}
@@ -1,27 +1,286 @@
 package manager
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4/database"
 	mpostgres "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
 	pgdialect "github.com/lenhattri/kaeshi-migrate/pkg/validate/postgres"
 )
 
 // PostgresBackend implements DBBackend for PostgreSQL databases.
-type PostgresBackend struct{}
+type PostgresBackend struct {
+	// AdvisoryLockID replaces the advisory lock key golang-migrate derives
+	// from the database, schema and migrations table names. Managers with
+	// different ids never wait for each other; zero keeps the derived key.
+	AdvisoryLockID int64
+}
 
 func (PostgresBackend) DriverName() string { return "postgres" }
 
-func (PostgresBackend) NewDriver(db *sql.DB) (database.Driver, error) {
-	return mpostgres.WithInstance(db, &mpostgres.Config{})
+func (b PostgresBackend) NewDriver(db *sql.DB) (database.Driver, error) {
+	return b.NewDriverWithTable(db, mpostgres.DefaultMigrationsTable)
+}
+
+// NewDriverWithTable is NewDriver tracking versions in table.
+//
+// golang-migrate creates the version table under a session advisory lock.
+// The driver is built inside one transaction so that lock and its release
+// run on the same server session even behind PgBouncer's transaction
+// pooling, where they could otherwise land on different sessions and leak
+// the lock.
+func (b PostgresBackend) NewDriverWithTable(db *sql.DB, table string) (database.Driver, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &mpostgres.Config{MigrationsTable: table}
+	const query = `SELECT current_database(), current_schema()`
+	if err := conn.QueryRowContext(ctx, query).Scan(&cfg.DatabaseName, &cfg.SchemaName); err != nil {
+		conn.Close()
+		return nil, &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	if _, err := conn.ExecContext(ctx, `BEGIN`); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	driver, err := mpostgres.WithConnection(ctx, conn, cfg)
+	if err != nil {
+		_, _ = conn.ExecContext(ctx, `ROLLBACK`)
+		conn.Close()
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `COMMIT`); err != nil {
+		driver.Close()
+		return nil, err
+	}
+	return &advisoryLockDriver{Driver: driver, db: db, id: b.lockID(cfg.DatabaseName, cfg.SchemaName, table)}, nil
+}
+
+// lockID returns AdvisoryLockID, or else the key golang-migrate derives for
+// the version table, so older releases and the migrate CLI still wait for
+// this one.
+func (b PostgresBackend) lockID(databaseName, schema, table string) int64 {
+	if b.AdvisoryLockID != 0 {
+		return b.AdvisoryLockID
+	}
+	key, _ := database.GenerateAdvisoryLockId(databaseName, schema, table)
+	id, _ := strconv.ParseInt(key, 10, 64)
+	return id
+}
+
+// MaxOpenConns leaves room for the dedicated connection that holds the
+// advisory lock next to the one golang-migrate keeps and one for history.
+func (PostgresBackend) MaxOpenConns() int { return 3 }
+
+// advisoryLockDriver takes the session advisory lock on a connection kept
+// until Unlock, so the lock and its release share a session, and can try the
+// lock without blocking so a bounded wait leaves nothing in flight.
+type advisoryLockDriver struct {
+	database.Driver
+	db   *sql.DB
+	id   int64
+	conn *sql.Conn
+}
+
+func (d *advisoryLockDriver) Lock() error {
+	if d.conn != nil {
+		return database.ErrLocked
+	}
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	const query = `SELECT pg_advisory_lock($1)`
+	if _, err := conn.ExecContext(ctx, query, d.id); err != nil {
+		conn.Close()
+		return &database.Error{OrigErr: err, Err: "try lock failed", Query: []byte(query)}
+	}
+	d.conn = conn
+	return nil
+}
+
+// TryLock takes the lock if it is free and reports whether it did.
+func (d *advisoryLockDriver) TryLock() (bool, error) {
+	if d.conn != nil {
+		return false, database.ErrLocked
+	}
+	ctx := context.Background()
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	const query = `SELECT pg_try_advisory_lock($1)`
+	var ok bool
+	if err := conn.QueryRowContext(ctx, query, d.id).Scan(&ok); err != nil {
+		conn.Close()
+		return false, &database.Error{OrigErr: err, Err: "try lock failed", Query: []byte(query)}
+	}
+	if !ok {
+		conn.Close()
+		return false, nil
+	}
+	d.conn = conn
+	return true, nil
+}
+
+func (d *advisoryLockDriver) Unlock() error {
+	if d.conn == nil {
+		return nil
+	}
+	conn := d.conn
+	d.conn = nil
+	defer conn.Close()
+	const query = `SELECT pg_advisory_unlock($1)`
+	if _, err := conn.ExecContext(context.Background(), query, d.id); err != nil {
+		return &database.Error{OrigErr: err, Query: []byte(query)}
+	}
+	return nil
 }
 
 func (PostgresBackend) Validator() validate.Dialect { return pgdialect.Dialect{} }
 
+// Placeholder returns PostgreSQL's numbered $n parameter.
+func (PostgresBackend) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// TransactionalDDL reports that PostgreSQL rolls back DDL with its
+// transaction, so migrations commit together with their history row.
+func (PostgresBackend) TransactionalDDL() bool { return true }
+
+// LockTimeoutSQL uses SET LOCAL so the timeout ends with the transaction and
+// never leaks to other clients of a transaction-pooled connection.
+func (PostgresBackend) LockTimeoutSQL(d time.Duration) string {
+	return fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", d.Milliseconds())
+}
+
+// StatementTimeoutSQL uses SET LOCAL for the same reason as LockTimeoutSQL.
+func (PostgresBackend) StatementTimeoutSQL(d time.Duration) string {
+	return fmt.Sprintf("SET LOCAL statement_timeout = '%dms'", d.Milliseconds())
+}
+
+// IsLockTimeout reports PostgreSQL's lock_not_available error (55P03).
+func (PostgresBackend) IsLockTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "55P03"
+}
+
+// IsMissingTable reports PostgreSQL's undefined_table error (42P01).
+func (PostgresBackend) IsMissingTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "42P01"
+}
+
+// LockHolder reports the session holding an advisory lock that another session
+// in the current database is waiting on.
+func (PostgresBackend) LockHolder(db *sql.DB) (string, error) {
+	return scanLockHolder(db.QueryRow(`
+SELECT h.pid, COALESCE(a.usename, ''), COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), ''), a.backend_start
+FROM pg_locks w
+JOIN pg_locks h ON h.locktype = 'advisory' AND h.granted AND h.pid <> w.pid
+	AND h.database = w.database AND h.classid = w.classid AND h.objid = w.objid AND h.objsubid = w.objsubid
+JOIN pg_stat_activity a ON a.pid = h.pid
+WHERE w.locktype = 'advisory' AND NOT w.granted
+	AND w.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+LIMIT 1`))
+}
+
+// currentHolder describes the session holding the advisory lock d takes,
+// or returns "" when it is free. Unlike LockHolder it needs no waiting
+// session, so it works while TryLock polls. pg_locks shows a bigint key as
+// its high and low 32 bits, both unsigned, so they are split here rather
+// than with SQL shifts that keep the sign of a negative id.
+func (d *advisoryLockDriver) currentHolder() (string, error) {
+	classid, objid := int64(uint64(d.id)>>32), int64(uint32(d.id))
+	return scanLockHolder(d.db.QueryRow(`
+SELECT a.pid, COALESCE(a.usename, ''), COALESCE(a.application_name, ''), COALESCE(host(a.client_addr), ''), a.backend_start
+FROM pg_locks l
+JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+	AND l.classid::bigint = $1 AND l.objid::bigint = $2
+	AND l.database = (SELECT oid FROM pg_database WHERE datname = current_database())
+LIMIT 1`, classid, objid))
+}
+
+// scanLockHolder formats the pid, user, application, client address and
+// backend start of a lock holding session.
+func scanLockHolder(row *sql.Row) (string, error) {
+	var pid int
+	var user, app, addr string
+	var since time.Time
+	err := row.Scan(&pid, &user, &app, &addr, &since)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pid=%d user=%s application=%s client=%s since=%s", pid, user, app, addr, since.Format(time.RFC3339)), nil
+}
+
+// CheckPrivileges creates and alters a probe table in the current schema
+// inside a transaction that is always rolled back.
+func (PostgresBackend) CheckPrivileges(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	probes := []struct{ op, sql string }{
+		{"CREATE TABLE", `CREATE TABLE kaeshi_privilege_probe (id integer)`},
+		{"ALTER TABLE", `ALTER TABLE kaeshi_privilege_probe ADD COLUMN probe integer`},
+	}
+	for _, p := range probes {
+		if _, err := tx.Exec(p.sql); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code == "42501" { // insufficient_privilege
+				return &PrivilegeError{Op: p.op, Err: err}
+			}
+			return fmt.Errorf("privilege preflight %s: %w", p.op, err)
+		}
+	}
+	return nil
+}
+
+// SchemaObjects lists the columns, indexes and constraints of every table
+// outside the system schemas.
+func (PostgresBackend) SchemaObjects(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`
+SELECT table_name, 'column ' || table_schema || '.' || column_name || ' ' || data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+FROM information_schema.columns
+WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
+UNION ALL
+SELECT tablename, 'index ' || schemaname || ' ' || indexdef
+FROM pg_indexes
+WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+UNION ALL
+SELECT table_name, 'constraint ' || table_schema || '.' || constraint_name || ' ' || constraint_type
+FROM information_schema.table_constraints
+WHERE table_schema NOT IN ('pg_catalog', 'information_schema')`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var table, object string
+		if err := rows.Scan(&table, &object); err != nil {
+			return nil, err
+		}
+		out = append(out, table+"\t"+object)
+	}
+	return out, rows.Err()
+}
+
 func init() {
 	RegisterBackend("postgres", PostgresBackend{})
 }
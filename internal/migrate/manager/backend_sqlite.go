@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	msqlite "github.com/golang-migrate/migrate/v4/database/sqlite"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	sqlitedialect "github.com/lenhattri/kaeshi-migrate/pkg/validate/sqlite"
+)
+
+// SQLiteBackend implements DBBackend for SQLite files; the DSN is the file
+// path. SQLite has no advisory locks: golang-migrate's lock only guards the
+// current process, and concurrent runs from other processes are serialized by
+// SQLite's file lock alone. The pool holds a single connection so the
+// manager's own writes never wait on a lock held by another of its
+// connections.
+type SQLiteBackend struct{}
+
+func (SQLiteBackend) DriverName() string { return "sqlite" }
+
+func (SQLiteBackend) NewDriver(db *sql.DB) (database.Driver, error) {
+	return msqlite.WithInstance(db, &msqlite.Config{})
+}
+
+// NewDriverWithTable is NewDriver tracking versions in table.
+func (SQLiteBackend) NewDriverWithTable(db *sql.DB, table string) (database.Driver, error) {
+	return msqlite.WithInstance(db, &msqlite.Config{MigrationsTable: table})
+}
+
+func (SQLiteBackend) Validator() validate.Dialect { return sqlitedialect.Dialect{} }
+
+// Placeholder returns SQLite's positional ? parameter.
+func (SQLiteBackend) Placeholder(int) string { return "?" }
+
+// MaxOpenConns keeps the pool at one connection.
+func (SQLiteBackend) MaxOpenConns() int { return 1 }
+
+// SchemaObjects describes the SQLite schema from sqlite_master.
+func (SQLiteBackend) SchemaObjects(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`SELECT tbl_name, type || ' ' || name || ' ' || COALESCE(sql, '') FROM sqlite_master WHERE name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var table, object string
+		if err := rows.Scan(&table, &object); err != nil {
+			return nil, err
+		}
+		out = append(out, table+"\t"+object)
+	}
+	return out, rows.Err()
+}
+
+// IsMissingTable reports SQLite's "no such table" error, which shares the
+// generic SQLITE_ERROR code with every other SQL error.
+func (SQLiteBackend) IsMissingTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such table")
+}
+
+func init() {
+	RegisterBackend("sqlite", SQLiteBackend{})
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultBackoff is the retry schedule of withRetry unless WithBackoff
+// replaces it: 1s, 2s, 4s, … up to 30s, each shortened by up to half at
+// random.
+var DefaultBackoff = Backoff{Base: time.Second, Max: 30 * time.Second, Multiplier: 2, Jitter: 0.5}
+
+// Backoff shapes the pauses between retries of a failed operation. The pause
+// before retry n is Base·Multiplierⁿ⁻¹, capped at Max, minus a random share of
+// up to Jitter (0–1) of it, so runners contending for the same lock do not
+// retry in lockstep. Zero Base, Max or Multiplier take the DefaultBackoff
+// value; zero Jitter disables it.
+type Backoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+}
+
+// WithBackoff sets the retry schedule of operations retried per max retries.
+func WithBackoff(b Backoff) Option {
+	return func(mgr *Manager) { mgr.backoff = b }
+}
+
+// jitterFraction is swapped out in tests for a deterministic schedule.
+var jitterFraction = rand.Float64
+
+// delay returns the pause before retry attempt (1 for the first retry).
+func (b Backoff) delay(attempt int) time.Duration {
+	if b.Base <= 0 {
+		b.Base = DefaultBackoff.Base
+	}
+	if b.Max <= 0 {
+		b.Max = DefaultBackoff.Max
+	}
+	if b.Multiplier < 1 {
+		b.Multiplier = DefaultBackoff.Multiplier
+	}
+	d := min(float64(b.Base)*math.Pow(b.Multiplier, float64(attempt-1)), float64(b.Max))
+	if j := min(max(b.Jitter, 0), 1); j > 0 {
+		d -= d * j * jitterFraction()
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// CheckItem is one line of a preflight report.
+type CheckItem struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// preflightPingTimeout bounds the connection attempt of Preflight.
+const preflightPingTimeout = 5 * time.Second
+
+func passed(name, detail string) CheckItem { return CheckItem{Name: name, OK: true, Detail: detail} }
+
+func failed(name string, err error) CheckItem {
+	return CheckItem{Name: name, Detail: err.Error()}
+}
+
+// Preflight checks what must hold before NewManager can work: the database/sql
+// driver of backend is registered and dsn accepts a connection. The items
+// come back in order and stop at the first failure.
+func Preflight(backend DBBackend, dsn string) []CheckItem {
+	if !slices.Contains(sql.Drivers(), backend.DriverName()) {
+		return []CheckItem{failed("driver", fmt.Errorf("database/sql driver %q is not registered", backend.DriverName()))}
+	}
+	items := []CheckItem{passed("driver", backend.DriverName())}
+	db, err := sql.Open(backend.DriverName(), readOnlyDSN(backend, dsn))
+	if err != nil {
+		return append(items, failed("connect", err))
+	}
+	defer db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), preflightPingTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return append(items, failed("connect", err))
+	}
+	return append(items, passed("connect", ""))
+}
+
+// Check runs the read-only checks of a deploy preflight on an open Manager,
+// best opened WithReadOnly: the migration files exist, parse and pair up,
+// the history table exists, the database is not dirty and committed files
+// still match their recorded hashes. A database nothing was applied to yet
+// passes without a history table. Nothing is written; every item is
+// reported even after a failure.
+func (mgr *Manager) Check() []CheckItem {
+	var items []CheckItem
+	v, dirty, verr := mgr.Version()
+	fresh := errors.Is(verr, migrate.ErrNilVersion)
+	if mgr.remoteSource() {
+		items = append(items, passed("migrations", "skipped: remote source "+mgr.sourceURL))
+	} else {
+		items = append(items, mgr.checkFiles()...)
+	}
+
+	if !mgr.recordHist {
+		items = append(items, passed("history table", "skipped: history disabled"))
+	} else if !mgr.historyHasColumn("version") {
+		if fresh {
+			items = append(items, passed("history table", "not created yet: nothing applied"))
+		} else {
+			items = append(items, failed("history table", fmt.Errorf("%s is missing or unreadable", mgr.HistoryTable())))
+		}
+	} else {
+		items = append(items, passed("history table", mgr.HistoryTable()))
+		if mismatches, err := mgr.Verify(); err != nil {
+			items = append(items, failed("hashes", err))
+		} else if len(mismatches) > 0 {
+			items = append(items, failed("hashes", fmt.Errorf("%d committed file(s) changed, first %s", len(mismatches), mismatches[0])))
+		} else {
+			items = append(items, passed("hashes", "committed files match history"))
+		}
+	}
+
+	if fresh {
+		items = append(items, passed("version", "nothing applied yet"))
+	} else if verr != nil {
+		items = append(items, failed("version", verr))
+	} else if dirty {
+		items = append(items, failed("version", fmt.Errorf("database is dirty at version %d; %s", v, recoveryHint(v))))
+	} else {
+		items = append(items, passed("version", fmt.Sprintf("clean at %d", v)))
+	}
+	return items
+}
+
+// checkFiles reports whether the migration files can be read, parse under the
+// backend dialect and come in up/down pairs.
+func (mgr *Manager) checkFiles() []CheckItem {
+	ups, err := fs.Glob(mgr.fsys, "*.up.sql")
+	if err == nil {
+		_, err = fs.Stat(mgr.fsys, ".")
+	}
+	if err != nil {
+		return []CheckItem{failed("migrations", err)}
+	}
+	items := []CheckItem{passed("migrations", fmt.Sprintf("%d up file(s)", len(ups)))}
+
+	findings, err := Lint(mgr.fsys, mgr.backend.Validator())
+	var problems []string
+	for _, f := range findings {
+		if f.Severity == validate.LintError {
+			problems = append(problems, f.String())
+		}
+	}
+	switch {
+	case err != nil:
+		items = append(items, failed("parse", err))
+	case len(problems) > 0:
+		items = append(items, failed("parse", errors.New(strings.Join(problems, "; "))))
+	default:
+		items = append(items, passed("parse", ""))
+	}
+
+	if unpaired, err := mgr.CheckPairs(); err != nil {
+		items = append(items, failed("pairs", err))
+	} else if len(unpaired) > 0 {
+		items = append(items, failed("pairs", errors.New(strings.Join(unpaired, "; "))))
+	} else {
+		items = append(items, passed("pairs", "every up file has a down file"))
+	}
+	return items
+}
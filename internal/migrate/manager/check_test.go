@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+// unregisteredBackend names a database/sql driver no one registered.
+type unregisteredBackend struct{ SQLiteBackend }
+
+func (unregisteredBackend) DriverName() string { return "kaeshi-missing" }
+
+func failedItems(items []CheckItem) map[string]string {
+	out := map[string]string{}
+	for _, it := range items {
+		if !it.OK {
+			out[it.Name] = it.Detail
+		}
+	}
+	return out
+}
+
+func TestCheckReportsEachItem(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(3); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if bad := failedItems(mgr.Check()); len(bad) != 0 {
+		t.Fatalf("healthy setup failed %v", bad)
+	}
+
+	if err := os.WriteFile(filepath.Join(mgr.migrationsDir, "000003_c.up.sql"), []byte("CREATE TABLE c (id BIGINT);"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mgr.migrationsDir, "000004_d.up.sql"), []byte("SELECT 'open;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.driver.SetVersion(3, true); err != nil {
+		t.Fatal(err)
+	}
+	bad := failedItems(mgr.Check())
+	for _, name := range []string{"parse", "pairs", "hashes", "version"} {
+		if _, ok := bad[name]; !ok {
+			t.Errorf("%s passed, want failure; failures: %v", name, bad)
+		}
+	}
+	if v, dirty, _ := mgr.Version(); v != 3 || !dirty {
+		t.Fatalf("Check changed the version to %d dirty %v", v, dirty)
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "db.sqlite")
+	if bad := failedItems(Preflight(SQLiteBackend{}, dsn)); bad["connect"] == "" {
+		t.Fatalf("Preflight of a missing SQLite file = %v, want a connect failure", bad)
+	}
+	if _, err := os.Stat(dsn); !os.IsNotExist(err) {
+		t.Fatalf("Preflight created the database file: %v", err)
+	}
+	if err := os.WriteFile(dsn, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if bad := failedItems(Preflight(SQLiteBackend{}, dsn)); len(bad) != 0 {
+		t.Fatalf("Preflight failed %v", bad)
+	}
+	items := Preflight(unregisteredBackend{}, dsn)
+	if len(items) != 1 || items[0].OK || items[0].Name != "driver" {
+		t.Fatalf("Preflight = %+v, want a single driver failure", items)
+	}
+}
+
+func TestReadOnlyCheckAndPlanOnEmptyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, gotoMigrations)
+	dsn := filepath.Join(t.TempDir(), "empty.db")
+	if err := os.WriteFile(dsn, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mgr, err := NewManager(SQLiteBackend{}, dsn, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil, WithReadOnly())
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer mgr.Close()
+
+	if bad := failedItems(mgr.Check()); len(bad) != 0 {
+		t.Fatalf("check of an empty database failed %v", bad)
+	}
+	plan, err := mgr.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan) != 3 || plan[0].Version != 1 || plan[0].InHistory {
+		t.Fatalf("plan = %+v, want every file, none in history", plan)
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master`).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatalf("read-only check and plan created %d schema objects", tables)
+	}
+}
@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// StatementTimeoutBackend is implemented by backends that can bound how long
+// each statement of a transaction runs.
+type StatementTimeoutBackend interface {
+	// StatementTimeoutSQL returns the statement limiting statements to d for
+	// the rest of the current transaction only.
+	StatementTimeoutSQL(d time.Duration) string
+}
+
+// ValidateCumulative applies every pending migration in order inside one
+// transaction that is always rolled back, so each file is checked against
+// the schema its predecessors produce rather than the current one. It needs
+// a backend with transactional DDL. Files that cannot run in a transaction
+// or are scoped to other environments are skipped with a warning; the others
+// get the checks of validateFile, with the transaction in place of the dry
+// run. Lock waits and statements are bounded by the validation timeout, as
+// the transaction holds every lock it takes until the end.
+func (mgr *Manager) ValidateCumulative() ([]string, error) {
+	td, ok := mgr.backend.(TransactionalDDLBackend)
+	if !ok || !td.TransactionalDDL() {
+		return nil, fmt.Errorf("cumulative validation needs a backend with transactional DDL; %s cannot roll schema changes back", mgr.backend.DriverName())
+	}
+	before, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("read version before Validate: %w", err)
+	}
+	if dirty {
+		return nil, &DirtyError{Version: before}
+	}
+	if mgr.remoteSource() {
+		return nil, fmt.Errorf("validate needs a file-based migrations source; %s cannot be listed", mgr.sourceURL)
+	}
+	if mgr.strictOrder {
+		if err := mgr.checkStrictOrder(before); err != nil {
+			return nil, err
+		}
+	}
+	upFiles, err := mgr.pendingUpFiles(before)
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.checkUpFiles(before, upFiles); err != nil {
+		return nil, err
+	}
+
+	tx, err := mgr.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin validation transaction: %w", err)
+	}
+	defer tx.Rollback()
+	timeout := mgr.validateOpts.Timeout
+	if timeout <= 0 {
+		timeout = validate.DefaultTimeout
+	}
+	if lb := mgr.lockTimeoutBackend(); lb != nil {
+		if _, err := tx.Exec(lb.LockTimeoutSQL(timeout)); err != nil {
+			return nil, fmt.Errorf("set lock timeout for validation: %w", err)
+		}
+	}
+	if sb, ok := mgr.backend.(StatementTimeoutBackend); ok {
+		if _, err := tx.Exec(sb.StatementTimeoutSQL(timeout)); err != nil {
+			return nil, fmt.Errorf("set statement timeout for validation: %w", err)
+		}
+	}
+	var warnings []string
+	for _, f := range upFiles {
+		base := filepath.Base(f)
+		content, err := fs.ReadFile(mgr.fsys, f)
+		if err != nil {
+			return warnings, err
+		}
+		d, err := parseDirectives(string(content))
+		if err != nil {
+			return warnings, fmt.Errorf("%s: %w", base, err)
+		}
+		switch {
+		case !d.runsIn(mgr.env):
+			warnings = append(warnings, fmt.Sprintf("%s: not validated: scoped to other environments", base))
+			continue
+		case d.noTransaction:
+			warnings = append(warnings, fmt.Sprintf("%s: not validated: no-transaction files cannot run in the validation transaction; later files may fail without it", base))
+			continue
+		}
+		err = mgr.validateFileWith(f, func(content string) error {
+			ctx, cancel := context.WithTimeout(mgr.context(), timeout)
+			defer cancel()
+			if _, err := tx.ExecContext(ctx, content); err != nil {
+				return fmt.Errorf("%s fails on the schema left by the pending migrations before it: %w", base, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return warnings, err
+		}
+	}
+	// The lints query history, which needs the connection the transaction
+	// holds.
+	if err := tx.Rollback(); err != nil {
+		return warnings, fmt.Errorf("roll back validation transaction: %w", err)
+	}
+	for _, f := range upFiles {
+		warnings = append(warnings, mgr.lintDown(f)...)
+		warnings = append(warnings, mgr.lintLockOrder(f)...)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,91 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var dependentMigrations = map[string]string{
+	"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+	"0001_create_users.down.sql": "DROP TABLE users;",
+	"0002_users_email.up.sql":    "ALTER TABLE users ADD COLUMN email TEXT;",
+	"0002_users_email.down.sql":  "ALTER TABLE users DROP COLUMN email;",
+	"0003_index_email.up.sql":    "CREATE INDEX idx_users_email ON users (email);",
+	"0003_index_email.down.sql":  "DROP INDEX idx_users_email;",
+}
+
+func TestValidateCumulativeSeesEarlierPendingMigrations(t *testing.T) {
+	mgr := newTestManager(t, dependentMigrations)
+	mgr.backend = transactionalSQLiteBackend{}
+
+	// Each file alone fails against the empty database.
+	if _, err := mgr.ValidateSince(0); err == nil {
+		t.Fatal("expected per-file validation to miss the users table")
+	}
+	if _, err := mgr.ValidateCumulative(); err != nil {
+		t.Fatalf("ValidateCumulative: %v", err)
+	}
+	// Nothing may survive the sandbox.
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'users'`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("users table count = %d (%v), want rolled back", n, err)
+	}
+	if v, _, _ := mgr.Version(); v != 0 {
+		t.Fatalf("version = %d, want untouched", v)
+	}
+}
+
+func TestValidateCumulativeReportsBrokenFile(t *testing.T) {
+	files := map[string]string{
+		"0001_create_users.up.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0002_orders_fk.up.sql":    "CREATE INDEX idx_orders_user ON orders (user_id);",
+	}
+	mgr := newTestManager(t, files)
+	mgr.backend = transactionalSQLiteBackend{}
+	_, err := mgr.ValidateCumulative()
+	if err == nil || !strings.Contains(err.Error(), "0002_orders_fk.up.sql") {
+		t.Fatalf("err = %v, want 0002 to fail", err)
+	}
+}
+
+func TestValidateCumulativeNeedsTransactionalDDL(t *testing.T) {
+	mgr := newTestManager(t, dependentMigrations)
+	if _, err := mgr.ValidateCumulative(); err == nil || !strings.Contains(err.Error(), "transactional DDL") {
+		t.Fatalf("err = %v, want refusal", err)
+	}
+}
+
+func TestValidateCumulativeBoundsLockWaits(t *testing.T) {
+	mgr := newTestManager(t, dependentMigrations)
+	state := &lockTimeoutState{}
+	mgr.backend = lockTimeoutSQLiteBackend{state: state}
+	mgr.validateOpts.Timeout = 3 * time.Second
+	if _, err := mgr.ValidateCumulative(); err != nil {
+		t.Fatalf("ValidateCumulative: %v", err)
+	}
+	if len(state.timeouts) != 1 || state.timeouts[0] != 3*time.Second {
+		t.Fatalf("lock timeouts set = %v, want the 3s validation timeout once", state.timeouts)
+	}
+}
+
+func TestValidateCumulativeAppliesFileChecks(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"0001_create_users.up.sql": "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0002_drop_users.up.sql":   "DROP TABLE users;",
+	})
+	mgr.backend = transactionalSQLiteBackend{}
+	mgr.validateOpts.Deny = []string{"DROP TABLE"}
+	_, err := mgr.ValidateCumulative()
+	if err == nil || !strings.Contains(err.Error(), "0002_drop_users.up.sql") || !strings.Contains(err.Error(), "denied by policy") {
+		t.Fatalf("err = %v, want 0002 denied by policy", err)
+	}
+
+	mgr = newTestManager(t, map[string]string{
+		"0001_users.up.sql": "-- kaeshi:isolation repeatable read\nCREATE TABLE users (id INTEGER);",
+	})
+	mgr.backend = transactionalSQLiteBackend{}
+	if _, err := mgr.ValidateCumulative(); err == nil || !strings.Contains(err.Error(), "not supported by sqlite") {
+		t.Fatalf("err = %v, want unsupported isolation level", err)
+	}
+}
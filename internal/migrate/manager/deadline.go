@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// UpContext is Up bounded by ctx. Once ctx is done no further file is
+// started and no retry is attempted, and the running statement is canceled
+// when kaeshi executes it itself: on backends with transactional DDL and for
+// no-transaction and isolation files. Statements golang-migrate runs cannot
+// be interrupted from here, so callers enforcing a hard deadline should also
+// stop waiting for UpContext; see LastApplied.
+func (mgr *Manager) UpContext(ctx context.Context) error {
+	mgr.ctx = ctx
+	defer func() { mgr.ctx = nil }()
+	return mgr.Up()
+}
+
+// UpToContext is UpTo bounded by ctx, like UpContext.
+func (mgr *Manager) UpToContext(ctx context.Context, version uint) error {
+	mgr.ctx = ctx
+	defer func() { mgr.ctx = nil }()
+	return mgr.UpTo(version)
+}
+
+// context returns the context of the running operation.
+func (mgr *Manager) context() context.Context {
+	if mgr.ctx == nil {
+		return context.Background()
+	}
+	return mgr.ctx
+}
+
+// checkContext returns an error naming f when the running operation's
+// context is done.
+func (mgr *Manager) checkContext(f string) error {
+	if err := mgr.context().Err(); err != nil {
+		return fmt.Errorf("stopped before %s: %w", filepath.Base(f), err)
+	}
+	return nil
+}
+
+// LastApplied returns the version reached by the latest file Up applied, or
+// the version Up started from when none has been applied yet. It is safe to
+// call while Up runs, e.g. to report progress when a deadline expires; ok is
+// false before any Up has started.
+func (mgr *Manager) LastApplied() (v uint, ok bool) {
+	p := mgr.progress.Load()
+	if p == 0 {
+		return 0, false
+	}
+	return uint(p - 1), true
+}
+
+func (mgr *Manager) setProgress(v uint) { mgr.progress.Store(uint64(v) + 1) }
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// DialectProblem is a parse problem of one migration file under one dialect.
+type DialectProblem struct {
+	File    string
+	Dialect string
+	Err     error
+}
+
+// CheckDialects parses every migration file in fsys under each registered
+// validation dialect using only the checks that need no database. Dialects
+// register themselves when their package is imported.
+func CheckDialects(fsys fs.FS) ([]DialectProblem, error) {
+	var files []string
+	for _, pattern := range []string{"*.up.sql", "*.down.sql"} {
+		matches, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	var problems []DialectProblem
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range validate.Dialects() {
+			for _, err := range validate.ParseOffline(string(data), d) {
+				problems = append(problems, DialectProblem{File: filepath.Base(f), Dialect: d.DriverName(), Err: err})
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckDialects(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"000001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"000002_b.up.sql":   {Data: []byte("CREATE INDEX CONCURRENTLY b_idx ON a (id);")},
+	}
+	problems, err := CheckDialects(fsys)
+	if err != nil {
+		t.Fatalf("CheckDialects: %v", err)
+	}
+	// CONCURRENTLY is PostgreSQL only: MySQL and SQLite reject it.
+	if len(problems) != 2 {
+		t.Fatalf("expected two problems, got %+v", problems)
+	}
+	for i, dialect := range []string{"mysql", "sqlite"} {
+		p := problems[i]
+		if p.File != "000002_b.up.sql" || p.Dialect != dialect || !strings.Contains(p.Err.Error(), "CONCURRENTLY") {
+			t.Fatalf("unexpected problem %+v", p)
+		}
+	}
+}
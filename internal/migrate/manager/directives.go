@@ -0,0 +1,171 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// directivePrefix introduces a kaeshi directive in a migration's header.
+const directivePrefix = "-- kaeshi:"
+
+// directives holds per-migration settings declared in the file header.
+type directives struct {
+	// noTransaction applies the file statement by statement instead of
+	// inside a single transaction, for DDL such as CREATE INDEX CONCURRENTLY.
+	noTransaction bool
+	// envs limits the environments the migration executes in; empty means all.
+	envs []string
+	// isolation runs the migration's transaction at this isolation level,
+	// normalized to its lower-case SQL spelling; empty uses the default.
+	isolation string
+	// tags group migrations by subsystem for status --tag and apply --tag.
+	tags []string
+}
+
+// runsIn reports whether the migration executes in env.
+func (d directives) runsIn(env string) bool {
+	if len(d.envs) == 0 {
+		return true
+	}
+	for _, e := range d.envs {
+		if strings.EqualFold(e, env) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTag reports whether the migration carries tag, ignoring case.
+func (d directives) hasTag(tag string) bool {
+	for _, t := range d.tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits directive arguments separated by commas and/or spaces.
+func splitList(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		for _, item := range strings.Split(arg, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// parseDirectives reads `-- kaeshi:<name> [args]` lines from the comment
+// block at the top of a migration. Parsing stops at the first SQL line.
+func parseDirectives(content string) (directives, error) {
+	var d directives
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+		if !strings.HasPrefix(line, directivePrefix) {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, directivePrefix))
+		if len(fields) == 0 {
+			return d, fmt.Errorf("empty kaeshi directive")
+		}
+		switch name := fields[0]; name {
+		case "no-transaction":
+			d.noTransaction = true
+		case "env":
+			d.envs = splitList(fields[1:])
+			if len(d.envs) == 0 {
+				return d, fmt.Errorf("kaeshi:env needs at least one environment")
+			}
+		case "isolation":
+			d.isolation = validate.NormalizeIsolation(strings.Join(fields[1:], " "))
+			if d.isolation == "" {
+				return d, fmt.Errorf("kaeshi:isolation needs a level")
+			}
+		case "tags":
+			d.tags = splitList(fields[1:])
+			if len(d.tags) == 0 {
+				return d, fmt.Errorf("kaeshi:tags needs at least one tag")
+			}
+		case "timeout":
+			// statement-level; applied by pkg/validate to the statement it precedes
+		default:
+			return d, fmt.Errorf("unknown kaeshi directive %q", name)
+		}
+	}
+	if d.noTransaction && d.isolation != "" {
+		return d, fmt.Errorf("kaeshi:isolation cannot be combined with kaeshi:no-transaction")
+	}
+	return d, nil
+}
+
+// readDirectives parses the directives of migration f, reading only its
+// leading comment block.
+func readDirectives(fsys fs.FS, f string) (directives, error) {
+	file, err := fsys.Open(f)
+	if err != nil {
+		return directives{}, err
+	}
+	defer file.Close()
+	var header strings.Builder
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line != "" && !strings.HasPrefix(line, "--") {
+			break
+		}
+		header.WriteString(line + "\n")
+	}
+	if err := sc.Err(); err != nil {
+		return directives{}, err
+	}
+	return parseDirectives(header.String())
+}
+
+// skippedDownSQL replaces the down file of a migration runFile skipped. It is
+// a statement rather than nothing, since MySQL rejects an empty query.
+const skippedDownSQL = "-- up migration skipped by kaeshi:env; nothing to roll back\nSELECT 1;\n"
+
+// upSkippedIn reports whether the up file paired with down is scoped by
+// kaeshi:env to environments other than env, so it was never executed.
+func upSkippedIn(fsys fs.FS, down, env string) bool {
+	up, ok := strings.CutSuffix(down, ".down.sql")
+	if !ok {
+		return false
+	}
+	d, err := readDirectives(fsys, up+".up.sql")
+	return err == nil && !d.runsIn(env)
+}
+
+// envScopedFS serves golang-migrate skippedDownSQL in place of the down file
+// of a migration scoped to other environments: runFile recorded its version
+// without running it, so a rollback must not run its down SQL either.
+type envScopedFS struct {
+	withoutSignatures
+	env string
+}
+
+func (e envScopedFS) Open(name string) (fs.File, error) {
+	if !upSkippedIn(e.withoutSignatures, name, e.env) {
+		return e.withoutSignatures.Open(name)
+	}
+	info, err := fs.Stat(e.withoutSignatures, name)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedFile{Reader: bytes.NewReader([]byte(skippedDownSQL)), info: renderedInfo{info, int64(len(skippedDownSQL))}}, nil
+}
@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDirectives(t *testing.T) {
+	d, err := parseDirectives("-- add index\n-- kaeshi:no-transaction\n\nCREATE INDEX i ON t(a);\n-- kaeshi:bogus\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if !d.noTransaction {
+		t.Fatal("expected no-transaction directive")
+	}
+
+	if _, err := parseDirectives("-- kaeshi:bogus\nSELECT 1;"); err == nil {
+		t.Fatal("expected error for unknown directive")
+	}
+
+	d, err = parseDirectives("-- kaeshi:env Production staging\nSELECT 1;")
+	if err != nil {
+		t.Fatalf("parse env: %v", err)
+	}
+	if !d.runsIn("production") || !d.runsIn("staging") || d.runsIn("development") {
+		t.Fatalf("env scoping = %v, want production and staging only", d.envs)
+	}
+	if !(directives{}).runsIn("development") {
+		t.Fatal("unscoped file must run everywhere")
+	}
+	if _, err := parseDirectives("-- kaeshi:timeout 120s\nCREATE INDEX i ON t(a);"); err != nil {
+		t.Fatalf("statement-level timeout directive rejected: %v", err)
+	}
+	if _, err := parseDirectives("-- kaeshi:env\nSELECT 1;"); err == nil {
+		t.Fatal("expected error for env directive without environments")
+	}
+
+	d, err = parseDirectives("-- kaeshi:isolation REPEATABLE_READ\nUPDATE t SET a = 1;")
+	if err != nil {
+		t.Fatalf("parse isolation: %v", err)
+	}
+	if d.isolation != "repeatable read" {
+		t.Fatalf("isolation = %q, want repeatable read", d.isolation)
+	}
+	d, err = parseDirectives("-- kaeshi:tags billing, Reporting\nSELECT 1;")
+	if err != nil {
+		t.Fatalf("parse tags: %v", err)
+	}
+	if len(d.tags) != 2 || !d.hasTag("reporting") || d.hasTag("bill") {
+		t.Fatalf("tags = %v, want billing and Reporting", d.tags)
+	}
+	if _, err := parseDirectives("-- kaeshi:tags\nSELECT 1;"); err == nil {
+		t.Fatal("expected error for tags directive without tags")
+	}
+	if _, err := parseDirectives("-- kaeshi:isolation serializable\n-- kaeshi:no-transaction\nSELECT 1;"); err == nil {
+		t.Fatal("expected error for isolation without a transaction")
+	}
+}
+
+func TestUpWithIsolationDirective(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"0001_users.up.sql":   "-- kaeshi:isolation serializable\nCREATE TABLE users (id INTEGER);\nINSERT INTO users VALUES (1);",
+		"0001_users.down.sql": "DROP TABLE users;",
+		"0002_bad.up.sql":     "-- kaeshi:isolation repeatable read\nUPDATE users SET id = 2;",
+		"0002_bad.down.sql":   "SELECT 1;",
+	})
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 1 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 1 clean", v, dirty)
+	}
+	err := mgr.Up()
+	if err == nil || !strings.Contains(err.Error(), `isolation level "repeatable read" is not supported by sqlite`) {
+		t.Fatalf("Up err = %v, want unsupported isolation level", err)
+	}
+}
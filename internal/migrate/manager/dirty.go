@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// DirtyError reports a database left dirty by a partially-applied migration,
+// together with the recommended recovery.
+type DirtyError struct {
+	Version uint
+}
+
+func (e *DirtyError) Error() string {
+	return fmt.Sprintf("database dirty at version %d; manual intervention required: %s", e.Version, recoveryHint(e.Version))
+}
+
+// PartialApplyError reports an Up batch that failed after applying some of
+// its migrations, so operators know exactly where the database stands.
+type PartialApplyError struct {
+	// Applied are the versions applied before the failure, in order.
+	Applied []uint
+	// Failed is the version whose file failed.
+	Failed uint
+	// Version and Dirty are the database state read after the failure.
+	Version uint
+	Dirty   bool
+	Err     error
+}
+
+func (e *PartialApplyError) Error() string {
+	applied := fmt.Sprintf("applied version %d", e.Applied[0])
+	if n := len(e.Applied); n > 1 {
+		applied = fmt.Sprintf("applied versions %d–%d", e.Applied[0], e.Applied[n-1])
+	}
+	state := fmt.Sprintf("database at version %d", e.Version)
+	if e.Dirty {
+		state += ", dirty"
+	}
+	return fmt.Sprintf("%s, failed on %d (%s): %v", applied, e.Failed, state, e.Err)
+}
+
+func (e *PartialApplyError) Unwrap() error { return e.Err }
+
+func recoveryHint(v uint) string {
+	if v == 0 {
+		return "inspect the partially-applied migration and repair the schema manually"
+	}
+	return fmt.Sprintf("inspect the partially-applied migration %d, revert what it changed, then run `kaeshi safe-force %d`", v, v-1)
+}
+
+// DirtyState describes a dirty database for status reporting.
+type DirtyState struct {
+	Version uint
+	// File is the up migration that was mid-flight, when present on disk.
+	File string
+	// LastAction, LastVersion, LastActor and LastAt describe the latest history
+	// row, i.e. the last operation known to have completed.
+	LastAction  string
+	LastVersion string
+	LastActor   string
+	LastAt      string
+	// Recovery is the suggested next step.
+	Recovery string
+}
+
+// Dirty returns details about a dirty database, or nil when it is clean.
+func (mgr *Manager) Dirty() (*DirtyState, error) {
+	ver, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, err
+	}
+	if !dirty {
+		return nil, nil
+	}
+	st := &DirtyState{Version: ver, Recovery: recoveryHint(ver)}
+	files, _ := fs.Glob(mgr.fsys, "*.up.sql")
+	for _, f := range files {
+		if v, err := fileVersion(f); err == nil && v == ver {
+			st.File = filepath.Base(f)
+			break
+		}
+	}
+	if mgr.recordHist {
+		var at sql.NullString
+		err := mgr.db.QueryRow(`SELECT action, version, executed_by, `+mgr.historyTimeColumn()+` FROM `+mgr.hist()+` ORDER BY id DESC LIMIT 1`).
+			Scan(&st.LastAction, &st.LastVersion, &st.LastActor, &at)
+		if err != nil && err != sql.ErrNoRows {
+			mgr.logger.WithError(err).Warn("cannot read last history entry")
+		}
+		st.LastAt = at.String
+	}
+	return st, nil
+}
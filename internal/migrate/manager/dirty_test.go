@@ -0,0 +1,187 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirtyErrorGuidance(t *testing.T) {
+	err := error(&DirtyError{Version: 7})
+	for _, want := range []string{"dirty at version 7", "inspect the partially-applied migration 7", "kaeshi safe-force 6"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q should contain %q", err, want)
+		}
+	}
+}
+
+func TestDirtyStateReport(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql": "CREATE TABLE b (id INTEGER);",
+	})
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if st, err := mgr.Dirty(); err != nil || st != nil {
+		t.Fatalf("clean database should report nil, got %+v err=%v", st, err)
+	}
+	// simulate a crash while applying version 2
+	if err := mgr.driver.SetVersion(2, true); err != nil {
+		t.Fatalf("set dirty: %v", err)
+	}
+
+	st, err := mgr.Dirty()
+	if err != nil || st == nil {
+		t.Fatalf("expected dirty state, got %+v err=%v", st, err)
+	}
+	if st.Version != 2 || st.File != "000002_b.up.sql" {
+		t.Fatalf("unexpected dirty state %+v", st)
+	}
+	if st.LastAction != "up" || st.LastVersion != "1" || st.LastActor != "tester" {
+		t.Fatalf("unexpected last history entry %+v", st)
+	}
+	if !strings.Contains(st.Recovery, "kaeshi safe-force 1") {
+		t.Fatalf("recovery should suggest safe-force 1: %s", st.Recovery)
+	}
+
+	var dirtyErr *DirtyError
+	if err := mgr.Up(); !errors.As(err, &dirtyErr) || dirtyErr.Version != 2 {
+		t.Fatalf("Up should fail with DirtyError, got %v", err)
+	}
+}
+
+func TestDirtyStateReportOnCreatedAtHistory(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql": "CREATE TABLE b (id INTEGER);",
+	})
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	// history tables from older installs name the timestamp created_at
+	if _, err := mgr.db.Exec(`ALTER TABLE migrations_history RENAME COLUMN executed_at TO created_at`); err != nil {
+		t.Fatalf("rename column: %v", err)
+	}
+	if err := mgr.driver.SetVersion(2, true); err != nil {
+		t.Fatalf("set dirty: %v", err)
+	}
+	st, err := mgr.Dirty()
+	if err != nil || st == nil {
+		t.Fatalf("expected dirty state, got %+v err=%v", st, err)
+	}
+	if st.LastAction != "up" || st.LastVersion != "1" || st.LastActor != "tester" || st.LastAt == "" {
+		t.Fatalf("last history entry %+v, want the up of version 1 with its time", st)
+	}
+}
+
+// stateRow is the before/after state persisted with one history row.
+type stateRow struct {
+	action                      string
+	versionBefore, versionAfter sql.NullInt64
+	dirtyBefore, dirtyAfter     bool
+}
+
+func historyStates(t *testing.T, mgr *Manager) []stateRow {
+	t.Helper()
+	rows, err := mgr.db.Query(`SELECT action, version_before, dirty_before, version_after, dirty_after FROM ` + mgr.hist() + ` ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	defer rows.Close()
+	var out []stateRow
+	for rows.Next() {
+		var r stateRow
+		if err := rows.Scan(&r.action, &r.versionBefore, &r.dirtyBefore, &r.versionAfter, &r.dirtyAfter); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestHistoryRecordsStateTransitions(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+	})
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.driver.SetVersion(2, true); err != nil {
+		t.Fatalf("set dirty: %v", err)
+	}
+	if err := mgr.SafeForce(1); err != nil {
+		t.Fatalf("SafeForce: %v", err)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Force(1); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+
+	v := func(n int64) sql.NullInt64 { return sql.NullInt64{Int64: n, Valid: true} }
+	want := []stateRow{
+		{"up", sql.NullInt64{}, v(1), false, false},
+		{"safe-force", v(2), v(1), true, false},
+		{"up", v(1), v(2), false, false},
+		{"force", v(2), v(1), false, false},
+	}
+	got := historyStates(t, mgr)
+	if len(got) != len(want) {
+		t.Fatalf("history = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUpReportsPartialApply(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000012_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000013_b.up.sql": "CREATE TABLE b (id INTEGER);",
+		"000014_c.up.sql": "CREATE TABLE c (id INTEGER);",
+		// validates on its own but collides with 000013 when applied
+		"000015_dup.up.sql": "CREATE TABLE b (id INTEGER);",
+		"000016_d.up.sql":   "CREATE TABLE d (id INTEGER);",
+	})
+	err := mgr.Up()
+	var partial *PartialApplyError
+	if !errors.As(err, &partial) {
+		t.Fatalf("Up error = %v, want PartialApplyError", err)
+	}
+	if !reflect.DeepEqual(partial.Applied, []uint{12, 13, 14}) || partial.Failed != 15 {
+		t.Fatalf("partial = %+v, want applied 12-14 and failed 15", partial)
+	}
+	if !strings.HasPrefix(err.Error(), "applied versions 12–14, failed on 15 (database at version 15, dirty): ") {
+		t.Fatalf("unexpected message: %v", err)
+	}
+	if got, want := historyRows(t, mgr), []string{"up:12", "up:13", "up:14"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+}
+
+func TestSafeForceNotifies(t *testing.T) {
+	note := &recordingNotifier{}
+	mgr := newTestManager(t, threeMigrations)
+	mgr.notifier = note
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.driver.SetVersion(2, true); err != nil {
+		t.Fatalf("set dirty: %v", err)
+	}
+	if err := mgr.SafeForce(1); err != nil {
+		t.Fatalf("SafeForce: %v", err)
+	}
+	last := note.events[len(note.events)-1]
+	if last.Status != "safe-force" || last.Version != "1" || last.User != "tester" || last.DB != "sqlite" || last.Error != nil {
+		t.Fatalf("last event = %+v, want safe-force to version 1", last)
+	}
+}
@@ -0,0 +1,40 @@
+package manager
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithDryRun makes Up, Down and Steps run their checks and validation, then
+// stop before executing anything or writing history. The files they would
+// have executed are reported in LastRun().Planned.
+func WithDryRun(enabled bool) Option {
+	return func(mgr *Manager) { mgr.dryRun = enabled }
+}
+
+// plan records files as the dry-run plan of the current operation.
+func (mgr *Manager) plan(files []string) {
+	planned := make([]string, len(files))
+	for i, f := range files {
+		planned[i] = filepath.Base(f)
+	}
+	mgr.lastRun.Planned = planned
+	mgr.logger.WithFields(logrus.Fields{"actor": mgr.actor, "files": planned}).
+		Info("dry run: checks passed, nothing executed")
+}
+
+// downPlan returns the down files that rolling back the n highest versions
+// at or below cur would execute, highest first; n < 0 means all of them.
+func (mgr *Manager) downPlan(cur uint, n int) ([]string, error) {
+	ups, err := mgr.appliedUpFiles(cur, n)
+	if err != nil {
+		return nil, err
+	}
+	downs := make([]string, len(ups))
+	for i, up := range ups {
+		downs[i] = strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+	}
+	return downs, nil
+}
@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDryRunPlansWithoutExecuting(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	mgr.dryRun = true
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("dry-run Up: %v", err)
+	}
+	if got, want := mgr.LastRun().Planned, []string{"000002_b.up.sql", "000003_c.up.sql"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned = %v, want %v", got, want)
+	}
+	if err := mgr.Down(); err != nil {
+		t.Fatalf("dry-run Down: %v", err)
+	}
+	if got, want := mgr.LastRun().Planned, []string{"000001_a.down.sql"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("planned = %v, want %v", got, want)
+	}
+	if v, dirty, _ := mgr.Version(); v != 1 || dirty {
+		t.Fatalf("version = %d dirty %v, want 1 untouched", v, dirty)
+	}
+	if got := historyRows(t, mgr); !reflect.DeepEqual(got, []string{"up:1"}) {
+		t.Fatalf("history = %v, want only the real Steps row", got)
+	}
+}
+
+func TestDryRunFailsWhenChecksWouldBlock(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(3); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	mgr.dryRun = true
+	if err := mgr.Steps(-1); err == nil || !strings.Contains(err.Error(), "committed") {
+		t.Fatalf("dry-run rollback err = %v, want committed refusal", err)
+	}
+}
+
+type probingSQLiteBackend struct {
+	SQLiteBackend
+	probes *int
+}
+
+func (b probingSQLiteBackend) CheckPrivileges(*sql.DB) error {
+	*b.probes++
+	return nil
+}
+
+func TestDryRunSkipsPrivilegeProbe(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	probes := 0
+	mgr.backend = probingSQLiteBackend{probes: &probes}
+	mgr.dryRun = true
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("dry-run Up: %v", err)
+	}
+	if probes != 0 {
+		t.Fatalf("dry run probed privileges %d times, want none", probes)
+	}
+	mgr.dryRun = false
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if probes != 1 {
+		t.Fatalf("probed privileges %d times, want once for the real run", probes)
+	}
+}
+
+func TestDryRunRefusesContinueOnError(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations, WithDryRun(true))
+	if _, err := mgr.UpContinueOnError(); err == nil || !strings.Contains(err.Error(), "dry run") {
+		t.Fatalf("err = %v, want dry run refused", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 0 || dirty {
+		t.Fatalf("version = %d dirty %v, want nothing applied", v, dirty)
+	}
+	if got := historyRows(t, mgr); len(got) != 0 {
+		t.Fatalf("history = %v, want nothing recorded", got)
+	}
+}
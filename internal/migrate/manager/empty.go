@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Empty migration policies accepted by WithEmptyMigrations.
+const (
+	EmptyWarn   = "warn"
+	EmptySkip   = "skip"
+	EmptyRefuse = "refuse"
+)
+
+// WithEmptyMigrations sets how up treats files holding only comments and
+// whitespace: warn applies them with a warning, skip records the version
+// without executing anything, refuse rejects the run before applying.
+func WithEmptyMigrations(policy string) Option {
+	return func(mgr *Manager) { mgr.emptyPolicy = policy }
+}
+
+func (mgr *Manager) checkEmptyPolicy() error {
+	switch mgr.emptyPolicy {
+	case "", EmptyWarn, EmptySkip, EmptyRefuse:
+		return nil
+	}
+	return fmt.Errorf("unknown empty migration policy %q: want warn, skip or refuse", mgr.emptyPolicy)
+}
+
+// isEmptyFile reports whether f has no statements once comments are
+// stripped.
+func (mgr *Manager) isEmptyFile(f string) (bool, error) {
+	content, err := fs.ReadFile(mgr.fsys, f)
+	if err != nil {
+		return false, err
+	}
+	stmts, err := mgr.typedStatements(string(content))
+	if err != nil {
+		return false, fmt.Errorf("split %s: %w", filepath.Base(f), err)
+	}
+	return len(stmts) == 0, nil
+}
+
+// checkEmpty applies the empty migration policy to upFiles before anything
+// runs. It returns a warning per empty file, or an error under refuse.
+func (mgr *Manager) checkEmpty(upFiles []string) ([]string, error) {
+	var empty []string
+	for _, f := range upFiles {
+		ok, err := mgr.isEmptyFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			empty = append(empty, filepath.Base(f))
+		}
+	}
+	if len(empty) == 0 {
+		return nil, nil
+	}
+	switch mgr.emptyPolicy {
+	case EmptyRefuse:
+		return nil, fmt.Errorf("empty migrations (only comments or whitespace): %s", strings.Join(empty, ", "))
+	case EmptySkip:
+		return prefixAll(empty, ": empty migration; version will be recorded without executing it"), nil
+	}
+	return prefixAll(empty, ": empty migration; did you forget to fill it in?"), nil
+}
+
+func prefixAll(files []string, suffix string) []string {
+	out := make([]string, len(files))
+	for i, f := range files {
+		out[i] = f + suffix
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func emptyMigrationFiles() map[string]string {
+	return map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_todo.up.sql":           "-- TODO: backfill users\n\n/* nothing yet */\n",
+		"0002_todo.down.sql":         "",
+	}
+}
+
+func TestEmptyMigrationWarnAppliesWithWarning(t *testing.T) {
+	mgr := newTestManager(t, emptyMigrationFiles())
+	logger, hook := test.NewNullLogger()
+	mgr.logger = logrus.NewEntry(logger)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got, want := historyRows(t, mgr), []string{"up:1", "up:2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+	var warned bool
+	for _, e := range hook.AllEntries() {
+		warned = warned || strings.Contains(e.Message, "0002_todo.up.sql: empty migration")
+	}
+	if !warned {
+		t.Fatal("expected a warning about the empty migration")
+	}
+}
+
+func TestEmptyMigrationSkipRecordsReason(t *testing.T) {
+	mgr := newTestManager(t, emptyMigrationFiles(), WithEmptyMigrations(EmptySkip))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, dirty, _ := mgr.m.Version(); v != 2 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 2 clean", v, dirty)
+	}
+	var reason string
+	if err := mgr.db.QueryRow(`SELECT reason FROM migrations_history WHERE action = 'up' AND version = '2'`).Scan(&reason); err != nil {
+		t.Fatalf("query reason: %v", err)
+	}
+	if reason != "skipped: empty migration" {
+		t.Fatalf("reason = %q", reason)
+	}
+}
+
+func TestEmptyMigrationRefuseStopsBeforeApplying(t *testing.T) {
+	mgr := newTestManager(t, emptyMigrationFiles(), WithEmptyMigrations(EmptyRefuse))
+	err := mgr.Up()
+	if err == nil || !strings.Contains(err.Error(), "0002_todo.up.sql") {
+		t.Fatalf("Up error = %v, want empty migration refusal", err)
+	}
+	if got := historyRows(t, mgr); len(got) != 0 {
+		t.Fatalf("history = %v, want nothing applied", got)
+	}
+	if _, err := mgr.ValidateSince(0); err == nil {
+		t.Fatal("ValidateSince accepted an empty migration under refuse")
+	}
+}
+
+func TestEmptyMigrationPolicyUnderContinueOnError(t *testing.T) {
+	mgr := newTestManager(t, emptyMigrationFiles())
+	if failures, err := mgr.UpContinueOnError(); err != nil || len(failures) != 0 {
+		t.Fatalf("UpContinueOnError = %v, %v", failures, err)
+	}
+	if w := mgr.LastRun().Warnings; len(w) != 1 || !strings.Contains(w[0], "0002_todo.up.sql: empty migration") {
+		t.Fatalf("warnings = %q, want the empty migration reported", w)
+	}
+
+	mgr = newTestManager(t, emptyMigrationFiles(), WithEmptyMigrations(EmptyRefuse))
+	if _, err := mgr.UpContinueOnError(); err == nil || !strings.Contains(err.Error(), "0002_todo.up.sql") {
+		t.Fatalf("UpContinueOnError error = %v, want empty migration refusal", err)
+	}
+	if got := historyRows(t, mgr); len(got) != 0 {
+		t.Fatalf("history = %v, want nothing applied", got)
+	}
+}
+
+func TestEmptyMigrationPolicyRejectsUnknown(t *testing.T) {
+	mgr := &Manager{emptyPolicy: "ignore"}
+	if err := mgr.checkEmptyPolicy(); err == nil {
+		t.Fatal("expected unknown policy to be rejected")
+	}
+}
@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// Exec runs sqlText as a one-off repair outside the migration sequence. The
+// SQL is validated, executed in a single transaction and audited as a
+// "manual" history row carrying reason, the actor and the SQL hash. The
+// schema version is left untouched.
+func (mgr *Manager) Exec(sqlText, reason string) error {
+	sqlText = strings.TrimSpace(sqlText)
+	reason = strings.TrimSpace(reason)
+	if sqlText == "" {
+		return fmt.Errorf("exec: SQL is required")
+	}
+	if reason == "" {
+		return fmt.Errorf("exec: a reason is required for the audit trail")
+	}
+	cur, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("read version before exec: %w", err)
+	}
+	// Manual SQL never moves the version, so before and after are the same.
+	st := stateOf(cur, dirty, err)
+
+	mgr.printSQL(sqlText)
+	if ok, err := validate.ValidateSQL(sqlText, map[string]string{"dsn": mgr.dsn}, mgr.validateOpts, mgr.backend.Validator()); !ok || err != nil {
+		if err != nil {
+			mgr.logger.WithError(err).Error("SQL validation failed")
+		}
+		return invalidSQL("manual exec", err)
+	}
+	stmts, err := mgr.backend.Validator().SplitStatements(sqlText)
+	if err != nil {
+		return err
+	}
+	if mgr.recordHist {
+		mgr.ensureHistoryColumns()
+	}
+
+	if err := mgr.driver.Lock(); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer mgr.driver.Unlock()
+
+	start := time.Now()
+	err = mgr.execManual(stmts, cur, sqlText, reason, transition{from: st, to: st})
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status:   "manual",
+		User:     mgr.actor,
+		Version:  fmt.Sprintf("%d", cur),
+		DB:       mgr.backend.DriverName(),
+		Duration: time.Since(start),
+		Error:    err,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	mgr.logger.WithFields(logrus.Fields{
+		"version": cur,
+		"actor":   mgr.actor,
+		"reason":  reason,
+	}).Warn("manual SQL executed and recorded")
+	return nil
+}
+
+// execManual runs stmts and, when history is enabled, the audit insert in one
+// transaction so a failed repair leaves no trace and a successful one is
+// always recorded.
+func (mgr *Manager) execManual(stmts []string, cur uint, sqlText, reason string, tr transition) error {
+	tx, err := mgr.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("manual exec failed: %w", err)
+		}
+	}
+	if mgr.recordHist {
+		actor := mgr.actor
+		if actor == "" {
+			actor = "unknown"
+		}
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(sqlText)))
+		if _, err := tx.Exec(mgr.rebind(
+			`INSERT INTO `+mgr.hist()+`(action, version, executed_by, sha256, committed, reason, `+transitionColumns+`)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)`),
+			append([]any{"manual", fmt.Sprintf("%d", cur), actor, hash, false, reason}, tr.args()...)...); err != nil {
+			return fmt.Errorf("record manual exec: %w", err)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,54 @@
+package manager
+
+import "testing"
+
+func TestExecRecordsManualEntry(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Steps(2); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+
+	if err := mgr.Exec("INSERT INTO a (id) VALUES (1); INSERT INTO a (id) VALUES (2);", "incident 42: backfill"); err != nil {
+		t.Fatalf("Exec: %v", err)
+	}
+
+	var action, version, actor, reason string
+	err := mgr.db.QueryRow(`SELECT action, version, executed_by, reason FROM migrations_history ORDER BY id DESC LIMIT 1`).
+		Scan(&action, &version, &actor, &reason)
+	if err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	if action != "manual" || version != "2" || actor != "tester" || reason != "incident 42: backfill" {
+		t.Fatalf("history = %s/%s/%s/%q", action, version, actor, reason)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM a`).Scan(&n); err != nil || n != 2 {
+		t.Fatalf("rows in a = %d (%v), want 2", n, err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 2 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 2 clean", v, dirty)
+	}
+}
+
+func TestExecFailureLeavesNoAudit(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	before := len(historyRows(t, mgr))
+
+	if err := mgr.Exec("INSERT INTO a (id) VALUES (1);", ""); err == nil {
+		t.Fatal("expected error without a reason")
+	}
+	// The second statement references a column that does not exist.
+	if err := mgr.Exec("INSERT INTO a (id) VALUES (1); INSERT INTO a (id, missing) VALUES (2, 2);", "fix"); err == nil {
+		t.Fatal("expected failing SQL to be rejected")
+	}
+	if got := len(historyRows(t, mgr)); got != before {
+		t.Fatalf("history rows = %d, want %d", got, before)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM a`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("rows in a = %d (%v), want 0", n, err)
+	}
+}
@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+)
+
+// Goto migrates up or down to exactly version, which must have an up file.
+// Going up applies the files in between like Up; going down refuses to roll
+// back committed versions, validates the first down file like Steps and
+// records a rollback history row for each version it reverts.
+func (mgr *Manager) Goto(version uint) error {
+	return mgr.track("goto", func() error { return mgr.goTo(version) })
+}
+
+func (mgr *Manager) goTo(target uint) error {
+	if mgr.remoteSource() {
+		return fmt.Errorf("goto needs a file-based migrations source")
+	}
+	cur, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("read version before goto: %w", err)
+	}
+	if dirty {
+		return &DirtyError{Version: cur}
+	}
+	if _, err := mgr.migrationFile(target, "up"); err != nil {
+		return fmt.Errorf("cannot go to version %d: no migration file for it", target)
+	}
+	switch {
+	case target == cur:
+		mgr.logger.WithField("actor", mgr.actor).Infof("already at version %d (goto)", target)
+		return nil
+	case target > cur:
+		files, err := mgr.pendingUpFiles(cur)
+		if err != nil {
+			return err
+		}
+		var upFiles []string
+		for _, f := range files {
+			if v, err := fileVersion(f); err == nil && v <= target {
+				upFiles = append(upFiles, f)
+			}
+		}
+		return mgr.applyPending(cur, upFiles)
+	}
+	return mgr.gotoDown(cur, target)
+}
+
+// gotoDown rolls back from cur to target, both with up files.
+func (mgr *Manager) gotoDown(cur, target uint) error {
+	ups, err := mgr.appliedUpFiles(cur, -1)
+	if err != nil {
+		return err
+	}
+	var (
+		versions  []uint // reverted, highest first
+		firstDown string
+	)
+	for _, f := range ups {
+		v, err := fileVersion(f)
+		if err != nil {
+			return err
+		}
+		if v <= target {
+			break
+		}
+		committed, err := mgr.VersionCommitted(v)
+		if err != nil {
+			return err
+		}
+		if committed {
+			return fmt.Errorf("migration version %d has been committed; cannot go below it", v)
+		}
+		if firstDown == "" {
+			firstDown = strings.TrimSuffix(f, ".up.sql") + ".down.sql"
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no migration files between version %d and %d", target, cur)
+	}
+
+	// Later down files depend on the effects of earlier ones, so only the
+	// first can be validated against the current database.
+	if data, err := fs.ReadFile(mgr.fsys, firstDown); err == nil {
+		if err := mgr.validateContent(firstDown, string(data)); err != nil {
+			return err
+		}
+	}
+	if err := mgr.checkRollback(cur, len(versions), false); err != nil {
+		return err
+	}
+
+	if mgr.dryRun {
+		files, err := mgr.downPlan(cur, len(versions))
+		if err != nil {
+			return err
+		}
+		mgr.plan(files)
+		return nil
+	}
+
+	release, err := mgr.holdLock("goto")
+	if err != nil {
+		return err
+	}
+	defer release()
+	start := time.Now()
+	stop := mgr.heartbeat("goto", fmt.Sprintf("from version %d to %d", cur, target))
+	err = mgr.withRetry(func() error { return mgr.m.Migrate(target) })
+	stop()
+	duration := time.Since(start)
+
+	after, dirtyAfter, _ := mgr.m.Version()
+	status := "rollback"
+	if err != nil {
+		status = "fail"
+	}
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status:   status,
+		User:     mgr.actor,
+		Version:  fmt.Sprintf("%d", after),
+		DB:       mgr.backend.DriverName(),
+		Duration: duration,
+		Error:    err,
+		Time:     time.Now(),
+	})
+	for i, v := range versions {
+		next := target
+		if i+1 < len(versions) {
+			next = versions[i+1]
+		}
+		if next < after || (next == after && dirtyAfter) {
+			break // v was not reverted, or only partially
+		}
+		mgr.recordHistory("rollback", next, transition{from: stateOf(v, false, nil), to: stateOf(next, false, nil)})
+	}
+	switch {
+	case err != nil:
+		mgr.logger.WithError(err).
+			WithFields(logrus.Fields{"from": cur, "to": after, "actor": mgr.actor}).
+			Error("goto migration failed")
+		return err
+	case dirtyAfter:
+		return fmt.Errorf("goto left database dirty at version %d", after)
+	}
+	mgr.logger.WithFields(logrus.Fields{"from": cur, "to": after, "actor": mgr.actor}).
+		Info("migrations rolled back (goto)")
+	return nil
+}
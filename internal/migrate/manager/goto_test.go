@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var gotoMigrations = map[string]string{
+	"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+	"000001_a.down.sql": "DROP TABLE a;",
+	"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+	"000002_b.down.sql": "DROP TABLE b;",
+	"000003_c.up.sql":   "CREATE TABLE c (id INTEGER);",
+	"000003_c.down.sql": "DROP TABLE c;",
+}
+
+func TestGotoMovesUpAndDown(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.Goto(2); err != nil {
+		t.Fatalf("Goto(2): %v", err)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("version = %d, want 2", v)
+	}
+	if err := mgr.Goto(3); err != nil {
+		t.Fatalf("Goto(3): %v", err)
+	}
+	if err := mgr.Goto(1); err != nil {
+		t.Fatalf("Goto(1): %v", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 1 || dirty {
+		t.Fatalf("version = %d dirty %v, want clean 1", v, dirty)
+	}
+	want := []string{"up:1", "up:2", "up:3", "rollback:2", "rollback:1"}
+	if got := historyRows(t, mgr); !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+	if run := mgr.LastRun(); run.Operation != "goto" || !reflect.DeepEqual(run.RolledBack, []uint{3, 2}) {
+		t.Fatalf("last run = %+v", run)
+	}
+}
+
+func TestGotoRefusesCommittedAndUnknownVersions(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(2); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if err := mgr.Goto(1); err == nil || !strings.Contains(err.Error(), "version 2 has been committed") {
+		t.Fatalf("Goto(1) err = %v, want committed refusal", err)
+	}
+	if err := mgr.Goto(7); err == nil || !strings.Contains(err.Error(), "no migration file") {
+		t.Fatalf("Goto(7) err = %v, want missing file error", err)
+	}
+	if v, _, _ := mgr.Version(); v != 3 {
+		t.Fatalf("version = %d, want 3 untouched", v)
+	}
+}
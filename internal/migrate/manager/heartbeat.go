@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultHeartbeatInterval is how often a running migration reports that it
+// is still alive.
+const defaultHeartbeatInterval = 30 * time.Second
+
+// WithHeartbeatInterval sets how often a still-running Up, Down or Steps
+// logs its elapsed time and current file.
+func WithHeartbeatInterval(d time.Duration) Option {
+	return func(mgr *Manager) { mgr.heartbeatInterval = d }
+}
+
+// heartbeat logs at the configured interval that op is still working on
+// current until the returned stop function is called, so a slow migration can
+// be told apart from a hung one.
+func (mgr *Manager) heartbeat(op, current string) (stop func()) {
+	interval := mgr.heartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	start := time.Now()
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				elapsed := time.Since(start).Round(time.Second)
+				mgr.logger.WithFields(logrus.Fields{
+					"operation": op,
+					"file":      current,
+					"elapsed":   elapsed.String(),
+					"actor":     mgr.actor,
+				}).Infof("%s still running, elapsed %s, current file %s", op, elapsed, current)
+			}
+		}
+	}()
+	return func() {
+		ticker.Stop()
+		close(done)
+	}
+}
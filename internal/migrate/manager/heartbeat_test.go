@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func heartbeats(hook *test.Hook) int {
+	n := 0
+	for _, e := range hook.AllEntries() {
+		if strings.Contains(e.Message, "still running") && strings.Contains(e.Message, "current file 0001_slow.up.sql") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHeartbeatDuringSlowMigration(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	log.SetOutput(io.Discard)
+	mgr := &Manager{logger: logrus.NewEntry(log), heartbeatInterval: 10 * time.Millisecond}
+
+	stop := mgr.heartbeat("up", "0001_slow.up.sql")
+	time.Sleep(55 * time.Millisecond) // the simulated migration
+	stop()
+
+	got := heartbeats(hook)
+	if got < 2 {
+		t.Fatalf("heartbeats = %d, want at least 2 during a 55ms run at 10ms interval", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if after := heartbeats(hook); after != got {
+		t.Fatalf("heartbeats continued after stop: %d -> %d", got, after)
+	}
+}
+
+func TestHeartbeatQuietForFastMigration(t *testing.T) {
+	log, hook := test.NewNullLogger()
+	mgr := &Manager{logger: logrus.NewEntry(log), heartbeatInterval: 50 * time.Millisecond}
+	mgr.heartbeat("up", "0001_slow.up.sql")()
+	time.Sleep(80 * time.Millisecond)
+	if n := len(hook.AllEntries()); n != 0 {
+		t.Fatalf("fast migration logged %d heartbeats, want none", n)
+	}
+}
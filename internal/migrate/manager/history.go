@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// HistoryFilter narrows History. Zero values match everything.
+type HistoryFilter struct {
+	// Limit caps the number of records; 0 returns all of them.
+	Limit int
+	// Action keeps records of one action, e.g. up, down, rollback or safe-force.
+	Action string
+	// Version keeps records of one version as stored in history, e.g. "12".
+	Version string
+}
+
+// HistoryRecord is one row of migrations_history.
+type HistoryRecord struct {
+	ID         int64  `json:"id"`
+	Action     string `json:"action"`
+	Version    string `json:"version"`
+	ExecutedBy string `json:"executed_by"`
+	SHA256     string `json:"sha256"`
+	Committed  bool   `json:"committed"`
+	// CreatedAt is empty when the history table has no timestamp column.
+	CreatedAt string `json:"created_at,omitempty"`
+	// DurationMs is how long an applied up migration took to execute; nil
+	// for other actions and rows recorded before it was tracked.
+	DurationMs *int64 `json:"duration_ms,omitempty"`
+}
+
+// historyTimeColumns are the timestamp columns History looks for, in order:
+// executed_at as created by the init migration, created_at on older installs.
+var historyTimeColumns = []string{"executed_at", "created_at"}
+
+// History returns the entries of migrations_history matching filter, newest
+// first.
+func (mgr *Manager) History(filter HistoryFilter) ([]HistoryRecord, error) {
+	if !mgr.recordHist {
+		return nil, fmt.Errorf("history is disabled (WithRecordHistory(false))")
+	}
+	created := mgr.historyTimeColumn()
+	duration := "NULL"
+	if mgr.historyHasColumn("duration_ms") {
+		duration = "duration_ms"
+	}
+	var where []string
+	var args []any
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		where = append(where, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if filter.Version != "" {
+		args = append(args, filter.Version)
+		where = append(where, fmt.Sprintf("version = $%d", len(args)))
+	}
+	query := `SELECT id, action, version, executed_by, sha256, committed, ` + created + `, ` + duration + ` FROM ` + mgr.hist()
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := mgr.db.Query(mgr.rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("read history: %w", err)
+	}
+	defer rows.Close()
+	var out []HistoryRecord
+	for rows.Next() {
+		var r HistoryRecord
+		var sha, at sql.NullString
+		var ms sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Action, &r.Version, &r.ExecutedBy, &sha, &r.Committed, &at, &ms); err != nil {
+			return nil, fmt.Errorf("read history: %w", err)
+		}
+		r.SHA256, r.CreatedAt = sha.String, at.String
+		if ms.Valid {
+			r.DurationMs = &ms.Int64
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// historyTimeColumn returns the first of historyTimeColumns present in
+// migrations_history, or NULL when it has none.
+func (mgr *Manager) historyTimeColumn() string {
+	for _, c := range historyTimeColumns {
+		if mgr.historyHasColumn(c) {
+			return c
+		}
+	}
+	return "NULL"
+}
+
+// historyHasColumn reports whether migrations_history has column name.
+func (mgr *Manager) historyHasColumn(name string) bool {
+	_, err := mgr.db.Exec(`SELECT ` + name + ` FROM ` + mgr.hist() + ` WHERE 1 = 0`)
+	return err == nil
+}
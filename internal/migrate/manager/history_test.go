@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHistoryFilters(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(1); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	all, err := mgr.History(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(all) < 3 || all[0].ID < all[len(all)-1].ID {
+		t.Fatalf("history should list newest first, got %+v", all)
+	}
+	for _, r := range all {
+		if r.CreatedAt == "" || r.ExecutedBy != "tester" {
+			t.Fatalf("incomplete record %+v", r)
+		}
+	}
+
+	ups, err := mgr.History(HistoryFilter{Action: "up", Limit: 2})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(ups) != 2 || ups[0].Version != "3" || ups[1].Version != "2" || ups[0].SHA256 == "" {
+		t.Fatalf("up records = %+v, want versions 3 and 2 with hashes", ups)
+	}
+
+	v1, err := mgr.History(HistoryFilter{Action: "up", Version: "1"})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(v1) != 1 || v1[0].Version != "1" || !v1[0].Committed {
+		t.Fatalf("version 1 records = %+v, want one committed up", v1)
+	}
+}
+
+func TestHistoryWithoutTimestampColumn(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := mgr.db.Exec(`ALTER TABLE migrations_history DROP COLUMN executed_at`); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	recs, err := mgr.History(HistoryFilter{Limit: 1})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(recs) != 1 || recs[0].CreatedAt != "" {
+		t.Fatalf("records = %+v, want one without a timestamp", recs)
+	}
+}
+
+func TestHistoryRecordsUpDuration(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	ups, err := mgr.History(HistoryFilter{Action: "up"})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	for _, r := range ups {
+		if r.DurationMs == nil || *r.DurationMs < 0 {
+			t.Fatalf("up record %+v has no duration", r)
+		}
+	}
+
+	// A history table the column could not be added to still gets rows.
+	bare := newTestManager(t, threeMigrations)
+	for _, c := range historyColumns[:len(historyColumns)-1] {
+		if _, err := bare.db.Exec(`ALTER TABLE migrations_history ADD COLUMN ` + c.def); err != nil {
+			t.Fatalf("add %s: %v", c.name, err)
+		}
+	}
+	if _, err := bare.insertApplied(bare.db, 1, "000001_a.up.sql", fileRun{}); err != nil {
+		t.Fatalf("insert without duration_ms column: %v", err)
+	}
+	recs, err := bare.History(HistoryFilter{})
+	if err != nil || len(recs) != 1 || recs[0].DurationMs != nil {
+		t.Fatalf("History = %+v, %v; want one untimed record", recs, err)
+	}
+}
+
+// noDurationConnector opens connections that refuse to add the duration_ms
+// column, like a role without ALTER on a history table from an older release.
+type noDurationConnector struct {
+	drv driver.Driver
+	dsn string
+}
+
+func (c noDurationConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return noDurationConn{conn}, nil
+}
+
+func (c noDurationConnector) Driver() driver.Driver { return c.drv }
+
+type noDurationConn struct{ driver.Conn }
+
+func (c noDurationConn) Prepare(query string) (driver.Stmt, error) {
+	if strings.Contains(query, "ADD COLUMN duration_ms") {
+		return nil, errors.New("permission denied for table migrations_history")
+	}
+	return c.Conn.Prepare(query)
+}
+
+func TestUpRecordsHistoryWithoutDurationColumn(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	db := sql.OpenDB(noDurationConnector{drv: mgr.db.Driver(), dsn: mgr.dsn})
+	t.Cleanup(func() { _ = db.Close() })
+	mgr.db = db
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if mgr.historyHasColumn("duration_ms") {
+		t.Fatal("duration_ms was added; the connector should have refused it")
+	}
+	if got, want := historyRows(t, mgr), []string{"up:1", "up:2", "up:3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+	recs, err := mgr.History(HistoryFilter{Action: "up"})
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	for _, r := range recs {
+		if r.DurationMs != nil {
+			t.Fatalf("record %+v has a duration without the column", r)
+		}
+	}
+}
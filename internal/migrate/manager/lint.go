@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// LintFinding is one problem Lint found in a migration file.
+type LintFinding struct {
+	File     string
+	Severity string // validate.LintError or validate.LintWarning
+	Message  string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.File, f.Severity, f.Message)
+}
+
+// Lint checks every migration file in fsys with validate.Lint under d and
+// warns about up files without a down file and down files without an up
+// file. Like CheckDialects it needs no database, so CI can run it without
+// credentials.
+func Lint(fsys fs.FS, d validate.Dialect) ([]LintFinding, error) {
+	ups, err := fs.Glob(fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	downs, err := fs.Glob(fsys, "*.down.sql")
+	if err != nil {
+		return nil, err
+	}
+	files := append(append([]string{}, ups...), downs...)
+	sort.Strings(files)
+
+	unpaired, err := unpairedFiles(fsys)
+	if err != nil {
+		return nil, err
+	}
+	missing := map[string]string{}
+	for _, u := range unpaired {
+		missing[u.File] = u.Missing
+	}
+
+	var out []LintFinding
+	for _, f := range files {
+		data, err := fs.ReadFile(fsys, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range validate.Lint(string(data), d) {
+			out = append(out, LintFinding{File: filepath.Base(f), Severity: l.Severity, Message: l.Message})
+		}
+		if side := missing[filepath.Base(f)]; side != "" {
+			out = append(out, LintFinding{File: filepath.Base(f), Severity: validate.LintWarning, Message: "no matching " + side + " file"})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,29 @@
+package manager
+
+import (
+	"testing"
+	"testing/fstest"
+
+	pgdialect "github.com/lenhattri/kaeshi-migrate/pkg/validate/postgres"
+)
+
+func TestLintReportsFindingsPerFile(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"000001_a.down.sql": {Data: []byte("DROP TABLE a;")},
+		"000002_b.up.sql":   {Data: []byte("CREATE FUNCTION f() RETURNS int AS $$ SELECT 1;")},
+	}
+	got, err := Lint(fsys, pgdialect.Dialect{})
+	if err != nil {
+		t.Fatalf("Lint: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("findings = %v, want 2", got)
+	}
+	if got[0].File != "000002_b.up.sql" || got[0].Severity != "error" {
+		t.Fatalf("first finding = %v, want the unterminated dollar quote", got[0])
+	}
+	if got[1].String() != "000002_b.up.sql: warning: no matching down file" {
+		t.Fatalf("second finding = %v", got[1])
+	}
+}
@@ -0,0 +1,206 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/sirupsen/logrus"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+)
+
+// defaultLockWaitThreshold is how long lock acquisition may take before the
+// Manager reports that another migration appears to be in progress.
+const defaultLockWaitThreshold = 10 * time.Second
+
+// LockInspector is implemented by backends that can describe the session
+// currently holding the migration lock.
+type LockInspector interface {
+	LockHolder(db *sql.DB) (string, error)
+}
+
+// WithLockWaitThreshold sets how long lock acquisition may block before a
+// warning (and notifier event) is emitted.
+func WithLockWaitThreshold(d time.Duration) Option {
+	return func(mgr *Manager) { mgr.lockWaitThreshold = d }
+}
+
+// ErrLockNotAcquired is wrapped by the error of an operation that gave up
+// waiting for the migration lock after the WithLockTimeout limit.
+var ErrLockNotAcquired = errors.New("migration lock not acquired")
+
+// WithLockTimeout bounds how long Up, Down, Steps and the other migrating
+// operations wait for the migration lock, e.g. while another deploy holds
+// the Postgres advisory lock. Zero waits indefinitely.
+func WithLockTimeout(d time.Duration) Option {
+	return func(mgr *Manager) { mgr.lockTimeout = d }
+}
+
+// TryLocker is implemented by lock drivers that can attempt the lock without
+// blocking. waitLock polls them, so giving up after WithLockTimeout leaves
+// no lock request behind; other drivers are waited on in a goroutine.
+type TryLocker interface {
+	TryLock() (bool, error)
+}
+
+// heldLockDriver is the driver golang-migrate works through. While the
+// Manager holds the migration lock for a whole operation, the Lock and Unlock
+// calls golang-migrate makes around each of its steps are no-ops, so the lock
+// is not released between files.
+type heldLockDriver struct {
+	database.Driver
+	held atomic.Bool
+}
+
+func (d *heldLockDriver) Lock() error {
+	if d.held.Load() {
+		return nil
+	}
+	return d.Driver.Lock()
+}
+
+func (d *heldLockDriver) Unlock() error {
+	if d.held.Load() {
+		return nil
+	}
+	return d.Driver.Unlock()
+}
+
+// holdLock acquires the migration lock for operation op and returns the
+// function releasing it; everything between the two, including the steps
+// golang-migrate runs, happens under the lock. A long wait is reported
+// instead of looking like a hang, and the wait gives up after the
+// WithLockTimeout limit.
+func (mgr *Manager) holdLock(op string) (release func(), err error) {
+	if mgr.driver == nil {
+		return func() {}, nil
+	}
+	if err := mgr.waitLock(op); err != nil {
+		return nil, err
+	}
+	if mgr.held != nil {
+		mgr.held.held.Store(true)
+	}
+	return func() {
+		if mgr.held != nil {
+			mgr.held.held.Store(false)
+		}
+		if err := mgr.driver.Unlock(); err != nil {
+			mgr.logger.WithError(err).Warn("failed to release migration lock")
+		}
+	}, nil
+}
+
+// waitLock takes the migration lock for holdLock.
+func (mgr *Manager) waitLock(op string) error {
+	threshold := mgr.lockWaitThreshold
+	if threshold <= 0 {
+		threshold = defaultLockWaitThreshold
+	}
+	start := time.Now()
+	timer := time.AfterFunc(threshold, func() { mgr.reportLockWait(op, time.Since(start)) })
+	defer timer.Stop()
+	var expired <-chan time.Time
+	if mgr.lockTimeout > 0 {
+		t := time.NewTimer(mgr.lockTimeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	if tl, ok := mgr.driver.(TryLocker); ok {
+		return mgr.pollLock(tl, expired)
+	}
+	done := make(chan error, 1)
+	go func() { done <- mgr.driver.Lock() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		return nil
+	case <-expired:
+		// the abandoned attempt releases the lock if it is granted later
+		go func() {
+			if <-done == nil {
+				_ = mgr.driver.Unlock()
+			}
+		}()
+		return mgr.lockTimeoutError()
+	}
+}
+
+// pollLock tries tl every lockPollInterval until it is granted, the lock
+// timeout expires or the Manager's context is done.
+func (mgr *Manager) pollLock(tl TryLocker, expired <-chan time.Time) error {
+	for {
+		ok, err := tl.TryLock()
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-time.After(lockPollInterval):
+		case <-expired:
+			return mgr.lockTimeoutError()
+		case <-mgr.context().Done():
+			return fmt.Errorf("acquire migration lock: %w", mgr.context().Err())
+		}
+	}
+}
+
+// lockTimeoutError reports a lock wait that hit WithLockTimeout, naming the
+// holder when it is known.
+func (mgr *Manager) lockTimeoutError() error {
+	msg := fmt.Sprintf("could not acquire migration lock within %s", mgr.lockTimeout)
+	if holder, err := mgr.lockHolder(); err == nil && holder != "" {
+		msg += " (held by " + holder + ")"
+	}
+	return fmt.Errorf("%s: %w", msg, ErrLockNotAcquired)
+}
+
+// reportLockWait logs that the lock is still held by someone else, including
+// holder details when the backend can provide them.
+func (mgr *Manager) reportLockWait(op string, waited time.Duration) {
+	fields := logrus.Fields{
+		"operation": op,
+		"waited":    waited.Round(time.Second).String(),
+		"actor":     mgr.actor,
+	}
+	msg := fmt.Sprintf("migration lock not acquired after %s; another migration appears to be in progress", waited.Round(time.Second))
+	holder, err := mgr.lockHolder()
+	if err != nil {
+		mgr.logger.WithError(err).Debug("cannot inspect lock holder")
+	} else if holder != "" {
+		fields["holder"] = holder
+		msg += " (held by " + holder + ")"
+	}
+	mgr.logger.WithFields(fields).Warn(msg)
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status: "waiting",
+		User:   mgr.actor,
+		DB:     mgr.backend.DriverName(),
+		Error:  fmt.Errorf("%s", msg),
+		Time:   time.Now(),
+	})
+}
+
+// lockHolder describes who holds the migration lock, from the lock table in
+// pooler mode, from pg_locks for the advisory lock or from the backend's
+// LockInspector otherwise.
+func (mgr *Manager) lockHolder() (string, error) {
+	switch d := mgr.driver.(type) {
+	case *tableLockDriver:
+		return d.currentHolder()
+	case *advisoryLockDriver:
+		return d.currentHolder()
+	}
+	if insp, ok := mgr.backend.(LockInspector); ok {
+		return insp.LockHolder(mgr.db)
+	}
+	return "", nil
+}
@@ -0,0 +1,251 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/stub"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
+)
+
+// slowLockDriver blocks in Lock for the configured delay.
+type slowLockDriver struct {
+	database.Driver
+	delay time.Duration
+}
+
+func (d *slowLockDriver) Lock() error   { time.Sleep(d.delay); return nil }
+func (d *slowLockDriver) Unlock() error { return nil }
+
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notifier.MigrationEvent
+}
+
+func (n *recordingNotifier) Notify(_ context.Context, e notifier.MigrationEvent) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, e)
+	return nil
+}
+
+func newLockTestManager(delay, threshold time.Duration) (*Manager, *test.Hook, *recordingNotifier) {
+	log, hook := test.NewNullLogger()
+	log.SetOutput(io.Discard)
+	note := &recordingNotifier{}
+	return &Manager{
+		driver:            &slowLockDriver{Driver: &stub.Stub{}, delay: delay},
+		backend:           SQLiteBackend{},
+		logger:            logrus.NewEntry(log),
+		notifier:          note,
+		lockWaitThreshold: threshold,
+	}, hook, note
+}
+
+func TestHoldLockReportsSlowAcquisition(t *testing.T) {
+	mgr, hook, note := newLockTestManager(100*time.Millisecond, 20*time.Millisecond)
+	release, err := mgr.holdLock("up")
+	if err != nil {
+		t.Fatalf("holdLock: %v", err)
+	}
+	release()
+	var found bool
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "another migration appears to be in progress") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected lock-wait warning to be logged")
+	}
+	note.mu.Lock()
+	defer note.mu.Unlock()
+	if len(note.events) != 1 || note.events[0].Status != "waiting" {
+		t.Fatalf("expected one waiting notification, got %+v", note.events)
+	}
+}
+
+func TestHoldLockQuietWhenFast(t *testing.T) {
+	mgr, hook, note := newLockTestManager(0, 50*time.Millisecond)
+	release, err := mgr.holdLock("up")
+	if err != nil {
+		t.Fatalf("holdLock: %v", err)
+	}
+	release()
+	time.Sleep(80 * time.Millisecond)
+	if len(hook.AllEntries()) != 0 || len(note.events) != 0 {
+		t.Fatalf("no wait should be reported, got logs=%d events=%d", len(hook.AllEntries()), len(note.events))
+	}
+}
+
+func TestHoldLockGivesUpAfterTimeout(t *testing.T) {
+	mgr, _, _ := newLockTestManager(time.Second, time.Minute)
+	mgr.lockTimeout = 30 * time.Millisecond
+	start := time.Now()
+	_, err := mgr.holdLock("up")
+	if !errors.Is(err, ErrLockNotAcquired) || !strings.Contains(err.Error(), "within 30ms") {
+		t.Fatalf("holdLock err = %v, want lock timeout", err)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Fatalf("holdLock waited %s, want about the 30ms timeout", waited)
+	}
+}
+
+// countingLockDriver counts the Lock and Unlock calls reaching the backend.
+type countingLockDriver struct {
+	database.Driver
+	locks, unlocks int
+}
+
+func (d *countingLockDriver) Lock() error   { d.locks++; return d.Driver.Lock() }
+func (d *countingLockDriver) Unlock() error { d.unlocks++; return d.Driver.Unlock() }
+
+func TestUpHoldsLockForWholeRun(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	counting := &countingLockDriver{Driver: mgr.held.Driver}
+	mgr.held.Driver, mgr.driver = counting, counting
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if counting.locks != 1 || counting.unlocks != 1 {
+		t.Fatalf("locks=%d unlocks=%d, want the lock taken once for all three files", counting.locks, counting.unlocks)
+	}
+}
+
+// unlockedWriteDriver counts version writes made without the lock held.
+type unlockedWriteDriver struct {
+	countingLockDriver
+	unlockedWrites int
+}
+
+func (d *unlockedWriteDriver) SetVersion(v int, dirty bool) error {
+	if d.locks == d.unlocks {
+		d.unlockedWrites++
+	}
+	return d.Driver.SetVersion(v, dirty)
+}
+
+func TestIsolatedMigrationRunsUnderLock(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"0001_users.up.sql":   "-- kaeshi:isolation serializable\nCREATE TABLE users (id INTEGER);",
+		"0001_users.down.sql": "DROP TABLE users;",
+	})
+	d := &unlockedWriteDriver{countingLockDriver: countingLockDriver{Driver: mgr.held.Driver}}
+	mgr.held.Driver, mgr.driver = d, d
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if d.locks != 1 || d.unlockedWrites != 0 {
+		t.Fatalf("locks=%d unlocked version writes=%d, want every write under the lock", d.locks, d.unlockedWrites)
+	}
+}
+
+func TestAdvisoryLockDriverUsesConfiguredID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	inner, err := (&stub.Stub{}).Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver := &advisoryLockDriver{Driver: inner, db: db, id: PostgresBackend{AdvisoryLockID: 4242}.lockID("app", "public", "schema_migrations")}
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WithArgs(int64(4242)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WithArgs(int64(4242)).WillReturnResult(sqlmock.NewResult(0, 0))
+	if err := driver.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := driver.Lock(); !errors.Is(err, database.ErrLocked) {
+		t.Fatalf("second Lock = %v, want ErrLocked", err)
+	}
+	if err := driver.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	want, _ := database.GenerateAdvisoryLockId("app", "public", "schema_migrations")
+	if got := (PostgresBackend{}).lockID("app", "public", "schema_migrations"); strconv.FormatInt(got, 10) != want {
+		t.Fatalf("derived lock id = %d, want golang-migrate's %s", got, want)
+	}
+}
+
+// busyTryLocker is a lock that stays taken; Lock must never be called.
+type busyTryLocker struct {
+	database.Driver
+	tries int
+}
+
+func (d *busyTryLocker) Lock() error            { panic("blocking Lock called") }
+func (d *busyTryLocker) TryLock() (bool, error) { d.tries++; return false, nil }
+
+func TestHoldLockPollsTryLockerUntilTimeout(t *testing.T) {
+	mgr, _, _ := newLockTestManager(0, time.Minute)
+	busy := &busyTryLocker{Driver: &stub.Stub{}}
+	mgr.driver = busy
+	mgr.lockTimeout = 30 * time.Millisecond
+	if _, err := mgr.holdLock("up"); !errors.Is(err, ErrLockNotAcquired) {
+		t.Fatalf("holdLock err = %v, want lock timeout", err)
+	}
+	if busy.tries == 0 {
+		t.Fatal("TryLock was never attempted")
+	}
+}
+
+func TestAdvisoryLockDriverTryLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	driver := &advisoryLockDriver{Driver: &stub.Stub{}, db: db, id: 7}
+	query := regexp.QuoteMeta(`SELECT pg_try_advisory_lock($1)`)
+	mock.ExpectQuery(query).WithArgs(int64(7)).WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(false))
+	mock.ExpectQuery(query).WithArgs(int64(7)).WillReturnRows(sqlmock.NewRows([]string{"ok"}).AddRow(true))
+	if ok, err := driver.TryLock(); ok || err != nil {
+		t.Fatalf("TryLock on a taken lock = %v, %v", ok, err)
+	}
+	if driver.conn != nil {
+		t.Fatal("a failed TryLock must not keep its connection")
+	}
+	if ok, err := driver.TryLock(); !ok || err != nil {
+		t.Fatalf("TryLock on a free lock = %v, %v", ok, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdvisoryLockHolderSplitsNegativeID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// -2 is 0xFFFFFFFF_FFFFFFFE, which pg_locks shows as two unsigned halves.
+	driver := &advisoryLockDriver{Driver: &stub.Stub{}, db: db, id: -2}
+	mock.ExpectQuery(`FROM pg_locks l`).WithArgs(int64(4294967295), int64(4294967294)).
+		WillReturnRows(sqlmock.NewRows([]string{"pid", "usename", "application_name", "client_addr", "backend_start"}).
+			AddRow(42, "deploy", "kaeshi", "10.0.0.1", time.Unix(0, 0).UTC()))
+	holder, err := driver.currentHolder()
+	if err != nil || !strings.Contains(holder, "pid=42") {
+		t.Fatalf("currentHolder = %q, %v, want the holding session", holder, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// defaultDDLLockAttempts is how often a migration is tried when
+// WithDDLLockTimeout is given no attempt count.
+const defaultDDLLockAttempts = 5
+
+// LockTimeoutBackend is implemented by backends that can bound how long a
+// transaction waits for table locks.
+type LockTimeoutBackend interface {
+	// LockTimeoutSQL returns the statement limiting lock waits to d for the
+	// rest of the current transaction only, which is safe behind poolers.
+	LockTimeoutSQL(d time.Duration) string
+	// IsLockTimeout reports whether err means a lock wait hit that limit.
+	IsLockTimeout(err error) bool
+}
+
+// WithDDLLockTimeout runs migrations containing ALTER TABLE with lock waits
+// bounded by timeout, so an ALTER queued behind a long query gives up instead
+// of blocking every other query on the table. A migration that times out is
+// rolled back and tried again after a pause following the WithBackoff
+// schedule, attempts times in total.
+// It needs a LockTimeoutBackend with transactional DDL; zero disables it.
+func WithDDLLockTimeout(timeout time.Duration, attempts int) Option {
+	return func(mgr *Manager) { mgr.ddlLockTimeout, mgr.ddlLockAttempts = timeout, attempts }
+}
+
+var reAlterTable = regexp.MustCompile(`(?i)^ALTER\s+TABLE\b`)
+
+// ddlLockTimeoutFor returns the lock timeout to apply to content, or zero when
+// none applies.
+func (mgr *Manager) ddlLockTimeoutFor(content string) time.Duration {
+	if mgr.ddlLockTimeout <= 0 || mgr.lockTimeoutBackend() == nil {
+		return 0
+	}
+	stmts, err := mgr.typedStatements(content)
+	if err != nil {
+		return 0
+	}
+	for _, stmt := range stmts {
+		if reAlterTable.MatchString(stmt.SQL) {
+			return mgr.ddlLockTimeout
+		}
+	}
+	return 0
+}
+
+func (mgr *Manager) lockTimeoutBackend() LockTimeoutBackend {
+	lb, ok := mgr.backend.(LockTimeoutBackend)
+	if !ok {
+		return nil
+	}
+	if td, ok := mgr.backend.(TransactionalDDLBackend); !ok || !td.TransactionalDDL() {
+		return nil
+	}
+	return lb
+}
+
+// retryLockTimeout reports whether the failed attempt of version v should be
+// tried again, pausing first as mgr.backoff says.
+func (mgr *Manager) retryLockTimeout(v uint, attempt int, err error) bool {
+	attempts := mgr.ddlLockAttempts
+	if attempts <= 0 {
+		attempts = defaultDDLLockAttempts
+	}
+	if !mgr.lockTimeoutBackend().IsLockTimeout(err) || attempt >= attempts || mgr.context().Err() != nil {
+		return false
+	}
+	wait := mgr.backoff.delay(attempt)
+	mgr.logger.WithFields(logrus.Fields{
+		"version":      v,
+		"attempt":      attempt,
+		"lock_timeout": mgr.ddlLockTimeout.String(),
+		"wait":         wait.String(),
+	}).Warn("migration could not get its table locks in time; rolled back, retrying")
+	sleep(wait)
+	return true
+}
@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTestLockTimeout = errors.New("canceling statement due to lock timeout")
+
+// lockTimeoutSQLiteBackend simulates lock timeouts: while failures remains
+// positive, each transaction that set a lock timeout fails at commit.
+type lockTimeoutSQLiteBackend struct {
+	transactionalSQLiteBackend
+	state *lockTimeoutState
+}
+
+type lockTimeoutState struct {
+	timeouts []time.Duration
+	armed    bool
+	failures int
+}
+
+func (b lockTimeoutSQLiteBackend) LockTimeoutSQL(d time.Duration) string {
+	b.state.timeouts = append(b.state.timeouts, d)
+	b.state.armed = true
+	return "SELECT 1"
+}
+
+func (lockTimeoutSQLiteBackend) IsLockTimeout(err error) bool {
+	return errors.Is(err, errTestLockTimeout)
+}
+
+var alterMigrations = map[string]string{
+	"0001_users.up.sql":       "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+	"0001_users.down.sql":     "DROP TABLE users;",
+	"0002_add_email.up.sql":   "ALTER TABLE users ADD COLUMN email TEXT;",
+	"0002_add_email.down.sql": "ALTER TABLE users DROP COLUMN email;",
+}
+
+func newLockTimeoutManager(t *testing.T, failures, attempts int) (*Manager, *lockTimeoutState, *[]time.Duration) {
+	t.Helper()
+	mgr := newTestManager(t, alterMigrations, WithDDLLockTimeout(2*time.Second, attempts))
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	state := &lockTimeoutState{failures: failures}
+	mgr.backend = lockTimeoutSQLiteBackend{state: state}
+	beforeAtomicCommit = func() error {
+		armed := state.armed
+		state.armed = false
+		if armed && state.failures > 0 {
+			state.failures--
+			return errTestLockTimeout
+		}
+		return nil
+	}
+	var pauses []time.Duration
+	oldSleep := sleep
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	t.Cleanup(func() { beforeAtomicCommit, sleep = nil, oldSleep })
+	return mgr, state, &pauses
+}
+
+func TestDDLLockTimeoutRetriesUntilLocksAreGranted(t *testing.T) {
+	mgr, state, pauses := newLockTimeoutManager(t, 2, 3)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 2 || dirty {
+		t.Fatalf("version = %d dirty %v, want clean 2", v, dirty)
+	}
+	if len(state.timeouts) != 3 || state.timeouts[0] != 2*time.Second {
+		t.Fatalf("lock timeouts set = %v, want 2s on each of 3 attempts", state.timeouts)
+	}
+	if len(*pauses) != 2 || (*pauses)[1] <= (*pauses)[0] {
+		t.Fatalf("pauses = %v, want two growing pauses", *pauses)
+	}
+}
+
+func TestDDLLockTimeoutPausesFollowBackoff(t *testing.T) {
+	mgr, _, pauses := newLockTimeoutManager(t, 2, 3)
+	mgr.backoff = Backoff{Base: 100 * time.Millisecond, Multiplier: 3}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond}
+	if len(*pauses) != len(want) || (*pauses)[0] != want[0] || (*pauses)[1] != want[1] {
+		t.Fatalf("pauses = %v, want %v", *pauses, want)
+	}
+}
+
+func TestDDLLockTimeoutGivesUpAndRestoresVersion(t *testing.T) {
+	mgr, state, _ := newLockTimeoutManager(t, 10, 2)
+	err := mgr.Up()
+	if !errors.Is(err, errTestLockTimeout) || !strings.Contains(err.Error(), "2 attempt(s)") {
+		t.Fatalf("Up err = %v, want lock timeout after 2 attempts", err)
+	}
+	if len(state.timeouts) != 2 {
+		t.Fatalf("attempts = %d, want 2", len(state.timeouts))
+	}
+	if v, dirty, _ := mgr.Version(); v != 1 || dirty {
+		t.Fatalf("version = %d dirty %v, want clean 1 after rollback", v, dirty)
+	}
+}
+
+func TestDDLLockTimeoutNotRetriedAgainByWithRetry(t *testing.T) {
+	mgr, state, _ := newLockTimeoutManager(t, 10, 2)
+	mgr.maxRetries = 3
+	if err := mgr.Up(); !errors.Is(err, errTestLockTimeout) {
+		t.Fatalf("Up err = %v, want lock timeout", err)
+	}
+	if len(state.timeouts) != 2 {
+		t.Fatalf("attempts = %d, want 2: lock timeouts are retried in one layer", len(state.timeouts))
+	}
+}
+
+func TestDDLLockTimeoutOnlyWrapsAlterTable(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations, WithDDLLockTimeout(time.Second, 3))
+	state := &lockTimeoutState{}
+	mgr.backend = lockTimeoutSQLiteBackend{state: state}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if len(state.timeouts) != 0 {
+		t.Fatalf("lock timeout set for CREATE TABLE migrations: %v", state.timeouts)
+	}
+}
@@ -1,21 +1,27 @@
 package manager
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/lib/pq"
-
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
 	"github.com/lenhattri/kaeshi-migrate/internal/notifier"
 	"github.com/sirupsen/logrus"
 
@@ -24,276 +30,1369 @@ import (
 
 // Manager wraps golang-migrate with retries, logging, notifications, and resource handling.
 type Manager struct {
-	m             *migrate.Migrate
-	db            *sql.DB
-	maxRetries    int
-	migrationsDir string
-	logger        *logrus.Entry
-	actor         string // user performing the migration
-	strictHash    bool
-	dsn           string
-	backend       DBBackend
-	validateOpts  validate.ValidateOptions
-	notifier      notifier.Notifier
+	m                 *migrate.Migrate
+	db                *sql.DB
+	maxRetries        int
+	backoff           Backoff
+	migrationsDir     string
+	logger            *logrus.Entry
+	actor             string // user performing the migration
+	strictHash        bool
+	dsn               string
+	backend           DBBackend
+	validateOpts      validate.ValidateOptions
+	notifier          notifier.Notifier
+	env               string
+	fsys              fs.FS
+	recordHist        bool
+	downLint          bool
+	lockOrderLint     bool
+	tablePrefix       string
+	migrationsTable   string
+	historyTable      string
+	pooler            string
+	stripLogComments  bool
+	normalizeHash     bool
+	sqlOut            io.Writer
+	driver            database.Driver
+	held              *heldLockDriver // what golang-migrate uses; see holdLock
+	lockWaitThreshold time.Duration
+	lockTimeout       time.Duration
+	heartbeatInterval time.Duration
+	rollbackCheck     string
+	schemaSnapshot    bool
+	signatureKeys     SignatureKeys
+	requireSignatures bool
+	emptyPolicy       string
+	ddlLockTimeout    time.Duration
+	ddlLockAttempts   int
+	histColumnsReady  bool
+	strictOrder       bool
+	sourceURL         string
+	ctx               context.Context
+	progress          atomic.Uint64 // last applied version + 1; see LastApplied
+	lastRun           RunResult
+	dryRun            bool
+	readOnly          bool
+	noValidate        bool
+	templateVars      map[string]string
+}
+
+// ConnLimiter is implemented by backends that need a smaller connection pool
+// than the default of two, such as SQLite.
+type ConnLimiter interface {
+	MaxOpenConns() int
 }
 
 // NewManager creates a Manager. It limits DB pool to 1 connection to ensure advisory locks
 // (used internally by the Postgres driver) apply correctly.
-func NewManager(backend DBBackend, dsn, migrationsDir string, retries int, logger *logrus.Entry, actor string, strict bool, confirmFn validate.ConfirmFunc, note notifier.Notifier) (*Manager, error) {
+func NewManager(backend DBBackend, dsn, migrationsDir string, retries int, logger *logrus.Entry, actor string, strict bool, confirmFn validate.ConfirmFunc, note notifier.Notifier, opts ...Option) (*Manager, error) {
+	mgr := &Manager{
+		maxRetries:    retries,
+		backoff:       DefaultBackoff,
+		migrationsDir: migrationsDir,
+		logger:        logger,
+		actor:         actor,
+		strictHash:    strict,
+		dsn:           dsn,
+		backend:       backend,
+		validateOpts: validate.ValidateOptions{
+			SkipOnConfirmation: true,
+			ConfirmFn:          confirmFn,
+		},
+		notifier:      note,
+		recordHist:    true,
+		downLint:      true,
+		lockOrderLint: true,
+	}
+	for _, opt := range opts {
+		opt(mgr)
+	}
+	if err := mgr.resolveSourceFS(); err != nil {
+		return nil, err
+	}
+	if err := mgr.checkPooler(); err != nil {
+		return nil, err
+	}
+	if err := mgr.checkRollbackMode(); err != nil {
+		return nil, err
+	}
+	if err := mgr.checkEmptyPolicy(); err != nil {
+		return nil, err
+	}
+	if mgr.fsys == nil {
+		mgr.fsys = os.DirFS(migrationsDir)
+	}
+	if mgr.templateVars != nil {
+		mgr.fsys = templatedFS{mgr.fsys, mgr.templateVars}
+	}
+	if err := checkDuplicateVersions(mgr.fsys); err != nil {
+		return nil, err
+	}
+
+	dsn = mgr.dsn
+	if mgr.readOnly {
+		dsn = readOnlyDSN(backend, dsn)
+	}
 	db, err := sql.Open(backend.DriverName(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
 	// Ensure only one open connection so Postgres advisory lock is effective.
-	db.SetMaxOpenConns(2)
+	maxConns := 2
+	if cl, ok := backend.(ConnLimiter); ok {
+		maxConns = cl.MaxOpenConns()
+	}
+	db.SetMaxOpenConns(maxConns)
 	db.SetConnMaxIdleTime(5 * time.Minute)
+	if mgr.readOnly {
+		mgr.db = db
+		return mgr, nil
+	}
 
-	driver, err := backend.NewDriver(db)
+	driver, err := mgr.newDriver(db)
 	if err != nil {
 		return nil, fmt.Errorf("prepare migrate driver: %w", err)
 	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://"+migrationsDir,
-		backend.DriverName(),
-		driver,
-	)
+	driver = mgr.withTableLock(driver, db)
+	mgr.held = &heldLockDriver{Driver: driver}
+	var m *migrate.Migrate
+	if mgr.sourceURL != "" {
+		m, err = migrate.NewWithDatabaseInstance(mgr.sourceURL, backend.DriverName(), mgr.held)
+	} else {
+		var src source.Driver
+		src, err = iofs.New(envScopedFS{withoutSignatures{mgr.fsys}, mgr.env}, ".")
+		if err != nil {
+			return nil, fmt.Errorf("open migrations source: %w", err)
+		}
+		m, err = migrate.NewWithInstance("iofs", src, backend.DriverName(), mgr.held)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("new migrate instance: %w", err)
 	}
 
-	return &Manager{
-		m:             m,
-		db:            db,
-		maxRetries:    retries,
-		migrationsDir: migrationsDir,
-		logger:        logger,
-		actor:         actor,
-		strictHash:    strict,
-		dsn:           dsn,
-		backend:       backend,
-		validateOpts: validate.ValidateOptions{
-			SkipOnConfirmation: true,
-			ConfirmFn:          confirmFn,
-		},
-		notifier: note,
-	}, nil
+	if mgr.lockTimeout > 0 {
+		m.LockTimeout = mgr.lockTimeout
+	}
+	mgr.m = m
+	mgr.db = db
+	mgr.driver = driver
+	return mgr, nil
+}
+
+// Close cleans up resources.
+func (mgr *Manager) Close() error {
+	_ = mgr.db.Close()
+	if mgr.m == nil {
+		return nil
+	}
+	err1, err2 := mgr.m.Close()
+	if err1 != nil {
+		return err1
+	}
+	return err2
+}
+
+// CommitAll marks all rows in migrations_history as committed.
+func (mgr *Manager) CommitAll() error {
+	if !mgr.recordHist {
+		return fmt.Errorf("history recording is disabled; nothing to commit")
+	}
+	tx, err := mgr.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE ` + mgr.hist() + ` SET committed = true WHERE committed = false`); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Commit marks the history rows of version v as committed, leaving later
+// versions editable.
+func (mgr *Manager) Commit(v uint) error {
+	return mgr.commitVersions([]uint{v})
+}
+
+// CommitThrough marks every uncommitted version up to and including v as committed.
+func (mgr *Manager) CommitThrough(v uint) error {
+	uncommitted, err := mgr.UncommittedVersions()
+	if err != nil {
+		return err
+	}
+	var versions []uint
+	for _, n := range uncommitted {
+		if n <= v {
+			versions = append(versions, n)
+		}
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no uncommitted migrations up to version %d", v)
+	}
+	return mgr.commitVersions(versions)
+}
+
+// UncommittedVersions returns the versions with uncommitted history rows in
+// ascending order, i.e. what CommitAll would freeze. It changes nothing.
+func (mgr *Manager) UncommittedVersions() ([]uint, error) {
+	if !mgr.recordHist {
+		return nil, fmt.Errorf("history recording is disabled; nothing to commit")
+	}
+	rows, err := mgr.db.Query(`SELECT DISTINCT version FROM ` + mgr.hist() + ` WHERE committed = false`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []uint
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			versions = append(versions, uint(n))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions, nil
+}
+
+// commitVersions marks the given versions as committed in a single transaction.
+func (mgr *Manager) commitVersions(versions []uint) error {
+	if !mgr.recordHist {
+		return fmt.Errorf("history recording is disabled; nothing to commit")
+	}
+	tx, err := mgr.db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		res, err := tx.Exec(mgr.rebind(`UPDATE `+mgr.hist()+` SET committed = true WHERE version = $1 AND committed = false`), fmt.Sprintf("%d", v))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			_ = tx.Rollback()
+			return fmt.Errorf("no uncommitted history for version %d", v)
+		}
+		mgr.logger.WithFields(logrus.Fields{"version": v, "actor": mgr.actor}).Info("migration committed")
+	}
+	return tx.Commit()
+}
+
+// versionCommitted reports whether the given version has been committed.
+func (mgr *Manager) VersionCommitted(v uint) (bool, error) {
+	if !mgr.recordHist {
+		return false, nil
+	}
+	var committed bool
+	err := mgr.db.QueryRow(mgr.rebind(`SELECT committed FROM `+mgr.hist()+` WHERE version = $1 ORDER BY id DESC LIMIT 1`), fmt.Sprintf("%d", v)).Scan(&committed)
+	if err == sql.ErrNoRows || mgr.missingTable(err) {
+		// no entry, or no history table yet (first run)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return committed, nil
+}
+
+// recordHistory inserts an entry into migrations_history for auditing,
+// including the version and dirty flag observed before and after the action.
+func (mgr *Manager) recordHistory(action string, version uint, tr transition) {
+	if !mgr.recordHist {
+		return
+	}
+	mgr.ensureHistoryColumns()
+	actor := mgr.actor
+	if actor == "" {
+		actor = "unknown"
+	}
+	_, err := mgr.db.Exec(
+		mgr.rebind(`INSERT INTO `+mgr.hist()+`(action, version, executed_by, committed, `+transitionColumns+`) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`),
+		append([]any{action, fmt.Sprintf("%d", version), actor, false}, tr.args()...)...,
+	)
+	if err != nil {
+		mgr.logger.WithError(err).Warn("failed to record history")
+	}
+}
+
+func (mgr *Manager) notifyEvent(event notifier.MigrationEvent) {
+	if mgr.notifier == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			mgr.logger.WithFields(logrus.Fields{
+				"component":   "notifier",
+				"panic":       r,
+				"error.stack": string(debug.Stack()),
+			}).Error("notifier panic")
+		}
+	}()
+	// A run cut short by its context still reports how it ended.
+	ctx := mgr.context()
+	if ctx.Err() != nil {
+		ctx = context.WithoutCancel(ctx)
+	}
+	err := mgr.notifier.Notify(ctx, event)
+	switch {
+	case errors.Is(err, notifier.ErrCircuitOpen):
+		mgr.logger.WithField("status", event.Status).Warn("notifications degraded: endpoint failing, event not sent")
+	case err != nil:
+		mgr.logger.WithError(err).Warn("failed to send notification")
+	}
+}
+
+// sleep is swapped out in tests to avoid real backoff delays.
+var sleep = time.Sleep
+
+// noRetryError stops withRetry: the failed operation must not run again, as
+// for a no-transaction migration that already executed some statements. why
+// is logged with the decision.
+type noRetryError struct {
+	err error
+	why string
+}
+
+func (e *noRetryError) Error() string { return e.err.Error() }
+
+func (e *noRetryError) Unwrap() error { return e.err }
+
+// withRetry runs op once and retries it up to maxRetries more times, pausing
+// as mgr.backoff says, so a maxRetries of 0 fails on the first error. Every
+// error except migrate.ErrNoChange and a noRetryError is treated as
+// retryable.
+func (mgr *Manager) withRetry(op func() error) error {
+	var err error
+	for attempt := 0; attempt <= mgr.maxRetries; attempt++ {
+		if attempt > 0 {
+			if cerr := mgr.context().Err(); cerr != nil {
+				return fmt.Errorf("%v; not retrying: %w", err, cerr)
+			}
+			wait := mgr.backoff.delay(attempt)
+			mgr.logger.WithFields(logrus.Fields{"attempt": attempt, "wait": wait.String()}).
+				Warn("retrying migration operation")
+			sleep(wait)
+		}
+		err = op()
+		if err == nil || errors.Is(err, migrate.ErrNoChange) {
+			return nil
+		}
+		mgr.logger.WithFields(logrus.Fields{
+			"attempt": attempt,
+			"error":   err,
+		}).Error("migration operation failed")
+		if nr := (*noRetryError)(nil); errors.As(err, &nr) {
+			mgr.logger.Warn("not retrying: " + nr.why)
+			return nr.err
+		}
+	}
+	mgr.logger.WithFields(logrus.Fields{
+		"maxRetries": mgr.maxRetries,
+		"error":      err,
+	}).Error("all migration retries exhausted")
+	return err
+}
+
+// pendingUpFiles returns all .up.sql files whose version is > current.
+func (mgr *Manager) pendingUpFiles(cur uint) ([]string, error) {
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	var out []string
+	for _, f := range files {
+		parts := strings.SplitN(filepath.Base(f), "_", 2)
+		if v, err := strconv.ParseUint(parts[0], 10, 64); err == nil && uint(v) > cur {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// pendingDownFiles returns all .down.sql files for the given version, in reverse order.
+func (mgr *Manager) pendingDownFiles(cur uint) ([]string, error) {
+	all, err := fs.Glob(mgr.fsys, "*.down.sql")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, f := range all {
+		// compare parsed versions so zero-padded names like 000001_x match 1
+		if v, err := fileVersion(f); err == nil && v == cur {
+			files = append(files, f)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files, nil
+}
+
+// checkUpFiles refuses files at or below the current version and committed
+// versions and, in strict mode, files whose hash differs from committed history.
+func (mgr *Manager) checkUpFiles(before uint, upFiles []string) error {
+	for _, f := range upFiles {
+		base := filepath.Base(f)
+		parts := strings.SplitN(base, "_", 2)
+		v, _ := strconv.ParseUint(parts[0], 10, 64)
+		if uint(v) <= before {
+			return fmt.Errorf(
+				"migration version %d (file %s) is less than or equal to current DB version %d; refusing to apply, please rebase or resequence your migrations",
+				v, base, before)
+		}
+		committed, err := mgr.VersionCommitted(uint(v))
+		if err != nil {
+			return err
+		}
+		if committed {
+			return fmt.Errorf("migration version %d has been committed; cannot modify committed migrations", v)
+		}
+	}
+
+	// Check conflict hash cho các file version đã có trong history (phòng trường hợp rollback hoặc file copy lỗi)
+	if mgr.strictHash && mgr.recordHist {
+		for _, f := range upFiles {
+			base := filepath.Base(f)
+			parts := strings.SplitN(base, "_", 2)
+			v, _ := strconv.ParseUint(parts[0], 10, 64)
+			//kiểm tra hash trong DB (nếu có)
+			var dbHash string
+			err := mgr.db.QueryRow(mgr.rebind(`SELECT sha256 FROM `+mgr.hist()+` WHERE action='up' AND version=$1 AND committed=true ORDER BY id DESC LIMIT 1`), fmt.Sprintf("%d", v)).Scan(&dbHash)
+			if err == sql.ErrNoRows || mgr.missingTable(err) {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("query hash: %w", err)
+			}
+			hash, herr := mgr.hashAs(f, dbHash)
+			if herr != nil {
+				return fmt.Errorf("cannot compute hash for %s: %v", f, herr)
+			}
+			if dbHash != "" && dbHash != hash {
+				return fmt.Errorf(
+					"migration version %d (file %s) has been applied with a different hash; refusing to apply: current hash: %s, DB hash: %s; please fix the conflict",
+					v, base, hash, dbHash)
+			}
+		}
+	}
+	return nil
+}
+
+// checkStrictOrder rejects up files that were never applied but sit at or
+// below the highest version ever applied, such as a branch merged after newer
+// migrations already ran. Files older than the first recorded "up" predate
+// the history table and are not judged.
+func (mgr *Manager) checkStrictOrder(cur uint) error {
+	if !mgr.recordHist {
+		return nil
+	}
+	rows, err := mgr.db.Query(`SELECT DISTINCT version FROM ` + mgr.hist() + ` WHERE action = 'up'`)
+	if mgr.missingTable(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("strict-order: query history: %w", err)
+	}
+	defer rows.Close()
+	applied := map[uint]bool{}
+	minApplied, maxApplied := ^uint(0), cur
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return err
+		}
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			continue
+		}
+		applied[uint(v)] = true
+		minApplied = min(minApplied, uint(v))
+		maxApplied = max(maxApplied, uint(v))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	var late []string
+	for _, f := range files {
+		v, err := fileVersion(f)
+		if err != nil || applied[v] || v < minApplied || v > maxApplied {
+			continue
+		}
+		late = append(late, filepath.Base(f))
+	}
+	if len(late) > 0 {
+		return fmt.Errorf("strict-order: %s never applied but at or below highest applied version %d; renumber above it",
+			strings.Join(late, ", "), maxApplied)
+	}
+	return nil
+}
+
+// validateFile prints the SQL of a migration file and validates it against the
+// database using the backend dialect.
+func (mgr *Manager) validateFile(f string) error { return mgr.validateFileWith(f, nil) }
+
+// validateFileWith runs the checks of validateFile on f. A non-nil exec
+// replaces the rolled-back dry run: after the deny policy, the content is
+// passed to exec, which ValidateCumulative uses to run it in its transaction.
+func (mgr *Manager) validateFileWith(f string, exec func(content string) error) error {
+	mgr.logger.WithField("actor", mgr.actor).Debugf("Applying migration file: %s", filepath.Base(f))
+
+	if info, err := fs.Stat(mgr.fsys, f); exec == nil && err == nil && info.Size() > validate.MaxInlineSQLSize {
+		return mgr.validateLargeFile(f, info.Size())
+	}
+	data, err := fs.ReadFile(mgr.fsys, f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", f, err)
+	}
+	content := string(data)
+	d, err := parseDirectives(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(f), err)
+	}
+	if !d.runsIn(mgr.env) {
+		mgr.logger.Infof("skipping validation of %s: scoped to env %s", filepath.Base(f), strings.Join(d.envs, ","))
+		return nil
+	}
+	if err := mgr.checkIsolation(f, d); err != nil {
+		return err
+	}
+	mgr.printSQL(content)
+	if exec != nil {
+		if err := validate.CheckDenied(content, mgr.validateOpts, mgr.backend.Validator()); err != nil {
+			return invalidSQL(filepath.Base(f), err)
+		}
+		return exec(content)
+	}
+	opts := mgr.validateOpts
+	opts.Isolation = d.isolation
+	if ok, err := validate.ValidateSQL(content, map[string]string{"dsn": mgr.dsn}, opts, mgr.backend.Validator()); !ok || err != nil {
+		if err != nil {
+			mgr.logger.WithError(err).Error("SQL validation failed")
+		}
+		return invalidSQL(filepath.Base(f), err)
+	}
+	return nil
+}
+
+// validateForRun validates f before it is applied. Under WithValidation(false)
+// only the dry run is skipped, logged at WARN so the skip shows up in audit
+// logs; directives, isolation levels and the deny policy are still checked.
+func (mgr *Manager) validateForRun(f string) error {
+	if !mgr.noValidate {
+		return mgr.validateFile(f)
+	}
+	return mgr.validateFileWith(f, func(string) error {
+		mgr.logger.WithFields(logrus.Fields{"actor": mgr.actor, "file": filepath.Base(f)}).
+			Warn("SQL validation disabled: running without a rolled-back dry run")
+		return nil
+	})
+}
+
+// validateContent prints content, the SQL of file f, and validates it with
+// the Manager's options, or under WithValidation(false) only checks the deny
+// policy and logs the skip at WARN.
+func (mgr *Manager) validateContent(f, content string) error {
+	if upSkippedIn(mgr.fsys, f, mgr.env) {
+		mgr.logger.Infof("skipping validation of %s: its up file is scoped to other envs", filepath.Base(f))
+		return nil
+	}
+	if mgr.noValidate {
+		mgr.logger.WithFields(logrus.Fields{"actor": mgr.actor, "file": filepath.Base(f)}).
+			Warn("SQL validation disabled: running without a rolled-back dry run")
+		if err := validate.CheckDenied(content, mgr.validateOpts, mgr.backend.Validator()); err != nil {
+			return invalidSQL(filepath.Base(f), err)
+		}
+		return nil
+	}
+	mgr.printSQL(content)
+	if ok, err := validate.ValidateSQL(content, map[string]string{"dsn": mgr.dsn}, mgr.validateOpts, mgr.backend.Validator()); !ok || err != nil {
+		if err != nil {
+			mgr.logger.WithError(err).Error("SQL validation failed")
+		}
+		return invalidSQL(filepath.Base(f), err)
+	}
+	return nil
+}
+
+// invalidSQL reports that validation of name failed, with the cause when
+// validation returned one.
+func invalidSQL(name string, err error) error {
+	if err == nil {
+		return fmt.Errorf("invalid SQL in %s", name)
+	}
+	return fmt.Errorf("invalid SQL in %s: %w", name, err)
+}
+
+// validateLargeFile validates a file above validate.MaxInlineSQLSize by
+// streaming its statements. The SQL is not echoed to keep logs bounded.
+func (mgr *Manager) validateLargeFile(f string, size int64) error {
+	d, err := readDirectives(mgr.fsys, f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(f), err)
+	}
+	if !d.runsIn(mgr.env) {
+		mgr.logger.Infof("skipping validation of %s: scoped to env %s", filepath.Base(f), strings.Join(d.envs, ","))
+		return nil
+	}
+	if err := mgr.checkIsolation(f, d); err != nil {
+		return err
+	}
+	out := mgr.sqlOut
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintf(out, "-- %s: %d bytes, validated as a stream; SQL not echoed\n", filepath.Base(f), size)
+
+	file, err := mgr.fsys.Open(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", f, err)
+	}
+	defer file.Close()
+	opts := mgr.validateOpts
+	opts.Isolation = d.isolation
+	if ok, err := validate.ValidateReader(file, map[string]string{"dsn": mgr.dsn}, opts, mgr.backend.Validator()); !ok || err != nil {
+		if err != nil {
+			mgr.logger.WithError(err).Error("SQL validation failed")
+		}
+		return invalidSQL(filepath.Base(f), err)
+	}
+	return nil
+}
+
+// printSQL echoes migration SQL for the audit log. When configured, comments
+// are removed from the echoed copy only; execution and hashing always use the
+// file contents unchanged.
+func (mgr *Manager) printSQL(content string) {
+	if mgr.stripLogComments {
+		var lines []string
+		for _, l := range strings.Split(validate.StripComments(content), "\n") {
+			if strings.TrimSpace(l) != "" {
+				lines = append(lines, strings.TrimRight(l, " \t"))
+			}
+		}
+		content = strings.Join(lines, "\n")
+	}
+	out := mgr.sqlOut
+	if out == nil {
+		out = os.Stdout
+	}
+	fmt.Fprintln(out, strings.TrimSpace(content))
+}
+
+// fileRun describes how applyFile handled a migration.
+type fileRun struct {
+	inTx    bool       // executed inside a transaction
+	skipped string     // why execution was skipped, if it was
+	tr      transition // state around the file
+	tags    []string   // from the kaeshi:tags directive
+	signer  string     // who signed the file, if signatures are checked
+	// started and duration time the execution of the file.
+	started  time.Time
+	duration time.Duration
+	// recorded is set when the history row was committed together with
+	// the migration.
+	recorded bool
+}
+
+// recordApplied inserts an "up" history row carrying the hash of file f, how
+// it was executed and, for skipped files, the reason in the reason column.
+// Rows already written in the migration's own transaction are not repeated.
+func (mgr *Manager) recordApplied(v uint, f string, run fileRun) {
+	if !mgr.recordHist || run.recorded {
+		return
+	}
+	mgr.ensureHistoryColumns()
+	hash, err := mgr.insertApplied(mgr.db, v, f, run)
+	if err != nil {
+		mgr.logger.WithError(err).Warnf("failed to record history with hash for version %d", v)
+		return
+	}
+	mgr.logRecorded(v, f, run, hash)
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertApplied writes the "up" history row of recordApplied through exec and
+// returns the recorded hash.
+func (mgr *Manager) insertApplied(exec execer, v uint, f string, run fileRun) (string, error) {
+	hash, herr := mgr.hashFile(f)
+	if herr != nil {
+		mgr.logger.WithError(herr).Warnf("cannot compute hash for %s", f)
+	}
+	var reason, tags, signer any
+	if run.skipped != "" {
+		reason = run.skipped
+	}
+	if len(run.tags) > 0 {
+		tags = strings.Join(run.tags, ",")
+	}
+	if run.signer != "" {
+		signer = run.signer
+	}
+	columns := `action, version, executed_by, sha256, committed, in_transaction, reason, tags, signed_by, ` + transitionColumns
+	values := `$1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13`
+	args := append([]any{"up", fmt.Sprintf("%d", v), mgr.historyActor(), hash, false, run.inTx, reason, tags, signer}, run.tr.args()...)
+	// duration_ms is the newest column; without it (ALTER TABLE refused)
+	// the row is still written, just untimed.
+	if mgr.histColumnsReady {
+		columns += `, duration_ms`
+		values += `,$14`
+		args = append(args, run.duration.Milliseconds())
+	}
+	_, err := exec.Exec(mgr.rebind(`INSERT INTO `+mgr.hist()+`(`+columns+`)
+VALUES (`+values+`)`), args...)
+	return hash, err
+}
+
+func (mgr *Manager) historyActor() string {
+	if mgr.actor == "" {
+		return "unknown"
+	}
+	return mgr.actor
+}
+
+func (mgr *Manager) logRecorded(v uint, f string, run fileRun, hash string) {
+	mgr.logger.WithFields(logrus.Fields{
+		"version":        v,
+		"file":           filepath.Base(f),
+		"actor":          mgr.historyActor(),
+		"hash":           hash,
+		"in_transaction": run.inTx,
+	}).Info("migration up applied and recorded")
+}
+
+// historyColumns lists columns added to migrations_history after its first
+// release, with the DDL used to add them to existing tables.
+var historyColumns = []struct{ name, def string }{
+	{"in_transaction", "in_transaction BOOLEAN NOT NULL DEFAULT TRUE"},
+	{"reason", "reason TEXT"},
+	{"version_before", "version_before BIGINT"},
+	{"dirty_before", "dirty_before BOOLEAN"},
+	{"version_after", "version_after BIGINT"},
+	{"dirty_after", "dirty_after BOOLEAN"},
+	{"tags", "tags TEXT"},
+	{"schema_fingerprint", "schema_fingerprint TEXT"},
+	{"signed_by", "signed_by TEXT"},
+	{"duration_ms", "duration_ms BIGINT"}, // keep last; see insertApplied
+}
+
+// ensureHistoryColumns adds columns introduced after migrations_history was
+// first created. It is idempotent and runs at most once per Manager.
+func (mgr *Manager) ensureHistoryColumns() {
+	if mgr.histColumnsReady {
+		return
+	}
+	for _, c := range historyColumns {
+		if mgr.historyHasColumn(c.name) {
+			continue
+		}
+		if _, err := mgr.db.Exec(`ALTER TABLE ` + mgr.hist() + ` ADD COLUMN ` + c.def); err != nil {
+			mgr.logger.WithError(err).Warnf("failed to add %s column to %s", c.name, mgr.HistoryTable())
+			return
+		}
+	}
+	mgr.histColumnsReady = true
+}
+
+// applyFile applies the up migration f, which must be the next pending
+// version and was signed by signer, and captures the state transition it
+// caused.
+func (mgr *Manager) applyFile(v uint, f, signer string) (fileRun, error) {
+	from := mgr.observeState()
+	stop := mgr.heartbeat("up", filepath.Base(f))
+	run, err := mgr.runFile(v, f, fileRun{signer: signer, tr: transition{from: from}, started: time.Now()})
+	stop()
+	run.duration = time.Since(run.started)
+	run.tr.to = mgr.observeState()
+	return run, err
+}
+
+// runFile executes f for applyFile, completing run. Files scoped by a
+// kaeshi:env directive to other environments are not executed; their version
+// is still recorded to keep versions monotonic, with the skip as the history
+// reason, and rolling them back skips their down file too (see envScopedFS).
+func (mgr *Manager) runFile(v uint, f string, run fileRun) (fileRun, error) {
+	d, err := readDirectives(mgr.fsys, f)
+	if err != nil {
+		return run, fmt.Errorf("%s: %w", filepath.Base(f), err)
+	}
+	run.tags = d.tags
+	if !d.runsIn(mgr.env) {
+		reason := fmt.Sprintf("skipped: scoped to env %s, running in %q", strings.Join(d.envs, ","), mgr.env)
+		mgr.logger.WithFields(logrus.Fields{
+			"version": v,
+			"file":    filepath.Base(f),
+			"env":     mgr.env,
+			"envs":    d.envs,
+		}).Info("migration skipped due to env scoping; recording version as applied")
+		run.inTx, run.skipped = true, reason
+		return run, mgr.driver.SetVersion(int(v), false)
+	}
+	if mgr.emptyPolicy == EmptySkip {
+		if empty, err := mgr.isEmptyFile(f); err != nil {
+			return run, err
+		} else if empty {
+			mgr.logger.WithFields(logrus.Fields{"version": v, "file": filepath.Base(f)}).Info("empty migration skipped; recording version as applied")
+			run.inTx, run.skipped = true, "skipped: empty migration"
+			return run, mgr.driver.SetVersion(int(v), false)
+		}
+	}
+	if !d.noTransaction && d.isolation == "" {
+		run.inTx = true
+		if mgr.historyInTransaction() {
+			return mgr.applyAtomic(v, f, run)
+		}
+		if content, err := fs.ReadFile(mgr.fsys, f); err == nil && mgr.ddlLockTimeoutFor(string(content)) > 0 {
+			return mgr.applyAtomic(v, f, run)
+		}
+		return run, mgr.m.Steps(1)
+	}
+	file, err := mgr.fsys.Open(f)
+	if err != nil {
+		return run, fmt.Errorf("read %s: %w", f, err)
+	}
+	defer file.Close()
+	if d.isolation != "" {
+		if err := mgr.checkIsolation(f, d); err != nil {
+			return run, err
+		}
+		run.inTx = true
+		return run, mgr.applyIsolated(v, file, d.isolation)
+	}
+	return run, mgr.applyWithoutTransaction(v, file)
+}
+
+// checkIsolation rejects a kaeshi:isolation level the backend cannot run.
+func (mgr *Manager) checkIsolation(f string, d directives) error {
+	if d.isolation == "" {
+		return nil
+	}
+	if err := validate.CheckIsolation(mgr.backend.Validator(), d.isolation); err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(f), err)
+	}
+	return nil
+}
+
+// applyIsolated executes the statements streamed from r in one transaction at
+// the given isolation level and moves the schema version to v. The version
+// stays dirty if the transaction fails. Like m.Up, it runs under the
+// migration lock its callers hold.
+func (mgr *Manager) applyIsolated(v uint, r io.Reader, level string) error {
+	if err := mgr.driver.SetVersion(int(v), true); err != nil {
+		return fmt.Errorf("mark version %d dirty: %w", v, err)
+	}
+	tx, err := validate.BeginIsolated(mgr.db, mgr.backend.Validator(), level)
+	if err != nil {
+		return fmt.Errorf("begin %s transaction for version %d: %w", level, v, err)
+	}
+	defer tx.Rollback()
+	sr := validate.NewStatementReader(r)
+	for {
+		stmt, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read version %d: %w", v, err)
+		}
+		if _, err := tx.ExecContext(mgr.context(), stmt); err != nil {
+			return fmt.Errorf("migration %d failed: %w", v, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit version %d: %w", v, err)
+	}
+	return mgr.driver.SetVersion(int(v), false)
+}
+
+// applyWithoutTransaction executes each statement streamed from r on its own
+// and moves the schema version to v. The version stays dirty if a statement
+// fails. Once a statement has been sent, errors are noRetryErrors: running
+// the file again would repeat the statements that succeeded.
+func (mgr *Manager) applyWithoutTransaction(v uint, r io.Reader) error {
+	if err := mgr.driver.SetVersion(int(v), true); err != nil {
+		return fmt.Errorf("mark version %d dirty: %w", v, err)
+	}
+	sr := validate.NewStatementReader(r)
+	started := false
+	fail := func(err error) error {
+		if started {
+			return &noRetryError{err, "the migration ran outside a transaction and may be partly applied"}
+		}
+		return err
+	}
+	for {
+		stmt, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("read version %d: %w", v, err))
+		}
+		started = true
+		if _, err := mgr.db.ExecContext(mgr.context(), stmt); err != nil {
+			return fail(fmt.Errorf("migration %d failed: %w", v, err))
+		}
+	}
+	if err := mgr.driver.SetVersion(int(v), false); err != nil {
+		return fail(err)
+	}
+	return nil
+}
+
+// Up applies all pending migrations.
+func (mgr *Manager) Up() error { return mgr.track("up", mgr.up) }
+
+func (mgr *Manager) up() error {
+	before, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("read version before Up: %w", err)
+	}
+	if dirty {
+		return &DirtyError{Version: before}
+	}
+	if mgr.remoteSource() {
+		return mgr.upFromSource(before)
+	}
+
+	// Lấy danh sách file up sẽ được apply (pending > before)
+	upFiles, _ := mgr.pendingUpFiles(before)
+	if len(upFiles) == 0 {
+		mgr.logger.WithField("actor", mgr.actor).Info("no pending migrations to apply (Up)")
+		return nil
+	}
+
+	return mgr.applyPending(before, upFiles)
+}
+
+// applyPending validates every file of upFiles, then applies them in order,
+// notifies and records history. upFiles must directly follow version before.
+func (mgr *Manager) applyPending(before uint, upFiles []string) error {
+	// The privilege probe creates a table, which a dry run must not do.
+	if !mgr.dryRun {
+		if err := mgr.CheckPrivileges(); err != nil {
+			return err
+		}
+	}
+	// 1-2. Chặn file có version <= DB version, file đã commit và hash conflict
+	if err := mgr.checkUpFiles(before, upFiles); err != nil {
+		return err
+	}
+	signers, err := mgr.checkSignatures(upFiles)
+	if err != nil {
+		return err
+	}
+	emptyWarnings, err := mgr.checkEmpty(upFiles)
+	if err != nil {
+		return err
+	}
+	for _, w := range emptyWarnings {
+		mgr.logger.WithField("actor", mgr.actor).Warn(w)
+	}
+	mgr.lastRun.Warnings = append(mgr.lastRun.Warnings, emptyWarnings...)
+
+	// 3. Log filenames sắp apply
+	for _, f := range upFiles {
+		if err := mgr.validateForRun(f); err != nil {
+			return err
+		}
+	}
+
+	if mgr.dryRun {
+		mgr.plan(upFiles)
+		return nil
+	}
+
+	// 4. Thực thi migrate Up
+	release, err := mgr.holdLock("up")
+	if err != nil {
+		return err
+	}
+	defer release()
+	start := time.Now()
+	runs := map[uint]fileRun{}
+	var applied []uint
+	var failed uint
+	mgr.setProgress(before)
+	for _, f := range upFiles {
+		v, verr := fileVersion(f)
+		if verr != nil {
+			err = verr
+			break
+		}
+		failed = v
+		if err = mgr.checkContext(f); err != nil {
+			break
+		}
+		fileStart := time.Now()
+		err = mgr.withRetry(func() error {
+			run, aerr := mgr.applyFile(v, f, signers[f])
+			runs[v] = run
+			return aerr
+		})
+		if err != nil {
+			break
+		}
+		mgr.setProgress(v)
+		applied = append(applied, v)
+		mgr.lastRun.Applied = append(mgr.lastRun.Applied, AppliedMigration{
+			Version: v, File: filepath.Base(f), Duration: time.Since(fileStart), Skipped: runs[v].skipped,
+		})
+	}
+	duration := time.Since(start)
+	after, dirtyAfter, _ := mgr.m.Version()
+	status := "success"
+	if err != nil {
+		status = "fail"
+	}
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status:   status,
+		User:     mgr.actor,
+		Version:  fmt.Sprintf("%d", after),
+		DB:       mgr.backend.DriverName(),
+		Duration: duration,
+		Error:    err,
+		Time:     time.Now(),
+		Tags:     runTags(runs),
+	})
+
+	// 5. Ghi lại history với hash từng file vừa apply, kể cả khi batch lỗi giữa chừng
+	for _, f := range upFiles {
+		v, _ := fileVersion(f)
+		if slices.Contains(applied, v) {
+			mgr.recordApplied(v, f, runs[v])
+		}
+	}
+	if err == nil && after > before {
+		mgr.recordSnapshot(after)
+	}
+
+	switch {
+	case err != nil:
+		mgr.logger.WithError(err).
+			WithFields(logrus.Fields{"from": before, "to": after, "dirty": dirtyAfter, "applied": len(applied), "actor": mgr.actor}).
+			Error("Up migration failed")
+		if len(applied) > 0 {
+			return &PartialApplyError{Applied: applied, Failed: failed, Version: after, Dirty: dirtyAfter, Err: err}
+		}
+		return err
+	case dirtyAfter:
+		return fmt.Errorf("Up migration left database dirty at version %d", after)
+	}
+	return nil
+}
+
+// upFromSource applies all pending migrations from a source that cannot be
+// listed locally. Files are neither validated nor hashed; history records the
+// versions that were reached.
+func (mgr *Manager) upFromSource(before uint) error {
+	mgr.logger.WithField("source", mgr.sourceURL).
+		Warn("non-file migrations source: per-file validation and hash checks are disabled")
+	if mgr.dryRun {
+		return fmt.Errorf("dry run needs a file-based migrations source")
+	}
+	if err := mgr.CheckPrivileges(); err != nil {
+		return err
+	}
+	release, err := mgr.holdLock("up")
+	if err != nil {
+		return err
+	}
+	defer release()
+	from := mgr.observeState()
+	start := time.Now()
+	stop := mgr.heartbeat("up", mgr.sourceURL)
+	err = mgr.withRetry(mgr.m.Up)
+	stop()
+	duration := time.Since(start)
+	after, dirtyAfter, _ := mgr.m.Version()
+	status := "success"
+	if err != nil {
+		status = "fail"
+	}
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status:   status,
+		User:     mgr.actor,
+		Version:  fmt.Sprintf("%d", after),
+		DB:       mgr.backend.DriverName(),
+		Duration: duration,
+		Error:    err,
+		Time:     time.Now(),
+	})
+	if err == nil && after > before {
+		mgr.recordHistory("up", after, transition{from: from, to: stateOf(after, dirtyAfter, nil)})
+	}
+	switch {
+	case errors.Is(err, migrate.ErrNoChange):
+		return nil
+	case err != nil:
+		return err
+	case dirtyAfter:
+		return fmt.Errorf("Up migration left database dirty at version %d", after)
+	}
+	return nil
 }
 
-// Close cleans up resources.
-func (mgr *Manager) Close() error {
-	_ = mgr.db.Close()
-	err1, err2 := mgr.m.Close()
-	if err1 != nil {
-		return err1
-	}
-	return err2
+// Validate runs the checks Up performs before applying pending migrations
+// without applying anything, and returns lint warnings for those files.
+func (mgr *Manager) Validate() ([]string, error) {
+	return mgr.ValidateSince(0)
 }
 
-// CommitAll marks all rows in migrations_history as committed.
-func (mgr *Manager) CommitAll() error {
-	tx, err := mgr.db.Begin()
+// ValidateSince is Validate restricted to pending files with a version above
+// since, so CI can check only the migrations a branch adds.
+func (mgr *Manager) ValidateSince(since uint) ([]string, error) {
+	before, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("read version before Validate: %w", err)
+	}
+	if dirty {
+		return nil, &DirtyError{Version: before}
+	}
+	if mgr.remoteSource() {
+		return nil, fmt.Errorf("validate needs a file-based migrations source; %s cannot be listed", mgr.sourceURL)
+	}
+	if mgr.strictOrder {
+		if err := mgr.checkStrictOrder(before); err != nil {
+			return nil, err
+		}
+	}
+	upFiles, err := mgr.pendingUpFiles(max(before, since))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if _, err = tx.Exec(`UPDATE migrations_history SET committed = true WHERE committed = false`); err != nil {
-		_ = tx.Rollback()
-		return err
+	if err := mgr.checkUpFiles(before, upFiles); err != nil {
+		return nil, err
 	}
-	return tx.Commit()
+	warnings, err := mgr.checkEmpty(upFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range upFiles {
+		if err := mgr.validateFile(f); err != nil {
+			return warnings, err
+		}
+		warnings = append(warnings, mgr.lintDown(f)...)
+		warnings = append(warnings, mgr.lintLockOrder(f)...)
+	}
+	return warnings, nil
 }
 
-// versionCommitted reports whether the given version has been committed.
-func (mgr *Manager) VersionCommitted(v uint) (bool, error) {
-	var committed bool
-	err := mgr.db.QueryRow(`SELECT committed FROM migrations_history WHERE version = $1 ORDER BY id DESC LIMIT 1`, fmt.Sprintf("%d", v)).Scan(&committed)
-	if err == sql.ErrNoRows {
-		return false, nil
+// lintDown returns warnings for a down file that looks like it recreates
+// instead of reverting the changes of upFile.
+func (mgr *Manager) lintDown(upFile string) []string {
+	if !mgr.downLint {
+		return nil
 	}
-	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" {
-		// migrations_history table does not exist yet (first run)
-		return false, nil
+	downFile := strings.TrimSuffix(upFile, ".up.sql") + ".down.sql"
+	up, err := fs.ReadFile(mgr.fsys, upFile)
+	if err != nil {
+		return nil
 	}
+	down, err := fs.ReadFile(mgr.fsys, downFile)
 	if err != nil {
-		return false, err
+		return nil
 	}
-	return committed, nil
+	var out []string
+	for _, w := range migration.LintDown(up, down) {
+		out = append(out, fmt.Sprintf("%s: %s", filepath.Base(downFile), w))
+	}
+	return out
 }
 
-// recordHistory inserts an entry into migrations_history for auditing.
-func (mgr *Manager) recordHistory(action string, version uint) {
-	actor := mgr.actor
-	if actor == "" {
-		actor = "unknown"
+// lockOrderWindow is how many recently applied migrations lintLockOrder
+// compares against.
+const lockOrderWindow = 10
+
+// lintLockOrder returns warnings when upFile locks tables in an order that
+// conflicts with one of the most recently applied migrations in history.
+func (mgr *Manager) lintLockOrder(upFile string) []string {
+	if !mgr.lockOrderLint || !mgr.recordHist {
+		return nil
 	}
-	_, err := mgr.db.Exec(
-		"INSERT INTO migrations_history(action, version, executed_by, committed) VALUES ($1,$2,$3,$4)",
-		action, fmt.Sprintf("%d", version), actor, false,
-	)
+	content, err := fs.ReadFile(mgr.fsys, upFile)
 	if err != nil {
-		mgr.logger.WithError(err).Warn("failed to record history")
+		return nil
 	}
-}
-
-func (mgr *Manager) notifyEvent(event notifier.MigrationEvent) {
-	if mgr.notifier == nil {
-		return
+	recent, err := mgr.recentUpFiles(lockOrderWindow)
+	if err != nil {
+		mgr.logger.WithError(err).Warn("lock-order lint skipped: cannot read history")
+		return nil
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			mgr.logger.WithFields(logrus.Fields{
-				"component":   "notifier",
-				"panic":       r,
-				"error.stack": string(debug.Stack()),
-			}).Error("notifier panic")
-		}
-	}()
-	if err := mgr.notifier.Notify(event); err != nil {
-		mgr.logger.WithError(err).Warn("failed to send notification")
+	var out []string
+	for _, w := range migration.LintLockOrder(content, recent) {
+		out = append(out, fmt.Sprintf("%s: %s", filepath.Base(upFile), w))
 	}
+	return out
 }
 
-// withRetry retries the given migration operation up to maxRetries times.
-func (mgr *Manager) withRetry(op func() error) error {
-	var err error
-	for attempt := 0; attempt <= mgr.maxRetries; attempt++ {
-		if attempt > 0 {
-			mgr.logger.WithField("attempt", attempt).
-				Warn("retrying migration operation")
-			time.Sleep(time.Second * time.Duration(attempt))
+// recentUpFiles returns the contents, keyed by file name, of up to n distinct
+// migrations most recently applied according to history. Versions whose file
+// is no longer present are ignored.
+func (mgr *Manager) recentUpFiles(n int) (map[string][]byte, error) {
+	rows, err := mgr.db.Query(`SELECT version FROM ` + mgr.hist() + ` WHERE action = 'up' ORDER BY id DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var versions []uint
+	seen := map[uint]bool{}
+	for rows.Next() && len(versions) < n {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
 		}
-		err = op()
-		if err == nil || errors.Is(err, migrate.ErrNoChange) {
-			return nil
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || seen[uint(v)] {
+			continue
 		}
-		mgr.logger.WithFields(logrus.Fields{
-			"attempt": attempt,
-			"error":   err,
-		}).Error("migration operation failed")
+		seen[uint(v)] = true
+		versions = append(versions, uint(v))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-	mgr.logger.WithFields(logrus.Fields{
-		"maxRetries": mgr.maxRetries,
-		"error":      err,
-	}).Error("all migration retries exhausted")
-	return err
-}
 
-// pendingUpFiles returns all .up.sql files whose version is > current.
-func (mgr *Manager) pendingUpFiles(cur uint) ([]string, error) {
-	pattern := filepath.Join(mgr.migrationsDir, "*.up.sql")
-	files, err := filepath.Glob(pattern)
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
 	if err != nil {
 		return nil, err
 	}
-	sort.Strings(files)
-	var out []string
+	out := map[string][]byte{}
 	for _, f := range files {
-		parts := strings.SplitN(filepath.Base(f), "_", 2)
-		if v, err := strconv.ParseUint(parts[0], 10, 64); err == nil && uint(v) > cur {
-			out = append(out, f)
+		if v, err := fileVersion(f); err != nil || !seen[v] {
+			continue
+		}
+		if content, err := fs.ReadFile(mgr.fsys, f); err == nil {
+			out[filepath.Base(f)] = content
 		}
 	}
 	return out, nil
 }
 
-// pendingDownFiles returns all .down.sql files for the given version, in reverse order.
-func (mgr *Manager) pendingDownFiles(cur uint) ([]string, error) {
-	pattern := filepath.Join(mgr.migrationsDir, fmt.Sprintf("%d_*.down.sql", cur))
-	files, err := filepath.Glob(pattern)
-	if err != nil {
-		return nil, err
-	}
-	sort.Sort(sort.Reverse(sort.StringSlice(files)))
-	return files, nil
+// MigrationFailure describes a migration skipped by UpContinueOnError.
+type MigrationFailure struct {
+	Version uint
+	File    string
+	Err     error
+}
+
+// UpContinueOnError applies pending migrations one file at a time. When a file
+// fails validation or execution, the dirty flag is cleared by forcing its
+// version, a "failed" history row is recorded and the next file is applied.
+// It is intended for development sandboxes and is refused in production and
+// on dry runs.
+func (mgr *Manager) UpContinueOnError() (failures []MigrationFailure, err error) {
+	err = mgr.track("up", func() error {
+		failures, err = mgr.upContinueOnError()
+		return err
+	})
+	return failures, err
 }
 
-func (mgr *Manager) Up() error {
+func (mgr *Manager) upContinueOnError() ([]MigrationFailure, error) {
+	if mgr.isProduction() {
+		return nil, fmt.Errorf("continue-on-error is not allowed in production")
+	}
+	if mgr.remoteSource() {
+		return nil, fmt.Errorf("continue-on-error needs a file-based migrations source")
+	}
+	if mgr.dryRun {
+		return nil, fmt.Errorf("dry run cannot be combined with continue-on-error")
+	}
 	before, dirty, err := mgr.m.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
-		return fmt.Errorf("read version before Up: %w", err)
+		return nil, fmt.Errorf("read version before Up: %w", err)
 	}
 	if dirty {
-		return fmt.Errorf("database dirty at version %d; manual intervention required", before)
+		return nil, &DirtyError{Version: before}
 	}
 
-	// Lấy danh sách file up sẽ được apply (pending > before)
 	upFiles, _ := mgr.pendingUpFiles(before)
 	if len(upFiles) == 0 {
 		mgr.logger.WithField("actor", mgr.actor).Info("no pending migrations to apply (Up)")
-		return nil
+		return nil, nil
+	}
+	if err := mgr.checkUpFiles(before, upFiles); err != nil {
+		return nil, err
+	}
+	signers, err := mgr.checkSignatures(upFiles)
+	if err != nil {
+		return nil, err
 	}
+	// Like the signature checks, a refused empty file stops the whole run
+	// up front rather than counting as one failure among others.
+	emptyWarnings, err := mgr.checkEmpty(upFiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range emptyWarnings {
+		mgr.logger.WithField("actor", mgr.actor).Warn(w)
+	}
+	mgr.lastRun.Warnings = append(mgr.lastRun.Warnings, emptyWarnings...)
 
-	// 1. Chặn file có version <= DB version
+	invalid := map[string]error{}
 	for _, f := range upFiles {
-		base := filepath.Base(f)
-		parts := strings.SplitN(base, "_", 2)
-		v, _ := strconv.ParseUint(parts[0], 10, 64)
-		if uint(v) <= before {
-			return fmt.Errorf(
-				"migration version %d (file %s) is less than or equal to current DB version %d; refusing to apply, please rebase or resequence your migrations",
-				v, base, before)
-		}
-		committed, err := mgr.VersionCommitted(uint(v))
-		if err != nil {
-			return err
-		}
-		if committed {
-			return fmt.Errorf("migration version %d has been committed; cannot modify committed migrations", v)
+		if err := mgr.validateForRun(f); err != nil {
+			invalid[f] = err
 		}
 	}
 
-	// 2. Check conflict hash cho các file version đã có trong history (phòng trường hợp rollback hoặc file copy lỗi)
-	if mgr.strictHash {
-		for _, f := range upFiles {
-			base := filepath.Base(f)
-			parts := strings.SplitN(base, "_", 2)
-			v, _ := strconv.ParseUint(parts[0], 10, 64)
-			hash, herr := fileHash(f)
-			if herr != nil {
-				return fmt.Errorf("cannot compute hash for %s: %v", f, herr)
-			}
-			//kiểm tra hash trong DB (nếu có)
-			var dbHash string
-			err := mgr.db.QueryRow(`SELECT sha256 FROM migrations_history WHERE action='up' AND version=$1 AND committed=true ORDER BY id DESC LIMIT 1`, fmt.Sprintf("%d", v)).Scan(&dbHash)
-			if err == sql.ErrNoRows {
-				continue
-			}
-			if err != nil {
-				return fmt.Errorf("query hash: %w", err)
-			}
-			if dbHash != "" && dbHash != hash {
-				return fmt.Errorf(
-					"migration version %d (file %s) has been applied with a different hash; refusing to apply: current hash: %s, DB hash: %s; please fix the conflict",
-					v, base, hash, dbHash)
-			}
-		}
+	release, err := mgr.holdLock("up")
+	if err != nil {
+		return nil, err
 	}
-
-	// 3. Log filenames sắp apply
+	defer release()
+	var failures []MigrationFailure
+	start := time.Now()
 	for _, f := range upFiles {
-		mgr.logger.WithField("actor", mgr.actor).Debugf("Applying migration file: %s", filepath.Base(f))
-
-		data, err := os.ReadFile(f)
+		v, err := fileVersion(f)
 		if err != nil {
-			return fmt.Errorf("read %s: %w", f, err)
+			return failures, err
 		}
-		content := string(data)
-		fmt.Println(strings.TrimSpace(content))
-		if ok, err := validate.ValidateSQL(content, map[string]string{"dsn": mgr.dsn}, mgr.validateOpts, mgr.backend.Validator()); !ok || err != nil {
-			if err != nil {
-				mgr.logger.WithError(err).Error("SQL validation failed")
-			}
-			return fmt.Errorf("invalid SQL in %s", filepath.Base(f))
+		from := mgr.observeState()
+		err = invalid[f]
+		var run fileRun
+		if err == nil {
+			run, err = mgr.applyFile(v, f, signers[f])
+		}
+		if err == nil {
+			mgr.recordApplied(v, f, run)
+			continue
+		}
+		mgr.logger.WithError(err).WithFields(logrus.Fields{
+			"version": v,
+			"file":    filepath.Base(f),
+			"actor":   mgr.actor,
+		}).Warn("migration failed; skipping (continue-on-error)")
+		if ferr := mgr.m.Force(int(v)); ferr != nil {
+			return failures, fmt.Errorf("clear dirty state at version %d: %w", v, ferr)
 		}
+		mgr.recordHistory("failed", v, transition{from: from, to: mgr.observeState()})
+		failures = append(failures, MigrationFailure{Version: v, File: filepath.Base(f), Err: err})
 	}
-
-	// 4. Thực thi migrate Up
-	start := time.Now()
-	err = mgr.withRetry(mgr.m.Up)
 	duration := time.Since(start)
-	after, dirtyAfter, _ := mgr.m.Version()
+
+	after, _, _ := mgr.m.Version()
 	status := "success"
-	if err != nil {
+	var runErr error
+	if len(failures) > 0 {
 		status = "fail"
+		runErr = fmt.Errorf("%d migration(s) failed", len(failures))
 	}
 	mgr.notifyEvent(notifier.MigrationEvent{
 		Status:   status,
@@ -301,81 +1400,68 @@ func (mgr *Manager) Up() error {
 		Version:  fmt.Sprintf("%d", after),
 		DB:       mgr.backend.DriverName(),
 		Duration: duration,
-		Error:    err,
+		Error:    runErr,
 		Time:     time.Now(),
 	})
-
-	// 5. Ghi lại history với hash từng file vừa apply (từ before+1 đến after)
-	if err == nil && after > before {
-		for _, f := range upFiles {
-			base := filepath.Base(f)
-			parts := strings.SplitN(base, "_", 2)
-			v, _ := strconv.ParseUint(parts[0], 10, 64)
-			if uint(v) > before && uint(v) <= after {
-				hash, herr := fileHash(f)
-				if herr != nil {
-					mgr.logger.WithError(herr).Warnf("cannot compute hash for %s", f)
-				}
-				actor := mgr.actor
-				if actor == "" {
-					actor = "unknown"
-				}
-				_, err := mgr.db.Exec(
-					`INSERT INTO migrations_history(action, version, executed_by, sha256, committed) VALUES ($1,$2,$3,$4,$5)`,
-					"up", fmt.Sprintf("%d", v), actor, hash, false)
-				if err != nil {
-					mgr.logger.WithError(err).Warnf("failed to record history with hash for version %d", v)
-				} else {
-					mgr.logger.WithFields(logrus.Fields{
-						"version": v,
-						"file":    base,
-						"actor":   actor,
-						"hash":    hash,
-					}).Info("migration up applied and recorded")
-				}
-			}
-		}
-	}
-
-	switch {
-	case err != nil:
-		mgr.logger.WithError(err).
-			WithFields(logrus.Fields{"from": before, "to": after, "actor": mgr.actor}).
-			Error("Up migration failed")
-		return err
-	case dirtyAfter:
-		return fmt.Errorf("Up migration left database dirty at version %d", after)
-	}
-	return nil
+	return failures, nil
 }
 
 // Down rolls back all applied migrations.
-func (mgr *Manager) Down() error {
+func (mgr *Manager) Down() error { return mgr.track("down", mgr.down) }
+
+func (mgr *Manager) down() error {
 	before, dirty, err := mgr.m.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		return fmt.Errorf("read version before Down: %w", err)
 	}
 	if dirty {
-		return fmt.Errorf("database dirty at version %d; manual intervention required", before)
+		return &DirtyError{Version: before}
 	}
+	from := stateOf(before, dirty, err)
 
 	var exists bool
-	if err := mgr.db.QueryRow(`SELECT true FROM migrations_history WHERE committed = true LIMIT 1`).Scan(&exists); err != nil && err != sql.ErrNoRows {
-		return err
+	if mgr.recordHist {
+		if err := mgr.db.QueryRow(`SELECT true FROM ` + mgr.hist() + ` WHERE committed = true LIMIT 1`).Scan(&exists); err != nil && err != sql.ErrNoRows && !mgr.missingTable(err) {
+			return err
+		}
 	}
 	if exists {
 		return fmt.Errorf("migration version %d has been committed; cannot modify committed migrations", before)
 	}
+	if err := mgr.checkDownFiles(before, -1); err != nil {
+		return err
+	}
 
 	// Log filenames in reverse order
+	downFile := fmt.Sprintf("version %d", before)
 	if files, _ := mgr.pendingDownFiles(before); len(files) > 0 {
+		downFile = filepath.Base(files[0])
 		for _, f := range files {
 			mgr.logger.Debugf("Rolling back migration file: %s", filepath.Base(f))
 		}
 	}
 
+	if err := mgr.checkRollback(before, -1, true); err != nil {
+		return err
+	}
+	if mgr.dryRun {
+		files, err := mgr.downPlan(before, -1)
+		if err != nil {
+			return err
+		}
+		mgr.plan(files)
+		return nil
+	}
+
+	release, err := mgr.holdLock("down")
+	if err != nil {
+		return err
+	}
+	defer release()
 	start := time.Now()
+	stop := mgr.heartbeat("down", downFile)
 	err = mgr.withRetry(mgr.m.Down)
+	stop()
 	duration := time.Since(start)
 
 	after, dirtyAfter, _ := mgr.m.Version()
@@ -408,7 +1494,7 @@ func (mgr *Manager) Down() error {
 			"to":    after,
 			"actor": mgr.actor,
 		}).Info("migrations rolled back (Down)")
-		mgr.recordHistory("down", after)
+		mgr.recordHistory("down", after, transition{from: from, to: stateOf(after, dirtyAfter, nil)})
 	default:
 		mgr.logger.WithField("actor", mgr.actor).Info("no migrations to roll back (Down)")
 	}
@@ -417,13 +1503,18 @@ func (mgr *Manager) Down() error {
 
 // Steps migrates exactly n steps (negative to rollback).
 func (mgr *Manager) Steps(n int) error {
+	return mgr.track("steps", func() error { return mgr.steps(n) })
+}
+
+func (mgr *Manager) steps(n int) error {
 	before, dirty, err := mgr.m.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		return fmt.Errorf("read version before Steps: %w", err)
 	}
 	if dirty {
-		return fmt.Errorf("database dirty at version %d; manual intervention required", before)
+		return &DirtyError{Version: before}
 	}
+	from := stateOf(before, dirty, err)
 
 	if n < 0 {
 		committed, err := mgr.VersionCommitted(before)
@@ -433,29 +1524,55 @@ func (mgr *Manager) Steps(n int) error {
 		if committed {
 			return fmt.Errorf("migration version %d has been committed; cannot modify committed migrations", before)
 		}
+		if err := mgr.checkDownFiles(before, -n); err != nil {
+			return err
+		}
 	}
 
 	if n < 0 {
 		files, _ := mgr.pendingDownFiles(before)
 		if len(files) > 0 {
 			f := files[0]
-			data, err := os.ReadFile(f)
+			data, err := fs.ReadFile(mgr.fsys, f)
 			if err != nil {
 				return fmt.Errorf("read %s: %w", f, err)
 			}
-			content := string(data)
-			fmt.Println(strings.TrimSpace(content))
-			if ok, err := validate.ValidateSQL(content, map[string]string{"dsn": mgr.dsn}, mgr.validateOpts, mgr.backend.Validator()); !ok || err != nil {
-				if err != nil {
-					mgr.logger.WithError(err).Error("SQL validation failed")
-				}
-				return fmt.Errorf("invalid SQL in %s", filepath.Base(f))
+			if err := mgr.validateContent(f, string(data)); err != nil {
+				return err
 			}
 		}
 	}
 
+	if n < 0 {
+		// the first down file was already dry-run above
+		if err := mgr.checkRollback(before, -n, false); err != nil {
+			return err
+		}
+	}
+
+	if mgr.dryRun {
+		var files []string
+		if n < 0 {
+			files, err = mgr.downPlan(before, -n)
+		} else if files, err = mgr.pendingUpFiles(before); len(files) > n {
+			files = files[:n]
+		}
+		if err != nil {
+			return err
+		}
+		mgr.plan(files)
+		return nil
+	}
+
+	release, err := mgr.holdLock("steps")
+	if err != nil {
+		return err
+	}
+	defer release()
 	start := time.Now()
+	stop := mgr.heartbeat("steps", fmt.Sprintf("%d step(s) from version %d", n, before))
 	err = mgr.withRetry(func() error { return mgr.m.Steps(n) })
+	stop()
 	duration := time.Since(start)
 
 	after, dirtyAfter, _ := mgr.m.Version()
@@ -485,22 +1602,24 @@ func (mgr *Manager) Steps(n int) error {
 			"to":    after,
 			"actor": mgr.actor,
 		}).Infof("migrations applied %d steps", n)
-		mgr.recordHistory("up", after)
+		mgr.recordHistory("up", after, transition{from: from, to: stateOf(after, dirtyAfter, nil)})
 	case before > after:
 		mgr.logger.WithFields(logrus.Fields{
 			"from":  before,
 			"to":    after,
 			"actor": mgr.actor,
 		}).Infof("migrations rolled back %d steps", -n)
-		mgr.recordHistory("rollback", after)
+		mgr.recordHistory("rollback", after, transition{from: from, to: stateOf(after, dirtyAfter, nil)})
 	default:
 		mgr.logger.WithField("actor", mgr.actor).Info("no effect from Steps migration")
 	}
 	return nil
 }
 
-// Force sets the DB to a specific version and clears the dirty flag.
+// Force sets the DB to a specific version and clears the dirty flag. The
+// state it replaced is kept in a "force" history row.
 func (mgr *Manager) Force(version int) error {
+	from := mgr.observeState()
 	if err := mgr.m.Force(version); err != nil {
 		return fmt.Errorf("force to version %d failed: %w", version, err)
 	}
@@ -508,6 +1627,9 @@ func (mgr *Manager) Force(version int) error {
 		"version": version,
 		"actor":   mgr.actor,
 	}).Warn("forced database version; dirty flag cleared")
+	if version >= 0 {
+		mgr.recordHistory("force", uint(version), transition{from: from, to: mgr.observeState()})
+	}
 	return nil
 }
 
@@ -517,7 +1639,7 @@ func (mgr *Manager) Status() (uint, int, error) {
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		return 0, 0, err
 	}
-	files, _ := filepath.Glob(filepath.Join(mgr.migrationsDir, "*.up.sql"))
+	files, _ := fs.Glob(mgr.fsys, "*.up.sql")
 	pending := 0
 	for _, f := range files {
 		parts := strings.SplitN(filepath.Base(f), "_", 2)
@@ -536,9 +1658,20 @@ func (mgr *Manager) Status() (uint, int, error) {
 
 // Version returns (currentVersion, dirtyFlag, error).
 func (mgr *Manager) Version() (uint, bool, error) {
+	if mgr.readOnly {
+		return mgr.readVersion()
+	}
 	return mgr.m.Version()
 }
 
+// Dialect returns the validation dialect of the Manager's backend, so callers
+// can split and classify SQL exactly as the Manager does.
+func (mgr *Manager) Dialect() validate.Dialect { return mgr.backend.Validator() }
+
+// BackendName returns the driver name of the Manager's backend, e.g.
+// "postgres".
+func (mgr *Manager) BackendName() string { return mgr.backend.DriverName() }
+
 // SafeForce only allows forcing down by one if dirty, and never up beyond last file.
 func (mgr *Manager) SafeForce(target int) error {
 	cur, dirty, err := mgr.m.Version()
@@ -565,22 +1698,38 @@ func (mgr *Manager) SafeForce(target int) error {
 	if uint(target) != cur-1 {
 		return fmt.Errorf("dirty at %d; only allowed force to %d", cur, cur-1)
 	}
-	if err := mgr.m.Force(target); err != nil {
-		return fmt.Errorf("force failed: %w", err)
+	from := stateOf(cur, dirty, nil)
+	start := time.Now()
+	err = mgr.m.Force(target)
+	status, version := "safe-force", target
+	if err != nil {
+		status, version = "fail", int(cur)
+		err = fmt.Errorf("force failed: %w", err)
+	}
+	mgr.notifyEvent(notifier.MigrationEvent{
+		Status:   status,
+		User:     mgr.actor,
+		Version:  fmt.Sprintf("%d", version),
+		DB:       mgr.backend.DriverName(),
+		Duration: time.Since(start),
+		Error:    err,
+		Time:     time.Now(),
+	})
+	if err != nil {
+		return err
 	}
 	mgr.logger.WithFields(logrus.Fields{
 		"from":  cur,
 		"to":    target,
 		"actor": mgr.actor,
 	}).Warn("SAFE-FORCE executed, dirty cleared")
-	mgr.recordHistory("safe-force", uint(target))
+	mgr.recordHistory("safe-force", uint(target), transition{from: from, to: mgr.observeState()})
 	return nil
 }
 
 // lastFileVersion finds the highest version number among *.up.sql files.
 func (mgr *Manager) lastFileVersion() (uint, error) {
-	pattern := filepath.Join(mgr.migrationsDir, "*.up.sql")
-	files, err := filepath.Glob(pattern)
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
 	if err != nil {
 		return 0, err
 	}
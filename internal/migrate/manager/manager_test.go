@@ -0,0 +1,791 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/templates"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+const testHistoryDDL = `CREATE TABLE migrations_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	executed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	action TEXT NOT NULL,
+	version TEXT NOT NULL,
+	executed_by TEXT NOT NULL,
+	committed BOOLEAN NOT NULL DEFAULT FALSE,
+	sha256 TEXT NOT NULL DEFAULT ''
+)`
+
+// newTestManager writes files into a fresh migrations directory and returns a
+// Manager backed by a temporary SQLite database with the history table created.
+func newTestManager(t *testing.T, files map[string]string, opts ...Option) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	dsn := filepath.Join(t.TempDir(), "test.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if _, err := db.Exec(testHistoryDDL); err != nil {
+		t.Fatalf("create history table: %v", err)
+	}
+	db.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mgr, err := NewManager(SQLiteBackend{}, dsn, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil, opts...)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = mgr.Close() })
+	return mgr
+}
+
+func TestUpOnEmptySQLiteDatabase(t *testing.T) {
+	initUp, err := templates.InitUp("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"000001_init.up.sql":   initUp,
+		"000001_init.down.sql": templates.InitDown,
+		"000002_a.up.sql":      "CREATE TABLE a (id INTEGER);",
+		"000002_a.down.sql":    "DROP TABLE a;",
+	})
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	// nothing creates the file or the history table beforehand
+	mgr, err := NewManager(SQLiteBackend{}, filepath.Join(t.TempDir(), "fresh.db"), dir, 0, logrus.NewEntry(log), "tester", true, nil, nil)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	t.Cleanup(func() { _ = mgr.Close() })
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up on an empty database: %v", err)
+	}
+	if v, dirty, err := mgr.m.Version(); err != nil || v != 2 || dirty {
+		t.Fatalf("version = %d dirty=%v err=%v, want 2", v, dirty, err)
+	}
+	if got := historyRows(t, mgr); !reflect.DeepEqual(got, []string{"up:1", "up:2"}) {
+		t.Fatalf("history = %v", got)
+	}
+}
+
+// historyRows returns "action:version" pairs from migrations_history in insert order.
+func historyRows(t *testing.T, mgr *Manager) []string {
+	t.Helper()
+	rows, err := mgr.db.Query(`SELECT action, version FROM ` + mgr.hist() + ` ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var action, version string
+		if err := rows.Scan(&action, &version); err != nil {
+			t.Fatalf("scan history: %v", err)
+		}
+		out = append(out, action+":"+version)
+	}
+	return out
+}
+
+func TestUpContinueOnError(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql": "CREATE TABLE b (id INTEGER);",
+		// validates on its own but collides with 000002 when applied
+		"000003_dup.up.sql": "CREATE TABLE b (id INTEGER);",
+		"000004_c.up.sql":   "CREATE TABLE c (id INTEGER);",
+	}, WithEnv("development"))
+
+	failures, err := mgr.UpContinueOnError()
+	if err != nil {
+		t.Fatalf("UpContinueOnError: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Version != 3 {
+		t.Fatalf("expected a single failure for version 3, got %+v", failures)
+	}
+	v, dirty, err := mgr.Version()
+	if err != nil || dirty || v != 4 {
+		t.Fatalf("expected clean version 4, got v=%d dirty=%v err=%v", v, dirty, err)
+	}
+	if _, err := mgr.db.Exec(`INSERT INTO c (id) VALUES (1)`); err != nil {
+		t.Fatalf("migration after failure was not applied: %v", err)
+	}
+	want := []string{"up:1", "up:2", "failed:3", "up:4"}
+	got := historyRows(t, mgr)
+	if len(got) != len(want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("history = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestUpContinueOnErrorBlockedInProduction(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+	}, WithEnv("production"))
+	if _, err := mgr.UpContinueOnError(); err == nil {
+		t.Fatal("expected continue-on-error to be refused in production")
+	}
+	if v, _, _ := mgr.Version(); v != 0 {
+		t.Fatalf("no migration should run in production, got version %d", v)
+	}
+}
+
+func TestRecordHistoryDisabled(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"000002_b.down.sql": "DROP TABLE b;",
+	}, WithRecordHistory(false))
+	// Any history query would now fail because the table is gone.
+	if _, err := mgr.db.Exec(`DROP TABLE migrations_history`); err != nil {
+		t.Fatalf("drop history: %v", err)
+	}
+	hook := test.NewLocal(mgr.logger.Logger)
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Steps(-1); err != nil {
+		t.Fatalf("Steps(-1): %v", err)
+	}
+	if err := mgr.Down(); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if err := mgr.CommitAll(); err == nil {
+		t.Fatal("CommitAll should fail when history is disabled")
+	}
+	for _, e := range hook.AllEntries() {
+		if e.Level <= logrus.WarnLevel {
+			t.Fatalf("unexpected %s log: %s", e.Level, e.Message)
+		}
+	}
+}
+
+// committedVersions returns the versions whose history rows are committed.
+func committedVersions(t *testing.T, mgr *Manager) []string {
+	t.Helper()
+	rows, err := mgr.db.Query(`SELECT version FROM migrations_history WHERE committed = true ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query committed: %v", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		rows.Scan(&v)
+		out = append(out, v)
+	}
+	return out
+}
+
+var threeMigrations = map[string]string{
+	"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+	"000002_b.up.sql": "CREATE TABLE b (id INTEGER);",
+	"000003_c.up.sql": "CREATE TABLE c (id INTEGER);",
+}
+
+func TestCommitSingleVersion(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(2); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if got := committedVersions(t, mgr); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("committed = %v, want [2]", got)
+	}
+	if err := mgr.Commit(2); err == nil {
+		t.Fatal("committing an already committed version should fail")
+	}
+}
+
+func TestCommitThrough(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.CommitThrough(2); err != nil {
+		t.Fatalf("CommitThrough: %v", err)
+	}
+	if got := committedVersions(t, mgr); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("committed = %v, want [1 2]", got)
+	}
+	if c, _ := mgr.VersionCommitted(3); c {
+		t.Fatal("version 3 must stay editable")
+	}
+}
+
+func TestUncommittedVersionsChangesNothing(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Commit(1); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	got, err := mgr.UncommittedVersions()
+	if err != nil {
+		t.Fatalf("UncommittedVersions: %v", err)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Fatalf("uncommitted = %v, want [2 3]", got)
+	}
+	if got := committedVersions(t, mgr); len(got) != 1 || got[0] != "1" {
+		t.Fatalf("committed = %v, want [1] untouched", got)
+	}
+}
+
+func TestStripLoggedComments(t *testing.T) {
+	content := "-- NOTE: postgres://admin:secret@db\nCREATE TABLE a (id INTEGER); /* internal */\n"
+	mgr := newTestManager(t, map[string]string{"000001_a.up.sql": content}, WithStripLoggedComments(true))
+	var logged bytes.Buffer
+	mgr.sqlOut = &logged
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if strings.Contains(logged.String(), "secret") || strings.Contains(logged.String(), "internal") {
+		t.Fatalf("comments leaked into logged SQL: %q", logged.String())
+	}
+	if !strings.Contains(logged.String(), "CREATE TABLE a") {
+		t.Fatalf("statement missing from logged SQL: %q", logged.String())
+	}
+	// execution and hashing use the file as written, comments included
+	var hash string
+	if err := mgr.db.QueryRow(`SELECT sha256 FROM migrations_history WHERE version = '1'`).Scan(&hash); err != nil {
+		t.Fatalf("query hash: %v", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(content))); hash != want {
+		t.Fatalf("hash = %s, want hash of full file %s", hash, want)
+	}
+}
+
+func TestWithRetryAttempts(t *testing.T) {
+	oldSleep := sleep
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = oldSleep })
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	for _, retries := range []int{0, 1, 4} {
+		mgr := &Manager{maxRetries: retries, logger: logrus.NewEntry(log)}
+		attempts := 0
+		err := mgr.withRetry(func() error {
+			attempts++
+			return errors.New("boom")
+		})
+		if err == nil {
+			t.Fatalf("retries=%d: expected error", retries)
+		}
+		if attempts != retries+1 {
+			t.Fatalf("retries=%d: attempts = %d, want %d", retries, attempts, retries+1)
+		}
+	}
+}
+
+func TestStartedNoTransactionFileIsNotRetried(t *testing.T) {
+	oldSleep := sleep
+	sleep = func(time.Duration) {}
+	t.Cleanup(func() { sleep = oldSleep })
+
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE seen (id INTEGER);",
+		"000002_b.up.sql": "-- kaeshi:no-transaction\nINSERT INTO seen (id) VALUES (1);\nINSERT INTO missing (id) VALUES (1);",
+	}, WithValidation(false))
+	mgr.maxRetries = 2
+	if err := mgr.Up(); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("Up err = %v, want the failing statement", err)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM seen`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("first statement ran %d times, want once", n)
+	}
+}
+
+func TestWithRetryBacksOffExponentially(t *testing.T) {
+	var pauses []time.Duration
+	oldSleep, oldJitter := sleep, jitterFraction
+	sleep = func(d time.Duration) { pauses = append(pauses, d) }
+	jitterFraction = func() float64 { return 0.5 }
+	t.Cleanup(func() { sleep, jitterFraction = oldSleep, oldJitter })
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mgr := &Manager{
+		maxRetries: 5,
+		backoff:    Backoff{Base: 100 * time.Millisecond, Max: 500 * time.Millisecond, Multiplier: 3, Jitter: 0.2},
+		logger:     logrus.NewEntry(log),
+	}
+	attempts := 0
+	err := mgr.withRetry(func() error {
+		if attempts++; attempts < 4 {
+			return errors.New("lock busy")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withRetry: %v", err)
+	}
+	if attempts != 4 {
+		t.Fatalf("attempts = %d, want 4", attempts)
+	}
+	// 100ms, 300ms, then capped at 500ms, each shortened by 0.2·0.5
+	want := []time.Duration{90 * time.Millisecond, 270 * time.Millisecond, 450 * time.Millisecond}
+	if !reflect.DeepEqual(pauses, want) {
+		t.Fatalf("pauses = %v, want %v", pauses, want)
+	}
+}
+
+func TestUpWithoutValidation(t *testing.T) {
+	// a 1ns budget makes every dry run fail, so only a skipped one passes
+	strict := newTestManager(t, threeMigrations, WithValidateTimeout(time.Nanosecond))
+	if err := strict.Up(); err == nil || !strings.Contains(err.Error(), "execution failed") {
+		t.Fatalf("validated Up err = %v, want execution failed", err)
+	}
+
+	mgr := newTestManager(t, threeMigrations, WithValidateTimeout(time.Nanosecond), WithValidation(false))
+	logger, hook := test.NewNullLogger()
+	mgr.logger = logrus.NewEntry(logger)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up without validation: %v", err)
+	}
+	warned := 0
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "validation disabled") {
+			warned++
+		}
+	}
+	if warned != 3 {
+		t.Fatalf("got %d validation-disabled warnings, want one per file", warned)
+	}
+
+	denied := newTestManager(t, map[string]string{"000001_a.up.sql": "DROP TABLE IF EXISTS a;"},
+		WithValidation(false), WithDeniedStatements([]string{"DROP TABLE"}, false))
+	if err := denied.Up(); err == nil || !strings.Contains(err.Error(), "denied by policy") {
+		t.Fatalf("Up err = %v, want the deny policy to still apply", err)
+	}
+
+	// Only the dry run is skipped: directives and isolation levels are
+	// checked before anything is applied.
+	for body, want := range map[string]string{
+		"-- kaeshi:bogus\nCREATE TABLE b (id INTEGER);":                     "unknown kaeshi directive",
+		"-- kaeshi:isolation repeatable read\nCREATE TABLE b (id INTEGER);": "not supported by sqlite",
+	} {
+		bad := newTestManager(t, map[string]string{
+			"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+			"000002_b.up.sql": body,
+		}, WithValidation(false))
+		if err := bad.Up(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Up err = %v, want %q", err, want)
+		}
+		if v, _, _ := bad.Version(); v != 0 {
+			t.Fatalf("version = %d, want nothing applied before the checks fail", v)
+		}
+	}
+}
+
+func TestHistoryRecordsTransactionMode(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql": "-- kaeshi:no-transaction\nCREATE TABLE b (id INTEGER);\nCREATE TABLE c (id INTEGER);",
+	})
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	// Running the column check again must not fail or duplicate the column.
+	mgr.histColumnsReady = false
+	mgr.ensureHistoryColumns()
+	if !mgr.histColumnsReady {
+		t.Fatal("ensureHistoryColumns is not idempotent")
+	}
+
+	rows, err := mgr.db.Query(`SELECT version, in_transaction FROM migrations_history WHERE action = 'up' ORDER BY id`)
+	if err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	defer rows.Close()
+	got := map[string]bool{}
+	for rows.Next() {
+		var v string
+		var inTx bool
+		if err := rows.Scan(&v, &inTx); err != nil {
+			t.Fatalf("scan: %v", err)
+		}
+		got[v] = inTx
+	}
+	if len(got) != 2 || !got["1"] || got["2"] {
+		t.Fatalf("in_transaction = %v, want map[1:true 2:false]", got)
+	}
+	if v, dirty, _ := mgr.Version(); v != 2 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 2 clean", v, dirty)
+	}
+}
+
+func TestUpSkipsFilesScopedToOtherEnv(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":    "CREATE TABLE a (id INTEGER);",
+		"000002_prod.up.sql": "-- kaeshi:env production, staging\nINSERT INTO missing VALUES (1);",
+		"000003_c.up.sql":    "CREATE TABLE c (id INTEGER);",
+	}, WithEnv("development"))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 3 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 3 clean", v, dirty)
+	}
+	var reason sql.NullString
+	if err := mgr.db.QueryRow(`SELECT reason FROM migrations_history WHERE action = 'up' AND version = '2'`).Scan(&reason); err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	if !strings.Contains(reason.String, "production,staging") {
+		t.Fatalf("reason = %q, want skip note naming the scoped envs", reason.String)
+	}
+	if got := historyRows(t, mgr); len(got) != 3 {
+		t.Fatalf("history = %v, want three up rows", got)
+	}
+}
+
+func TestRollbackSkipsDownOfFilesScopedToOtherEnv(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":      "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql":    "DROP TABLE a;",
+		"000002_prod.up.sql":   "-- kaeshi:env production\nCREATE TABLE prod (id INTEGER);",
+		"000002_prod.down.sql": "DROP TABLE prod;",
+		"000003_c.up.sql":      "CREATE TABLE c (id INTEGER);",
+		"000003_c.down.sql":    "DROP TABLE c;",
+	}, WithEnv("development"))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Steps(-2); err != nil {
+		t.Fatalf("Steps(-2): %v", err)
+	}
+	if v, dirty, _ := mgr.Version(); v != 1 || dirty {
+		t.Fatalf("version = %d dirty=%v, want 1 clean", v, dirty)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('a', 'c')`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("tables a and c = %d (%v), want only a left", n, err)
+	}
+}
+
+func TestValidateWarnsOnLockOrderInversion(t *testing.T) {
+	files := map[string]string{
+		"000001_a.up.sql":  "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);",
+		"000002_ab.up.sql": "UPDATE a SET id = 1;\nUPDATE b SET id = 1;",
+		"000003_ba.up.sql": "UPDATE b SET id = 2;\nUPDATE a SET id = 2;",
+	}
+	mgr := newTestManager(t, files)
+	if err := mgr.Steps(2); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	warnings, err := mgr.Validate()
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "000003_ba.up.sql: locks b before a") {
+		t.Fatalf("expected lock-order warning, got %v", warnings)
+	}
+
+	mgr = newTestManager(t, files, WithLockOrderLint(false))
+	if err := mgr.Steps(2); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if warnings, err := mgr.Validate(); err != nil || len(warnings) != 0 {
+		t.Fatalf("disabled lint: warnings=%v err=%v", warnings, err)
+	}
+}
+
+func TestTablePrefixSharesDatabase(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "shared.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	open := func(prefix string, files map[string]string) *Manager {
+		ddl := strings.Replace(testHistoryDDL, "migrations_history", prefix+"migrations_history", 1)
+		if _, err := db.Exec(ddl); err != nil {
+			t.Fatalf("create %s history table: %v", prefix, err)
+		}
+		dir := t.TempDir()
+		writeFiles(t, dir, files)
+		mgr, err := NewManager(SQLiteBackend{}, dsn, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil, WithTablePrefix(prefix))
+		if err != nil {
+			t.Fatalf("new manager %s: %v", prefix, err)
+		}
+		t.Cleanup(func() { _ = mgr.Close() })
+		return mgr
+	}
+	billing := open("billing_", map[string]string{
+		"000001_invoices.up.sql": "CREATE TABLE invoices (id INTEGER);",
+		"000002_lines.up.sql":    "CREATE TABLE invoice_lines (id INTEGER);",
+	})
+	crm := open("crm_", map[string]string{
+		"000001_contacts.up.sql": "CREATE TABLE contacts (id INTEGER);",
+	})
+	for _, mgr := range []*Manager{billing, crm} {
+		if err := mgr.Up(); err != nil {
+			t.Fatalf("Up %s: %v", mgr.tablePrefix, err)
+		}
+	}
+
+	if v, _, _ := billing.Version(); v != 2 {
+		t.Fatalf("billing version = %d, want 2", v)
+	}
+	if v, _, _ := crm.Version(); v != 1 {
+		t.Fatalf("crm version = %d, want 1", v)
+	}
+	if got := historyRows(t, billing); len(got) != 2 {
+		t.Fatalf("billing history = %v, want two rows", got)
+	}
+	if got := historyRows(t, crm); len(got) != 1 || got[0] != "up:1" {
+		t.Fatalf("crm history = %v, want [up:1]", got)
+	}
+	for _, table := range []string{"billing_schema_migrations", "crm_schema_migrations"} {
+		var n int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&n); err != nil || n != 1 {
+			t.Fatalf("table %s missing: n=%d err=%v", table, n, err)
+		}
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('schema_migrations', 'migrations_history')`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("unprefixed tables created: n=%d err=%v", n, err)
+	}
+}
+
+func TestTablePrefixRejectsInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewManager(SQLiteBackend{}, filepath.Join(dir, "x.db"), dir, 0, logrus.NewEntry(logrus.New()), "tester", false, nil, nil, WithTablePrefix("bad-prefix;"))
+	if err == nil || !strings.Contains(err.Error(), "invalid table prefix") {
+		t.Fatalf("expected invalid prefix error, got %v", err)
+	}
+}
+
+func TestTableNamesReplaceDefaults(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, threeMigrations)
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(strings.Replace(testHistoryDDL, "migrations_history", "app_history", 1)); err != nil {
+		t.Fatalf("create history table: %v", err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	mgr, err := NewManager(SQLiteBackend{}, dsn, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil, WithTableNames("app_versions", "app_history"))
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	defer mgr.Close()
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got := historyRows(t, mgr); len(got) != 3 {
+		t.Fatalf("history = %v, want three rows in app_history", got)
+	}
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'app_versions'`).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("app_versions missing: n=%d err=%v", n, err)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name IN ('schema_migrations', 'migrations_history')`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("default tables created: n=%d err=%v", n, err)
+	}
+
+	_, err = NewManager(SQLiteBackend{}, dsn, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil, WithTableNames("", "bad name"))
+	if err == nil || !strings.Contains(err.Error(), "invalid table name") {
+		t.Fatalf("expected invalid table name error, got %v", err)
+	}
+}
+
+func TestValidateStrictOrderRejectsLateFile(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000003_c.up.sql": "CREATE TABLE c (id INTEGER);",
+	}, WithStrictOrder(true))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := mgr.Validate(); err != nil {
+		t.Fatalf("Validate before late file: %v", err)
+	}
+
+	writeFiles(t, mgr.migrationsDir, map[string]string{"000002_b.up.sql": "CREATE TABLE b (id INTEGER);"})
+	_, err := mgr.Validate()
+	if err == nil || !strings.Contains(err.Error(), "000002_b.up.sql") {
+		t.Fatalf("expected strict-order error naming 000002_b.up.sql, got %v", err)
+	}
+
+	mgr.strictOrder = false
+	if _, err := mgr.Validate(); err != nil {
+		t.Fatalf("Validate without strict-order: %v", err)
+	}
+}
+
+func TestValidateSinceVersion(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql": "CREATE TABLE b (id INTEGER",
+		"000003_c.up.sql": "CREATE TABLE c (id INTEGER);",
+	})
+	var buf bytes.Buffer
+	mgr.sqlOut = &buf
+
+	if _, err := mgr.ValidateSince(2); err != nil {
+		t.Fatalf("ValidateSince(2): %v", err)
+	}
+	if got := buf.String(); strings.Contains(got, "TABLE a") || strings.Contains(got, "TABLE b") || !strings.Contains(got, "TABLE c") {
+		t.Fatalf("ValidateSince(2) validated the wrong files:\n%s", got)
+	}
+	if _, err := mgr.ValidateSince(1); err == nil || !strings.Contains(err.Error(), "000002_b") {
+		t.Fatalf("ValidateSince(1) should reach the broken file 2, got %v", err)
+	}
+}
+
+func TestUpLargeSeedFiles(t *testing.T) {
+	var seed strings.Builder
+	seed.WriteString("-- kaeshi:no-transaction\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&seed, "INSERT INTO a (id) VALUES (%d); -- padding; to grow the file past the inline limit\n", i)
+	}
+	var txSeed strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&txSeed, "INSERT INTO a (id) VALUES (%d); -- padding; to grow the file past the inline limit\n", i)
+	}
+	if seed.Len() <= validate.MaxInlineSQLSize || txSeed.Len() <= validate.MaxInlineSQLSize {
+		t.Fatal("seed files must exceed the inline validation limit")
+	}
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":    "CREATE TABLE a (id INTEGER);",
+		"000002_seed.up.sql": seed.String(),
+		"000003_more.up.sql": txSeed.String(),
+	})
+	var out bytes.Buffer
+	mgr.sqlOut = &out
+
+	// Pending files are validated against the current schema, so create a first.
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM a`).Scan(&n); err != nil || n != 4000 {
+		t.Fatalf("rows = %d (%v), want 4000", n, err)
+	}
+	if !strings.Contains(out.String(), "000002_seed.up.sql") || strings.Contains(out.String(), "VALUES (1999)") {
+		t.Fatalf("large files should be summarized, not echoed:\n%.300s", out.String())
+	}
+}
+
+func TestUpContextStopsAtDeadline(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	err := mgr.UpContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("UpContext error = %v, want deadline exceeded", err)
+	}
+	if got := historyRows(t, mgr); len(got) != 0 {
+		t.Fatalf("history = %v, want nothing applied", got)
+	}
+	if v, ok := mgr.LastApplied(); !ok || v != 0 {
+		t.Fatalf("LastApplied = %d, %v; want 0, true", v, ok)
+	}
+}
+
+func TestNoTransactionStatementsUseOperationContext(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	mgr.ctx = ctx
+	err := mgr.applyWithoutTransaction(1, strings.NewReader("CREATE TABLE a (id INTEGER);"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("applyWithoutTransaction err = %v, want the canceled context", err)
+	}
+}
+
+func TestDialectAndBackendNameAccessors(t *testing.T) {
+	all := map[string]DBBackend{"sqlite": SQLiteBackend{}}
+	for name, b := range backends {
+		all[name] = b
+	}
+	for name, b := range all {
+		mgr := &Manager{backend: b}
+		if got := mgr.BackendName(); got != name {
+			t.Errorf("BackendName() = %q, want %q", got, name)
+		}
+		d := mgr.Dialect()
+		if d == nil || d.DriverName() != name {
+			t.Errorf("%s: Dialect() = %v, want the %s dialect", name, d, name)
+			continue
+		}
+		stmts, err := d.SplitStatements("CREATE TABLE a (id INTEGER); INSERT INTO a VALUES (1);")
+		if err != nil || len(stmts) != 2 || d.StatementType(stmts[0]) != "DDL" {
+			t.Errorf("%s: split = %q, %v; want 2 statements starting with DDL", name, stmts, err)
+		}
+	}
+}
+
+func TestDeniedStatementsRefusedWhateverConfirmation(t *testing.T) {
+	files := map[string]string{
+		"000001_a.up.sql":    "CREATE TABLE a (id INTEGER);",
+		"000002_drop.up.sql": "DROP TABLE a;",
+	}
+	prod := newTestManager(t, files, WithEnv("production"), WithDeniedStatements([]string{"DROP TABLE", "TRUNCATE"}, false))
+	prod.validateOpts.ConfirmFn = func(string) (bool, error) { return true, nil }
+	if err := prod.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	err := prod.Up()
+	if err == nil || !strings.Contains(err.Error(), "denied by policy (DROP TABLE)") {
+		t.Fatalf("Up in production = %v, want policy denial", err)
+	}
+	if v, _, _ := prod.Version(); v != 1 {
+		t.Fatalf("the DROP should not be applied, got version %d", v)
+	}
+
+	dev := newTestManager(t, files, WithEnv("development"))
+	applyAll(t, dev)
+
+	override := newTestManager(t, files, WithEnv("production"), WithDeniedStatements([]string{"DROP TABLE"}, true))
+	applyAll(t, override)
+}
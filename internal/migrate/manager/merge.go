@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MergeDirs exposes the migration files of several directories as one flat
+// fs.FS, so golang-migrate and the file checks see a single version-ordered
+// set. Like tarGzFS it packs the files into an in-memory zip. A version may
+// only be defined in one directory.
+func MergeDirs(dirs ...string) (fs.FS, error) {
+	origin := map[string]string{}      // file name -> directory
+	byVersion := map[string][]string{} // version and suffix -> dir/file
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("read migrations dir: %w", err)
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			name := e.Name()
+			if prev, ok := origin[name]; ok {
+				return nil, fmt.Errorf("migration %s exists in both %s and %s", name, prev, dir)
+			}
+			origin[name] = dir
+			for _, suffix := range []string{".up.sql", ".down.sql"} {
+				if strings.HasSuffix(name, suffix) {
+					if v, err := fileVersion(name); err == nil {
+						key := fmt.Sprintf("version %d (%s)", v, suffix)
+						byVersion[key] = append(byVersion[key], filepath.Join(dir, name))
+					}
+				}
+			}
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return nil, err
+			}
+			w, err := zw.Create(name)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := w.Write(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for key, files := range byVersion {
+		if len(files) > 1 {
+			conflicts = append(conflicts, fmt.Sprintf("%s: %s", key, strings.Join(files, ", ")))
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("migration versions must be unique across directories:\n  %s", strings.Join(conflicts, "\n  "))
+	}
+	return zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+}
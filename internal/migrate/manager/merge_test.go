@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMergeDirsOrdersVersionsAcrossDirs(t *testing.T) {
+	core, billing := t.TempDir(), t.TempDir()
+	writeFiles(t, core, map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0003_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email TEXT;",
+		"0003_add_email.down.sql":    "ALTER TABLE users DROP COLUMN email;",
+	})
+	writeFiles(t, billing, map[string]string{
+		"0002_create_invoices.up.sql":   "CREATE TABLE invoices (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users (id));",
+		"0002_create_invoices.down.sql": "DROP TABLE invoices;",
+	})
+
+	merged, err := MergeDirs(core, billing)
+	if err != nil {
+		t.Fatalf("MergeDirs: %v", err)
+	}
+	ups, err := fs.Glob(merged, "*.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0001_create_users.up.sql", "0002_create_invoices.up.sql", "0003_add_email.up.sql"}
+	if !reflect.DeepEqual(ups, want) {
+		t.Fatalf("merged up files = %v, want %v", ups, want)
+	}
+
+	mgr := newTestManager(t, nil, WithFS(merged))
+	applyAll(t, mgr)
+	if got := historyRows(t, mgr); !reflect.DeepEqual(got, []string{"up:1", "up:2", "up:3"}) {
+		t.Fatalf("history = %v, want versions applied in order", got)
+	}
+}
+
+func TestMergeDirsRejectsDuplicateVersion(t *testing.T) {
+	core, billing := t.TempDir(), t.TempDir()
+	writeFiles(t, core, map[string]string{"0002_add_email.up.sql": "SELECT 1;"})
+	writeFiles(t, billing, map[string]string{"0002_create_invoices.up.sql": "SELECT 1;"})
+
+	_, err := MergeDirs(core, billing)
+	if err == nil {
+		t.Fatal("expected duplicate version across directories to be rejected")
+	}
+	for _, want := range []string{"version 2", filepath.Join(core, "0002_add_email.up.sql"), filepath.Join(billing, "0002_create_invoices.up.sql")} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestMergeDirsRejectsDuplicateFile(t *testing.T) {
+	core, billing := t.TempDir(), t.TempDir()
+	writeFiles(t, core, map[string]string{"0001_init.up.sql": "SELECT 1;"})
+	writeFiles(t, billing, map[string]string{"0001_init.up.sql": "SELECT 2;"})
+
+	if _, err := MergeDirs(core, billing); err == nil || !strings.Contains(err.Error(), "exists in both") {
+		t.Fatalf("err = %v, want duplicate file error", err)
+	}
+}
@@ -0,0 +1,78 @@
+package manager
+
+import "time"
+
+// Option customizes a Manager created by NewManager.
+type Option func(*Manager)
+
+// WithEnv records the environment the Manager runs in. Development-only
+// operations are refused when env is "production".
+func WithEnv(env string) Option {
+	return func(mgr *Manager) { mgr.env = env }
+}
+
+func (mgr *Manager) isProduction() bool { return mgr.env == "production" }
+
+// WithRecordHistory toggles writes to and checks against migrations_history.
+// When disabled the Manager behaves like plain golang-migrate with validation,
+// which suits ephemeral test databases without a history table.
+func WithRecordHistory(enabled bool) Option {
+	return func(mgr *Manager) { mgr.recordHist = enabled }
+}
+
+// WithDownLint toggles the heuristic that warns about down files which recreate
+// objects instead of dropping them.
+func WithDownLint(enabled bool) Option {
+	return func(mgr *Manager) { mgr.downLint = enabled }
+}
+
+// WithLockOrderLint toggles the heuristic that warns when a pending migration
+// locks tables in the opposite order of a recently applied one.
+func WithLockOrderLint(enabled bool) Option {
+	return func(mgr *Manager) { mgr.lockOrderLint = enabled }
+}
+
+// WithStripLoggedComments removes SQL comments from the migration text echoed
+// to the log so secrets noted in comments never reach the log pipeline.
+func WithStripLoggedComments(enabled bool) Option {
+	return func(mgr *Manager) { mgr.stripLogComments = enabled }
+}
+
+// WithStrictOrder makes Validate reject migration files that were never applied
+// but carry a version at or below the highest version ever applied.
+func WithStrictOrder(enabled bool) Option {
+	return func(mgr *Manager) { mgr.strictOrder = enabled }
+}
+
+// WithValidation toggles the rolled-back dry run of migration SQL before Up,
+// Steps and the other commands that apply files. Disabling it skips only
+// that dry run: committed versions and recorded hashes are still checked.
+// The explicit Validate command always validates.
+func WithValidation(enabled bool) Option {
+	return func(mgr *Manager) { mgr.noValidate = !enabled }
+}
+
+// WithValidateTimeout bounds the dry run of each statement during validation;
+// zero keeps the validate package default. A kaeshi:timeout directive still
+// overrides it for its statement.
+func WithValidateTimeout(d time.Duration) Option {
+	return func(mgr *Manager) { mgr.validateOpts.Timeout = d }
+}
+
+// WithDeniedStatements refuses, during validation, statements whose type or
+// leading keywords match an entry of deny, e.g. DROP TABLE or TRUNCATE,
+// regardless of confirmation. allow overrides the policy for one run.
+func WithDeniedStatements(deny []string, allow bool) Option {
+	return func(mgr *Manager) {
+		mgr.validateOpts.Deny = deny
+		mgr.validateOpts.AllowDenied = allow
+	}
+}
+
+// WithNormalizedHash records and compares migration hashes over the SQL as
+// normalized by validate.Normalize, so reformatting a file (whitespace,
+// keyword case, comments) does not trip hash checks. Hashes recorded in the
+// other mode keep being compared in that mode.
+func WithNormalizedHash(enabled bool) Option {
+	return func(mgr *Manager) { mgr.normalizeHash = enabled }
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unpairedFile is a migration file whose counterpart of the same version is
+// missing; Missing is "up" or "down".
+type unpairedFile struct {
+	Version uint
+	File    string
+	Missing string
+}
+
+// unpairedFiles pairs the up and down files in fsys by version prefix and
+// returns the ones without a partner, in version order.
+func unpairedFiles(fsys fs.FS) ([]unpairedFile, error) {
+	sides := map[uint]map[string]string{}
+	for _, side := range []string{"up", "down"} {
+		files, err := fs.Glob(fsys, "*."+side+".sql")
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			v, err := fileVersion(f)
+			if err != nil {
+				continue
+			}
+			if sides[v] == nil {
+				sides[v] = map[string]string{}
+			}
+			sides[v][side] = filepath.Base(f)
+		}
+	}
+	var out []unpairedFile
+	for v, s := range sides {
+		switch {
+		case s["down"] == "":
+			out = append(out, unpairedFile{Version: v, File: s["up"], Missing: "down"})
+		case s["up"] == "":
+			out = append(out, unpairedFile{Version: v, File: s["down"], Missing: "up"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// CheckPairs returns one line for every version that has an up file without
+// a down file or the other way round. golang-migrate treats a missing down
+// file as a no-op, so such a version rolls back without undoing anything.
+func (mgr *Manager) CheckPairs() ([]string, error) {
+	unpaired, err := unpairedFiles(mgr.fsys)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(unpaired))
+	for i, u := range unpaired {
+		out[i] = fmt.Sprintf("version %d: %s has no %s file", u.Version, u.File, u.Missing)
+	}
+	return out, nil
+}
+
+// checkDownFiles refuses to roll back the n highest versions at or below cur
+// (all of them when n < 0) when one of them has no down file, instead of
+// letting golang-migrate step past it without running any SQL.
+func (mgr *Manager) checkDownFiles(cur uint, n int) error {
+	ups, err := mgr.appliedUpFiles(cur, n)
+	if err != nil {
+		return err
+	}
+	for _, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		if _, err := fs.Stat(mgr.fsys, down); err != nil {
+			v, _ := fileVersion(up)
+			return fmt.Errorf("version %d has no down file (%s missing); refusing to roll back", v, filepath.Base(down))
+		}
+	}
+	return nil
+}
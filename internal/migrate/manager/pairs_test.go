@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckPairsReportsMissingSides(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"000003_c.down.sql": "DROP TABLE c;",
+	})
+	got, err := mgr.CheckPairs()
+	if err != nil {
+		t.Fatalf("CheckPairs: %v", err)
+	}
+	want := []string{
+		"version 2: 000002_b.up.sql has no down file",
+		"version 3: 000003_c.down.sql has no up file",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("CheckPairs = %q, want %q", got, want)
+	}
+}
+
+func TestRollbackRefusesVersionWithoutDownFile(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+	})
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Steps(-1); err == nil || !strings.Contains(err.Error(), "000002_b.down.sql missing") {
+		t.Fatalf("Steps(-1) err = %v, want missing down file", err)
+	}
+	if err := mgr.Down(); err == nil || !strings.Contains(err.Error(), "no down file") {
+		t.Fatalf("Down err = %v, want missing down file", err)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("version = %d, want 2 untouched", v)
+	}
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// PendingMigration describes an up migration that has not been applied yet.
+type PendingMigration struct {
+	Version uint
+	// File is the base name of the up file, e.g. 0002_add_email.up.sql.
+	File string
+	// Name is the file name without version prefix and suffix, e.g. add_email.
+	Name string
+	// Hash is the SHA256 recorded in history when the file is applied.
+	Hash string
+	// Statements summarizes the statements of the file.
+	Statements StatementSummary
+	// Tags are the subsystems named by the kaeshi:tags directive.
+	Tags []string
+}
+
+// HasTag reports whether the migration carries tag, ignoring case.
+func (p PendingMigration) HasTag(tag string) bool {
+	return directives{tags: p.Tags}.hasTag(tag)
+}
+
+// StatementSummary counts the statements of a migration by type as reported
+// by the backend's validation dialect (DDL, DML or UNKNOWN).
+type StatementSummary struct {
+	Total  int
+	ByType map[string]int
+}
+
+// Pending returns the up migrations above the current version in apply order.
+func (mgr *Manager) Pending() ([]PendingMigration, error) {
+	cur, _, err := mgr.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("read version: %w", err)
+	}
+	files, err := mgr.pendingUpFiles(cur)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PendingMigration, 0, len(files))
+	for _, f := range files {
+		p, err := mgr.describePending(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (mgr *Manager) describePending(f string) (PendingMigration, error) {
+	v, err := fileVersion(f)
+	if err != nil {
+		return PendingMigration{}, err
+	}
+	hash, err := mgr.hashFile(f)
+	if err != nil {
+		return PendingMigration{}, fmt.Errorf("hash %s: %w", f, err)
+	}
+	content, err := fs.ReadFile(mgr.fsys, f)
+	if err != nil {
+		return PendingMigration{}, err
+	}
+	dir, err := parseDirectives(string(content))
+	if err != nil {
+		return PendingMigration{}, fmt.Errorf("%s: %w", filepath.Base(f), err)
+	}
+	stmts, err := mgr.typedStatements(string(content))
+	if err != nil {
+		return PendingMigration{}, fmt.Errorf("split %s: %w", f, err)
+	}
+	sum := StatementSummary{ByType: map[string]int{}}
+	for _, stmt := range stmts {
+		sum.Total++
+		sum.ByType[stmt.Type]++
+	}
+
+	base := filepath.Base(f)
+	name := strings.TrimSuffix(base, ".up.sql")
+	if _, rest, ok := strings.Cut(name, "_"); ok {
+		name = rest
+	}
+	return PendingMigration{Version: v, File: base, Name: name, Hash: hash, Statements: sum, Tags: dir.tags}, nil
+}
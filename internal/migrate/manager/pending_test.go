@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestPendingDescribesUnappliedFiles(t *testing.T) {
+	files := map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_seed_users.up.sql": `-- seed a first user
+CREATE INDEX idx_users_id ON users (id);
+INSERT INTO users (id) VALUES (1);
+INSERT INTO users (id) VALUES (2);`,
+		"0002_seed_users.down.sql": "DELETE FROM users;",
+		"0003_add_email.up.sql":    "ALTER TABLE users ADD COLUMN email TEXT;",
+		"0003_add_email.down.sql":  "ALTER TABLE users DROP COLUMN email;",
+	}
+	mgr := newTestManager(t, files)
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+
+	got, err := mgr.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("pending = %+v, want 2 entries", got)
+	}
+
+	seed := got[0]
+	if seed.Version != 2 || seed.File != "0002_seed_users.up.sql" || seed.Name != "seed_users" {
+		t.Fatalf("first pending = %+v", seed)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(files["0002_seed_users.up.sql"]))); seed.Hash != want {
+		t.Fatalf("hash = %s, want %s", seed.Hash, want)
+	}
+	if s := seed.Statements; s.Total != 3 || s.ByType["DDL"] != 1 || s.ByType["DML"] != 2 {
+		t.Fatalf("statements = %+v, want 1 DDL and 2 DML", s)
+	}
+
+	if email := got[1]; email.Version != 3 || email.Name != "add_email" || email.Statements.ByType["DDL"] != 1 {
+		t.Fatalf("second pending = %+v", email)
+	}
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got, err := mgr.Pending(); err != nil || len(got) != 0 {
+		t.Fatalf("Pending after Up = %+v, %v; want none", got, err)
+	}
+}
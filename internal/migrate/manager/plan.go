@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// PlannedMigration is a pending up migration as up would apply it.
+type PlannedMigration struct {
+	Version uint   `json:"version"`
+	File    string `json:"file"`
+	// Hash is the hash up will record for the file.
+	Hash string `json:"sha256"`
+	// InHistory is set when history already has an "up" row for the version,
+	// e.g. because it was applied and rolled back before.
+	InHistory bool `json:"in_history"`
+	// Committed is set when the latest history row of the version is
+	// committed.
+	Committed bool `json:"committed"`
+}
+
+// Plan lists the pending up migrations in apply order. It only reads the
+// database and the migration files, and works on a Manager opened
+// WithReadOnly.
+func (mgr *Manager) Plan() ([]PlannedMigration, error) {
+	cur, _, err := mgr.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("read version: %w", err)
+	}
+	files, err := mgr.pendingUpFiles(cur)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PlannedMigration, 0, len(files))
+	for _, f := range files {
+		v, err := fileVersion(f)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := mgr.hashFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", f, err)
+		}
+		p := PlannedMigration{Version: v, File: filepath.Base(f), Hash: hash}
+		if mgr.recordHist {
+			if p.InHistory, err = mgr.upInHistory(v); err != nil {
+				return nil, err
+			}
+			if p.Committed, err = mgr.VersionCommitted(v); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// upInHistory reports whether history has an "up" row for version v.
+func (mgr *Manager) upInHistory(v uint) (bool, error) {
+	var id int64
+	err := mgr.db.QueryRow(mgr.rebind(`SELECT id FROM `+mgr.hist()+` WHERE action = 'up' AND version = $1 LIMIT 1`), fmt.Sprintf("%d", v)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) || mgr.missingTable(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read history: %w", err)
+	}
+	return true, nil
+}
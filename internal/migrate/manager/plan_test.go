@@ -0,0 +1,35 @@
+package manager
+
+import "testing"
+
+func TestPlanListsPendingWithHistoryState(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"000002_b.down.sql": "DROP TABLE b;",
+		"000003_c.up.sql":   "CREATE TABLE c (id INTEGER);",
+	})
+	if err := mgr.Steps(2); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.Steps(-1); err != nil {
+		t.Fatalf("Steps(-1): %v", err)
+	}
+
+	plan, err := mgr.Plan()
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(plan) != 2 || plan[0].Version != 2 || plan[1].Version != 3 {
+		t.Fatalf("plan = %+v, want versions 2 and 3", plan)
+	}
+	if p := plan[0]; p.File != "000002_b.up.sql" || !p.InHistory || p.Committed || p.Hash == "" {
+		t.Fatalf("plan[0] = %+v, want b in history, not committed", p)
+	}
+	if p := plan[1]; p.InHistory || p.Committed {
+		t.Fatalf("plan[1] = %+v, want c absent from history", p)
+	}
+	if v, _, _ := mgr.Version(); v != 1 {
+		t.Fatalf("Plan changed the version to %d", v)
+	}
+}
@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// PoolerPgBouncerTransaction is PgBouncer's transaction pooling mode, in which
+// consecutive transactions of one client may run on different server
+// sessions, so session advisory locks, session SETs and named prepared
+// statements cannot be relied upon.
+const PoolerPgBouncerTransaction = "pgbouncer-transaction"
+
+// lockPollInterval is how often a table lock held by someone else is retried.
+const lockPollInterval = 500 * time.Millisecond
+
+// WithPooler declares the connection pooler between kaeshi and the database.
+// Only "" (direct connection) and PoolerPgBouncerTransaction are known.
+func WithPooler(pooler string) Option {
+	return func(mgr *Manager) { mgr.pooler = pooler }
+}
+
+// checkPooler validates the pooler setting and adapts the DSN to it.
+func (mgr *Manager) checkPooler() error {
+	switch mgr.pooler {
+	case "", "none":
+		return nil
+	case PoolerPgBouncerTransaction:
+		if mgr.backend.DriverName() == "postgres" {
+			mgr.dsn = pgbouncerDSN(mgr.dsn)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown pooler %q: want %q or empty", mgr.pooler, PoolerPgBouncerTransaction)
+}
+
+// pgbouncerDSN makes lib/pq send query parameters inline with the execute
+// message instead of preparing statements that a pooled server session may
+// not know.
+func pgbouncerDSN(dsn string) string {
+	if strings.Contains(dsn, "binary_parameters=") {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+		q := u.Query()
+		q.Set("binary_parameters", "yes")
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSpace(dsn) + " binary_parameters=yes"
+}
+
+// tableLockDriver replaces the session advisory lock of the wrapped driver
+// with a row in a lock table. The row is committed data, so the lock holds
+// no matter which pooled server session later statements run on.
+type tableLockDriver struct {
+	database.Driver
+	db     *sql.DB
+	table  string // quoted
+	holder string
+	ready  bool
+}
+
+// withTableLock wraps driver when the pooler cannot keep session locks.
+func (mgr *Manager) withTableLock(driver database.Driver, db *sql.DB) database.Driver {
+	if mgr.pooler != PoolerPgBouncerTransaction {
+		return driver
+	}
+	host, _ := os.Hostname()
+	return &tableLockDriver{
+		Driver: driver,
+		db:     db,
+		table:  quoteIdent(mgr.tablePrefix + "migrations_lock"),
+		holder: fmt.Sprintf("%s@%s pid=%d started=%s", mgr.actor, host, os.Getpid(), time.Now().UTC().Format(time.RFC3339Nano)),
+	}
+}
+
+// Lock inserts the single lock row, waiting while another holder owns it.
+func (d *tableLockDriver) Lock() error {
+	for {
+		ok, err := d.TryLock()
+		if ok || err != nil {
+			return err
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// TryLock inserts the single lock row unless another holder owns it.
+func (d *tableLockDriver) TryLock() (bool, error) {
+	if !d.ready {
+		if _, err := d.db.Exec(`CREATE TABLE IF NOT EXISTS ` + d.table + ` (
+	id INTEGER PRIMARY KEY,
+	holder TEXT NOT NULL,
+	locked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`); err != nil {
+			return false, fmt.Errorf("create lock table: %w", err)
+		}
+		d.ready = true
+	}
+	_, err := d.db.Exec(`INSERT INTO `+d.table+` (id, holder) VALUES (1, $1)`, d.holder)
+	if err == nil {
+		return true, nil
+	}
+	if holder, herr := d.currentHolder(); herr != nil || holder == "" {
+		// no row to wait for: the insert failed for another reason
+		return false, fmt.Errorf("take table lock: %w", err)
+	}
+	return false, nil
+}
+
+// Unlock deletes the lock row if this driver still owns it.
+func (d *tableLockDriver) Unlock() error {
+	res, err := d.db.Exec(`DELETE FROM `+d.table+` WHERE id = 1 AND holder = $1`, d.holder)
+	if err != nil {
+		return fmt.Errorf("release table lock: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return database.ErrNotLocked
+	}
+	return nil
+}
+
+// currentHolder describes the owner of the lock row, or returns "" when the
+// lock is free.
+func (d *tableLockDriver) currentHolder() (string, error) {
+	var holder string
+	var since time.Time
+	err := d.db.QueryRow(`SELECT holder, locked_at FROM `+d.table+` WHERE id = 1`).Scan(&holder, &since)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s since=%s (row in %s)", holder, since.UTC().Format(time.RFC3339), d.table), nil
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPgbouncerDSN(t *testing.T) {
+	cases := map[string]string{
+		"postgres://u:p@db:6432/app?sslmode=disable": "postgres://u:p@db:6432/app?binary_parameters=yes&sslmode=disable",
+		"host=db port=6432 dbname=app":               "host=db port=6432 dbname=app binary_parameters=yes",
+		"postgres://db/app?binary_parameters=no":     "postgres://db/app?binary_parameters=no",
+	}
+	for in, want := range cases {
+		if got := pgbouncerDSN(in); got != want {
+			t.Errorf("pgbouncerDSN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPgbouncerModeUsesTableLock(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER);",
+	}, WithPooler(PoolerPgBouncerTransaction))
+	tl, ok := mgr.driver.(*tableLockDriver)
+	if !ok {
+		t.Fatalf("driver = %T, want table lock wrapper", mgr.driver)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	var n int
+	if err := mgr.db.QueryRow(`SELECT COUNT(*) FROM migrations_lock`).Scan(&n); err != nil || n != 0 {
+		t.Fatalf("lock row left behind: n=%d err=%v", n, err)
+	}
+
+	// A second holder must wait until the first releases the row.
+	if err := tl.Lock(); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if holder, err := mgr.lockHolder(); err != nil || !strings.Contains(holder, "tester@") {
+		t.Fatalf("lockHolder = %q, %v", holder, err)
+	}
+	other := &tableLockDriver{Driver: tl.Driver, db: tl.db, table: tl.table, holder: "other", ready: true}
+	acquired := make(chan error, 1)
+	go func() { acquired <- other.Lock() }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("second Lock returned while the row was held: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := tl.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("second Lock: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second Lock not acquired after release")
+	}
+	if err := tl.Unlock(); err == nil {
+		t.Fatal("Unlock must not release a row owned by another holder")
+	}
+	if err := other.Unlock(); err != nil {
+		t.Fatalf("Unlock other: %v", err)
+	}
+	if holder, err := mgr.lockHolder(); err != nil || holder != "" {
+		t.Fatalf("lockHolder of a free lock = %q, %v; want none", holder, err)
+	}
+}
+
+func TestPostgresDriverCreatesVersionTableInOneTransaction(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT current_database(), current_schema()`)).
+		WillReturnRows(sqlmock.NewRows([]string{"db", "schema"}).AddRow("app", "public"))
+	mock.ExpectExec(`BEGIN`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(1\) FROM information_schema.tables`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`COMMIT`).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := (PostgresBackend{}).NewDriver(db); err != nil {
+		t.Fatalf("NewDriver: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnknownPoolerRejected(t *testing.T) {
+	mgr := &Manager{pooler: "pgpool", backend: SQLiteBackend{}}
+	if err := mgr.checkPooler(); err == nil {
+		t.Fatal("expected unknown pooler to be rejected")
+	}
+}
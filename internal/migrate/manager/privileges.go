@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// PrivilegeChecker is implemented by backends that can verify, without side
+// effects, that the connected role may create and alter objects in the schema
+// migrations run in.
+type PrivilegeChecker interface {
+	CheckPrivileges(db *sql.DB) error
+}
+
+// PrivilegeError reports that the connected role lacks a privilege that
+// migrations need.
+type PrivilegeError struct {
+	// Op is the probe statement that was refused, e.g. CREATE TABLE.
+	Op  string
+	Err error
+}
+
+func (e *PrivilegeError) Error() string {
+	return fmt.Sprintf("connected role may not %s in the target schema; grant it before migrating: %v", e.Op, e.Err)
+}
+
+func (e *PrivilegeError) Unwrap() error { return e.Err }
+
+// CheckPrivileges verifies that the connected role can create and alter
+// tables, so up fails fast instead of leaving the database dirty halfway
+// through. Backends without a PrivilegeChecker are assumed to be fine.
+func (mgr *Manager) CheckPrivileges() error {
+	pc, ok := mgr.backend.(PrivilegeChecker)
+	if !ok {
+		return nil
+	}
+	return pc.CheckPrivileges(mgr.db)
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestCheckPrivilegesPermissionDenied(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE kaeshi_privilege_probe").
+		WillReturnError(&pq.Error{Code: "42501", Message: "permission denied for schema public"})
+	mock.ExpectRollback()
+
+	mgr := &Manager{backend: PostgresBackend{}, db: db}
+	err = mgr.CheckPrivileges()
+	var perr *PrivilegeError
+	if !errors.As(err, &perr) || perr.Op != "CREATE TABLE" {
+		t.Fatalf("err = %v, want PrivilegeError for CREATE TABLE", err)
+	}
+	if !strings.Contains(err.Error(), "permission denied for schema public") {
+		t.Fatalf("error %q does not carry the database message", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestCheckPrivilegesRollsBackProbe(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("mock db: %v", err)
+	}
+	defer db.Close()
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE kaeshi_privilege_probe").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ALTER TABLE kaeshi_privilege_probe").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	mgr := &Manager{backend: PostgresBackend{}, db: db}
+	if err := mgr.CheckPrivileges(); err != nil {
+		t.Fatalf("CheckPrivileges: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("probe must be rolled back: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultProgressFile is where long runs record their progress by default.
+const DefaultProgressFile = ".kaeshi-progress.json"
+
+// Progress persists which units of a long run, such as tenants of
+// up --all-tenants or the phases of redo, have completed, so a run that died
+// partway can be resumed without redoing them. It is safe for concurrent use.
+type Progress struct {
+	path  string
+	mu    sync.Mutex
+	state progressState
+}
+
+type progressState struct {
+	Command string `json:"command"`
+	// Target is a version the run works towards, when it has one.
+	Target uint `json:"target,omitempty"`
+	// Completed maps finished units to the version they reached.
+	Completed map[string]uint `json:"completed"`
+}
+
+// OpenProgress returns the progress of command stored at path. With resume
+// the units completed by a previous run are kept; without it, or when no file
+// exists, the run starts from scratch. A file written by another command is
+// refused rather than resumed.
+func OpenProgress(path, command string, resume bool) (*Progress, error) {
+	p := &Progress{path: path, state: progressState{Command: command, Completed: map[string]uint{}}}
+	if !resume {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read progress: %w", err)
+	}
+	var st progressState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("read progress %s: %w", path, err)
+	}
+	if st.Command != command {
+		return nil, fmt.Errorf("progress file %s belongs to %q, not %q; remove it or run without --resume", path, st.Command, command)
+	}
+	if st.Completed == nil {
+		st.Completed = map[string]uint{}
+	}
+	p.state = st
+	return p, nil
+}
+
+// Target returns the recorded target version, if any.
+func (p *Progress) Target() (uint, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state.Target, p.state.Target != 0
+}
+
+// SetTarget records the version the run works towards.
+func (p *Progress) SetTarget(v uint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state.Target = v
+	return p.save()
+}
+
+// Done reports whether unit completed and the version it reached.
+func (p *Progress) Done(unit string) (uint, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, ok := p.state.Completed[unit]
+	return v, ok
+}
+
+// Complete records that unit finished at version v.
+func (p *Progress) Complete(unit string, v uint) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.state.Completed[unit] = v
+	return p.save()
+}
+
+// Finish removes the progress file once the whole run has succeeded.
+func (p *Progress) Finish() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := os.Remove(p.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// save writes the state through a temporary file so an interruption never
+// leaves a truncated file behind.
+func (p *Progress) save() error {
+	data, err := json.MarshalIndent(p.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), ".kaeshi-progress-*")
+	if err != nil {
+		return fmt.Errorf("write progress: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write progress: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write progress: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return fmt.Errorf("write progress: %w", err)
+	}
+	return nil
+}
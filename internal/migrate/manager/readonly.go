@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// ReadOnlyOpener is implemented by backends whose DSN can ask for a
+// connection that never writes, such as SQLite, which otherwise creates a
+// missing database file on connect.
+type ReadOnlyOpener interface {
+	ReadOnlyDSN(dsn string) string
+}
+
+// WithReadOnly opens the Manager for inspection only, as check and plan do:
+// no golang-migrate driver is created, so neither the version table nor a
+// SQLite database file is, and Version reads the version table directly.
+// Operations that migrate must not be called on such a Manager.
+func WithReadOnly() Option {
+	return func(mgr *Manager) { mgr.readOnly = true }
+}
+
+// readOnlyDSN returns the DSN backend opens without writing.
+func readOnlyDSN(backend DBBackend, dsn string) string {
+	if ro, ok := backend.(ReadOnlyOpener); ok {
+		return ro.ReadOnlyDSN(dsn)
+	}
+	return dsn
+}
+
+// ReadOnlyDSN opens dsn as a read-only URI, so a missing file is an error
+// rather than a new empty database.
+func (SQLiteBackend) ReadOnlyDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "file:") {
+		dsn = "file:" + dsn
+	}
+	if strings.Contains(dsn, "?") {
+		return dsn + "&mode=ro"
+	}
+	return dsn + "?mode=ro"
+}
+
+// readVersion reads the version table the way the golang-migrate drivers
+// do, reporting migrate.ErrNilVersion while it is missing or empty.
+func (mgr *Manager) readVersion() (uint, bool, error) {
+	var v int64
+	var dirty bool
+	err := mgr.db.QueryRow(`SELECT version, dirty FROM `+mgr.quote(mgr.MigrationsTable())+` LIMIT 1`).Scan(&v, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows) || mgr.missingTable(err):
+		return 0, false, migrate.ErrNilVersion
+	case err != nil:
+		return 0, false, fmt.Errorf("read %s: %w", mgr.MigrationsTable(), err)
+	case v < 0:
+		return 0, false, migrate.ErrNilVersion
+	}
+	return uint(v), dirty, nil
+}
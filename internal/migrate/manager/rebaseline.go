@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/sirupsen/logrus"
+)
+
+// BaselineName is the file name stem Rebaseline writes, without suffix.
+const BaselineName = "000001_baseline"
+
+// Rebaseline replaces every migration in the migrations directory with a
+// single version 1 baseline holding up and down, then resets the version
+// table to 1 and the history to one "up" row for the baseline. It is a
+// development and adoption tool for starting clean after a long history: it
+// is refused in production, for remote sources, archives and merged
+// directories, and unless the database is clean with nothing pending. The
+// removed files are returned; recover them from version control if needed.
+// The Manager's migration source still lists the old files, so close it and
+// open a new one before migrating again.
+func (mgr *Manager) Rebaseline(up, down string) ([]string, error) {
+	if mgr.isProduction() {
+		return nil, fmt.Errorf("rebaseline is refused in production")
+	}
+	// The default file system is os.DirFS(migrationsDir); anything else is an
+	// archive, merged directories or a remote source that cannot be rewritten.
+	if mgr.remoteSource() || mgr.fsys != os.DirFS(mgr.migrationsDir) {
+		return nil, fmt.Errorf("rebaseline needs a single local migrations directory")
+	}
+	if strings.TrimSpace(up) == "" {
+		return nil, fmt.Errorf("rebaseline: baseline up SQL is empty")
+	}
+	cur, dirty, err := mgr.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("rebaseline: no migrations applied yet; nothing to compact")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read version: %w", err)
+	}
+	if dirty {
+		return nil, fmt.Errorf("rebaseline: database is dirty at version %d; fix it first", cur)
+	}
+	pending, err := mgr.pendingUpFiles(cur)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) > 0 {
+		return nil, fmt.Errorf("rebaseline: %d migration(s) pending, starting with %s; apply them first", len(pending), filepath.Base(pending[0]))
+	}
+
+	old, err := migrationFiles(mgr.fsys)
+	if err != nil {
+		return nil, err
+	}
+	upFile, downFile := BaselineName+".up.sql", BaselineName+".down.sql"
+	for _, f := range []struct{ name, sql string }{{upFile, up}, {downFile, down}} {
+		if err := os.WriteFile(filepath.Join(mgr.migrationsDir, f.name), []byte(f.sql), 0o644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+	if err := mgr.resetToBaseline(cur, upFile); err != nil {
+		// Leave the directory as it was; the old files are still in place.
+		for _, name := range []string{upFile, downFile} {
+			if !slices.Contains(old, name) {
+				_ = os.Remove(filepath.Join(mgr.migrationsDir, name))
+			}
+		}
+		return nil, err
+	}
+
+	var removed []string
+	for _, f := range old {
+		if f == upFile || f == downFile {
+			continue
+		}
+		if err := os.Remove(filepath.Join(mgr.migrationsDir, f)); err != nil {
+			return removed, fmt.Errorf("remove %s: %w", f, err)
+		}
+		removed = append(removed, f)
+	}
+	mgr.logger.WithFields(logrus.Fields{
+		"actor":   mgr.actor,
+		"from":    cur,
+		"removed": len(removed),
+	}).Warn("migrations rebaselined to " + BaselineName)
+	return removed, nil
+}
+
+// resetToBaseline points the version table at 1 and replaces the history
+// with a single "up" row for upFile, in one transaction.
+func (mgr *Manager) resetToBaseline(cur uint, upFile string) error {
+	tx, err := mgr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin rebaseline: %w", err)
+	}
+	defer tx.Rollback()
+	versions := mgr.quote(mgr.MigrationsTable())
+	if _, err := tx.Exec(`DELETE FROM ` + versions); err != nil {
+		return fmt.Errorf("reset version: %w", err)
+	}
+	if _, err := tx.Exec(mgr.rebind(`INSERT INTO `+versions+` (version, dirty) VALUES ($1, $2)`), int64(1), false); err != nil {
+		return fmt.Errorf("reset version: %w", err)
+	}
+	if mgr.recordHist {
+		if _, err := tx.Exec(`DELETE FROM ` + mgr.hist()); err != nil {
+			return fmt.Errorf("reset history: %w", err)
+		}
+		run := fileRun{
+			skipped: fmt.Sprintf("rebaseline from version %d", cur),
+			tr: transition{
+				from: stateOf(cur, false, nil),
+				to:   stateOf(1, false, nil),
+			},
+		}
+		if _, err := mgr.insertApplied(tx, 1, upFile, run); err != nil {
+			return fmt.Errorf("reset history: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit rebaseline: %w", err)
+	}
+	return nil
+}
+
+// migrationFiles lists the up and down files of fsys with their signatures.
+func migrationFiles(fsys fs.FS) ([]string, error) {
+	var out []string
+	for _, pattern := range []string{"*.up.sql", "*.down.sql", "*.up.sql.sig", "*.down.sql.sig"} {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, files...)
+	}
+	return out, nil
+}
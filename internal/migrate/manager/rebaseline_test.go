@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRebaselineResetsDirectoryAndHistory(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000004_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"000009_c.up.sql":   "CREATE TABLE c (id INTEGER);",
+		"README.md":         "notes",
+	})
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	removed, err := mgr.Rebaseline("CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\nCREATE TABLE c (id INTEGER);\n",
+		"DROP TABLE c;\nDROP TABLE b;\nDROP TABLE a;\n")
+	if err != nil {
+		t.Fatalf("Rebaseline: %v", err)
+	}
+	sort.Strings(removed)
+	if want := []string{"000001_a.down.sql", "000001_a.up.sql", "000004_b.up.sql", "000009_c.up.sql"}; !reflect.DeepEqual(removed, want) {
+		t.Fatalf("removed = %v, want %v", removed, want)
+	}
+
+	entries, err := os.ReadDir(mgr.migrationsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"000001_baseline.down.sql", "000001_baseline.up.sql", "README.md"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("directory = %v, want %v", names, want)
+	}
+
+	if v, dirty, err := mgr.Version(); err != nil || v != 1 || dirty {
+		t.Fatalf("version = %d dirty=%v err=%v, want clean 1", v, dirty, err)
+	}
+	if got := historyRows(t, mgr); !reflect.DeepEqual(got, []string{"up:1"}) {
+		t.Fatalf("history = %v, want [up:1]", got)
+	}
+	if changed, err := mgr.upChangedSinceApplied("000001_baseline.up.sql"); err != nil || changed {
+		t.Fatalf("baseline hash mismatch: changed=%v err=%v", changed, err)
+	}
+	if pending, err := mgr.Pending(); err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %v err=%v, want none", pending, err)
+	}
+}
+
+func TestRebaselineRefusesPendingAndProduction(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if _, err := mgr.Rebaseline("CREATE TABLE a (id INTEGER);", ""); err == nil || !strings.Contains(err.Error(), "2 migration(s) pending") {
+		t.Fatalf("err = %v, want pending refusal", err)
+	}
+	if _, err := os.Stat(mgr.migrationsDir + "/000001_baseline.up.sql"); !os.IsNotExist(err) {
+		t.Fatalf("baseline written despite refusal: %v", err)
+	}
+
+	prod := newTestManager(t, threeMigrations, WithEnv("production"))
+	if err := prod.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := prod.Rebaseline("CREATE TABLE a (id INTEGER);", ""); err == nil || !strings.Contains(err.Error(), "production") {
+		t.Fatalf("err = %v, want production refusal", err)
+	}
+}
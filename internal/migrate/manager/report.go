@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// RunResult summarizes the latest Up, ApplyTag, Down, Steps or Goto call of a
+// Manager, e.g. for the --report artifact.
+type RunResult struct {
+	Operation string // up, apply, down, steps or goto
+	From, To  uint
+	// Applied lists the files applied, in order. Files applied by Steps
+	// carry no duration.
+	Applied []AppliedMigration
+	// RolledBack lists the versions rolled back, highest first.
+	RolledBack []uint
+	Warnings   []string
+	// Planned lists the files a dry run would have executed, in order.
+	Planned  []string
+	Duration time.Duration
+	Err      error
+}
+
+// AppliedMigration is one file applied during a run.
+type AppliedMigration struct {
+	Version  uint
+	File     string
+	Duration time.Duration
+	// Skipped is the history reason when the file was recorded without
+	// being executed.
+	Skipped string
+}
+
+// LastRun returns the summary of the latest Up, ApplyTag, Down, Steps or Goto
+// call. It must not be called while that call is still running.
+func (mgr *Manager) LastRun() RunResult { return mgr.lastRun }
+
+// track runs op and records its summary for LastRun.
+func (mgr *Manager) track(op string, run func() error) error {
+	from, _, _ := mgr.m.Version()
+	mgr.lastRun = RunResult{Operation: op, From: from}
+	start := time.Now()
+	err := run()
+	r := &mgr.lastRun
+	r.Duration, r.Err = time.Since(start), err
+	r.To, _, _ = mgr.m.Version()
+	switch {
+	case r.To > r.From && len(r.Applied) == 0:
+		if files, ferr := mgr.pendingUpFiles(r.From); ferr == nil {
+			for _, f := range files {
+				if v, verr := fileVersion(f); verr == nil && v <= r.To {
+					r.Applied = append(r.Applied, AppliedMigration{Version: v, File: filepath.Base(f)})
+				}
+			}
+		}
+	case r.To < r.From:
+		if files, ferr := mgr.appliedUpFiles(r.From, -1); ferr == nil {
+			for _, f := range files {
+				if v, verr := fileVersion(f); verr == nil && v > r.To {
+					r.RolledBack = append(r.RolledBack, v)
+				}
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLastRunSummarizesUpAndRollback(t *testing.T) {
+	mgr := newTestManager(t, map[string]string{
+		"000001_a.up.sql":   "CREATE TABLE a (id INTEGER);",
+		"000001_a.down.sql": "DROP TABLE a;",
+		"000002_b.up.sql":   "CREATE TABLE b (id INTEGER);",
+		"000002_b.down.sql": "DROP TABLE b;",
+		"000003_c.up.sql":   "CREATE TABLE c (id INTEGER);",
+		"000003_c.down.sql": "DROP TABLE c;",
+	})
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	run := mgr.LastRun()
+	if run.Operation != "up" || run.From != 0 || run.To != 3 || run.Err != nil {
+		t.Fatalf("run = %+v, want up from 0 to 3", run)
+	}
+	var files []string
+	for _, a := range run.Applied {
+		files = append(files, a.File)
+	}
+	if want := []string{"000001_a.up.sql", "000002_b.up.sql", "000003_c.up.sql"}; !reflect.DeepEqual(files, want) {
+		t.Fatalf("applied = %v, want %v", files, want)
+	}
+
+	if err := mgr.Steps(-2); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	run = mgr.LastRun()
+	if run.Operation != "steps" || run.From != 3 || run.To != 1 || !reflect.DeepEqual(run.RolledBack, []uint{3, 2}) {
+		t.Fatalf("run = %+v, want versions 3 and 2 rolled back", run)
+	}
+}
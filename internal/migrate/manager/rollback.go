@@ -0,0 +1,166 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/confirm"
+)
+
+// Rollback check modes accepted by WithRollbackCheck.
+const (
+	RollbackCheckOff     = "off"
+	RollbackCheckWarn    = "warn"
+	RollbackCheckConfirm = "confirm"
+)
+
+// WithRollbackCheck enables the pre-down rollback-safety check. In warn mode
+// findings are logged; in confirm mode each finding must be confirmed through
+// the Manager's ConfirmFunc before the rollback proceeds.
+func WithRollbackCheck(mode string) Option {
+	return func(mgr *Manager) { mgr.rollbackCheck = mode }
+}
+
+func (mgr *Manager) checkRollbackMode() error {
+	switch mgr.rollbackCheck {
+	case "", RollbackCheckOff, RollbackCheckWarn, RollbackCheckConfirm:
+		return nil
+	}
+	return fmt.Errorf("unknown rollback check mode %q: want off, warn or confirm", mgr.rollbackCheck)
+}
+
+// checkRollback verifies that the down files of the n highest versions at or
+// below cur revert what their up files created; n < 0 means every version.
+// The up file on disk is compared against the hash recorded when it was
+// applied, since the check trusts it to describe what the database holds.
+// With dryRun the first down file is also executed in a rolled-back
+// transaction; later ones depend on its effects and cannot be.
+func (mgr *Manager) checkRollback(cur uint, n int, dryRun bool) error {
+	if mgr.rollbackCheck == "" || mgr.rollbackCheck == RollbackCheckOff {
+		return nil
+	}
+	ups, err := mgr.appliedUpFiles(cur, n)
+	if err != nil {
+		return err
+	}
+	var findings []string
+	for i, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		if upSkippedIn(mgr.fsys, down, mgr.env) {
+			continue
+		}
+		downSQL, err := fs.ReadFile(mgr.fsys, down)
+		if err != nil {
+			findings = append(findings, fmt.Sprintf("%s: no down file", filepath.Base(up)))
+			continue
+		}
+		upSQL, err := fs.ReadFile(mgr.fsys, up)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", up, err)
+		}
+		if changed, err := mgr.upChangedSinceApplied(up); err != nil {
+			return err
+		} else if changed {
+			findings = append(findings, fmt.Sprintf("%s: up file differs from the applied one recorded in history", filepath.Base(up)))
+		}
+		for _, w := range migration.CheckRollback(upSQL, downSQL) {
+			findings = append(findings, fmt.Sprintf("%s: %s", filepath.Base(down), w))
+		}
+		if dryRun && i == 0 {
+			if ok, err := validate.ValidateSQL(string(downSQL), map[string]string{"dsn": mgr.dsn}, mgr.validateOpts, mgr.backend.Validator()); !ok || err != nil {
+				findings = append(findings, fmt.Sprintf("%s: fails in a rolled-back transaction: %v", filepath.Base(down), err))
+			}
+		}
+	}
+
+	for _, f := range findings {
+		if mgr.rollbackCheck == RollbackCheckWarn {
+			mgr.logger.WithField("actor", mgr.actor).Warn("rollback check: " + f)
+			continue
+		}
+		if err := confirm.FallbackConfirm(mgr.validateOpts.ConfirmFn, f, "rollback may be incomplete"); err != nil {
+			return fmt.Errorf("rollback check: %w", err)
+		}
+	}
+	return nil
+}
+
+// AppliedVersions returns the versions of the n highest up files at or below
+// the current version, highest first: those a rollback of n steps reverts.
+func (mgr *Manager) AppliedVersions(n int) ([]uint, error) {
+	cur, _, err := mgr.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read version: %w", err)
+	}
+	files, err := mgr.appliedUpFiles(cur, n)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]uint, 0, len(files))
+	for _, f := range files {
+		v, err := fileVersion(f)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// appliedUpFiles returns the up files of the n highest versions at or below
+// cur, highest first; n < 0 returns all of them.
+func (mgr *Manager) appliedUpFiles(cur uint, n int) ([]string, error) {
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	var out []string
+	for _, f := range files {
+		if v, err := fileVersion(f); err != nil || v > cur {
+			continue
+		}
+		if n >= 0 && len(out) == n {
+			break
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// upChangedSinceApplied reports whether up no longer matches the hash of its
+// latest up row in history. Without history nothing can be compared.
+func (mgr *Manager) upChangedSinceApplied(up string) (bool, error) {
+	if !mgr.recordHist {
+		return false, nil
+	}
+	v, err := fileVersion(up)
+	if err != nil {
+		return false, err
+	}
+	var recorded string
+	err = mgr.db.QueryRow(mgr.rebind(`SELECT sha256 FROM `+mgr.hist()+` WHERE action='up' AND version=$1 ORDER BY id DESC LIMIT 1`), fmt.Sprintf("%d", v)).Scan(&recorded)
+	if errors.Is(err, sql.ErrNoRows) || recorded == "" {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	hash, err := mgr.hashAs(up, recorded)
+	if err != nil {
+		return false, err
+	}
+	return hash != recorded, nil
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// mismatchedPair creates an index its down file forgets to drop.
+var mismatchedPair = map[string]string{
+	"0001_users.up.sql":   "CREATE TABLE users (id INTEGER, email TEXT);",
+	"0001_users.down.sql": "DROP TABLE users;",
+	"0002_email_idx.up.sql": "CREATE INDEX idx_users_email ON users (email);\n" +
+		"ALTER TABLE users ADD COLUMN name TEXT;",
+	"0002_email_idx.down.sql": "ALTER TABLE users DROP COLUMN name;",
+}
+
+// applyAll applies mismatchedPair one file at a time, since 0002 can only be
+// validated once 0001 exists.
+func applyAll(t *testing.T, mgr *Manager) {
+	t.Helper()
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+}
+
+func TestRollbackCheckWarnsOnMismatchedPair(t *testing.T) {
+	mgr := newTestManager(t, mismatchedPair, WithRollbackCheck(RollbackCheckWarn))
+	log, hook := test.NewNullLogger()
+	mgr.logger = logrus.NewEntry(log)
+	applyAll(t, mgr)
+	if err := mgr.Down(); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	var warnings []string
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.HasPrefix(e.Message, "rollback check: ") {
+			warnings = append(warnings, e.Message)
+		}
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "0002_email_idx.down.sql: down file does not revert index idx_users_email") {
+		t.Fatalf("warnings = %v, want the forgotten index only", warnings)
+	}
+}
+
+func TestRollbackCheckConfirmBlocksDown(t *testing.T) {
+	mgr := newTestManager(t, mismatchedPair, WithRollbackCheck(RollbackCheckConfirm))
+	applyAll(t, mgr)
+	err := mgr.Down()
+	if err == nil || !strings.Contains(err.Error(), "rollback may be incomplete") {
+		t.Fatalf("Down err = %v, want unconfirmed rollback check", err)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("version = %d, want 2 untouched", v)
+	}
+
+	var prompts []string
+	mgr.validateOpts.ConfirmFn = func(prompt string) (bool, error) {
+		prompts = append(prompts, prompt)
+		return true, nil
+	}
+	if err := mgr.Steps(-1); err != nil {
+		t.Fatalf("Steps(-1) after confirming: %v", err)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "idx_users_email") {
+		t.Fatalf("prompts = %q, want one about the forgotten index", prompts)
+	}
+}
+
+func TestRollbackCheckRejectsUnknownMode(t *testing.T) {
+	mgr := &Manager{rollbackCheck: "strict"}
+	if err := mgr.checkRollbackMode(); err == nil {
+		t.Fatal("expected unknown mode to be rejected")
+	}
+}
+
+func TestAppliedVersionsListsLatestFirst(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if got, err := mgr.AppliedVersions(2); err != nil || len(got) != 0 {
+		t.Fatalf("AppliedVersions on empty database = %v, %v; want none", got, err)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	got, err := mgr.AppliedVersions(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Fatalf("AppliedVersions(2) = %v, want [3 2]", got)
+	}
+}
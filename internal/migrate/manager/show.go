@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// MigrationDetail describes one migration on disk and its state in the
+// database.
+type MigrationDetail struct {
+	Version  uint
+	UpFile   string
+	DownFile string // empty when the migration has no down file
+	UpSQL    string
+	DownSQL  string
+	// Hash is the SHA256 of the up file, as recorded in history.
+	Hash string
+	// UpStatements and DownStatements are the files split by the backend's
+	// validation dialect, comments stripped.
+	UpStatements   []TypedStatement
+	DownStatements []TypedStatement
+	Applied        bool
+	Committed      bool
+	// AppliedBy is the actor of the latest up history row, if any.
+	AppliedBy string
+}
+
+// TypedStatement is a statement with its type as reported by the dialect
+// (DDL, DML or UNKNOWN).
+type TypedStatement struct {
+	Type string
+	SQL  string
+}
+
+// Show reads the migration with version v from disk and looks up whether it
+// is applied and committed. It changes nothing.
+func (mgr *Manager) Show(v uint) (MigrationDetail, error) {
+	up, err := mgr.migrationFile(v, "up")
+	if err != nil {
+		return MigrationDetail{}, err
+	}
+	detail := MigrationDetail{Version: v, UpFile: up}
+	if detail.DownFile, err = mgr.migrationFile(v, "down"); err != nil {
+		detail.DownFile = ""
+	}
+
+	upSQL, err := fs.ReadFile(mgr.fsys, up)
+	if err != nil {
+		return MigrationDetail{}, err
+	}
+	detail.UpSQL = string(upSQL)
+	if detail.UpStatements, err = mgr.typedStatements(detail.UpSQL); err != nil {
+		return MigrationDetail{}, fmt.Errorf("split %s: %w", up, err)
+	}
+	if detail.DownFile != "" {
+		downSQL, err := fs.ReadFile(mgr.fsys, detail.DownFile)
+		if err != nil {
+			return MigrationDetail{}, err
+		}
+		detail.DownSQL = string(downSQL)
+		if detail.DownStatements, err = mgr.typedStatements(detail.DownSQL); err != nil {
+			return MigrationDetail{}, fmt.Errorf("split %s: %w", detail.DownFile, err)
+		}
+	}
+	if detail.Hash, err = mgr.hashFile(up); err != nil {
+		return MigrationDetail{}, err
+	}
+
+	cur, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationDetail{}, fmt.Errorf("read version: %w", err)
+	}
+	detail.Applied = err == nil && (v < cur || (v == cur && !dirty))
+	if detail.Committed, err = mgr.VersionCommitted(v); err != nil {
+		return MigrationDetail{}, err
+	}
+	if mgr.recordHist {
+		err := mgr.db.QueryRow(mgr.rebind(`SELECT executed_by FROM `+mgr.hist()+` WHERE action = 'up' AND version = $1 ORDER BY id DESC LIMIT 1`),
+			fmt.Sprintf("%d", v)).Scan(&detail.AppliedBy)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			mgr.logger.WithError(err).Warn("failed to read history for show")
+		}
+	}
+	return detail, nil
+}
+
+// migrationFile returns the path of the up or down file of version v.
+func (mgr *Manager) migrationFile(v uint, direction string) (string, error) {
+	files, err := fs.Glob(mgr.fsys, "*."+direction+".sql")
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if fv, err := fileVersion(f); err == nil && fv == v {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("no %s migration with version %d", direction, v)
+}
+
+func (mgr *Manager) typedStatements(content string) ([]TypedStatement, error) {
+	d := mgr.backend.Validator()
+	stmts, err := d.SplitStatements(content)
+	if err != nil {
+		return nil, err
+	}
+	var out []TypedStatement
+	for _, stmt := range stmts {
+		stmt = strings.TrimSpace(validate.StripComments(stmt))
+		if stmt == "" {
+			continue
+		}
+		out = append(out, TypedStatement{Type: d.StatementType(stmt), SQL: stmt})
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestShowDescribesMigration(t *testing.T) {
+	files := map[string]string{
+		"000001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);\nINSERT INTO users (id) VALUES (1);",
+		"000001_create_users.down.sql": "DROP TABLE users;",
+		"000002_add_email.up.sql":      "ALTER TABLE users ADD COLUMN email TEXT;",
+	}
+	mgr := newTestManager(t, files)
+	if err := mgr.Steps(1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if err := mgr.Commit(1); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	d, err := mgr.Show(1)
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if !d.Applied || !d.Committed || d.AppliedBy != "tester" {
+		t.Fatalf("state = applied %v committed %v by %q", d.Applied, d.Committed, d.AppliedBy)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(files["000001_create_users.up.sql"]))); d.Hash != want {
+		t.Fatalf("hash = %s, want %s", d.Hash, want)
+	}
+	if len(d.UpStatements) != 2 || d.UpStatements[0].Type != "DDL" || d.UpStatements[1].Type != "DML" {
+		t.Fatalf("up statements = %+v", d.UpStatements)
+	}
+	if d.DownSQL != "DROP TABLE users;" || len(d.DownStatements) != 1 {
+		t.Fatalf("down = %q %+v", d.DownSQL, d.DownStatements)
+	}
+
+	d, err = mgr.Show(2)
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if d.Applied || d.Committed || d.DownFile != "" {
+		t.Fatalf("pending migration = %+v", d)
+	}
+	if _, err := mgr.Show(9); err == nil {
+		t.Fatal("expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,141 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+// SignatureKeys is the set of OpenPGP public keys whose detached signatures
+// are accepted on migration files.
+type SignatureKeys struct {
+	keyring openpgp.EntityList
+}
+
+// LoadSignatureKeys reads armored or binary OpenPGP public keys from paths.
+func LoadSignatureKeys(paths ...string) (SignatureKeys, error) {
+	var keys SignatureKeys
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return SignatureKeys{}, fmt.Errorf("read signature key: %w", err)
+		}
+		var list openpgp.EntityList
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+			list, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+		} else {
+			list, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+		}
+		if err != nil {
+			return SignatureKeys{}, fmt.Errorf("parse signature key %s: %w", p, err)
+		}
+		keys.keyring = append(keys.keyring, list...)
+	}
+	return keys, nil
+}
+
+// WithSignatureKeys makes up verify the detached signature (<file>.sig) of
+// each up file against keys before applying anything. A signature that does
+// not verify is always refused; with require, so is a missing one.
+func WithSignatureKeys(keys SignatureKeys, require bool) Option {
+	return func(mgr *Manager) {
+		mgr.signatureKeys = keys
+		mgr.requireSignatures = require
+	}
+}
+
+// SignatureError reports an up file whose signature is missing or invalid.
+type SignatureError struct {
+	File string
+	Err  error
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("signature check failed for %s: %v", e.File, e.Err)
+}
+
+func (e *SignatureError) Unwrap() error { return e.Err }
+
+var errUnsigned = errors.New("no .sig file and signatures are required")
+
+// checkSignatures verifies upFiles and returns the signer of each signed
+// file. Without keys and without require nothing is checked.
+func (mgr *Manager) checkSignatures(upFiles []string) (map[string]string, error) {
+	if len(mgr.signatureKeys.keyring) == 0 && !mgr.requireSignatures {
+		return nil, nil
+	}
+	signers := map[string]string{}
+	for _, f := range upFiles {
+		sig, err := fs.ReadFile(mgr.fsys, f+".sig")
+		if errors.Is(err, fs.ErrNotExist) {
+			if mgr.requireSignatures {
+				return nil, &SignatureError{File: filepath.Base(f), Err: errUnsigned}
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(mgr.rawFS(), f)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := mgr.signatureKeys.verify(content, sig)
+		if err != nil {
+			return nil, &SignatureError{File: filepath.Base(f), Err: err}
+		}
+		signers[f] = signer
+	}
+	return signers, nil
+}
+
+// verify checks an armored or binary detached signature of content and
+// returns the signer as "Name <email> (KEYID)".
+func (k SignatureKeys) verify(content, sig []byte) (string, error) {
+	if len(k.keyring) == 0 {
+		return "", errors.New("no signature keys configured")
+	}
+	var (
+		entity *openpgp.Entity
+		err    error
+	)
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+		entity, err = openpgp.CheckArmoredDetachedSignature(k.keyring, bytes.NewReader(content), bytes.NewReader(sig), nil)
+	} else {
+		entity, err = openpgp.CheckDetachedSignature(k.keyring, bytes.NewReader(content), bytes.NewReader(sig), nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for name := range entity.Identities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%s (%s)", strings.Join(names, ", "), entity.PrimaryKey.KeyIdString()), nil
+}
+
+// withoutSignatures hides .sig files from golang-migrate, which would parse
+// 0001_x.up.sql.sig as a second up migration for version 1.
+type withoutSignatures struct{ fs.FS }
+
+func (w withoutSignatures) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(w.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	out := entries[:0]
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".sig") {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,152 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+const signedUp = "CREATE TABLE users (id INTEGER PRIMARY KEY);"
+
+// signingKey creates a throwaway key pair and writes its armored public key
+// to a file for LoadSignatureKeys.
+func signingKey(t *testing.T, name string) (*openpgp.Entity, string) {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	w, err := armorPublicKey(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	path := filepath.Join(t.TempDir(), name+".asc")
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return e, path
+}
+
+func detachSign(t *testing.T, e *openpgp.Entity, content string) string {
+	t.Helper()
+	var sig bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&sig, e, strings.NewReader(content), nil); err != nil {
+		t.Fatal(err)
+	}
+	return sig.String()
+}
+
+func signedManager(t *testing.T, files map[string]string, keyPath string) *Manager {
+	t.Helper()
+	keys, err := LoadSignatureKeys(keyPath)
+	if err != nil {
+		t.Fatalf("LoadSignatureKeys: %v", err)
+	}
+	return newTestManager(t, files, WithSignatureKeys(keys, true))
+}
+
+func TestSignatureValidRecordsSigner(t *testing.T) {
+	alice, key := signingKey(t, "alice")
+	mgr := signedManager(t, map[string]string{
+		"0001_users.up.sql":     signedUp,
+		"0001_users.up.sql.sig": detachSign(t, alice, signedUp),
+	}, key)
+
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	var signer string
+	if err := mgr.db.QueryRow(`SELECT signed_by FROM migrations_history WHERE action = 'up'`).Scan(&signer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(signer, "alice <alice@example.com>") || !strings.Contains(signer, alice.PrimaryKey.KeyIdString()) {
+		t.Fatalf("signed_by = %q", signer)
+	}
+}
+
+func TestSignatureInvalidRefused(t *testing.T) {
+	alice, key := signingKey(t, "alice")
+	mallory, _ := signingKey(t, "mallory")
+	for name, sig := range map[string]string{
+		"tampered":    detachSign(t, alice, signedUp+" -- edited"),
+		"unknown key": detachSign(t, mallory, signedUp),
+	} {
+		t.Run(name, func(t *testing.T) {
+			mgr := signedManager(t, map[string]string{
+				"0001_users.up.sql":     signedUp,
+				"0001_users.up.sql.sig": sig,
+			}, key)
+			var se *SignatureError
+			if err := mgr.Up(); !errors.As(err, &se) {
+				t.Fatalf("err = %v, want SignatureError", err)
+			}
+			if v, _, _ := mgr.Version(); v != 0 {
+				t.Fatalf("version = %d, nothing should be applied", v)
+			}
+		})
+	}
+}
+
+func TestSignatureMissing(t *testing.T) {
+	_, key := signingKey(t, "alice")
+	files := map[string]string{"0001_users.up.sql": signedUp}
+
+	var se *SignatureError
+	if err := signedManager(t, files, key).Up(); !errors.As(err, &se) || !errors.Is(err, errUnsigned) {
+		t.Fatalf("err = %v, want missing signature refused", err)
+	}
+
+	keys, _ := LoadSignatureKeys(key)
+	if err := newTestManager(t, files, WithSignatureKeys(keys, false)).Up(); err != nil {
+		t.Fatalf("Up without require_signatures: %v", err)
+	}
+}
+
+func TestSignatureRequiredUnderContinueOnError(t *testing.T) {
+	alice, key := signingKey(t, "alice")
+	mgr := signedManager(t, map[string]string{
+		"0001_users.up.sql":     signedUp,
+		"0001_users.up.sql.sig": detachSign(t, alice, signedUp),
+		"0002_more.up.sql":      "CREATE TABLE more (id INTEGER);",
+	}, key)
+
+	var se *SignatureError
+	if _, err := mgr.UpContinueOnError(); !errors.As(err, &se) || !errors.Is(err, errUnsigned) || se.File != "0002_more.up.sql" {
+		t.Fatalf("err = %v, want the unsigned file refused", err)
+	}
+	if v, _, _ := mgr.Version(); v != 0 {
+		t.Fatalf("version = %d, nothing should be applied", v)
+	}
+
+	mgr = signedManager(t, map[string]string{
+		"0001_users.up.sql":     signedUp,
+		"0001_users.up.sql.sig": detachSign(t, alice, signedUp),
+	}, key)
+	if failures, err := mgr.UpContinueOnError(); err != nil || len(failures) != 0 {
+		t.Fatalf("UpContinueOnError = %v, %v", failures, err)
+	}
+	var signer string
+	if err := mgr.db.QueryRow(`SELECT signed_by FROM migrations_history WHERE action = 'up'`).Scan(&signer); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(signer, "alice <alice@example.com>") {
+		t.Fatalf("signed_by = %q", signer)
+	}
+}
+
+func armorPublicKey(buf *bytes.Buffer) (io.WriteCloser, error) {
+	return armor.Encode(buf, openpgp.PublicKeyType, nil)
+}
@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SchemaInspector is implemented by backends that can describe the live
+// schema for drift detection. SchemaObjects returns one line per column,
+// index or constraint, each starting with its table name and a tab; order
+// does not matter.
+type SchemaInspector interface {
+	SchemaObjects(db *sql.DB) ([]string, error)
+}
+
+// WithSchemaSnapshot records a fingerprint of the schema in the history row of
+// the last migration applied by each successful up, for Drift to compare
+// against later.
+func WithSchemaSnapshot(on bool) Option {
+	return func(mgr *Manager) { mgr.schemaSnapshot = on }
+}
+
+// SchemaFingerprint returns the SHA256 of the sorted schema objects, leaving
+// out kaeshi's own tables so that bookkeeping does not count as drift.
+func (mgr *Manager) SchemaFingerprint() (string, error) {
+	si, ok := mgr.backend.(SchemaInspector)
+	if !ok {
+		return "", fmt.Errorf("backend %s cannot introspect its schema", mgr.backend.DriverName())
+	}
+	objects, err := si.SchemaObjects(mgr.db)
+	if err != nil {
+		return "", fmt.Errorf("introspect schema: %w", err)
+	}
+	own := map[string]bool{
+		mgr.MigrationsTable():                      true,
+		mgr.HistoryTable():                         true,
+		mgr.tablePrefix + "migrations_lock":        true,
+		mgr.tablePrefix + "kaeshi_privilege_probe": true,
+	}
+	kept := objects[:0:0]
+	for _, o := range objects {
+		table, _, _ := strings.Cut(o, "\t")
+		if !own[table] {
+			kept = append(kept, o)
+		}
+	}
+	sort.Strings(kept)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(kept, "\n")))), nil
+}
+
+// recordSnapshot stores the current schema fingerprint on the latest up row
+// of version v. Failures are logged; the migration itself succeeded.
+func (mgr *Manager) recordSnapshot(v uint) {
+	if !mgr.schemaSnapshot || !mgr.recordHist {
+		return
+	}
+	fp, err := mgr.SchemaFingerprint()
+	if err != nil {
+		mgr.logger.WithError(err).Warn("failed to take schema snapshot")
+		return
+	}
+	// The derived table lets MySQL read the table being updated.
+	_, err = mgr.db.Exec(mgr.rebind(`UPDATE `+mgr.hist()+` SET schema_fingerprint = $1
+WHERE id = (SELECT id FROM (SELECT MAX(id) AS id FROM `+mgr.hist()+` WHERE action = 'up' AND version = $2) latest)`), fp, fmt.Sprintf("%d", v))
+	if err != nil {
+		mgr.logger.WithError(err).Warnf("failed to record schema fingerprint for version %d", v)
+	}
+}
+
+// DriftReport compares the latest recorded schema fingerprint with the live
+// schema.
+type DriftReport struct {
+	// Version is the version the snapshot was taken at.
+	Version  uint
+	Recorded string
+	Live     string
+}
+
+// Drifted reports whether the live schema differs from the snapshot.
+func (r DriftReport) Drifted() bool { return r.Recorded != r.Live }
+
+// Drift fingerprints the live schema and compares it with the snapshot taken
+// after the latest up. It refuses to compare when migrations ran since the
+// snapshot, because their changes would read as drift.
+func (mgr *Manager) Drift() (DriftReport, error) {
+	if !mgr.recordHist {
+		return DriftReport{}, fmt.Errorf("history recording is disabled; no schema snapshot to compare")
+	}
+	mgr.ensureHistoryColumns()
+	var id int64
+	var version, fp string
+	err := mgr.db.QueryRow(`SELECT id, version, schema_fingerprint FROM `+mgr.hist()+
+		` WHERE schema_fingerprint IS NOT NULL ORDER BY id DESC LIMIT 1`).Scan(&id, &version, &fp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DriftReport{}, fmt.Errorf("no schema snapshot recorded; enable schema snapshots and run up first")
+	}
+	if err != nil {
+		return DriftReport{}, err
+	}
+	var later int
+	if err := mgr.db.QueryRow(mgr.rebind(`SELECT COUNT(*) FROM `+mgr.hist()+` WHERE id > $1`), id).Scan(&later); err != nil {
+		return DriftReport{}, err
+	}
+	if later > 0 {
+		return DriftReport{}, fmt.Errorf("migrations ran after the snapshot at version %s; run up to take a new one", version)
+	}
+	live, err := mgr.SchemaFingerprint()
+	if err != nil {
+		return DriftReport{}, err
+	}
+	var v uint
+	fmt.Sscan(version, &v)
+	return DriftReport{Version: v, Recorded: fp, Live: live}, nil
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+)
+
+var snapshotMigrations = map[string]string{
+	"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT);",
+	"0001_create_users.down.sql": "DROP TABLE users;",
+}
+
+func TestSchemaFingerprintIsStable(t *testing.T) {
+	mgr := newTestManager(t, snapshotMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	first, err := mgr.SchemaFingerprint()
+	if err != nil {
+		t.Fatalf("SchemaFingerprint: %v", err)
+	}
+	// History bookkeeping touches only kaeshi's own tables.
+	if err := mgr.CommitAll(); err != nil {
+		t.Fatalf("CommitAll: %v", err)
+	}
+	if _, err := mgr.db.Exec(`ALTER TABLE migrations_history ADD COLUMN note TEXT`); err != nil {
+		t.Fatal(err)
+	}
+	second, err := mgr.SchemaFingerprint()
+	if err != nil {
+		t.Fatalf("SchemaFingerprint: %v", err)
+	}
+	if first != second {
+		t.Fatalf("fingerprint changed without a schema change: %s != %s", first, second)
+	}
+}
+
+func TestDriftDetectsOutOfBandChange(t *testing.T) {
+	mgr := newTestManager(t, snapshotMigrations, WithSchemaSnapshot(true))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	report, err := mgr.Drift()
+	if err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if report.Drifted() || report.Version != 1 {
+		t.Fatalf("report = %+v, want no drift at version 1", report)
+	}
+
+	if _, err := mgr.db.Exec(`CREATE INDEX idx_users_email ON users (email)`); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = mgr.Drift(); err != nil {
+		t.Fatalf("Drift: %v", err)
+	}
+	if !report.Drifted() {
+		t.Fatalf("report = %+v, want drift after an out-of-band index", report)
+	}
+}
+
+func TestDriftRefusesAfterLaterMigrations(t *testing.T) {
+	mgr := newTestManager(t, snapshotMigrations, WithSchemaSnapshot(true))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.Steps(-1); err != nil {
+		t.Fatalf("Steps: %v", err)
+	}
+	if _, err := mgr.Drift(); err == nil || !strings.Contains(err.Error(), "after the snapshot") {
+		t.Fatalf("err = %v, want refusal after a later migration", err)
+	}
+}
+
+func TestDriftWithoutSnapshot(t *testing.T) {
+	mgr := newTestManager(t, snapshotMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := mgr.Drift(); err == nil || !strings.Contains(err.Error(), "no schema snapshot") {
+		t.Fatalf("err = %v, want missing snapshot error", err)
+	}
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"strings"
+
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// WithSourceURL makes the Manager load migrations through a golang-migrate
+// source driver URL instead of the migrations directory. Only drivers compiled
+// into the binary can be used; file:// is always available.
+//
+// For file:// URLs the directory is also read directly, so hashing,
+// validation and linting keep working. Other sources cannot be globbed: Up
+// then applies migrations without per-file validation or hash recording, and
+// Validate and UpContinueOnError are refused.
+func WithSourceURL(sourceURL string) Option {
+	return func(mgr *Manager) { mgr.sourceURL = sourceURL }
+}
+
+// sourceDir returns the local directory of a file:// source URL.
+func sourceDir(sourceURL string) (string, bool) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	dir := u.Host + u.Path
+	if dir == "" {
+		dir = u.Opaque
+	}
+	return dir, true
+}
+
+// remoteSource reports whether migrations come from a source that cannot be
+// read as a local file system.
+func (mgr *Manager) remoteSource() bool {
+	if mgr.sourceURL == "" {
+		return false
+	}
+	_, ok := sourceDir(mgr.sourceURL)
+	return !ok
+}
+
+// resolveSourceFS picks the file system used for file-based checks when a
+// source URL is configured.
+func (mgr *Manager) resolveSourceFS() error {
+	if mgr.sourceURL == "" {
+		return nil
+	}
+	if mgr.fsys != nil {
+		return fmt.Errorf("source_url cannot be combined with a migrations archive")
+	}
+	if dir, ok := sourceDir(mgr.sourceURL); ok {
+		mgr.fsys = os.DirFS(dir)
+		return nil
+	}
+	if !strings.Contains(mgr.sourceURL, "://") {
+		return fmt.Errorf("invalid source_url %q", mgr.sourceURL)
+	}
+	mgr.fsys = emptyFS{}
+	return nil
+}
+
+// emptyFS stands in for sources that cannot be listed locally.
+type emptyFS struct{}
+
+func (emptyFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// memSource is a source driver registered under a non-file scheme so tests
+// can exercise the remote-source path.
+type memSource struct {
+	source.Driver
+	files fstest.MapFS
+}
+
+func (s *memSource) Open(string) (source.Driver, error) { return iofs.New(s.files, ".") }
+
+func init() {
+	files := fstest.MapFS{}
+	for name, body := range threeMigrations {
+		files[name] = &fstest.MapFile{Data: []byte(body)}
+	}
+	source.Register("kaeshi-mem", &memSource{files: files})
+}
+
+func TestSourceURLFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, threeMigrations)
+	mgr := newTestManager(t, nil, WithSourceURL("file://"+dir))
+
+	if mgr.remoteSource() {
+		t.Fatal("file:// source must not be treated as remote")
+	}
+	if _, err := mgr.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if v, _, _ := mgr.Version(); v != 3 {
+		t.Fatalf("version = %d, want 3", v)
+	}
+	var hash string
+	if err := mgr.db.QueryRow(`SELECT sha256 FROM migrations_history WHERE version = '3'`).Scan(&hash); err != nil || hash == "" {
+		t.Fatalf("expected hash recorded for file:// source, got %q (%v)", hash, err)
+	}
+}
+
+func TestSourceURLRemoteDegrades(t *testing.T) {
+	mgr := newTestManager(t, nil, WithSourceURL("kaeshi-mem://"))
+
+	if !mgr.remoteSource() {
+		t.Fatal("kaeshi-mem source should be remote")
+	}
+	if _, err := mgr.Validate(); err == nil {
+		t.Fatal("Validate should be refused for non-file sources")
+	}
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up from remote source: %v", err)
+	}
+	if v, _, _ := mgr.Version(); v != 3 {
+		t.Fatalf("version = %d, want 3", v)
+	}
+}
+
+func TestSourceURLWithArchiveRejected(t *testing.T) {
+	mgr := &Manager{sourceURL: "file:///tmp", fsys: emptyFS{}}
+	if err := mgr.resolveSourceFS(); err == nil {
+		t.Fatal("expected error combining source_url with an archive")
+	}
+}
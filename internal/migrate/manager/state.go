@@ -0,0 +1,37 @@
+package manager
+
+import "database/sql"
+
+// dbState is the golang-migrate version and dirty flag observed at one point
+// in time. An invalid version means no migration had been applied or the
+// version could not be read.
+type dbState struct {
+	version sql.NullInt64
+	dirty   bool
+}
+
+// stateOf builds a dbState from the results of migrate.Version.
+func stateOf(v uint, dirty bool, err error) dbState {
+	if err != nil {
+		return dbState{}
+	}
+	return dbState{version: sql.NullInt64{Int64: int64(v), Valid: true}, dirty: dirty}
+}
+
+func (mgr *Manager) observeState() dbState {
+	return stateOf(mgr.m.Version())
+}
+
+// transition is the state observed before and after an operation, persisted
+// with its history row so dirty episodes can be reconstructed later.
+type transition struct {
+	from, to dbState
+}
+
+// transitionColumns and args keep the column list and values of a transition
+// in the same order for history inserts.
+const transitionColumns = "version_before, dirty_before, version_after, dirty_after"
+
+func (t transition) args() []any {
+	return []any{t.from.version, t.from.dirty, t.to.version, t.to.dirty}
+}
@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// MigrationsTableDriver is implemented by backends whose golang-migrate driver
+// can track versions in a table other than schema_migrations.
+type MigrationsTableDriver interface {
+	NewDriverWithTable(db *sql.DB, table string) (database.Driver, error)
+}
+
+// reTableIdent matches table prefixes and names safe to embed in SQL.
+var reTableIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WithTablePrefix prepends prefix to the schema_migrations and
+// migrations_history table names so several applications can keep their
+// migrations in one database.
+func WithTablePrefix(prefix string) Option {
+	return func(mgr *Manager) { mgr.tablePrefix = prefix }
+}
+
+// WithTableNames replaces the version and history table names; an empty name
+// keeps the default, with any table prefix applied.
+func WithTableNames(migrations, history string) Option {
+	return func(mgr *Manager) {
+		mgr.migrationsTable = migrations
+		mgr.historyTable = history
+	}
+}
+
+// newDriver creates the golang-migrate driver, pointing it at the prefixed
+// or configured migrations table when one is set.
+func (mgr *Manager) newDriver(db *sql.DB) (database.Driver, error) {
+	for _, name := range []string{mgr.migrationsTable, mgr.historyTable} {
+		if name != "" && !reTableIdent.MatchString(name) {
+			return nil, fmt.Errorf("invalid table name %q: use letters, digits and underscores", name)
+		}
+	}
+	if mgr.tablePrefix == "" && mgr.migrationsTable == "" {
+		return mgr.backend.NewDriver(db)
+	}
+	if mgr.tablePrefix != "" && !reTableIdent.MatchString(mgr.tablePrefix) {
+		return nil, fmt.Errorf("invalid table prefix %q: use letters, digits and underscores", mgr.tablePrefix)
+	}
+	td, ok := mgr.backend.(MigrationsTableDriver)
+	if !ok {
+		return nil, fmt.Errorf("backend %s does not support a custom migrations table", mgr.backend.DriverName())
+	}
+	return td.NewDriverWithTable(db, mgr.MigrationsTable())
+}
+
+// MigrationsTable returns the name of the golang-migrate version table.
+func (mgr *Manager) MigrationsTable() string {
+	if mgr.migrationsTable != "" {
+		return mgr.migrationsTable
+	}
+	return mgr.tablePrefix + "schema_migrations"
+}
+
+// HistoryTable returns the name of the audit history table.
+func (mgr *Manager) HistoryTable() string {
+	if mgr.historyTable != "" {
+		return mgr.historyTable
+	}
+	return mgr.tablePrefix + "migrations_history"
+}
+
+// IdentQuoter is implemented by backends that do not quote identifiers with
+// ANSI double quotes, such as MySQL.
+type IdentQuoter interface {
+	QuoteIdent(name string) string
+}
+
+// MissingTableDetector is implemented by backends that can tell a query
+// failed because its table does not exist, as with the history table on a
+// database the first migration has not created yet.
+type MissingTableDetector interface {
+	IsMissingTable(err error) bool
+}
+
+// missingTable reports whether err means the queried table does not exist.
+func (mgr *Manager) missingTable(err error) bool {
+	d, ok := mgr.backend.(MissingTableDetector)
+	return ok && err != nil && d.IsMissingTable(err)
+}
+
+// rePlaceholder matches the $N bind parameters the manager writes queries with.
+var rePlaceholder = regexp.MustCompile(`\$[0-9]+`)
+
+// hist returns the quoted history table name for use in queries.
+func (mgr *Manager) hist() string {
+	return mgr.quote(mgr.HistoryTable())
+}
+
+// quote quotes a table name for the backend.
+func (mgr *Manager) quote(name string) string {
+	if q, ok := mgr.backend.(IdentQuoter); ok {
+		return q.QuoteIdent(name)
+	}
+	return quoteIdent(name)
+}
+
+// rebind rewrites the $N parameters of query through the backend's
+// Placeholder, so queries can be written once for every backend.
+func (mgr *Manager) rebind(query string) string {
+	return rePlaceholder.ReplaceAllStringFunc(query, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		return mgr.backend.Placeholder(n)
+	})
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,111 @@
+package manager
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMySQLBackendRewritesHistorySQL(t *testing.T) {
+	b, ok := GetBackend("mysql")
+	if !ok {
+		t.Fatal("mysql backend is not registered")
+	}
+	mgr := &Manager{backend: b}
+	got := mgr.rebind(`SELECT sha256 FROM ` + mgr.hist() + ` WHERE version = $1 AND id > $12`)
+	if want := "SELECT sha256 FROM `migrations_history` WHERE version = ? AND id > ?"; got != want {
+		t.Fatalf("rebind = %q, want %q", got, want)
+	}
+
+	pg := &Manager{backend: PostgresBackend{}}
+	if got := pg.rebind(`SELECT 1 FROM ` + pg.hist() + ` WHERE version = $1`); got != `SELECT 1 FROM "migrations_history" WHERE version = $1` {
+		t.Fatalf("postgres rebind = %q", got)
+	}
+}
+
+func TestHistoryQueriesUseBackendPlaceholders(t *testing.T) {
+	cases := []struct {
+		backend      DBBackend
+		insert, read string
+	}{
+		{
+			PostgresBackend{},
+			`INSERT INTO "migrations_history"(action, version, executed_by, committed, version_before, dirty_before, version_after, dirty_after) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)`,
+			`SELECT committed FROM "migrations_history" WHERE version = $1 ORDER BY id DESC LIMIT 1`,
+		},
+		{
+			MySQLBackend{},
+			"INSERT INTO `migrations_history`(action, version, executed_by, committed, version_before, dirty_before, version_after, dirty_after) VALUES (?,?,?,?,?,?,?,?)",
+			"SELECT committed FROM `migrations_history` WHERE version = ? ORDER BY id DESC LIMIT 1",
+		},
+		{
+			SQLiteBackend{},
+			`INSERT INTO "migrations_history"(action, version, executed_by, committed, version_before, dirty_before, version_after, dirty_after) VALUES (?,?,?,?,?,?,?,?)`,
+			`SELECT committed FROM "migrations_history" WHERE version = ? ORDER BY id DESC LIMIT 1`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.backend.DriverName(), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mgr := &Manager{
+				backend:          c.backend,
+				db:               db,
+				logger:           logrus.NewEntry(logrus.New()),
+				actor:            "tester",
+				recordHist:       true,
+				histColumnsReady: true,
+			}
+			mock.ExpectExec(regexp.QuoteMeta(c.insert)).
+				WithArgs("down", "3", "tester", false, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectQuery(regexp.QuoteMeta(c.read)).WithArgs("3").
+				WillReturnRows(sqlmock.NewRows([]string{"committed"}).AddRow(true))
+
+			mgr.recordHistory("down", 3, transition{})
+			if ok, err := mgr.VersionCommitted(3); err != nil || !ok {
+				t.Fatalf("VersionCommitted = %v, %v; want true", ok, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMissingHistoryTableMeansNothingCommitted(t *testing.T) {
+	cases := []struct {
+		backend DBBackend
+		err     error
+	}{
+		{PostgresBackend{}, &pq.Error{Code: "42P01", Message: `relation "migrations_history" does not exist`}},
+		{MySQLBackend{}, &mysql.MySQLError{Number: 1146, Message: "Table 'app.migrations_history' doesn't exist"}},
+		{SQLiteBackend{}, errors.New("SQL logic error: no such table: migrations_history (1)")},
+	}
+	for _, c := range cases {
+		t.Run(c.backend.DriverName(), func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			mgr := &Manager{backend: c.backend, db: db, recordHist: true}
+			mock.ExpectQuery(`SELECT committed FROM`).WillReturnError(c.err)
+			if ok, err := mgr.VersionCommitted(1); err != nil || ok {
+				t.Fatalf("VersionCommitted = %v, %v; want false, nil", ok, err)
+			}
+			mock.ExpectQuery(`SELECT committed FROM`).WillReturnError(errors.New("connection reset"))
+			if _, err := mgr.VersionCommitted(1); err == nil {
+				t.Fatal("other errors must still be reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// PendingTagged returns the pending migrations carrying tag, in apply order.
+func (mgr *Manager) PendingTagged(tag string) ([]PendingMigration, error) {
+	all, err := mgr.Pending()
+	if err != nil {
+		return nil, err
+	}
+	var out []PendingMigration
+	for _, p := range all {
+		if p.HasTag(tag) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// ApplyTag applies pending migrations tagged tag in order. Versions must stay
+// sequential, so it stops before the first pending migration without the
+// tag; that file is returned so callers can say why nothing further ran. It
+// is refused in production, where migrations only go through Up.
+func (mgr *Manager) ApplyTag(tag string) (applied []string, blockedBy string, err error) {
+	err = mgr.track("apply", func() error {
+		applied, blockedBy, err = mgr.applyTag(tag)
+		return err
+	})
+	return applied, blockedBy, err
+}
+
+func (mgr *Manager) applyTag(tag string) (applied []string, blockedBy string, err error) {
+	if mgr.isProduction() {
+		return nil, "", fmt.Errorf("apply --tag is not allowed in production; use up")
+	}
+	if mgr.remoteSource() {
+		return nil, "", fmt.Errorf("apply --tag needs a file-based migrations source")
+	}
+	before, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, "", fmt.Errorf("read version before apply: %w", err)
+	}
+	if dirty {
+		return nil, "", &DirtyError{Version: before}
+	}
+	files, err := mgr.pendingUpFiles(before)
+	if err != nil {
+		return nil, "", err
+	}
+	var upFiles []string
+	for _, f := range files {
+		d, err := readDirectives(mgr.fsys, f)
+		if err != nil {
+			return nil, "", fmt.Errorf("%s: %w", filepath.Base(f), err)
+		}
+		if !d.hasTag(tag) {
+			blockedBy = filepath.Base(f)
+			break
+		}
+		upFiles = append(upFiles, f)
+	}
+	if len(upFiles) == 0 {
+		return nil, blockedBy, nil
+	}
+	if err := mgr.applyPending(before, upFiles); err != nil {
+		return nil, blockedBy, err
+	}
+	for _, f := range upFiles {
+		applied = append(applied, filepath.Base(f))
+	}
+	return applied, blockedBy, nil
+}
+
+// runTags returns the distinct tags of runs, sorted, for notifier events.
+func runTags(runs map[uint]fileRun) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, run := range runs {
+		for _, t := range run.tags {
+			if k := strings.ToLower(t); !seen[k] {
+				seen[k] = true
+				out = append(out, t)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"database/sql"
+	"testing"
+)
+
+var taggedMigrations = map[string]string{
+	"0001_invoices.up.sql":   "-- kaeshi:tags billing\nCREATE TABLE invoices (id INTEGER);",
+	"0001_invoices.down.sql": "DROP TABLE invoices;",
+	"0002_reports.up.sql":    "-- kaeshi:tags reporting,billing\nCREATE TABLE reports (id INTEGER);",
+	"0002_reports.down.sql":  "DROP TABLE reports;",
+	"0003_users.up.sql":      "CREATE TABLE users (id INTEGER);",
+	"0003_users.down.sql":    "DROP TABLE users;",
+	"0004_dash.up.sql":       "-- kaeshi:tags reporting\nCREATE TABLE dash (id INTEGER);",
+	"0004_dash.down.sql":     "DROP TABLE dash;",
+}
+
+func TestPendingTaggedFiltersStatus(t *testing.T) {
+	mgr := newTestManager(t, taggedMigrations)
+	got, err := mgr.PendingTagged("Reporting")
+	if err != nil {
+		t.Fatalf("PendingTagged: %v", err)
+	}
+	if len(got) != 2 || got[0].File != "0002_reports.up.sql" || got[1].File != "0004_dash.up.sql" {
+		t.Fatalf("pending tagged reporting = %+v", got)
+	}
+	if got[0].Tags[0] != "reporting" || got[0].Tags[1] != "billing" {
+		t.Fatalf("tags = %v", got[0].Tags)
+	}
+}
+
+func TestApplyTagStopsAtUntaggedFile(t *testing.T) {
+	mgr := newTestManager(t, taggedMigrations)
+	applied, blockedBy, err := mgr.ApplyTag("billing")
+	if err != nil {
+		t.Fatalf("ApplyTag: %v", err)
+	}
+	if len(applied) != 2 || blockedBy != "0003_users.up.sql" {
+		t.Fatalf("applied = %v, blocked by %q; want 0001 and 0002 then stop at 0003", applied, blockedBy)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("version = %d, want 2", v)
+	}
+	var tags sql.NullString
+	if err := mgr.db.QueryRow(`SELECT tags FROM migrations_history WHERE action = 'up' AND version = '2'`).Scan(&tags); err != nil {
+		t.Fatalf("query history: %v", err)
+	}
+	if tags.String != "reporting,billing" {
+		t.Fatalf("history tags = %q", tags.String)
+	}
+
+	if applied, _, err := mgr.ApplyTag("reporting"); err != nil || len(applied) != 0 {
+		t.Fatalf("reporting is blocked by 0003: applied=%v err=%v", applied, err)
+	}
+}
+
+func TestApplyTagBlockedInProduction(t *testing.T) {
+	mgr := newTestManager(t, taggedMigrations, WithEnv("production"))
+	if _, _, err := mgr.ApplyTag("billing"); err == nil {
+		t.Fatal("expected apply --tag to be refused in production")
+	}
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// Tenant is one database or schema that receives the same migrations as the
+// others.
+type Tenant struct {
+	Name string
+	DSN  string
+}
+
+// TenantResult is the outcome of applying migrations to one tenant.
+type TenantResult struct {
+	Tenant string
+	Before uint
+	After  uint
+	// Resumed is set when the tenant was skipped because progress records
+	// it as completed by an earlier run.
+	Resumed bool
+	Err     error
+}
+
+// UpAllTenants runs Up against every tenant, at most concurrency at a time,
+// with a Manager built by open for each. A failing tenant does not stop the
+// others. Results are in the order of tenants. When progress is not nil,
+// tenants it records as completed are skipped and each tenant that succeeds
+// is recorded there.
+func UpAllTenants(tenants []Tenant, concurrency int, open func(Tenant) (*Manager, error), progress *Progress) []TenantResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]TenantResult, len(tenants))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, t := range tenants {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			if progress != nil {
+				if v, ok := progress.Done(t.Name); ok {
+					results[i] = TenantResult{Tenant: t.Name, Before: v, After: v, Resumed: true}
+					return
+				}
+			}
+			results[i] = upTenant(t, open)
+			if progress != nil && results[i].Err == nil {
+				results[i].Err = progress.Complete(t.Name, results[i].After)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func upTenant(t Tenant, open func(Tenant) (*Manager, error)) TenantResult {
+	res := TenantResult{Tenant: t.Name}
+	mgr, err := open(t)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer mgr.Close()
+	res.Before, _, _ = mgr.Version()
+	if err := mgr.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		res.Err = err
+	}
+	res.After, _, _ = mgr.Version()
+	return res
+}
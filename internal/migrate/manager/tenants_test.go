@@ -0,0 +1,138 @@
+package manager
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestUpAllTenantsContinuesPastFailure(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"0001_create_users.up.sql":   "CREATE TABLE users (id INTEGER PRIMARY KEY);",
+		"0001_create_users.down.sql": "DROP TABLE users;",
+		"0002_add_email.up.sql":      "CREATE TABLE emails (id INTEGER PRIMARY KEY);",
+		"0002_add_email.down.sql":    "DROP TABLE emails;",
+	})
+
+	var tenants []Tenant
+	for _, name := range []string{"acme", "globex", "initech"} {
+		dsn := filepath.Join(t.TempDir(), name+".db")
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(testHistoryDDL); err != nil {
+			t.Fatal(err)
+		}
+		if name == "globex" {
+			// An out-of-band table makes 0002 fail for this tenant only.
+			if _, err := db.Exec(`CREATE TABLE emails (id INTEGER)`); err != nil {
+				t.Fatal(err)
+			}
+		}
+		db.Close()
+		tenants = append(tenants, Tenant{Name: name, DSN: dsn})
+	}
+
+	log, _ := test.NewNullLogger()
+	open := func(tn Tenant) (*Manager, error) {
+		return NewManager(SQLiteBackend{}, tn.DSN, dir, 0, logrus.NewEntry(log).WithField("tenant", tn.Name), "tester", false, nil, nil)
+	}
+	results := UpAllTenants(tenants, 2, open, nil)
+
+	if len(results) != 3 {
+		t.Fatalf("results = %+v, want 3", results)
+	}
+	for _, r := range results {
+		switch r.Tenant {
+		case "globex":
+			if r.Err == nil || r.After == 2 {
+				t.Fatalf("globex = %+v, want failure before version 2", r)
+			}
+		default:
+			if r.Err != nil || r.Before != 0 || r.After != 2 {
+				t.Fatalf("%s = %+v, want 0 -> 2 without error", r.Tenant, r)
+			}
+		}
+	}
+	if results[0].Tenant != "acme" || results[2].Tenant != "initech" {
+		t.Fatalf("results out of tenant order: %+v", results)
+	}
+}
+
+func TestUpAllTenantsResumesFromProgress(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, threeMigrations)
+	var tenants []Tenant
+	for _, name := range []string{"acme", "globex", "initech"} {
+		dsn := filepath.Join(t.TempDir(), name+".db")
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(testHistoryDDL); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+		tenants = append(tenants, Tenant{Name: name, DSN: dsn})
+	}
+
+	log, _ := test.NewNullLogger()
+	var opened []string
+	interrupted := true
+	open := func(tn Tenant) (*Manager, error) {
+		opened = append(opened, tn.Name)
+		if interrupted && tn.Name == "initech" {
+			return nil, errors.New("connection lost")
+		}
+		return NewManager(SQLiteBackend{}, tn.DSN, dir, 0, logrus.NewEntry(log), "tester", false, nil, nil)
+	}
+	path := filepath.Join(t.TempDir(), DefaultProgressFile)
+
+	progress, err := OpenProgress(path, "up --all-tenants", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := UpAllTenants(tenants, 1, open, progress)
+	if first[2].Err == nil {
+		t.Fatalf("initech should fail in the interrupted run: %+v", first[2])
+	}
+
+	interrupted, opened = false, nil
+	progress, err = OpenProgress(path, "up --all-tenants", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := UpAllTenants(tenants, 1, open, progress)
+	if !reflect.DeepEqual(opened, []string{"initech"}) {
+		t.Fatalf("resumed run opened %v, want only initech", opened)
+	}
+	for _, r := range second {
+		if r.Err != nil || r.After != 3 || r.Resumed != (r.Tenant != "initech") {
+			t.Fatalf("resumed result %+v", r)
+		}
+	}
+	if err := progress.Finish(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("progress file should be removed after success: %v", err)
+	}
+
+	if _, err := OpenProgress(path, "up --all-tenants", true); err != nil {
+		t.Fatalf("resume without a progress file should start fresh: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"command":"redo","completed":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenProgress(path, "up --all-tenants", true); err == nil {
+		t.Fatal("progress of another command should be refused")
+	}
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// UpTo applies the pending migrations up to and including version, which
+// must have an up file, with every check Up makes. Pending versions are
+// applied in order from the current one, so none is skipped, and history
+// records exactly the versions applied. A version at or below the current
+// one is refused; use Goto to roll back.
+func (mgr *Manager) UpTo(version uint) error {
+	return mgr.track("up", func() error { return mgr.upTo(version) })
+}
+
+func (mgr *Manager) upTo(target uint) error {
+	if mgr.remoteSource() {
+		return fmt.Errorf("up --to needs a file-based migrations source")
+	}
+	before, dirty, err := mgr.m.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("read version before Up: %w", err)
+	}
+	if dirty {
+		return &DirtyError{Version: before}
+	}
+	if _, err := mgr.migrationFile(target, "up"); err != nil {
+		return fmt.Errorf("cannot migrate up to version %d: no migration file for it", target)
+	}
+	if target <= before {
+		return fmt.Errorf("version %d is not pending (current version %d); use goto to roll back", target, before)
+	}
+	files, err := mgr.pendingUpFiles(before)
+	if err != nil {
+		return err
+	}
+	var upFiles []string
+	for _, f := range files {
+		if v, err := fileVersion(f); err == nil && v <= target {
+			upFiles = append(upFiles, f)
+		}
+	}
+	return mgr.applyPending(before, upFiles)
+}
@@ -0,0 +1,33 @@
+package manager
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUpToAppliesOnlyTheWindow(t *testing.T) {
+	mgr := newTestManager(t, gotoMigrations)
+	if err := mgr.UpTo(2); err != nil {
+		t.Fatalf("UpTo(2): %v", err)
+	}
+	if v, _, _ := mgr.Version(); v != 2 {
+		t.Fatalf("version = %d, want 2", v)
+	}
+	if got, want := historyRows(t, mgr), []string{"up:1", "up:2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("history = %v, want %v", got, want)
+	}
+
+	if err := mgr.UpTo(2); err == nil || !strings.Contains(err.Error(), "not pending") {
+		t.Fatalf("UpTo(2) again err = %v, want not pending", err)
+	}
+	if err := mgr.UpTo(7); err == nil || !strings.Contains(err.Error(), "no migration file") {
+		t.Fatalf("UpTo(7) err = %v, want missing file", err)
+	}
+	if err := mgr.UpTo(3); err != nil {
+		t.Fatalf("UpTo(3): %v", err)
+	}
+	if got := historyRows(t, mgr); len(got) != 3 || got[2] != "up:3" {
+		t.Fatalf("history = %v, want up:3 appended", got)
+	}
+}
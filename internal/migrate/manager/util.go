@@ -4,12 +4,18 @@ import (
 	"crypto/sha256"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
 )
 
 // fileHash computes the SHA256 of the given file.
-func fileHash(path string) (string, error) {
-	f, err := os.Open(path)
+func fileHash(fsys fs.FS, path string) (string, error) {
+	f, err := fsys.Open(path)
 	if err != nil {
 		return "", err
 	}
@@ -20,3 +26,78 @@ func fileHash(path string) (string, error) {
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+// normalizedHashPrefix marks hashes of normalized SQL, so history rows keep
+// being compared the way they were recorded when the hash mode changes.
+const normalizedHashPrefix = "norm:"
+
+// hashFile hashes f as recorded in history: byte for byte, or after
+// validate.Normalize when WithNormalizedHash is on.
+func (mgr *Manager) hashFile(f string) (string, error) {
+	if mgr.normalizeHash {
+		return mgr.normalizedHash(f)
+	}
+	return fileHash(mgr.rawFS(), f)
+}
+
+// hashAs hashes f the same way recorded, a hash from history, was computed.
+func (mgr *Manager) hashAs(f, recorded string) (string, error) {
+	if strings.HasPrefix(recorded, normalizedHashPrefix) {
+		return mgr.normalizedHash(f)
+	}
+	return fileHash(mgr.rawFS(), f)
+}
+
+func (mgr *Manager) normalizedHash(f string) (string, error) {
+	data, err := fs.ReadFile(mgr.rawFS(), f)
+	if err != nil {
+		return "", err
+	}
+	norm, err := validate.Normalize(string(data), mgr.backend.Validator())
+	if err != nil {
+		return "", fmt.Errorf("normalize %s: %w", filepath.Base(f), err)
+	}
+	return fmt.Sprintf("%s%x", normalizedHashPrefix, sha256.Sum256([]byte(norm))), nil
+}
+
+// fileVersion parses the numeric version prefix of a migration file name.
+func fileVersion(path string) (uint, error) {
+	v, err := strconv.ParseUint(strings.SplitN(filepath.Base(path), "_", 2)[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid migration file name %s: %w", filepath.Base(path), err)
+	}
+	return uint(v), nil
+}
+
+// checkDuplicateVersions scans dir for migration files sharing the same numeric
+// version prefix. golang-migrate silently picks one of them, so any duplicate is
+// reported with the full list of conflicting files.
+func checkDuplicateVersions(fsys fs.FS) error {
+	var conflicts []string
+	for _, suffix := range []string{".up.sql", ".down.sql"} {
+		files, err := fs.Glob(fsys, "*"+suffix)
+		if err != nil {
+			return err
+		}
+		byVersion := map[uint64][]string{}
+		for _, f := range files {
+			base := filepath.Base(f)
+			v, err := strconv.ParseUint(strings.SplitN(base, "_", 2)[0], 10, 64)
+			if err != nil {
+				continue
+			}
+			byVersion[v] = append(byVersion[v], base)
+		}
+		for v, names := range byVersion {
+			if len(names) > 1 {
+				sort.Strings(names)
+				conflicts = append(conflicts, fmt.Sprintf("version %d (%s): %s", v, suffix, strings.Join(names, ", ")))
+			}
+		}
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	sort.Strings(conflicts)
+	return fmt.Errorf("duplicate migration versions found:\n  %s", strings.Join(conflicts, "\n  "))
+}
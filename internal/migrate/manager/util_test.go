@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
+
+func TestCheckDuplicateVersions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"000001_init.up.sql":         "SELECT 1;",
+		"000001_init.down.sql":       "SELECT 1;",
+		"000002_add_users.up.sql":    "SELECT 1;",
+		"000002_add_users.down.sql":  "SELECT 1;",
+		"000002_add_orders.up.sql":   "SELECT 1;",
+		"000002_add_orders.down.sql": "SELECT 1;",
+	})
+	err := checkDuplicateVersions(os.DirFS(dir))
+	if err == nil {
+		t.Fatal("expected duplicate version error")
+	}
+	for _, name := range []string{"000002_add_users.up.sql", "000002_add_orders.up.sql", "000002_add_orders.down.sql"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Fatalf("error should list %s: %v", name, err)
+		}
+	}
+	if strings.Contains(err.Error(), "000001_init") {
+		t.Fatalf("error should not list unique versions: %v", err)
+	}
+}
+
+func TestCheckDuplicateVersionsClean(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"000001_init.up.sql":   "SELECT 1;",
+		"000001_init.down.sql": "SELECT 1;",
+	})
+	if err := checkDuplicateVersions(os.DirFS(dir)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNewManagerRejectsDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"000003_a.up.sql": "SELECT 1;",
+		"000003_b.up.sql": "SELECT 1;",
+	})
+	_, err := NewManager(PostgresBackend{}, "postgres://invalid", dir, 0, nil, "", false, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "duplicate migration versions") {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+}
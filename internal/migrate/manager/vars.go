@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// WithTemplateVars renders every migration file through text/template before
+// it is validated or executed, with vars as the data, e.g. {{.schema}}. A
+// variable the file uses but vars lacks is an error, never an empty string;
+// write {{"{{"}} for a literal {{. Hashes and signatures cover the file as
+// written, so they are the same in every environment. Without vars files
+// are read verbatim; an empty non-nil vars still renders, so actions fail.
+func WithTemplateVars(vars map[string]string) Option {
+	return func(mgr *Manager) { mgr.templateVars = vars }
+}
+
+// rawFS returns the migration files as written, before WithTemplateVars
+// rendering.
+func (mgr *Manager) rawFS() fs.FS {
+	if t, ok := mgr.fsys.(templatedFS); ok {
+		return t.FS
+	}
+	return mgr.fsys
+}
+
+// templatedFS serves .sql files rendered with vars and everything else as is.
+type templatedFS struct {
+	fs.FS
+	vars map[string]string
+}
+
+func (t templatedFS) Open(name string) (fs.File, error) {
+	if !strings.HasSuffix(name, ".sql") {
+		return t.FS.Open(name)
+	}
+	data, err := fs.ReadFile(t.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	out, err := renderSQL(path.Base(name), data, t.vars)
+	if err != nil {
+		return nil, &fs.PathError{Op: "render", Path: name, Err: err}
+	}
+	info, err := fs.Stat(t.FS, name)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedFile{Reader: bytes.NewReader(out), info: renderedInfo{info, int64(len(out))}}, nil
+}
+
+// renderSQL executes data as a template over vars. Files without an action
+// are returned unchanged.
+func renderSQL(name string, data []byte, vars map[string]string) ([]byte, error) {
+	if !bytes.Contains(data, []byte("{{")) {
+		return data, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("unresolved template variable: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type renderedFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *renderedFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *renderedFile) Close() error               { return nil }
+
+// renderedInfo reports the size of the rendered file.
+type renderedInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (i renderedInfo) Size() int64 { return i.size }
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTemplateVarsRenderMigrations(t *testing.T) {
+	up := "CREATE TABLE {{.table}} (note TEXT DEFAULT '{{\"{{\"}}raw}}');"
+	mgr := newTestManager(t, map[string]string{"000001_a.up.sql": up},
+		WithTemplateVars(map[string]string{"table": "widgets"}))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if _, err := mgr.db.Exec(`INSERT INTO widgets DEFAULT VALUES`); err != nil {
+		t.Fatalf("rendered table missing: %v", err)
+	}
+	var note, hash string
+	if err := mgr.db.QueryRow(`SELECT note FROM widgets`).Scan(&note); err != nil || note != "{{raw}}" {
+		t.Fatalf("escaped default = %q, %v; want {{raw}}", note, err)
+	}
+	if err := mgr.db.QueryRow(`SELECT sha256 FROM migrations_history WHERE version = '1'`).Scan(&hash); err != nil {
+		t.Fatalf("query hash: %v", err)
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(up))); hash != want {
+		t.Fatalf("hash = %s, want hash of the file as written", hash)
+	}
+
+	missing := newTestManager(t, map[string]string{"000001_a.up.sql": "CREATE TABLE {{.schema}}.t (id INTEGER);"},
+		WithTemplateVars(map[string]string{"table": "widgets"}))
+	if err := missing.Up(); err == nil || !strings.Contains(err.Error(), "unresolved template variable") {
+		t.Fatalf("Up err = %v, want unresolved variable", err)
+	}
+}
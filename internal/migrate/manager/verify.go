@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// HashMismatch is a committed migration whose up file no longer matches the
+// hash recorded when it was applied.
+type HashMismatch struct {
+	Version uint
+	// File is the base name of the up file; empty when Missing.
+	File     string
+	Expected string
+	// Actual is the hash of the file on disk; empty when Missing.
+	Actual string
+	// Missing is set when no up file exists for the version.
+	Missing bool
+}
+
+func (m HashMismatch) String() string {
+	if m.Missing {
+		return fmt.Sprintf("version %d: up file is missing (expected hash %s)", m.Version, m.Expected)
+	}
+	return fmt.Sprintf("%s: expected hash %s, actual %s", m.File, m.Expected, m.Actual)
+}
+
+// Verify recomputes the hash of the up file of every committed version and
+// compares it with the latest hash recorded in history, returning every
+// edited or missing file in version order.
+func (mgr *Manager) Verify() ([]HashMismatch, error) {
+	if !mgr.recordHist {
+		return nil, fmt.Errorf("verify needs migration history; it is disabled")
+	}
+	rows, err := mgr.db.Query(`SELECT version, sha256 FROM ` + mgr.hist() + ` WHERE action='up' AND committed=true AND sha256 <> '' ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("read committed history: %w", err)
+	}
+	defer rows.Close()
+	expected := map[uint]string{}
+	for rows.Next() {
+		var version, hash string
+		if err := rows.Scan(&version, &hash); err != nil {
+			return nil, err
+		}
+		v, err := strconv.ParseUint(version, 10, 64)
+		if err != nil {
+			continue
+		}
+		expected[uint(v)] = hash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(mgr.fsys, "*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	upFile := map[uint]string{}
+	for _, f := range files {
+		if v, err := fileVersion(f); err == nil {
+			upFile[v] = f
+		}
+	}
+
+	var out []HashMismatch
+	for v, want := range expected {
+		f, ok := upFile[v]
+		if !ok {
+			out = append(out, HashMismatch{Version: v, Expected: want, Missing: true})
+			continue
+		}
+		got, err := mgr.hashAs(f, want)
+		if err != nil {
+			return nil, fmt.Errorf("hash %s: %w", f, err)
+		}
+		if got != want {
+			out = append(out, HashMismatch{Version: v, File: filepath.Base(f), Expected: want, Actual: got})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
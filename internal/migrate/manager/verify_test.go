@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyFlagsEditedAndMissingCommittedFiles(t *testing.T) {
+	mgr := newTestManager(t, threeMigrations)
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.CommitThrough(2); err != nil {
+		t.Fatalf("CommitThrough: %v", err)
+	}
+	if got, err := mgr.Verify(); err != nil || len(got) != 0 {
+		t.Fatalf("Verify on untouched files = %v, %v; want no mismatches", got, err)
+	}
+
+	writeFiles(t, mgr.migrationsDir, map[string]string{
+		"000001_a.up.sql": "CREATE TABLE a (id INTEGER, name TEXT);",
+		// version 3 is not committed, so editing it is not reported
+		"000003_c.up.sql": "CREATE TABLE c (id BIGINT);",
+	})
+	if err := os.Remove(filepath.Join(mgr.migrationsDir, "000002_b.up.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := mgr.Verify()
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("mismatches = %+v, want 2", got)
+	}
+	if m := got[0]; m.Version != 1 || m.File != "000001_a.up.sql" || m.Missing || m.Expected == "" || m.Actual == "" || m.Expected == m.Actual {
+		t.Fatalf("mismatch for version 1 = %+v", m)
+	}
+	if m := got[1]; m.Version != 2 || !m.Missing || m.File != "" {
+		t.Fatalf("mismatch for version 2 = %+v, want missing", m)
+	}
+}
+
+func TestNormalizedHashIgnoresReformatting(t *testing.T) {
+	files := map[string]string{"000001_a.up.sql": "CREATE TABLE a (id INTEGER);"}
+	reformatted := map[string]string{"000001_a.up.sql": "-- table a\ncreate table a (\n    id integer\n);\n"}
+
+	mgr := newTestManager(t, files, WithNormalizedHash(true))
+	if err := mgr.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := mgr.CommitThrough(1); err != nil {
+		t.Fatalf("CommitThrough: %v", err)
+	}
+	writeFiles(t, mgr.migrationsDir, reformatted)
+	if got, err := mgr.Verify(); err != nil || len(got) != 0 {
+		t.Fatalf("Verify after reformatting = %v, %v; want no mismatches", got, err)
+	}
+	writeFiles(t, mgr.migrationsDir, map[string]string{"000001_a.up.sql": "CREATE TABLE a (id BIGINT);"})
+	if got, err := mgr.Verify(); err != nil || len(got) != 1 {
+		t.Fatalf("Verify after editing = %v, %v; want one mismatch", got, err)
+	}
+
+	exact := newTestManager(t, files)
+	if err := exact.Up(); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := exact.CommitThrough(1); err != nil {
+		t.Fatalf("CommitThrough: %v", err)
+	}
+	writeFiles(t, exact.migrationsDir, reformatted)
+	if got, err := exact.Verify(); err != nil || len(got) != 1 {
+		t.Fatalf("byte-exact Verify after reformatting = %v, %v; want one mismatch", got, err)
+	}
+}
@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// waitForDBInterval is the pause between pings while waiting for the database.
+var waitForDBInterval = time.Second
+
+// WaitForDB pings the database behind dsn until it answers or timeout
+// elapses. It is meant for startup, before NewManager, when the database may
+// still be booting; failures of later operations are handled by retries.
+func WaitForDB(backend DBBackend, dsn string, timeout time.Duration, logger *logrus.Entry) error {
+	db, err := sql.Open(backend.DriverName(), dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return waitFor(db.PingContext, timeout, waitForDBInterval, logger)
+}
+
+// waitFor calls ping every interval until it succeeds or timeout elapses.
+// Every ping gets a context ending with timeout, so a connection attempt that
+// hangs cannot outlast it.
+func waitFor(ping func(context.Context) error, timeout, interval time.Duration, logger *logrus.Entry) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx)
+		if err == nil {
+			if attempt > 1 {
+				logger.WithField("waited", time.Since(start).Round(time.Millisecond)).Info("database is ready")
+			}
+			return nil
+		}
+		if !time.Now().Add(interval).Before(deadline) {
+			return fmt.Errorf("database not ready after %s: %w", timeout, err)
+		}
+		logger.WithError(err).WithFields(logrus.Fields{
+			"attempt": attempt,
+			"waited":  time.Since(start).Round(time.Millisecond),
+			"timeout": timeout,
+		}).Info("waiting for database")
+		time.Sleep(interval)
+	}
+}
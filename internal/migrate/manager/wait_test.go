@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestWaitForDelayedDatabase(t *testing.T) {
+	log, _ := test.NewNullLogger()
+	calls := 0
+	ping := func(context.Context) error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	if err := waitFor(ping, time.Second, time.Millisecond, logrus.NewEntry(log)); err != nil {
+		t.Fatalf("waitFor: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("pinged %d times, want 3", calls)
+	}
+}
+
+func TestWaitForTimesOut(t *testing.T) {
+	log, _ := test.NewNullLogger()
+	ping := func(context.Context) error { return errors.New("connection refused") }
+	err := waitFor(ping, 20*time.Millisecond, 5*time.Millisecond, logrus.NewEntry(log))
+	if err == nil || !strings.Contains(err.Error(), "database not ready after 20ms") {
+		t.Fatalf("err = %v, want not-ready error", err)
+	}
+}
+
+func TestWaitForBoundsHungPing(t *testing.T) {
+	log, _ := test.NewNullLogger()
+	ping := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	start := time.Now()
+	err := waitFor(ping, 20*time.Millisecond, 5*time.Millisecond, logrus.NewEntry(log))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want the deadline exceeded", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Fatalf("waited %s on a hung ping, want about 20ms", waited)
+	}
+}
@@ -0,0 +1,108 @@
+package migration
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+)
+
+// reIdent captures a possibly schema-qualified, possibly quoted identifier.
+const reIdent = `((?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*)(?:\.(?:"(?:[^"]|"")+"|[A-Za-z_][\w$]*))?)`
+
+var (
+	// reCreateObject captures the kind and name of a created object and, for
+	// indexes, the table it is built on.
+	reCreateObject = regexp.MustCompile(`(?is)\bCREATE\s+(?:OR\s+REPLACE\s+)?(?:UNIQUE\s+)?(?:MATERIALIZED\s+)?` +
+		`(TABLE|INDEX|VIEW|SEQUENCE|TYPE|FUNCTION)\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + reIdent +
+		`(?:[^;]*?\bON(?:\s+ONLY)?\s+` + reIdent + `)?`)
+	// reAddColumnTo captures the table and column of ALTER TABLE ... ADD COLUMN.
+	reAddColumnTo = regexp.MustCompile(`(?is)\bALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + reIdent +
+		`\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?` + reIdent)
+	reDropTableOf = regexp.MustCompile(`(?is)\bDROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?([^;]+)`)
+)
+
+// notColumns are words following ADD that introduce something other than a
+// column.
+var notColumns = map[string]bool{
+	"constraint": true, "primary": true, "unique": true, "foreign": true, "check": true, "exclude": true,
+}
+
+// CreatedObject is a schema object created by an up migration.
+type CreatedObject struct {
+	Kind string // table, index, view, sequence, type, function or column
+	Name string
+	// Table is the table an index or column belongs to.
+	Table string
+}
+
+func (o CreatedObject) String() string {
+	if o.Kind == "column" {
+		return fmt.Sprintf("column %s.%s", o.Table, o.Name)
+	}
+	return o.Kind + " " + o.Name
+}
+
+// CreatedObjects returns the objects an up migration creates, in order.
+// Names are lower-cased and unquoted.
+func CreatedObjects(up []byte) []CreatedObject {
+	body := validate.StripComments(string(up))
+	var out []CreatedObject
+	for _, m := range reCreateObject.FindAllStringSubmatch(body, -1) {
+		o := CreatedObject{Kind: strings.ToLower(m[1]), Name: identName(m[2])}
+		if o.Kind == "index" {
+			if o.Name == "on" {
+				continue // unnamed index; nothing to drop by name
+			}
+			o.Table = identName(m[3])
+		}
+		out = append(out, o)
+	}
+	for _, m := range reAddColumnTo.FindAllStringSubmatch(body, -1) {
+		col := identName(m[2])
+		if notColumns[col] {
+			continue
+		}
+		out = append(out, CreatedObject{Kind: "column", Name: col, Table: identName(m[1])})
+	}
+	return out
+}
+
+// CheckRollback compares a down migration against the objects its up
+// migration creates and returns a warning for each object the down never
+// mentions. Indexes and columns also count as reverted when the down drops
+// their table.
+func CheckRollback(up, down []byte) []string {
+	body := strings.ToLower(validate.StripComments(string(down)))
+	dropped := map[string]bool{}
+	for _, m := range reDropTableOf.FindAllStringSubmatch(body, -1) {
+		for _, t := range strings.Split(m[1], ",") {
+			if f := strings.Fields(t); len(f) > 0 {
+				dropped[identName(f[0])] = true
+			}
+		}
+	}
+	var warnings []string
+	for _, o := range CreatedObjects(up) {
+		if mentions(body, o.Name) || (o.Table != "" && dropped[o.Table]) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("down file does not revert %s created by the up file", o))
+	}
+	return warnings
+}
+
+// identName unquotes and lower-cases an identifier, dropping any schema.
+func identName(ident string) string {
+	ident = strings.ToLower(strings.ReplaceAll(ident, `"`, ""))
+	if i := strings.LastIndex(ident, "."); i >= 0 {
+		ident = ident[i+1:]
+	}
+	return ident
+}
+
+// mentions reports whether name appears as a whole word in body.
+func mentions(body, name string) bool {
+	return regexp.MustCompile(`(?:^|[^\w$])` + regexp.QuoteMeta(name) + `(?:[^\w$]|$)`).MatchString(body)
+}
@@ -0,0 +1,43 @@
+package migration_test
+
+import (
+	"strings"
+	"testing"
+
+	migration "github.com/lenhattri/kaeshi-migrate/internal/migrate"
+)
+
+func TestCreatedObjects(t *testing.T) {
+	up := []byte(`CREATE TABLE IF NOT EXISTS public."Users" (id int);
+CREATE UNIQUE INDEX CONCURRENTLY idx_users_email ON users (email);
+ALTER TABLE users ADD COLUMN age int;
+ALTER TABLE users ADD CONSTRAINT users_age_check CHECK (age > 0);
+CREATE OR REPLACE VIEW active_users AS SELECT * FROM users;`)
+	var got []string
+	for _, o := range migration.CreatedObjects(up) {
+		got = append(got, o.String())
+	}
+	want := "table users,index idx_users_email,view active_users,column users.age"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("objects = %v, want %s", got, want)
+	}
+}
+
+func TestCheckRollbackMismatchedPair(t *testing.T) {
+	up := []byte(`CREATE TABLE orders (id int);
+CREATE INDEX idx_orders_id ON orders (id);
+ALTER TABLE customers ADD COLUMN tier text;`)
+
+	warnings := migration.CheckRollback(up, []byte("DROP TABLE orders;"))
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "column customers.tier") {
+		t.Fatalf("warnings = %v, want only the unreverted column", warnings)
+	}
+
+	if w := migration.CheckRollback(up, []byte("ALTER TABLE customers DROP COLUMN tier;\nDROP TABLE IF EXISTS orders;")); len(w) != 0 {
+		t.Fatalf("complete down reported %v", w)
+	}
+	// ordersx must not count as a mention of orders
+	if w := migration.CheckRollback([]byte("CREATE TABLE orders (id int);"), []byte("DROP TABLE ordersx;")); len(w) != 1 {
+		t.Fatalf("warnings = %v, want table orders reported", w)
+	}
+}
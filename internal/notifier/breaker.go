@@ -0,0 +1,87 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the endpoint while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("notifications degraded: circuit open after repeated delivery failures")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker wraps a Notifier so that an endpoint which is down does not
+// slow every migration. After Threshold consecutive failures it opens and
+// fails fast for Cooldown; the next event is then sent as a probe, which
+// closes the circuit on success and reopens it on failure.
+type CircuitBreaker struct {
+	Next      Notifier
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	now      func() time.Time // swapped out in tests
+}
+
+// NewCircuitBreaker wraps next with a breaker opening after threshold
+// consecutive failures for cooldown.
+func NewCircuitBreaker(next Notifier, threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{Next: next, Threshold: threshold, Cooldown: cooldown, state: CircuitClosed, now: time.Now}
+}
+
+// State returns the current state, one of CircuitClosed, CircuitOpen or
+// CircuitHalfOpen.
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.currentState()
+}
+
+func (b *CircuitBreaker) currentState() string {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.Cooldown {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) Notify(ctx context.Context, event MigrationEvent) error {
+	b.mu.Lock()
+	state := b.currentState()
+	switch state {
+	case CircuitOpen:
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		// Let this event through as the only probe; others fail fast
+		// until it resolves.
+		b.state, b.openedAt = CircuitOpen, b.now()
+	}
+	b.mu.Unlock()
+
+	err := b.Next.Notify(ctx, event)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.state, b.failures = CircuitClosed, 0
+		return nil
+	}
+	b.failures++
+	if state == CircuitHalfOpen || b.failures >= b.Threshold {
+		b.state, b.openedAt = CircuitOpen, b.now()
+		return fmt.Errorf("%w; circuit opened for %s", err, b.Cooldown)
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyNotifier fails while down is set and counts delivery attempts.
+type flakyNotifier struct {
+	down  bool
+	calls int
+}
+
+func (n *flakyNotifier) Notify(context.Context, MigrationEvent) error {
+	n.calls++
+	if n.down {
+		return errors.New("endpoint unreachable")
+	}
+	return nil
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	next := &flakyNotifier{down: true}
+	clock := time.Unix(0, 0)
+	b := NewCircuitBreaker(next, 3, time.Minute)
+	b.now = func() time.Time { return clock }
+
+	for i := 0; i < 3; i++ {
+		if err := b.Notify(context.Background(), MigrationEvent{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("failure %d: err = %v, want delivery error", i+1, err)
+		}
+	}
+	if b.State() != CircuitOpen {
+		t.Fatalf("state = %s after 3 failures, want open", b.State())
+	}
+
+	// Open: fail fast without contacting the endpoint.
+	if err := b.Notify(context.Background(), MigrationEvent{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, want ErrCircuitOpen", err)
+	}
+	if next.calls != 3 {
+		t.Fatalf("calls = %d, open circuit must not deliver", next.calls)
+	}
+
+	// Half-open after the cooldown; a failed probe reopens the circuit.
+	clock = clock.Add(time.Minute)
+	if b.State() != CircuitHalfOpen {
+		t.Fatalf("state = %s after cooldown, want half-open", b.State())
+	}
+	if err := b.Notify(context.Background(), MigrationEvent{}); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("probe err = %v, want delivery error", err)
+	}
+	if b.State() != CircuitOpen || next.calls != 4 {
+		t.Fatalf("state = %s calls = %d, want reopened after one probe", b.State(), next.calls)
+	}
+
+	// A successful probe closes it again.
+	clock = clock.Add(time.Minute)
+	next.down = false
+	if err := b.Notify(context.Background(), MigrationEvent{}); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("state = %s after successful probe, want closed", b.State())
+	}
+}
+
+func TestCircuitBreakerResetsOnSuccess(t *testing.T) {
+	next := &flakyNotifier{}
+	b := NewCircuitBreaker(next, 2, time.Minute)
+	for _, down := range []bool{true, false, true} {
+		next.down = down
+		_ = b.Notify(context.Background(), MigrationEvent{})
+	}
+	if b.State() != CircuitClosed {
+		t.Fatalf("state = %s, failures are not consecutive", b.State())
+	}
+}
@@ -1,28 +1,34 @@
 package notifier
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
 )
 
 // DiscordNotifier posts events to a Discord webhook URL.
 type DiscordNotifier struct {
 	WebhookURL string
+	Messages   *messages.Templates
+	Retry      RetryPolicy
+	// Client sends the webhook; nil uses a client with DefaultTimeout.
+	Client *http.Client
 }
 
-func (n *DiscordNotifier) Notify(event MigrationEvent) error {
+func (n *DiscordNotifier) Notify(ctx context.Context, event MigrationEvent) error {
 	if n.WebhookURL == "" {
 		return nil
 	}
-	msg := formatMessage(event)
+	msg := formatMessage(n.Messages, event)
 	payload := map[string]string{"content": msg}
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
 	}
-	resp, err := http.Post(n.WebhookURL, "application/json", bytes.NewReader(body))
+	resp, err := postJSON(ctx, n.Client, n.Retry, n.WebhookURL, body)
 	if err != nil {
 		return err
 	}
@@ -1,6 +1,12 @@
 package notifier
 
-import "strings"
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
+)
 
 // Config defines notifier settings.
 type Config struct {
@@ -16,43 +22,71 @@ type Config struct {
 		URL     string            `mapstructure:"url" yaml:"url"`
 		Headers map[string]string `mapstructure:"headers" yaml:"headers"`
 	} `mapstructure:"webhook" yaml:"webhook"`
+	// Timeout bounds each HTTP exchange with the endpoint; zero means
+	// DefaultTimeout.
+	Timeout time.Duration `mapstructure:"timeout" yaml:"timeout"`
+	// Retry redelivers notifications rejected with 429 or 5xx.
+	Retry RetryPolicy `mapstructure:"retry" yaml:"retry"`
+	// CircuitBreaker fails fast for Cooldown after Failures consecutive
+	// delivery failures; zero Failures disables it.
+	CircuitBreaker struct {
+		Failures int           `mapstructure:"failures" yaml:"failures"`
+		Cooldown time.Duration `mapstructure:"cooldown" yaml:"cooldown"`
+	} `mapstructure:"circuit_breaker" yaml:"circuit_breaker"`
+	// Messages renders chat notifications; nil uses the default wording.
+	Messages *messages.Templates `mapstructure:"-" yaml:"-"`
 }
 
-// NewNotifier returns a Notifier implementation based on configuration.
+// NewNotifier returns a Notifier implementation based on configuration,
+// behind a CircuitBreaker when one is configured.
 func NewNotifier(cfg Config) Notifier {
+	n := newNotifier(cfg)
+	if cb := cfg.CircuitBreaker; cb.Failures > 0 {
+		if _, noop := n.(*NoopNotifier); !noop {
+			return NewCircuitBreaker(n, cb.Failures, cb.Cooldown)
+		}
+	}
+	return n
+}
+
+func newNotifier(cfg Config) Notifier {
 	if !cfg.Enabled {
 		return &NoopNotifier{}
 	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	client := &http.Client{Timeout: timeout}
 	switch strings.ToLower(cfg.Type) {
 	case "discord":
 		if cfg.Discord.WebhookURL != "" {
-			return &DiscordNotifier{WebhookURL: cfg.Discord.WebhookURL}
+			return &DiscordNotifier{WebhookURL: cfg.Discord.WebhookURL, Messages: cfg.Messages, Retry: cfg.Retry, Client: client}
 		}
 	case "slack":
 		if cfg.Slack.WebhookURL != "" {
-			return &SlackNotifier{WebhookURL: cfg.Slack.WebhookURL}
+			return &SlackNotifier{WebhookURL: cfg.Slack.WebhookURL, Messages: cfg.Messages, Retry: cfg.Retry, Client: client}
 		}
 	case "webhook":
 		if cfg.Webhook.URL != "" {
-			return &WebhookNotifier{URL: cfg.Webhook.URL, Headers: cfg.Webhook.Headers}
+			return &WebhookNotifier{URL: cfg.Webhook.URL, Headers: cfg.Webhook.Headers, Retry: cfg.Retry, Client: client}
 		}
 	}
 	return &NoopNotifier{}
 }
 
-func formatMessage(e MigrationEvent) string {
-	msg := e.Status + " migration"
-	if e.Version != "" {
-		msg += " version " + e.Version
-	}
-	if e.DB != "" {
-		msg += " on " + e.DB
-	}
-	if e.User != "" {
-		msg += " by " + e.User
+// formatMessage renders the notification template of msgs for e.
+func formatMessage(msgs *messages.Templates, e MigrationEvent) string {
+	r := messages.Result{
+		Status:   e.Status,
+		Version:  e.Version,
+		User:     e.User,
+		DB:       e.DB,
+		Tags:     e.Tags,
+		Duration: e.Duration,
 	}
 	if e.Error != nil {
-		msg += ": " + e.Error.Error()
+		r.Error = e.Error.Error()
 	}
-	return msg
+	return msgs.Render(messages.Notification, r)
 }
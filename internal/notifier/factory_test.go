@@ -0,0 +1,23 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
+)
+
+func TestFormatMessage(t *testing.T) {
+	e := MigrationEvent{Status: "fail", Version: "3", DB: "postgres", User: "alice", Tags: []string{"billing"}, Error: errors.New("boom")}
+	if got, want := formatMessage(nil, e), "fail migration version 3 on postgres by alice [billing]: boom"; got != want {
+		t.Fatalf("default = %q, want %q", got, want)
+	}
+
+	msgs, err := messages.New(map[string]string{messages.Notification: `{{if eq .Status "fail"}}🔥{{else}}🎉{{end}} {{.DB}} v{{.Version}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := formatMessage(msgs, e), "🔥 postgres v3"; got != want {
+		t.Fatalf("custom = %q, want %q", got, want)
+	}
+}
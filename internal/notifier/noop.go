@@ -1,6 +1,8 @@
 package notifier
 
+import "context"
+
 // NoopNotifier does nothing.
 type NoopNotifier struct{}
 
-func (n *NoopNotifier) Notify(event MigrationEvent) error { return nil }
+func (n *NoopNotifier) Notify(ctx context.Context, event MigrationEvent) error { return nil }
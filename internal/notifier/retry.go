@@ -0,0 +1,112 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRetryBackoff is the first pause of RetryPolicy when Backoff is zero.
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// maxRetryWait caps a single pause, including one asked for by Retry-After,
+// so a rate-limited endpoint cannot stall a migration run for long.
+const maxRetryWait = 30 * time.Second
+
+// DefaultTimeout bounds each notifier HTTP exchange when Config.Timeout is
+// zero.
+const DefaultTimeout = 10 * time.Second
+
+// defaultClient serves notifiers built without a Client.
+var defaultClient = &http.Client{Timeout: DefaultTimeout}
+
+// sleep pauses for d or until ctx is done; it is swapped out in tests.
+var sleep = func(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
+
+// clientOr returns c, or the default client with DefaultTimeout when c is nil.
+func clientOr(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return defaultClient
+}
+
+// RetryPolicy redelivers notifications the endpoint rejected with 429 or a
+// 5xx status. The zero value sends each notification once.
+type RetryPolicy struct {
+	// Retries is how many times a rejected notification is sent again.
+	Retries int `mapstructure:"retries" yaml:"retries"`
+	// Backoff is the pause before the first retry, doubled for each one
+	// after it; a Retry-After header takes precedence.
+	Backoff time.Duration `mapstructure:"backoff" yaml:"backoff"`
+}
+
+// doWithRetry sends the request built by newReq through client, building a
+// fresh one with ctx for every attempt, and retries it as p allows. The last
+// response is returned for the caller to check its status.
+func doWithRetry(ctx context.Context, client *http.Client, p RetryPolicy, newReq func(context.Context) (*http.Request, error)) (*http.Response, error) {
+	backoff := p.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := newReq(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := clientOr(client).Do(req)
+		if err != nil || attempt >= p.Retries || !retryable(resp.StatusCode) {
+			return resp, err
+		}
+		wait, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			wait = backoff << attempt
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		sleep(ctx, min(wait, maxRetryWait))
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(h); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// postJSON posts body to url as JSON through doWithRetry.
+func postJSON(ctx context.Context, client *http.Client, p RetryPolicy, url string, body []byte) (*http.Response, error) {
+	return doWithRetry(ctx, client, p, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	})
+}
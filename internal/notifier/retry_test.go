@@ -0,0 +1,110 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNotifyRetriesRateLimitsAndServerErrors(t *testing.T) {
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(_ context.Context, d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = orig }()
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	n := &SlackNotifier{WebhookURL: srv.URL, Retry: RetryPolicy{Retries: 3, Backoff: time.Second}}
+	if err := n.Notify(context.Background(), MigrationEvent{Status: "success"}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("calls = %d, want 3", calls)
+	}
+	// Retry-After first, then the doubled backoff of the second attempt.
+	if len(waits) != 2 || waits[0] != 2*time.Second || waits[1] != 2*time.Second {
+		t.Fatalf("waits = %v, want [2s 2s]", waits)
+	}
+
+	calls, waits = 0, nil
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer fail.Close()
+	w := &WebhookNotifier{URL: fail.URL, Retry: RetryPolicy{Retries: 2}}
+	if err := w.Notify(context.Background(), MigrationEvent{Status: "fail"}); err == nil {
+		t.Fatal("Notify succeeded against a failing endpoint")
+	}
+	if calls != 3 || len(waits) != 2 || waits[0] != defaultRetryBackoff || waits[1] != 2*defaultRetryBackoff {
+		t.Fatalf("calls = %d waits = %v, want 3 calls with 500ms and 1s pauses", calls, waits)
+	}
+}
+
+func TestNotifyDoesNotRetryClientErrors(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+	n := &DiscordNotifier{WebhookURL: srv.URL, Retry: RetryPolicy{Retries: 3}}
+	if err := n.Notify(context.Background(), MigrationEvent{}); err == nil || calls != 1 {
+		t.Fatalf("err = %v after %d calls, want an error after one", err, calls)
+	}
+}
+
+func TestNotifyTimesOutHangingEndpoint(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	cfg := Config{Enabled: true, Type: "webhook", Timeout: 50 * time.Millisecond}
+	cfg.Webhook.URL = srv.URL
+	n := NewNotifier(cfg)
+	start := time.Now()
+	if err := n.Notify(context.Background(), MigrationEvent{Status: "success"}); err == nil {
+		t.Fatal("Notify succeeded against a hanging endpoint")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("Notify took %v, want it bounded by the 50ms timeout", elapsed)
+	}
+}
+
+func TestNotifyStopsRetryingWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		cancel()
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	n := &WebhookNotifier{URL: srv.URL, Retry: RetryPolicy{Retries: 3}}
+	start := time.Now()
+	if err := n.Notify(ctx, MigrationEvent{Status: "success"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context canceled", err)
+	}
+	if calls != 1 || time.Since(start) > 5*time.Second {
+		t.Fatalf("calls = %d after %v, want one call and no 30s pause", calls, time.Since(start))
+	}
+}
@@ -1,28 +1,64 @@
 package notifier
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/messages"
 )
 
-// SlackNotifier posts events to a Slack webhook URL.
+// SlackNotifier posts events to a Slack webhook URL as a color-coded
+// attachment of Block Kit sections, with the rendered message as the plain
+// text fallback for clients that do not render blocks.
 type SlackNotifier struct {
 	WebhookURL string
+	Messages   *messages.Templates
+	Retry      RetryPolicy
+	// Client sends the webhook; nil uses a client with DefaultTimeout.
+	Client *http.Client
+}
+
+// Attachment colors by event status.
+const (
+	slackGreen  = "#2eb67d"
+	slackRed    = "#e01e5a"
+	slackYellow = "#ecb22e"
+	slackGrey   = "#9e9e9e"
+)
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color    string       `json:"color"`
+	Fallback string       `json:"fallback"`
+	Blocks   []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type   string      `json:"type"`
+	Text   *slackText  `json:"text,omitempty"`
+	Fields []slackText `json:"fields,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
 }
 
-func (n *SlackNotifier) Notify(event MigrationEvent) error {
+func (n *SlackNotifier) Notify(ctx context.Context, event MigrationEvent) error {
 	if n.WebhookURL == "" {
 		return nil
 	}
-	msg := formatMessage(event)
-	payload := map[string]string{"text": msg}
-	body, err := json.Marshal(payload)
+	body, err := json.Marshal(slackMessage(formatMessage(n.Messages, event), event))
 	if err != nil {
 		return err
 	}
-	resp, err := http.Post(n.WebhookURL, "application/json", bytes.NewReader(body))
+	resp, err := postJSON(ctx, n.Client, n.Retry, n.WebhookURL, body)
 	if err != nil {
 		return err
 	}
@@ -32,3 +68,46 @@ func (n *SlackNotifier) Notify(event MigrationEvent) error {
 	}
 	return nil
 }
+
+// slackMessage builds the payload for event, rendered as msg.
+func slackMessage(msg string, event MigrationEvent) slackPayload {
+	var fields []slackText
+	for _, f := range []struct{ name, value string }{
+		{"Version", event.Version},
+		{"User", event.User},
+		{"Duration", durationText(event)},
+		{"DB", event.DB},
+	} {
+		if f.value != "" {
+			fields = append(fields, slackText{Type: "mrkdwn", Text: "*" + f.name + "*\n" + f.value})
+		}
+	}
+	blocks := []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: msg}}}
+	if len(fields) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Fields: fields})
+	}
+	return slackPayload{
+		Text:        msg,
+		Attachments: []slackAttachment{{Color: slackColor(event.Status), Fallback: msg, Blocks: blocks}},
+	}
+}
+
+// slackColor maps an event status to its attachment color.
+func slackColor(status string) string {
+	switch status {
+	case "success":
+		return slackGreen
+	case "fail":
+		return slackRed
+	case "rollback":
+		return slackYellow
+	}
+	return slackGrey
+}
+
+func durationText(event MigrationEvent) string {
+	if event.Duration <= 0 {
+		return ""
+	}
+	return event.Duration.String()
+}
@@ -0,0 +1,56 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSlackColorFollowsStatus(t *testing.T) {
+	for status, want := range map[string]string{
+		"success":    slackGreen,
+		"fail":       slackRed,
+		"rollback":   slackYellow,
+		"safe-force": slackGrey,
+	} {
+		if got := slackColor(status); got != want {
+			t.Errorf("slackColor(%q) = %s, want %s", status, got, want)
+		}
+	}
+}
+
+func TestSlackNotifySendsBlocksWithFallback(t *testing.T) {
+	var got slackPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	n := &SlackNotifier{WebhookURL: srv.URL}
+	e := MigrationEvent{Status: "fail", Version: "7", User: "alice", DB: "postgres", Duration: 1500 * time.Millisecond}
+	if err := n.Notify(context.Background(), e); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if want := formatMessage(nil, e); got.Text != want {
+		t.Fatalf("text = %q, want the plain-text message %q", got.Text, want)
+	}
+	if len(got.Attachments) != 1 {
+		t.Fatalf("attachments = %+v", got.Attachments)
+	}
+	a := got.Attachments[0]
+	if a.Color != slackRed || a.Fallback != got.Text || len(a.Blocks) != 2 {
+		t.Fatalf("attachment = %+v", a)
+	}
+	var names []string
+	for _, f := range a.Blocks[1].Fields {
+		names = append(names, f.Text)
+	}
+	if len(names) != 4 || names[0] != "*Version*\n7" || names[2] != "*Duration*\n1.5s" {
+		t.Fatalf("fields = %q", names)
+	}
+}
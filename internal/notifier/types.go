@@ -1,10 +1,14 @@
 package notifier
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-// Notifier interface for sending migration events.
+// Notifier interface for sending migration events. Sending, including the
+// pauses between retries, stops when ctx is done.
 type Notifier interface {
-	Notify(event MigrationEvent) error
+	Notify(ctx context.Context, event MigrationEvent) error
 }
 
 // MigrationEvent holds contextual data about a migration action.
@@ -16,4 +20,5 @@ type MigrationEvent struct {
 	Duration time.Duration
 	Error    error
 	Time     time.Time
+	Tags     []string // kaeshi:tags of the migrations involved
 }
@@ -2,6 +2,7 @@ package notifier
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -11,9 +12,12 @@ import (
 type WebhookNotifier struct {
 	URL     string
 	Headers map[string]string
+	Retry   RetryPolicy
+	// Client sends the event; nil uses a client with DefaultTimeout.
+	Client *http.Client
 }
 
-func (n *WebhookNotifier) Notify(event MigrationEvent) error {
+func (n *WebhookNotifier) Notify(ctx context.Context, event MigrationEvent) error {
 	if n.URL == "" {
 		return nil
 	}
@@ -21,17 +25,19 @@ func (n *WebhookNotifier) Notify(event MigrationEvent) error {
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	for k, v := range n.Headers {
-		req.Header.Set(k, v)
-	}
-	if req.Header.Get("Content-Type") == "" {
-		req.Header.Set("Content-Type", "application/json")
-	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := doWithRetry(ctx, n.Client, n.Retry, func(ctx context.Context) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", n.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range n.Headers {
+			req.Header.Set(k, v)
+		}
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	})
 	if err != nil {
 		return err
 	}
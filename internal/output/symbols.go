@@ -0,0 +1,76 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// ColorMode selects when ANSI styling and emoji markers are used. It
+// implements pflag.Value so it can back a --color flag directly.
+type ColorMode string
+
+const (
+	ColorAuto   ColorMode = "auto"   // style only when writing to a terminal
+	ColorAlways ColorMode = "always" // style even when piped
+	ColorNever  ColorMode = "never"  // ASCII only
+)
+
+func (m *ColorMode) String() string {
+	if *m == "" {
+		return string(ColorAuto)
+	}
+	return string(*m)
+}
+
+// Set parses always, auto or never.
+func (m *ColorMode) Set(s string) error {
+	switch mode := ColorMode(s); mode {
+	case ColorAuto, ColorAlways, ColorNever:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("invalid color mode %q: want always, auto or never", s)
+}
+
+func (m *ColorMode) Type() string { return "mode" }
+
+// Styled reports whether output written to w should use ANSI styling and
+// emoji. In auto mode that requires w to be a terminal and NO_COLOR to be
+// unset.
+func (m ColorMode) Styled(w io.Writer) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	st, err := f.Stat()
+	return err == nil && st.Mode()&os.ModeCharDevice != 0
+}
+
+// Symbols are the status markers printed in front of user-facing messages.
+// Warn carries its own padding because the emoji renders two columns wide.
+type Symbols struct {
+	OK, Fail, Warn string
+}
+
+var (
+	EmojiSymbols = Symbols{OK: "✅", Fail: "❌", Warn: "⚠️ "}
+	ASCIISymbols = Symbols{OK: "[OK]", Fail: "[FAIL]", Warn: "[WARN]"}
+)
+
+// SymbolsFor returns the emoji markers when styled and ASCII ones otherwise.
+func SymbolsFor(styled bool) Symbols {
+	if styled {
+		return EmojiSymbols
+	}
+	return ASCIISymbols
+}
@@ -0,0 +1,34 @@
+package output_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/output"
+)
+
+func TestColorModeStyled(t *testing.T) {
+	var buf bytes.Buffer
+	if output.ColorAuto.Styled(&buf) {
+		t.Fatal("auto must not style a non-terminal writer")
+	}
+	if !output.ColorAlways.Styled(&buf) || output.ColorNever.Styled(os.Stdout) {
+		t.Fatal("always/never must ignore the writer")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if output.ColorAuto.Styled(os.Stdout) {
+		t.Fatal("auto must honour NO_COLOR")
+	}
+
+	var m output.ColorMode
+	if err := m.Set("never"); err != nil || m != output.ColorNever {
+		t.Fatalf("Set(never) = %v, %q", err, m)
+	}
+	if err := m.Set("sometimes"); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+	if got := output.SymbolsFor(false); got != output.ASCIISymbols {
+		t.Fatalf("unstyled symbols = %+v", got)
+	}
+}
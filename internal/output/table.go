@@ -0,0 +1,74 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	ansiBold  = "\x1b[1m"
+	ansiReset = "\x1b[0m"
+)
+
+// Table renders rows as aligned columns. Styling is applied after alignment so
+// escape sequences never affect column widths.
+type Table struct {
+	out    io.Writer
+	buf    bytes.Buffer
+	tw     *tabwriter.Writer
+	plain  bool
+	header bool
+}
+
+// NewTable returns a Table writing to w. When plain is true no ANSI styling is
+// emitted, which keeps the output safe for scripts and log files.
+func NewTable(w io.Writer, plain bool) *Table {
+	t := &Table{out: w, plain: plain}
+	t.tw = tabwriter.NewWriter(&t.buf, 0, 0, 2, ' ', 0)
+	return t
+}
+
+// Header sets the column titles. It must be called before any Row.
+func (t *Table) Header(cols ...string) {
+	t.header = true
+	t.write(cols)
+}
+
+// Row appends a row; values are formatted with %v.
+func (t *Table) Row(cols ...any) {
+	cells := make([]string, len(cols))
+	for i, c := range cols {
+		cells[i] = fmt.Sprintf("%v", c)
+	}
+	t.write(cells)
+}
+
+func (t *Table) write(cells []string) {
+	fmt.Fprintln(t.tw, strings.Join(cells, "\t"))
+}
+
+// Flush aligns the buffered rows and writes them to the underlying writer.
+func (t *Table) Flush() error {
+	if err := t.tw.Flush(); err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(t.buf.String(), "\n")
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		if i == 0 && t.header && !t.plain {
+			line = ansiBold + strings.TrimRight(line, " \n") + ansiReset + "\n"
+		} else {
+			line = strings.TrimRight(line, " \n") + "\n"
+		}
+		if _, err := io.WriteString(t.out, line); err != nil {
+			return err
+		}
+	}
+	t.buf.Reset()
+	return nil
+}
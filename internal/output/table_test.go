@@ -0,0 +1,57 @@
+package output_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/output"
+)
+
+func TestTableAlignment(t *testing.T) {
+	var buf bytes.Buffer
+	tbl := output.NewTable(&buf, true)
+	tbl.Header("VERSION", "ACTION", "USER")
+	tbl.Row(1, "up", "alice")
+	tbl.Row(12, "rollback", "bob")
+	tbl.Row(123, "safe-force", "carol")
+	if err := tbl.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), buf.String())
+	}
+	actionCol := strings.Index(lines[0], "ACTION")
+	userCol := strings.Index(lines[0], "USER")
+	for _, l := range lines[1:] {
+		fields := strings.Fields(l)
+		if got := strings.Index(l, fields[1]); got != actionCol {
+			t.Fatalf("column ACTION misaligned in %q: %d != %d", l, got, actionCol)
+		}
+		if got := strings.LastIndex(l, fields[2]); got != userCol {
+			t.Fatalf("column USER misaligned in %q: %d != %d", l, got, userCol)
+		}
+	}
+	if strings.Contains(buf.String(), "\x1b[") {
+		t.Fatalf("plain table must not contain escape sequences")
+	}
+}
+
+func TestTableColorHeader(t *testing.T) {
+	var buf bytes.Buffer
+	tbl := output.NewTable(&buf, false)
+	tbl.Header("A", "B")
+	tbl.Row("x", "y")
+	if err := tbl.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if !strings.HasPrefix(lines[0], "\x1b[1m") {
+		t.Fatalf("expected bold header, got %q", lines[0])
+	}
+	if strings.Contains(lines[1], "\x1b[") {
+		t.Fatalf("rows must not be styled: %q", lines[1])
+	}
+}
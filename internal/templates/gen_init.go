@@ -0,0 +1,30 @@
+//go:build ignore
+
+// gen_init writes the repository's own init migration, in ../../migrations,
+// from the PostgreSQL rendering of the template kaeshi init uses, so the two
+// cannot drift. Run it with go generate ./internal/templates.
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/templates"
+)
+
+func main() {
+	up, err := templates.InitUp("postgres")
+	if err != nil {
+		log.Fatal(err)
+	}
+	dir := filepath.Join("..", "..", "migrations")
+	for name, content := range map[string]string{
+		"000001_init.up.sql":   up,
+		"000001_init.down.sql": templates.InitDown,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
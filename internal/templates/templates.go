@@ -1,12 +1,48 @@
 package templates
 
-import _ "embed"
+import (
+	_ "embed"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+//go:generate go run gen_init.go
 
 //go:embed default_config.yml
 var DefaultConfig string
 
-//go:embed migrations/000001_init.up.sql
-var InitUp string
+//go:embed migrations/000001_init.up.sql.tmpl
+var initUp string
 
 //go:embed migrations/000001_init.down.sql
 var InitDown string
+
+// initUpTemplate renders initUp with the column types of a database driver.
+var initUpTemplate = template.Must(template.New("000001_init.up.sql").Parse(initUp))
+
+// dialect holds the column definitions that differ between databases.
+type dialect struct {
+	ID  string // auto-incrementing primary key
+	Now string // timestamp defaulting to the insert time
+}
+
+var dialects = map[string]dialect{
+	"postgres": {ID: "SERIAL PRIMARY KEY", Now: "TIMESTAMPTZ NOT NULL DEFAULT NOW()"},
+	"mysql":    {ID: "BIGINT AUTO_INCREMENT PRIMARY KEY", Now: "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+	"sqlite":   {ID: "INTEGER PRIMARY KEY AUTOINCREMENT", Now: "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+}
+
+// InitUp returns the initial up migration for driver, "postgres", "mysql" or
+// "sqlite".
+func InitUp(driver string) (string, error) {
+	d, ok := dialects[driver]
+	if !ok {
+		return "", fmt.Errorf("no init migration for driver %q: use postgres, mysql or sqlite", driver)
+	}
+	var b strings.Builder
+	if err := initUpTemplate.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
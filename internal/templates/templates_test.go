@@ -0,0 +1,28 @@
+package templates_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/templates"
+)
+
+func TestRepoInitMigrationMatchesTemplate(t *testing.T) {
+	up, err := templates.InitUp("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"000001_init.up.sql":   up,
+		"000001_init.down.sql": templates.InitDown,
+	} {
+		got, err := os.ReadFile(filepath.Join("..", "..", "migrations", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("migrations/%s differs from the init template; run go generate ./internal/templates", name)
+		}
+	}
+}
@@ -15,7 +15,15 @@ CREATE TABLE IF NOT EXISTS migrations_history (
     executed_by VARCHAR(100) NOT NULL,
     committed BOOLEAN NOT NULL DEFAULT FALSE,
     sha256 TEXT NOT NULL,
-    
+    in_transaction BOOLEAN NOT NULL DEFAULT TRUE,
+    reason TEXT,
+    version_before BIGINT,
+    dirty_before BOOLEAN,
+    version_after BIGINT,
+    dirty_after BOOLEAN,
+    tags TEXT,
+    schema_fingerprint TEXT,
+    signed_by TEXT
 );
 
 
@@ -1,31 +1,53 @@
 package logger
 
 import (
+	"errors"
 	"io"
 	"os"
+	"sync"
 
 	"github.com/IBM/sarama"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/sirupsen/logrus"
+
+	"github.com/lenhattri/kaeshi-migrate/internal/output"
 )
 
-// New creates a JSON structured logger at the specified level.
+// mqFormatter formats entries shipped to Kafka and RabbitMQ, whose consumers
+// expect JSON whatever the console format is.
+var mqFormatter = &logrus.JSONFormatter{}
+
+// KafkaHook ships log entries to a Kafka topic. Sends are serialized with
+// Close, so a send in flight completes before the producer shuts down;
+// entries logged after Close are dropped.
 type KafkaHook struct {
+	mu       sync.Mutex
 	producer sarama.SyncProducer
 	topic    string
+	closed   bool
 }
 
+// RabbitMQHook ships log entries to a RabbitMQ queue, serialized with Close
+// like KafkaHook.
 type RabbitMQHook struct {
-	ch    *amqp.Channel
-	queue string
+	mu     sync.Mutex
+	conn   *amqp.Connection
+	ch     *amqp.Channel
+	queue  string
+	closed bool
 }
 
 // Fire writes the log entry to Kafka.
 func (h *KafkaHook) Fire(e *logrus.Entry) error {
-	b, err := e.Logger.Formatter.Format(e)
+	b, err := mqFormatter.Format(e)
 	if err != nil {
 		return err
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
 	msg := &sarama.ProducerMessage{Topic: h.topic, Value: sarama.ByteEncoder(b)}
 	_, _, err = h.producer.SendMessage(msg)
 	return err
@@ -33,11 +55,27 @@ func (h *KafkaHook) Fire(e *logrus.Entry) error {
 
 func (h *KafkaHook) Levels() []logrus.Level { return logrus.AllLevels }
 
+// Close waits for a send in flight and shuts the producer down.
+func (h *KafkaHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.producer.Close()
+}
+
 func (h *RabbitMQHook) Fire(e *logrus.Entry) error {
-	b, err := e.Logger.Formatter.Format(e)
+	b, err := mqFormatter.Format(e)
 	if err != nil {
 		return err
 	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
 	return h.ch.Publish("", h.queue, false, false, amqp.Publishing{
 		ContentType: "application/json",
 		Body:        b,
@@ -46,15 +84,89 @@ func (h *RabbitMQHook) Fire(e *logrus.Entry) error {
 
 func (h *RabbitMQHook) Levels() []logrus.Level { return logrus.AllLevels }
 
-// New creates a JSON structured logger at the specified level. Logs are sent
-// to Kafka only when env is "production" and brokers are configured.
-// New creates a JSON structured logger at the specified level.
-// MQ driver can be "kafka" or "rabbitmq". File logging is enabled when env is
-// not "production" and filePath is provided (or defaulted).
-func New(level, env, driver string, brokers []string, topic, rabbitURL, rabbitQueue, filePath string) *logrus.Logger {
+// Close waits for a publish in flight and closes the channel and connection.
+func (h *RabbitMQHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return errors.Join(h.ch.Close(), h.conn.Close())
+}
+
+// fileHook appends log entries to a file with its own formatter, so console
+// colors never end up in the file.
+type fileHook struct {
+	mu        sync.Mutex
+	w         io.WriteCloser
+	formatter logrus.Formatter
+	closed    bool
+}
+
+func (h *fileHook) Fire(e *logrus.Entry) error {
+	b, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	_, err = h.w.Write(b)
+	return err
+}
+
+func (h *fileHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (h *fileHook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	h.closed = true
+	return h.w.Close()
+}
+
+// Close flushes and closes every hook New attached to log: the Kafka
+// producer, the RabbitMQ channel and connection, and the log file. Entries
+// logged afterwards only reach stdout. It is safe to call more than once.
+func Close(log *logrus.Logger) error {
+	seen := map[logrus.Hook]bool{}
+	var errs []error
+	for _, hooks := range log.Hooks {
+		for _, h := range hooks {
+			c, ok := h.(io.Closer)
+			if !ok || seen[h] {
+				continue
+			}
+			seen[h] = true
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// formatter returns the formatter for format, "text" or JSON otherwise,
+// colouring text only when color is true.
+func formatter(format string, color bool) logrus.Formatter {
+	if format == "text" {
+		return &logrus.TextFormatter{FullTimestamp: true, ForceColors: color, DisableColors: !color}
+	}
+	return &logrus.JSONFormatter{}
+}
+
+// New creates a structured logger at the specified level writing to stdout
+// in format, "json" (the default) or "text", which is colored when stdout
+// is a terminal. Entries are also appended to filePath when it is not
+// empty, and shipped as JSON to the MQ driver ("kafka" or "rabbitmq") when
+// its brokers or URL are configured, in any env.
+func New(level, driver string, brokers []string, topic, rabbitURL, rabbitQueue, filePath, format string) *logrus.Logger {
 	log := logrus.New()
 	log.SetOutput(os.Stdout)
-	log.Formatter = &logrus.JSONFormatter{}
+	log.Formatter = formatter(format, output.ColorAuto.Styled(os.Stdout))
 
 	lvl, err := logrus.ParseLevel(level)
 	if err != nil {
@@ -62,21 +174,14 @@ func New(level, env, driver string, brokers []string, topic, rabbitURL, rabbitQu
 	}
 	log.SetLevel(lvl)
 
-	// Non-production: enable file logging only
-	if env != "production" {
-		if filePath == "" {
-			filePath = "app.log"
-		}
+	if filePath != "" {
 		f, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
 		if err == nil {
-			log.SetOutput(io.MultiWriter(os.Stdout, f))
+			log.AddHook(&fileHook{w: f, formatter: formatter(format, false)})
 		} else {
 			log.WithError(err).Warn("cannot open log file")
 		}
-		return log // 💥 Return early: skip MQ setup
 	}
-
-	// Only in production: setup message queue hooks
 	switch driver {
 	case "kafka":
 		if len(brokers) > 0 {
@@ -102,6 +207,7 @@ func New(level, env, driver string, brokers []string, topic, rabbitURL, rabbitQu
 			ch, err := conn.Channel()
 			if err != nil {
 				log.WithError(err).Warn("failed to open rabbitmq channel")
+				conn.Close()
 				break
 			}
 			if rabbitQueue == "" {
@@ -110,9 +216,10 @@ func New(level, env, driver string, brokers []string, topic, rabbitURL, rabbitQu
 			_, err = ch.QueueDeclare(rabbitQueue, true, false, false, false, nil)
 			if err != nil {
 				log.WithError(err).Warn("failed to declare rabbitmq queue")
+				conn.Close()
 				break
 			}
-			log.AddHook(&RabbitMQHook{ch: ch, queue: rabbitQueue})
+			log.AddHook(&RabbitMQHook{conn: conn, ch: ch, queue: rabbitQueue})
 		}
 	}
 
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama/mocks"
+)
+
+func TestNewWritesFileInFormat(t *testing.T) {
+	for format, want := range map[string]string{
+		"text": `level=info msg=hello`,
+		"json": `"msg":"hello"`,
+		"":     `"msg":"hello"`,
+	} {
+		path := filepath.Join(t.TempDir(), "app.log")
+		log := New("info", "kafka", nil, "", "", "", path, format)
+		log.SetOutput(io.Discard)
+		log.Info("hello")
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); !strings.Contains(got, want) || strings.Contains(got, "\x1b[") {
+			t.Fatalf("format %q: file = %q, want %s without colors", format, got, want)
+		}
+	}
+}
+
+func TestCloseFlushesKafkaAndClosesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	log := New("info", "", nil, "", "", "", path, "json")
+	log.SetOutput(io.Discard)
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageAndSucceed()
+	log.AddHook(&KafkaHook{producer: producer, topic: "logging"})
+
+	log.Info("before close")
+	for i := 0; i < 2; i++ {
+		if err := Close(log); err != nil {
+			t.Fatalf("Close #%d: %v", i+1, err)
+		}
+	}
+	log.Info("after close")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(data); !strings.Contains(got, "before close") || strings.Contains(got, "after close") {
+		t.Fatalf("file = %q, want only the entry logged before Close", got)
+	}
+}
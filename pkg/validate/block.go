@@ -2,8 +2,8 @@ package validate
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
-	"time"
 
 	"github.com/lenhattri/kaeshi-migrate/pkg/validate/confirm"
 )
@@ -11,41 +11,80 @@ import (
 // validateBlock executes all statements in a block within a transaction and
 // rolls back after validation.
 func validateBlock(db *sql.DB, block []string, opts ValidateOptions, d Dialect) error {
-	tx, err := db.Begin()
+	tx, err := beginTx(db, block, opts, d)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
 	for _, stmt := range block {
-		trimmed := strings.TrimSpace(stmt)
-		typ := d.StatementType(trimmed)
-
-		if !d.IsCheckable(trimmed) {
-			if opts.SkipOnConfirmation {
-				if err := confirm.FallbackConfirm(opts.ConfirmFn, trimmed, "statement not automatically checkable"); err != nil {
-					return &ValidationError{Statement: trimmed, Reason: "confirmation failed", Err: err, Type: typ}
-				}
-				continue
-			}
-			return &ValidationError{Statement: trimmed, Reason: "statement not automatically checkable", Err: ErrConfirmRequired, Type: typ}
+		if err := validateStmt(tx, stmt, opts, d); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// validateStmt checks a single statement inside tx, asking for confirmation
+// when the dialect cannot check it automatically. A leading kaeshi:timeout
+// directive replaces opts.Timeout for this statement.
+func validateStmt(tx *sql.Tx, stmt string, opts ValidateOptions, d Dialect) error {
+	stmt, timeout, err := statementTimeout(stmt)
+	trimmed := strings.TrimSpace(stmt)
+	typ := d.StatementType(trimmed)
+	if err != nil {
+		return &ValidationError{Statement: trimmed, Reason: "invalid directive", Err: err, Type: typ}
+	}
+	if timeout == 0 {
+		timeout = opts.Timeout
+	}
+	if rule := deniedBy(trimmed, typ, opts.Deny); rule != "" && !opts.AllowDenied {
+		return &ValidationError{Statement: trimmed, Reason: fmt.Sprintf("denied by policy (%s)", rule), Type: typ}
+	}
 
-		if !d.IsSafeInTxn(trimmed) {
-			if opts.SkipOnConfirmation {
-				if err := confirm.FallbackConfirm(opts.ConfirmFn, trimmed, "cannot run in transaction"); err != nil {
-					return &ValidationError{Statement: trimmed, Reason: "confirmation failed", Err: err, Type: typ}
-				}
-				continue
+	if !d.IsCheckable(trimmed) {
+		if opts.SkipOnConfirmation {
+			if err := confirm.FallbackConfirm(opts.ConfirmFn, trimmed, "statement not automatically checkable"); err != nil {
+				return &ValidationError{Statement: trimmed, Reason: "confirmation failed", Err: err, Type: typ}
 			}
-			return &ValidationError{Statement: trimmed, Reason: "cannot run in transaction", Err: nil, Type: typ}
+			return nil
 		}
+		return &ValidationError{Statement: trimmed, Reason: "statement not automatically checkable", Err: ErrConfirmRequired, Type: typ}
+	}
 
-		start := time.Now()
-		if err := d.ValidateStmt(tx, trimmed, opts.Timeout); err != nil {
-			return &ValidationError{Statement: trimmed, Reason: "execution failed", Err: err, Type: typ}
+	if !d.IsSafeInTxn(trimmed) {
+		if opts.SkipOnConfirmation {
+			if err := confirm.FallbackConfirm(opts.ConfirmFn, trimmed, "cannot run in transaction"); err != nil {
+				return &ValidationError{Statement: trimmed, Reason: "confirmation failed", Err: err, Type: typ}
+			}
+			return nil
 		}
-		_ = start
+		return &ValidationError{Statement: trimmed, Reason: "cannot run in transaction", Err: nil, Type: typ}
+	}
+
+	if err := d.ValidateStmt(tx, trimmed, timeout); err != nil {
+		return &ValidationError{Statement: trimmed, Reason: "execution failed", Err: err, Type: typ}
 	}
 	return nil
 }
+
+// beginTx starts the validation transaction. Blocks made only of DML run in a
+// read-only transaction when the dialect supports it, so writes escaping the
+// rollback (sequences, dblink) are rejected. Blocks containing DDL fall back
+// to a normal transaction. An explicit opts.Isolation takes precedence over
+// the read-only mode so validation runs at the level the migration will.
+func beginTx(db *sql.DB, block []string, opts ValidateOptions, d Dialect) (*sql.Tx, error) {
+	if opts.Isolation != "" {
+		return BeginIsolated(db, d, opts.Isolation)
+	}
+	ro, ok := d.(ReadOnlyDialect)
+	if !ok || len(block) == 0 {
+		return db.Begin()
+	}
+	for _, stmt := range block {
+		if d.StatementType(strings.TrimSpace(stmt)) != "DML" {
+			return db.Begin()
+		}
+	}
+	return ro.BeginReadOnly(db)
+}
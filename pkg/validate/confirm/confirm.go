@@ -1,6 +1,9 @@
 package confirm
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ConfirmFunc is a user-provided callback for handling confirmations.
 type ConfirmFunc func(prompt string) (bool, error)
@@ -14,7 +17,7 @@ func FallbackConfirm(fn ConfirmFunc, stmt, reason string) error {
 	if fn == nil {
 		return fmt.Errorf("%w: %s", ErrConfirmRequired, reason)
 	}
-	ok, err := fn(fmt.Sprintf("%s\n%s", reason, stmt))
+	ok, err := fn(formatPrompt(reason, stmt))
 	if err != nil {
 		return err
 	}
@@ -23,3 +26,16 @@ func FallbackConfirm(fn ConfirmFunc, stmt, reason string) error {
 	}
 	return nil
 }
+
+// formatPrompt builds the prompt passed to a ConfirmFunc: the reason on the
+// first line, followed by the statement.
+func formatPrompt(reason, stmt string) string {
+	return reason + "\n" + stmt
+}
+
+// ParsePrompt splits a prompt built by FallbackConfirm back into the reason
+// and the statement, for ConfirmFuncs that decide programmatically.
+func ParsePrompt(prompt string) (reason, stmt string) {
+	reason, stmt, _ = strings.Cut(prompt, "\n")
+	return reason, stmt
+}
@@ -0,0 +1,69 @@
+package confirm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTPPolicy resolves confirmations by asking a policy service such as OPA
+// instead of prompting. It POSTs {"input": {"statement": ..., "reason": ...}}
+// and expects {"result": bool} (the OPA data API shape) or {"allow": bool}.
+// Any transport error or non-2xx response is treated as a denial.
+type HTTPPolicy struct {
+	URL     string
+	Headers map[string]string
+	Timeout time.Duration // defaults to 5s
+}
+
+type policyInput struct {
+	Statement string `json:"statement"`
+	Reason    string `json:"reason"`
+}
+
+type policyResponse struct {
+	Result *bool `json:"result"`
+	Allow  *bool `json:"allow"`
+}
+
+// Confirm implements ConfirmFunc.
+func (p HTTPPolicy) Confirm(prompt string) (bool, error) {
+	reason, stmt := ParsePrompt(prompt)
+	body, err := json.Marshal(map[string]policyInput{"input": {Statement: stmt, Reason: reason}})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest("POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range p.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	resp, err := (&http.Client{Timeout: timeout}).Do(req)
+	if err != nil {
+		return false, fmt.Errorf("confirmation policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return false, fmt.Errorf("confirmation policy status %s", resp.Status)
+	}
+	var out policyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, fmt.Errorf("confirmation policy: decode response: %w", err)
+	}
+	switch {
+	case out.Result != nil:
+		return *out.Result, nil
+	case out.Allow != nil:
+		return *out.Allow, nil
+	}
+	return false, fmt.Errorf("confirmation policy: response has neither result nor allow")
+}
@@ -0,0 +1,56 @@
+package confirm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/confirm"
+)
+
+// stubPolicy denies destructive statements and allows everything else.
+func stubPolicy(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input struct {
+				Statement string `json:"statement"`
+				Reason    string `json:"reason"`
+			} `json:"input"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Input.Reason == "" {
+			http.Error(w, "bad input", http.StatusBadRequest)
+			return
+		}
+		stmt := strings.ToUpper(req.Input.Statement)
+		allow := !strings.HasPrefix(stmt, "DROP") && !strings.HasPrefix(stmt, "TRUNCATE")
+		_ = json.NewEncoder(w).Encode(map[string]bool{"result": allow})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPPolicy(t *testing.T) {
+	srv := stubPolicy(t)
+	fn := confirm.HTTPPolicy{URL: srv.URL}.Confirm
+
+	if err := confirm.FallbackConfirm(fn, "DROP TABLE users", "cannot run in transaction"); !errors.Is(err, confirm.ErrConfirmRequired) {
+		t.Fatalf("DROP: got %v, want ErrConfirmRequired", err)
+	}
+	if err := confirm.FallbackConfirm(fn, "CREATE INDEX CONCURRENTLY i ON t(a)", "cannot run in transaction"); err != nil {
+		t.Fatalf("CREATE INDEX: %v", err)
+	}
+}
+
+func TestHTTPPolicyUnavailableDenies(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	if err := confirm.FallbackConfirm(confirm.HTTPPolicy{URL: srv.URL}.Confirm, "SELECT 1", "r"); err == nil {
+		t.Fatal("expected unavailable policy to deny")
+	}
+}
@@ -0,0 +1,51 @@
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// deniedBy returns the entry of deny matched by stmt, whose dialect type is
+// typ, or "" when none matches. An entry matches the statement type or the
+// leading keywords of the statement, ignoring case, comments and spacing.
+func deniedBy(stmt, typ string, deny []string) string {
+	if len(deny) == 0 {
+		return ""
+	}
+	words := strings.Fields(strings.ToUpper(StripComments(stmt)))
+	for _, rule := range deny {
+		prefix := strings.Fields(strings.ToUpper(rule))
+		if len(prefix) == 0 {
+			continue
+		}
+		if len(prefix) == 1 && prefix[0] == strings.ToUpper(typ) {
+			return rule
+		}
+		if len(prefix) <= len(words) && slices.Equal(words[:len(prefix)], prefix) {
+			return rule
+		}
+	}
+	return ""
+}
+
+// CheckDenied applies only the deny policy of opts to every statement of
+// sqlText, without a database, for callers that skip ValidateSQL but must
+// still honor validation.deny_statements.
+func CheckDenied(sqlText string, opts ValidateOptions, d Dialect) error {
+	if len(opts.Deny) == 0 || opts.AllowDenied {
+		return nil
+	}
+	stmts, err := d.SplitStatements(sqlText)
+	if err != nil {
+		return fmt.Errorf("split statements: %w", err)
+	}
+	for _, stmt := range stmts {
+		trimmed := strings.TrimSpace(stmt)
+		typ := d.StatementType(trimmed)
+		if rule := deniedBy(trimmed, typ, opts.Deny); rule != "" {
+			return &ValidationError{Statement: trimmed, Reason: fmt.Sprintf("denied by policy (%s)", rule), Type: typ}
+		}
+	}
+	return nil
+}
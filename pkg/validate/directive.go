@@ -0,0 +1,37 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeoutDirective, in the comments leading a statement, overrides
+// ValidateOptions.Timeout for that statement, e.g. `-- kaeshi:timeout 120s`.
+const timeoutDirective = "-- kaeshi:timeout"
+
+// statementTimeout removes timeout directives from the comment lines leading
+// stmt and returns the rest of the statement with the requested timeout, or
+// zero when there is none.
+func statementTimeout(stmt string) (string, time.Duration, error) {
+	lines := strings.Split(stmt, "\n")
+	var timeout time.Duration
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line != "" && !strings.HasPrefix(line, "--") {
+			break
+		}
+		arg, ok := strings.CutPrefix(line, timeoutDirective)
+		if !ok {
+			continue
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(arg))
+		if err != nil || d <= 0 {
+			return stmt, 0, fmt.Errorf("invalid kaeshi:timeout %q: want a positive duration such as 120s", strings.TrimSpace(arg))
+		}
+		timeout = d
+		lines = append(lines[:i], lines[i+1:]...)
+		i--
+	}
+	return strings.Join(lines, "\n"), timeout, nil
+}
@@ -0,0 +1,45 @@
+package validate
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// IsolationDialect is implemented by dialects that can open a transaction at
+// an explicit isolation level. Levels use their lower-case SQL spelling, e.g.
+// "repeatable read".
+type IsolationDialect interface {
+	IsolationLevels() []string
+	BeginIsolated(db *sql.DB, level string) (*sql.Tx, error)
+}
+
+// NormalizeIsolation lower-cases level and accepts '-' or '_' between words,
+// so "REPEATABLE_READ" and "repeatable-read" both mean "repeatable read".
+func NormalizeIsolation(level string) string {
+	level = strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(level))
+	return strings.Join(strings.Fields(level), " ")
+}
+
+// CheckIsolation returns an error unless d can run a transaction at level.
+func CheckIsolation(d Dialect, level string) error {
+	iso, ok := d.(IsolationDialect)
+	if !ok {
+		return fmt.Errorf("%s does not support explicit isolation levels", d.DriverName())
+	}
+	levels := iso.IsolationLevels()
+	for _, l := range levels {
+		if l == NormalizeIsolation(level) {
+			return nil
+		}
+	}
+	return fmt.Errorf("isolation level %q is not supported by %s (supported: %s)", level, d.DriverName(), strings.Join(levels, ", "))
+}
+
+// BeginIsolated checks level against d and opens a transaction at it.
+func BeginIsolated(db *sql.DB, d Dialect, level string) (*sql.Tx, error) {
+	if err := CheckIsolation(d, level); err != nil {
+		return nil, err
+	}
+	return d.(IsolationDialect).BeginIsolated(db, NormalizeIsolation(level))
+}
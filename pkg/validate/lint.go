@@ -0,0 +1,74 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lint severities. Errors stop a migration from applying; warnings need a
+// look but may be intended.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintFinding is one problem reported by Lint.
+type LintFinding struct {
+	Severity string
+	Message  string
+}
+
+func (f LintFinding) String() string { return f.Severity + ": " + f.Message }
+
+// Lint checks sqlText under d without a database. Unterminated quotes,
+// dollar quotes and block comments, and everything ParseOffline reports, are
+// errors. Statements d cannot run inside a transaction are warnings:
+// validation asks to confirm them and they run without one.
+func Lint(sqlText string, d Dialect) []LintFinding {
+	var out []LintFinding
+	if err := unterminated(sqlText, LexerOf(d)); err != nil {
+		// Splitting would only report follow-on errors.
+		return []LintFinding{{Severity: LintError, Message: err.Error()}}
+	}
+	for _, err := range ParseOffline(sqlText, d) {
+		out = append(out, LintFinding{Severity: LintError, Message: err.Error()})
+	}
+	stmts, err := d.SplitStatements(sqlText)
+	if err != nil {
+		return out
+	}
+	for _, stmt := range stmts {
+		stmt = strings.TrimSpace(StripComments(stmt))
+		if stmt != "" && !d.IsSafeInTxn(stmt) {
+			out = append(out, LintFinding{Severity: LintWarning, Message: excerpt(stmt) + ": cannot run in a transaction"})
+		}
+	}
+	return out
+}
+
+// unterminated reports the first quoted string, dollar-quoted section or
+// block comment of sqlText that is never closed under the quoting of lx.
+func unterminated(sqlText string, lx Lexer) error {
+	var err error
+	lx.scan(sqlText, func(seg string, kind segmentKind) {
+		if err != nil {
+			return
+		}
+		switch {
+		case kind == segComment && strings.HasPrefix(seg, "/*"):
+			if len(seg) < 4 || !strings.HasSuffix(seg, "*/") {
+				err = fmt.Errorf("unterminated block comment: %s", excerpt(seg))
+			}
+		case kind == segQuoted && seg[0] == '$':
+			tag := dollarTag(seg, 0)
+			if len(seg) < 2*len(tag) || !strings.HasSuffix(seg, tag) {
+				err = fmt.Errorf("unterminated dollar-quoted string %s: %s", tag, excerpt(seg))
+			}
+		case kind == segQuoted:
+			if !lx.closed(seg) {
+				err = fmt.Errorf("unterminated quoted string: %s", excerpt(seg))
+			}
+		}
+	})
+	return err
+}
@@ -3,6 +3,7 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"regexp"
 	"strings"
 	"time"
 
@@ -16,7 +17,10 @@ type Dialect struct{}
 
 func (Dialect) DriverName() string { return "mysql" }
 
-func (Dialect) SplitStatements(input string) ([]string, error) { return validate.GenericSplit(input) }
+// Lexer reads MySQL's backslash escapes and backtick identifiers.
+func (Dialect) Lexer() validate.Lexer { return validate.Lexer{Backslash: true, Backticks: true} }
+
+func (d Dialect) SplitStatements(input string) ([]string, error) { return d.Lexer().Split(input) }
 
 func (Dialect) ParseBlocks(stmts []string) ([][]string, error) {
 	// MySQL does not support transactional DDL in the same way. Treat each statement as its own block.
@@ -75,3 +79,36 @@ func (Dialect) ValidateStmt(tx *sql.Tx, stmt string, timeout time.Duration) erro
 	_, err := tx.ExecContext(ctx, stmt)
 	return err
 }
+
+// syntaxRules catch PostgreSQL and SQLite constructs MySQL rejects.
+var syntaxRules = []validate.SyntaxRule{
+	{Pattern: regexp.MustCompile(`\$\w*\$`), Message: "dollar-quoted strings are PostgreSQL syntax"},
+	{Pattern: regexp.MustCompile(`::`), Message: "'::' casts are PostgreSQL syntax; use CAST(... AS ...)"},
+	{Pattern: regexp.MustCompile(`(?i)\bCONCURRENTLY\b`), Message: "CONCURRENTLY is PostgreSQL syntax"},
+	{Pattern: regexp.MustCompile(`(?i)\bILIKE\b`), Message: "ILIKE is PostgreSQL syntax; use LIKE or LOWER()"},
+	{Pattern: regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`), Message: "AUTOINCREMENT is SQLite syntax; use AUTO_INCREMENT"},
+}
+
+// CheckSyntax implements validate.SyntaxChecker.
+func (Dialect) CheckSyntax(stmt string) error { return validate.CheckRules(stmt, syntaxRules) }
+
+func init() { validate.RegisterDialect(Dialect{}) }
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"read uncommitted": sql.LevelReadUncommitted,
+	"read committed":   sql.LevelReadCommitted,
+	"repeatable read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+// IsolationLevels lists the levels InnoDB implements.
+func (Dialect) IsolationLevels() []string {
+	return []string{"read uncommitted", "read committed", "repeatable read", "serializable"}
+}
+
+// BeginIsolated opens a transaction at level. The driver issues the
+// SET TRANSACTION ISOLATION LEVEL before START TRANSACTION on the same
+// connection, as MySQL requires.
+func (Dialect) BeginIsolated(db *sql.DB, level string) (*sql.Tx, error) {
+	return db.BeginTx(context.Background(), &sql.TxOptions{Isolation: isolationLevels[level]})
+}
@@ -0,0 +1,68 @@
+package validate
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Normalize rewrites sqlText so that formatting-only edits leave it
+// unchanged: comments are dropped, statements are split by the dialect and
+// rejoined with one separator, whitespace runs collapse, whitespace next to
+// punctuation disappears and unquoted text is upper-cased. Quoted strings,
+// quoted identifiers and dollar-quoted bodies are kept byte for byte.
+func Normalize(sqlText string, d Dialect) (string, error) {
+	stmts, err := d.SplitStatements(StripComments(sqlText))
+	if err != nil {
+		return "", err
+	}
+	out := make([]string, 0, len(stmts))
+	for _, stmt := range stmts {
+		if n := normalizeStatement(stmt); n != "" {
+			out = append(out, n)
+		}
+	}
+	return strings.Join(out, ";\n"), nil
+}
+
+// normalizeStatement normalizes one statement without its terminator.
+func normalizeStatement(stmt string) string {
+	var out []byte
+	space := false
+	write := func(b byte) {
+		if space && len(out) > 0 && wordByte(out[len(out)-1]) && wordByte(b) {
+			out = append(out, ' ')
+		}
+		space = false
+		out = append(out, b)
+	}
+	scanSQL(stmt, func(seg string, kind segmentKind) {
+		switch kind {
+		case segComment:
+			space = true
+		case segQuoted:
+			write(seg[0])
+			out = append(out, seg[1:]...)
+		default:
+			for i := 0; i < len(seg); i++ {
+				c := seg[i]
+				if c < utf8.RuneSelf && unicode.IsSpace(rune(c)) {
+					space = true
+					continue
+				}
+				if 'a' <= c && c <= 'z' {
+					c -= 'a' - 'A'
+				}
+				write(c)
+			}
+		}
+	})
+	return strings.TrimSuffix(string(out), ";")
+}
+
+// wordByte reports whether b can be part of a keyword, identifier or number,
+// so that whitespace between two such bytes is significant.
+func wordByte(b byte) bool {
+	return b == '_' || b == '$' || b >= utf8.RuneSelf ||
+		'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
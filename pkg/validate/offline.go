@@ -0,0 +1,95 @@
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SyntaxChecker is implemented by dialects that can spot syntax the database
+// does not accept without a connection. The checks are heuristics for
+// constructs borrowed from other databases, not a full parser.
+type SyntaxChecker interface {
+	CheckSyntax(stmt string) error
+}
+
+var (
+	dialectsMu sync.Mutex
+	dialects   = map[string]Dialect{}
+)
+
+// RegisterDialect makes d available to Dialects under its driver name.
+// Dialect packages register themselves when imported.
+func RegisterDialect(d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[d.DriverName()] = d
+}
+
+// Dialects returns the registered dialects ordered by driver name.
+func Dialects() []Dialect {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	out := make([]Dialect, 0, len(dialects))
+	for _, d := range dialects {
+		out = append(out, d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DriverName() < out[j].DriverName() })
+	return out
+}
+
+// ParseOffline runs the parts of validation that need no database: statement
+// splitting, block grouping, statement directives and, when d implements
+// SyntaxChecker, its syntax checks on every statement. All problems found are
+// returned.
+func ParseOffline(sqlText string, d Dialect) []error {
+	stmts, err := d.SplitStatements(sqlText)
+	if err != nil {
+		return []error{fmt.Errorf("split statements: %w", err)}
+	}
+	var errs []error
+	if _, err := d.ParseBlocks(stmts); err != nil {
+		errs = append(errs, fmt.Errorf("parse blocks: %w", err))
+	}
+	sc, _ := d.(SyntaxChecker)
+	for _, stmt := range stmts {
+		stmt, _, err := statementTimeout(stmt)
+		if err == nil && sc != nil {
+			err = sc.CheckSyntax(stmt)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", excerpt(stmt), err))
+		}
+	}
+	return errs
+}
+
+// SyntaxRule rejects statements whose unquoted text matches Pattern.
+type SyntaxRule struct {
+	Pattern *regexp.Regexp
+	Message string
+}
+
+// CheckRules returns an error naming the first rule stmt breaks. Rules see
+// the statement as returned by Unquoted.
+func CheckRules(stmt string, rules []SyntaxRule) error {
+	code := Unquoted(stmt)
+	for _, r := range rules {
+		if r.Pattern.MatchString(code) {
+			return errors.New(r.Message)
+		}
+	}
+	return nil
+}
+
+// excerpt shortens stmt to its first line, capped for error messages.
+func excerpt(stmt string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(stmt), "\n")
+	if len(line) > 60 {
+		line = line[:57] + "..."
+	}
+	return line
+}
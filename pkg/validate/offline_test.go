@@ -0,0 +1,114 @@
+package validate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/mysql"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/postgres"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/sqlite"
+)
+
+func TestDialectsRegistered(t *testing.T) {
+	var names []string
+	for _, d := range validate.Dialects() {
+		names = append(names, d.DriverName())
+	}
+	if got := strings.Join(names, ","); got != "mysql,postgres,sqlite" {
+		t.Fatalf("registered dialects = %s", got)
+	}
+}
+
+func TestParseOfflineDivergentSQL(t *testing.T) {
+	cases := []struct {
+		name string
+		sql  string
+		fail map[string]string // dialect -> expected message fragment
+	}{
+		{
+			name: "portable",
+			sql:  "CREATE TABLE t (id INTEGER PRIMARY KEY, note TEXT DEFAULT 'a::b $$ `x`');",
+		},
+		{
+			name: "postgres function",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1 $$ LANGUAGE sql;\nSELECT '1'::int;",
+			fail: map[string]string{"mysql": "dollar-quoted", "sqlite": "dollar-quoted"},
+		},
+		{
+			name: "mysql table",
+			sql:  "CREATE TABLE `t` (id INT AUTO_INCREMENT PRIMARY KEY) ENGINE=InnoDB;",
+			fail: map[string]string{"postgres": "backtick", "sqlite": "AUTO_INCREMENT"},
+		},
+		{
+			name: "add constraint",
+			sql:  "ALTER TABLE t ADD CONSTRAINT t_uq UNIQUE (id);",
+			fail: map[string]string{"sqlite": "ALTER TABLE"},
+		},
+		{
+			name: "unterminated block",
+			sql:  "BEGIN;\nCREATE TABLE t (id int);",
+			fail: map[string]string{"postgres": "unterminated BEGIN"},
+		},
+	}
+	dialects := []validate.Dialect{mysql.Dialect{}, postgres.Dialect{}, sqlite.Dialect{}}
+	for _, c := range cases {
+		for _, d := range dialects {
+			errs := validate.ParseOffline(c.sql, d)
+			want, shouldFail := c.fail[d.DriverName()]
+			switch {
+			case !shouldFail && len(errs) > 0:
+				t.Errorf("%s/%s: unexpected problems %v", c.name, d.DriverName(), errs)
+			case shouldFail && (len(errs) == 0 || !strings.Contains(errs[0].Error(), want)):
+				t.Errorf("%s/%s: expected %q, got %v", c.name, d.DriverName(), want, errs)
+			}
+		}
+	}
+}
+
+func TestLintUsesDialectQuoting(t *testing.T) {
+	cases := []struct {
+		sql          string
+		d            validate.Dialect
+		unterminated bool
+	}{
+		{`INSERT INTO t VALUES ('it\'s');`, mysql.Dialect{}, false},
+		{"INSERT INTO `t;x` VALUES (\"a\\\"b\");", mysql.Dialect{}, false},
+		{`INSERT INTO t VALUES (E'it\'s');`, postgres.Dialect{}, false},
+		{`INSERT INTO t VALUES (e'a\\');`, postgres.Dialect{}, false},
+		// standard_conforming_strings: \ is a plain character outside E''.
+		{`INSERT INTO t VALUES ('it\'s');`, postgres.Dialect{}, true},
+		{`INSERT INTO t VALUES ('it\'s');`, sqlite.Dialect{}, true},
+	}
+	for _, c := range cases {
+		got := validate.Lint(c.sql, c.d)
+		hasErr := len(got) > 0 && strings.Contains(got[0].Message, "unterminated")
+		if hasErr != c.unterminated {
+			t.Fatalf("Lint(%q, %s) = %v, want unterminated %v", c.sql, c.d.DriverName(), got, c.unterminated)
+		}
+	}
+}
+
+func TestLintFindsUnterminatedAndNonTransactional(t *testing.T) {
+	cases := []struct {
+		sql  string
+		want []string // "severity: fragment"
+	}{
+		{"CREATE TABLE t (id INTEGER);\nCOMMENT ON TABLE t IS 'it''s';", nil},
+		{"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1;", []string{"error: unterminated dollar-quoted string $$"}},
+		{"INSERT INTO t VALUES ('a);", []string{"error: unterminated quoted string"}},
+		{"/* todo\nCREATE TABLE t (id INTEGER);", []string{"error: unterminated block comment"}},
+		{"CREATE INDEX CONCURRENTLY i ON t (id);", []string{"warning: CREATE INDEX CONCURRENTLY i ON t (id): cannot run in a transaction"}},
+	}
+	for _, c := range cases {
+		got := validate.Lint(c.sql, postgres.Dialect{})
+		if len(got) != len(c.want) {
+			t.Fatalf("Lint(%q) = %v, want %v", c.sql, got, c.want)
+		}
+		for i, w := range c.want {
+			if !strings.HasPrefix(got[i].String(), w) {
+				t.Fatalf("Lint(%q)[%d] = %q, want prefix %q", c.sql, i, got[i], w)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -17,7 +18,10 @@ type Dialect struct{}
 
 func (Dialect) DriverName() string { return "postgres" }
 
-func (Dialect) SplitStatements(input string) ([]string, error) { return validate.GenericSplit(input) }
+// Lexer reads backslash escapes in E'...' strings.
+func (Dialect) Lexer() validate.Lexer { return validate.Lexer{EStrings: true} }
+
+func (d Dialect) SplitStatements(input string) ([]string, error) { return d.Lexer().Split(input) }
 
 func (Dialect) ParseBlocks(stmts []string) ([][]string, error) {
 	var blocks [][]string
@@ -111,6 +115,39 @@ func (Dialect) IsSafeInTxn(stmt string) bool {
 	return true
 }
 
+// BeginReadOnly opens a transaction that rejects any write attempt.
+func (Dialect) BeginReadOnly(db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SET TRANSACTION READ ONLY"); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// IsolationLevels lists the levels PostgreSQL implements; READ UNCOMMITTED is
+// left out because it silently behaves as READ COMMITTED.
+func (Dialect) IsolationLevels() []string {
+	return []string{"read committed", "repeatable read", "serializable"}
+}
+
+// BeginIsolated opens a transaction at level with SET TRANSACTION, which only
+// affects that transaction and so is safe behind transaction poolers.
+func (Dialect) BeginIsolated(db *sql.DB, level string) (*sql.Tx, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SET TRANSACTION ISOLATION LEVEL " + strings.ToUpper(level)); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
 func (Dialect) ValidateStmt(tx *sql.Tx, stmt string, timeout time.Duration) error {
 	typ := Dialect{}.StatementType(stmt)
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -122,3 +159,15 @@ func (Dialect) ValidateStmt(tx *sql.Tx, stmt string, timeout time.Duration) erro
 	_, err := tx.ExecContext(ctx, stmt)
 	return err
 }
+
+// syntaxRules catch MySQL and SQLite constructs PostgreSQL rejects.
+var syntaxRules = []validate.SyntaxRule{
+	{Pattern: regexp.MustCompile("`"), Message: "backtick-quoted identifiers are MySQL syntax; use double quotes"},
+	{Pattern: regexp.MustCompile(`(?i)\bAUTO_?INCREMENT\b`), Message: "AUTO_INCREMENT is not PostgreSQL; use GENERATED ... AS IDENTITY or SERIAL"},
+	{Pattern: regexp.MustCompile(`(?i)\)\s*ENGINE\s*=`), Message: "ENGINE= table options are MySQL syntax"},
+}
+
+// CheckSyntax implements validate.SyntaxChecker.
+func (Dialect) CheckSyntax(stmt string) error { return validate.CheckRules(stmt, syntaxRules) }
+
+func init() { validate.RegisterDialect(Dialect{}) }
@@ -5,129 +5,232 @@ import (
 	"unicode"
 )
 
-// GenericSplit splits SQL text into individual statements respecting quoted
-// strings, comments and dollar-quoted sections. Dialects may override this
-// if needed.
-func GenericSplit(sqlStr string) ([]string, error) {
-	var stmts []string
-	var sb strings.Builder
-	var inSQuote, inDQuote bool
-	var inLineComment, inBlockComment bool
-	var dollarTag string
+// segmentKind classifies a piece of SQL text produced by scanSQL.
+type segmentKind int
 
-	flush := func() {
-		stmt := strings.TrimSpace(sb.String())
-		if stmt != "" {
-			stmts = append(stmts, stmt)
+const (
+	segCode segmentKind = iota
+	segComment
+	segQuoted
+	segTerminator
+)
+
+// Lexer describes how a dialect quotes text. The zero Lexer is the generic
+// one: '...' and "..." strings escaped by doubling the quote, and $tag$
+// sections.
+type Lexer struct {
+	// Backslash lets \ escape the next character in every quoted string, as
+	// MySQL does by default.
+	Backslash bool
+	// EStrings lets \ escape the next character in E'...' strings, as
+	// PostgreSQL does.
+	EStrings bool
+	// Backticks quotes identifiers with `, as MySQL does.
+	Backticks bool
+}
+
+// LexingDialect is implemented by dialects whose quoting differs from the
+// zero Lexer.
+type LexingDialect interface {
+	Lexer() Lexer
+}
+
+// LexerOf returns the Lexer of d, or the zero Lexer.
+func LexerOf(d Dialect) Lexer {
+	if ld, ok := d.(LexingDialect); ok {
+		return ld.Lexer()
+	}
+	return Lexer{}
+}
+
+// scanSQL walks sqlStr and reports consecutive segments of code, comments,
+// quoted or dollar-quoted text, and statement terminators. Concatenating all
+// segments reproduces the input exactly.
+func scanSQL(sqlStr string, emit func(seg string, kind segmentKind)) {
+	Lexer{}.scan(sqlStr, emit)
+}
+
+// scan is scanSQL under the quoting rules of lx. An E'...' string is one
+// segment starting with the E.
+func (lx Lexer) scan(sqlStr string, emit func(seg string, kind segmentKind)) {
+	n := len(sqlStr)
+	codeStart := 0
+	flushCode := func(end int) {
+		if end > codeStart {
+			emit(sqlStr[codeStart:end], segCode)
 		}
-		sb.Reset()
 	}
 
-	for i := 0; i < len(sqlStr); i++ {
+	for i := 0; i < n; {
 		c := sqlStr[i]
 		next := byte(0)
-		if i+1 < len(sqlStr) {
+		if i+1 < n {
 			next = sqlStr[i+1]
 		}
 
+		end := -1
+		kind := segCode
 		switch {
-		case inLineComment:
-			if c == '\n' {
-				inLineComment = false
+		case c == '-' && next == '-':
+			kind = segComment
+			end = strings.IndexByte(sqlStr[i:], '\n')
+			if end < 0 {
+				end = n
+			} else {
+				end += i
 			}
-			sb.WriteByte(c)
-			continue
-		case inBlockComment:
-			if c == '*' && next == '/' {
-				inBlockComment = false
-				sb.WriteByte(c)
-				sb.WriteByte(next)
-				i++
-				continue
-			}
-			sb.WriteByte(c)
-			continue
-		case inSQuote:
-			sb.WriteByte(c)
-			if c == '\'' {
-				if next == '\'' {
-					sb.WriteByte(next)
-					i++
-				} else {
-					inSQuote = false
-				}
+		case c == '/' && next == '*':
+			kind = segComment
+			end = strings.Index(sqlStr[i+2:], "*/")
+			if end < 0 {
+				end = n
+			} else {
+				end += i + 4
 			}
-			continue
-		case inDQuote:
-			sb.WriteByte(c)
-			if c == '"' {
-				if next == '"' {
-					sb.WriteByte(next)
-					i++
+		case lx.EStrings && (c == 'E' || c == 'e') && next == '\'' && (i == 0 || !identByte(sqlStr[i-1])):
+			kind = segQuoted
+			end, _ = quotedEnd(sqlStr, i+1, '\'', true)
+		case c == '\'' || c == '"' || (c == '`' && lx.Backticks):
+			kind = segQuoted
+			end, _ = quotedEnd(sqlStr, i, c, lx.Backslash && c != '`')
+		case c == '$':
+			if tag := dollarTag(sqlStr, i); tag != "" {
+				kind = segQuoted
+				end = strings.Index(sqlStr[i+len(tag):], tag)
+				if end < 0 {
+					end = n
 				} else {
-					inDQuote = false
+					end += i + 2*len(tag)
 				}
 			}
-			continue
-		case dollarTag != "":
-			sb.WriteByte(c)
-			if len(sqlStr[i:]) >= len(dollarTag) && strings.HasPrefix(sqlStr[i:], dollarTag) {
-				sb.WriteString(dollarTag)
-				i += len(dollarTag) - 1
-				dollarTag = ""
-			}
-			continue
+		case c == ';':
+			kind = segTerminator
+			end = i + 1
 		}
 
-		if c == '-' && next == '-' {
-			inLineComment = true
-			sb.WriteByte(c)
-			sb.WriteByte(next)
-			i++
-			continue
-		}
-		if c == '/' && next == '*' {
-			inBlockComment = true
-			sb.WriteByte(c)
-			sb.WriteByte(next)
+		if end < 0 {
 			i++
 			continue
 		}
+		flushCode(i)
+		emit(sqlStr[i:end], kind)
+		i = end
+		codeStart = end
+	}
+	flushCode(n)
+}
 
-		if c == '\'' {
-			inSQuote = true
-			sb.WriteByte(c)
+// quotedEnd returns the index just past the quote that closes the string
+// starting at i, treating doubled quotes and, with backslash, \-escaped
+// characters as escapes. An unclosed string ends at len(s), not closed.
+func quotedEnd(s string, i int, q byte, backslash bool) (end int, closed bool) {
+	for j := i + 1; j < len(s); j++ {
+		if backslash && s[j] == '\\' {
+			j++
 			continue
 		}
-		if c == '"' {
-			inDQuote = true
-			sb.WriteByte(c)
+		if s[j] != q {
 			continue
 		}
-		if c == '$' {
-			j := i + 1
-			for j < len(sqlStr) && sqlStr[j] != '$' {
-				if !(unicode.IsLetter(rune(sqlStr[j])) || unicode.IsDigit(rune(sqlStr[j])) || sqlStr[j] == '_') {
-					j = i
-					break
-				}
-				j++
-			}
-			if j > i && j < len(sqlStr) && sqlStr[j] == '$' {
-				dollarTag = sqlStr[i : j+1]
-				sb.WriteString(dollarTag)
-				i = j
-				continue
-			}
+		if j+1 < len(s) && s[j+1] == q {
+			j++
+			continue
 		}
+		return j + 1, true
+	}
+	return len(s), false
+}
 
-		if c == ';' {
-			flush()
-			continue
+// closed reports whether seg, a quoted segment from lx.scan, ends with its
+// closing quote.
+func (lx Lexer) closed(seg string) bool {
+	if seg[0] == 'E' || seg[0] == 'e' {
+		_, ok := quotedEnd(seg, 1, '\'', true)
+		return ok
+	}
+	_, ok := quotedEnd(seg, 0, seg[0], lx.Backslash && seg[0] != '`')
+	return ok
+}
+
+// identByte reports whether c can be part of an unquoted identifier, so that
+// the E of NONE'x' does not start an E'...' string.
+func identByte(c byte) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted section at i, if any.
+func dollarTag(s string, i int) string {
+	j := i + 1
+	for j < len(s) && s[j] != '$' {
+		if !(unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j])) || s[j] == '_') {
+			return ""
 		}
+		j++
+	}
+	if j < len(s) {
+		return s[i : j+1]
+	}
+	return ""
+}
+
+// GenericSplit splits SQL text into individual statements respecting quoted
+// strings, comments and dollar-quoted sections. Dialects may override this
+// if needed.
+func GenericSplit(sqlStr string) ([]string, error) { return Lexer{}.Split(sqlStr) }
+
+// Split is GenericSplit under the quoting rules of lx.
+func (lx Lexer) Split(sqlStr string) ([]string, error) {
+	var stmts []string
+	var sb strings.Builder
 
-		sb.WriteByte(c)
+	flush := func() {
+		stmt := strings.TrimSpace(sb.String())
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		sb.Reset()
 	}
+
+	lx.scan(sqlStr, func(seg string, kind segmentKind) {
+		if kind == segTerminator {
+			flush()
+			return
+		}
+		sb.WriteString(seg)
+	})
 	flush()
 	return stmts, nil
 }
+
+// StripComments removes line and block comments from sqlStr, leaving quoted
+// strings and dollar-quoted bodies untouched.
+func StripComments(sqlStr string) string {
+	var sb strings.Builder
+	scanSQL(sqlStr, func(seg string, kind segmentKind) {
+		if kind != segComment {
+			sb.WriteString(seg)
+		}
+	})
+	return sb.String()
+}
+
+// Unquoted removes comments from sqlStr and empties every quoted or
+// dollar-quoted section down to its opening and closing delimiters, so
+// keyword checks cannot match text inside literals.
+func Unquoted(sqlStr string) string {
+	var sb strings.Builder
+	scanSQL(sqlStr, func(seg string, kind segmentKind) {
+		switch kind {
+		case segComment:
+		case segQuoted:
+			open := seg[:1]
+			if seg[0] == '$' {
+				open = dollarTag(seg, 0)
+			}
+			sb.WriteString(open + open)
+		default:
+			sb.WriteString(seg)
+		}
+	})
+	return sb.String()
+}
@@ -0,0 +1,92 @@
+package validate_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/mysql"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/postgres"
+)
+
+func TestGenericSplit(t *testing.T) {
+	sqlText := `-- header; with semicolon
+CREATE TABLE a(id int); /* block; comment */
+INSERT INTO a VALUES (';');
+CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql;`
+	got, err := validate.GenericSplit(sqlText)
+	if err != nil {
+		t.Fatalf("split: %v", err)
+	}
+	want := []string{
+		"-- header; with semicolon\nCREATE TABLE a(id int)",
+		"/* block; comment */\nINSERT INTO a VALUES (';')",
+		"CREATE FUNCTION f() RETURNS int AS $body$ SELECT 1; $body$ LANGUAGE sql",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("split = %q\nwant %q", got, want)
+	}
+}
+
+func TestDialectSplitFollowsQuoting(t *testing.T) {
+	got, err := mysql.Dialect{}.SplitStatements(`INSERT INTO t VALUES ('a\';b');` + "\nSELECT 1 FROM `x;y`;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`INSERT INTO t VALUES ('a\';b')`, "SELECT 1 FROM `x;y`"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mysql split = %q\nwant %q", got, want)
+	}
+	got, err = postgres.Dialect{}.SplitStatements(`INSERT INTO t VALUES (E'a\';b'), ('c\');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{`INSERT INTO t VALUES (E'a\';b'), ('c\')`}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("postgres split = %q\nwant %q", got, want)
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	sqlText := "-- NOTE: postgres://admin:secret@db\nSELECT '--not a comment', 1 /* inline */;\n"
+	got := validate.StripComments(sqlText)
+	if strings.Contains(got, "secret") || strings.Contains(got, "inline") {
+		t.Fatalf("comments not stripped: %q", got)
+	}
+	if !strings.Contains(got, "'--not a comment'") {
+		t.Fatalf("quoted text must be preserved: %q", got)
+	}
+}
+
+func TestNormalizeIgnoresFormatting(t *testing.T) {
+	d := postgres.Dialect{}
+	original := `-- add users
+CREATE TABLE users (id int PRIMARY KEY, name text DEFAULT 'Ann  Lee');
+CREATE INDEX idx_users_name ON users(name);`
+	reformatted := `create table users(
+    id   INT primary key,  /* surrogate key */
+    name TEXT default 'Ann  Lee'
+)
+;
+
+create index idx_users_name
+    on users (name)`
+	a, err := validate.Normalize(original, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := validate.Normalize(reformatted, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b {
+		t.Fatalf("normalized forms differ:\n%s\n---\n%s", a, b)
+	}
+
+	changed, err := validate.Normalize(strings.Replace(original, "'Ann  Lee'", "'Ann Lee'", 1), d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == a {
+		t.Fatal("a change inside a string literal must change the normalized form")
+	}
+}
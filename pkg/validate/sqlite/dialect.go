@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"regexp"
 	"strings"
 	"time"
 
@@ -56,3 +57,28 @@ func (Dialect) ValidateStmt(tx *sql.Tx, stmt string, timeout time.Duration) erro
 	_, err := tx.ExecContext(ctx, stmt)
 	return err
 }
+
+// syntaxRules catch PostgreSQL and MySQL constructs SQLite rejects.
+var syntaxRules = []validate.SyntaxRule{
+	{Pattern: regexp.MustCompile(`\$\w*\$`), Message: "dollar-quoted strings are PostgreSQL syntax"},
+	{Pattern: regexp.MustCompile(`::`), Message: "'::' casts are PostgreSQL syntax; use CAST(... AS ...)"},
+	{Pattern: regexp.MustCompile(`(?i)\bCONCURRENTLY\b`), Message: "CONCURRENTLY is PostgreSQL syntax"},
+	{Pattern: regexp.MustCompile(`(?is)\bALTER\s+TABLE\b.*\b(ADD\s+CONSTRAINT|ALTER\s+COLUMN|MODIFY)\b`), Message: "SQLite ALTER TABLE cannot add constraints or change columns; rebuild the table"},
+	{Pattern: regexp.MustCompile(`(?i)\bAUTO_INCREMENT\b`), Message: "AUTO_INCREMENT is MySQL syntax; use INTEGER PRIMARY KEY AUTOINCREMENT"},
+}
+
+// CheckSyntax implements validate.SyntaxChecker.
+func (Dialect) CheckSyntax(stmt string) error { return validate.CheckRules(stmt, syntaxRules) }
+
+func init() { validate.RegisterDialect(Dialect{}) }
+
+// IsolationLevels reports serializable only: SQLite transactions are always
+// serializable.
+func (Dialect) IsolationLevels() []string {
+	return []string{"serializable"}
+}
+
+// BeginIsolated opens a plain transaction, which is already serializable.
+func (Dialect) BeginIsolated(db *sql.DB, level string) (*sql.Tx, error) {
+	return db.Begin()
+}
@@ -0,0 +1,132 @@
+package validate
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MaxInlineSQLSize is the largest input ValidateSQL accepts. Larger files are
+// validated with ValidateReader, which never holds the whole file in memory.
+const MaxInlineSQLSize = 100 * 1024
+
+// streamChunkSize is how much StatementReader reads from its source at once.
+const streamChunkSize = 64 * 1024
+
+// StatementReader yields SQL statements from r one at a time, using the same
+// rules as GenericSplit, while buffering only the statement being read.
+type StatementReader struct {
+	r       io.Reader
+	buf     []byte
+	pending []string
+	eof     bool
+}
+
+// NewStatementReader returns a StatementReader reading from r.
+func NewStatementReader(r io.Reader) *StatementReader {
+	return &StatementReader{r: r}
+}
+
+// Next returns the next trimmed statement without its terminating semicolon,
+// or io.EOF once the input is exhausted.
+func (s *StatementReader) Next() (string, error) {
+	for len(s.pending) == 0 {
+		if s.eof {
+			stmt := strings.TrimSpace(string(s.buf))
+			s.buf = nil
+			if stmt == "" {
+				return "", io.EOF
+			}
+			return stmt, nil
+		}
+		if err := s.fill(); err != nil {
+			return "", err
+		}
+	}
+	stmt := s.pending[0]
+	s.pending = s.pending[1:]
+	return stmt, nil
+}
+
+// fill reads one chunk and moves every statement completed by a terminator
+// into pending. Text after the last terminator stays buffered and is scanned
+// again with the next chunk, so quotes or comments split across chunks are
+// never misread. The chunk grows with the buffered tail so one huge statement
+// is rescanned a logarithmic number of times.
+func (s *StatementReader) fill() error {
+	chunk := make([]byte, max(streamChunkSize, len(s.buf)))
+	n, err := s.r.Read(chunk)
+	s.buf = append(s.buf, chunk[:n]...)
+	if err == io.EOF {
+		s.eof = true
+	} else if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	consumed, pos := 0, 0
+	scanSQL(string(s.buf), func(seg string, kind segmentKind) {
+		pos += len(seg)
+		if kind != segTerminator {
+			sb.WriteString(seg)
+			return
+		}
+		if stmt := strings.TrimSpace(sb.String()); stmt != "" {
+			s.pending = append(s.pending, stmt)
+		}
+		sb.Reset()
+		consumed = pos
+	})
+	s.buf = append(s.buf[:0], s.buf[consumed:]...)
+	return nil
+}
+
+// ValidateReader validates statements streamed from r inside one transaction
+// that is rolled back afterwards. Explicit BEGIN/COMMIT statements are
+// skipped because the whole stream already runs in a transaction. It is
+// meant for inputs larger than MaxInlineSQLSize; unlike ValidateSQL it does
+// not cap the number of statements.
+func ValidateReader(r io.Reader, dbConfig map[string]string, opts ValidateOptions, d Dialect) (bool, error) {
+	dsn, ok := dbConfig["dsn"]
+	if !ok || strings.TrimSpace(dsn) == "" {
+		return false, fmt.Errorf("dbConfig missing dsn")
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	db, err := OpenDB(d.DriverName(), dsn)
+	if err != nil {
+		return false, err
+	}
+	defer db.Close()
+	tx, err := beginTx(db, nil, opts, d)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	sr := NewStatementReader(r)
+	count := 0
+	for {
+		stmt, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToUpper(stmt) {
+		case "BEGIN", "BEGIN TRANSACTION", "START TRANSACTION", "COMMIT", "END", "ROLLBACK":
+			continue
+		}
+		if err := validateStmt(tx, stmt, opts, d); err != nil {
+			return false, err
+		}
+		count++
+	}
+	if count == 0 {
+		return false, fmt.Errorf("no statements found")
+	}
+	return true, nil
+}
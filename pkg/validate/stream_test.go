@@ -0,0 +1,102 @@
+package validate_test
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
+	"github.com/lenhattri/kaeshi-migrate/pkg/validate/postgres"
+)
+
+// largeSeed builds a synthetic seed file of n INSERTs whose values contain
+// semicolons, comments and dollar quotes to exercise chunk boundaries.
+func largeSeed(n int) string {
+	var b strings.Builder
+	b.WriteString("-- seed; generated\nCREATE TABLE seed (id int, note text);\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "INSERT INTO seed VALUES (%d, 'row %d; it''s fine'); /* c;%d */\n", i, i, i)
+		if i%1000 == 0 {
+			fmt.Fprintf(&b, "SELECT $tag$ body; %d $tag$;\n", i)
+		}
+	}
+	return b.String()
+}
+
+func readAll(t *testing.T, r io.Reader) []string {
+	t.Helper()
+	sr := validate.NewStatementReader(r)
+	var out []string
+	for {
+		stmt, err := sr.Next()
+		if err == io.EOF {
+			return out
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		out = append(out, stmt)
+	}
+}
+
+func TestStatementReaderMatchesGenericSplit(t *testing.T) {
+	seed := largeSeed(20000)
+	if len(seed) < 1<<20 {
+		t.Fatalf("synthetic seed too small: %d bytes", len(seed))
+	}
+	want, _ := validate.GenericSplit(seed)
+
+	if got := readAll(t, strings.NewReader(seed)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("streamed %d statements, want %d", len(got), len(want))
+	}
+	// One byte at a time forces every construct to straddle a read boundary.
+	small := largeSeed(50)
+	want, _ = validate.GenericSplit(small)
+	if got := readAll(t, iotest.OneByteReader(strings.NewReader(small))); !reflect.DeepEqual(got, want) {
+		t.Fatalf("byte-wise split = %q\nwant %q", got, want)
+	}
+}
+
+func TestValidateReaderLargeInput(t *testing.T) {
+	seed := largeSeed(3000)
+	if len(seed) <= validate.MaxInlineSQLSize {
+		t.Fatalf("seed must exceed the inline limit")
+	}
+	if ok, _ := validate.ValidateSQL(seed, map[string]string{"dsn": "mock"}, validate.ValidateOptions{}, postgres.Dialect{}); ok {
+		t.Fatal("ValidateSQL should still reject large inputs")
+	}
+	stmts, _ := validate.GenericSplit(seed)
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		for range stmts {
+			mock.ExpectExec(".*").WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		mock.ExpectRollback()
+		ok, err := validate.ValidateReader(strings.NewReader(seed), map[string]string{"dsn": "mock"}, validate.ValidateOptions{}, postgres.Dialect{})
+		if !ok || err != nil {
+			t.Fatalf("ValidateReader: ok=%v err=%v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+	})
+}
+
+func BenchmarkStatementReader(b *testing.B) {
+	seed := largeSeed(20000)
+	b.SetBytes(int64(len(seed)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sr := validate.NewStatementReader(strings.NewReader(seed))
+		for {
+			if _, err := sr.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
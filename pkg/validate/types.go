@@ -19,6 +19,16 @@ type Dialect interface {
 	StatementType(stmt string) string
 }
 
+// ReadOnlyDialect is implemented by dialects that can open a read-only
+// transaction for validating DML-only blocks.
+type ReadOnlyDialect interface {
+	BeginReadOnly(db *sql.DB) (*sql.Tx, error)
+}
+
+// DefaultTimeout bounds each validated statement when ValidateOptions leaves
+// Timeout zero.
+const DefaultTimeout = 4 * time.Second
+
 // ErrConfirmRequired indicates manual confirmation is needed to proceed.
 var ErrConfirmRequired = confirm.ErrConfirmRequired
 
@@ -41,6 +51,14 @@ type ValidateOptions struct {
 	ConfirmFn          ConfirmFunc
 	Timeout            time.Duration
 	LogLevel           LogLevel
+	// Isolation runs validation transactions at this level, mirroring a
+	// kaeshi:isolation directive; empty uses the database default.
+	Isolation string
+	// Deny refuses statements whose type (e.g. DDL) or leading keywords
+	// (e.g. DROP TABLE, TRUNCATE) match an entry, whatever the confirmation
+	// policy, unless AllowDenied is set.
+	Deny        []string
+	AllowDenied bool
 }
 
 // ValidationError provides details about a failed statement validation.
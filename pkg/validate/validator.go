@@ -3,7 +3,6 @@ package validate
 import (
 	"fmt"
 	"strings"
-	"time"
 )
 
 // ValidateSQL checks SQL syntax or safely executes it in a transaction without
@@ -15,15 +14,15 @@ func ValidateSQL(sqlText string, dbConfig map[string]string, opts ValidateOption
 	}
 
 	if opts.Timeout == 0 {
-		opts.Timeout = 4 * time.Second
+		opts.Timeout = DefaultTimeout
 	}
 
 	trimmed := strings.TrimSpace(sqlText)
 	if trimmed == "" {
 		return false, fmt.Errorf("empty SQL statement")
 	}
-	if len(trimmed) > 100*1024 {
-		return false, fmt.Errorf("SQL input too large")
+	if len(trimmed) > MaxInlineSQLSize {
+		return false, fmt.Errorf("SQL input too large; use ValidateReader")
 	}
 
 	stmts, err := d.SplitStatements(trimmed)
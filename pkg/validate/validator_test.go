@@ -2,7 +2,10 @@ package validate_test
 
 import (
 	"database/sql"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/lenhattri/kaeshi-migrate/pkg/validate"
@@ -102,3 +105,128 @@ func TestValidateSQLConfirm(t *testing.T) {
 		}
 	})
 }
+
+func TestValidateSQLReadOnlyForDML(t *testing.T) {
+	d := postgres.Dialect{}
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SET TRANSACTION READ ONLY").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("EXPLAIN SELECT").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("EXPLAIN UPDATE").
+			WillReturnError(errors.New("pq: cannot execute UPDATE in a read-only transaction"))
+		mock.ExpectRollback()
+
+		ok, err := validate.ValidateSQL("SELECT nextval('s'); UPDATE foo SET a = 1;", map[string]string{"dsn": "mock"}, validate.ValidateOptions{}, d)
+		if ok || err == nil {
+			t.Fatal("expected write in read-only transaction to be rejected")
+		}
+		var verr *validate.ValidationError
+		if !errors.As(err, &verr) || verr.Reason != "execution failed" {
+			t.Fatalf("expected execution failure, got %v", err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+	})
+}
+
+func TestValidateSQLIsolation(t *testing.T) {
+	d := postgres.Dialect{}
+	opts := validate.ValidateOptions{Isolation: "serializable"}
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("SET TRANSACTION ISOLATION LEVEL SERIALIZABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("EXPLAIN UPDATE").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		ok, err := validate.ValidateSQL("UPDATE accounts SET balance = 0;", map[string]string{"dsn": "mock"}, opts, d)
+		if err != nil || !ok {
+			t.Fatalf("expected success, got ok=%v err=%v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+	})
+
+	if err := validate.CheckIsolation(d, "Repeatable-Read"); err != nil {
+		t.Fatalf("repeatable read rejected: %v", err)
+	}
+	err := validate.CheckIsolation(d, "read uncommitted")
+	if err == nil || !strings.Contains(err.Error(), "not supported by postgres") {
+		t.Fatalf("read uncommitted err = %v, want unsupported", err)
+	}
+}
+
+func TestValidateSQLStatementTimeoutDirective(t *testing.T) {
+	d := postgres.Dialect{}
+	opts := validate.ValidateOptions{Timeout: 20 * time.Millisecond}
+	slow := "CREATE INDEX big_idx ON big (id);"
+
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE INDEX").WillDelayFor(100 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		ok, err := validate.ValidateSQL(slow, map[string]string{"dsn": "mock"}, opts, d)
+		var verr *validate.ValidationError
+		if ok || !errors.As(err, &verr) || verr.Reason != "execution failed" {
+			t.Fatalf("expected the global timeout to cancel the statement, got ok=%v err=%v", ok, err)
+		}
+	})
+
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("CREATE INDEX").WillDelayFor(100 * time.Millisecond).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("INSERT INTO").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectRollback()
+		sqlText := "-- build it\n-- kaeshi:timeout 2s\n" + slow + "\nINSERT INTO big VALUES (1);"
+		if ok, err := validate.ValidateSQL(sqlText, map[string]string{"dsn": "mock"}, opts, d); !ok || err != nil {
+			t.Fatalf("expected the directive to extend the timeout, got ok=%v err=%v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("expectations: %v", err)
+		}
+	})
+
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+		_, err := validate.ValidateSQL("-- kaeshi:timeout soon\n"+slow, map[string]string{"dsn": "mock"}, opts, d)
+		var verr *validate.ValidationError
+		if !errors.As(err, &verr) || !strings.Contains(verr.Error(), "kaeshi:timeout") {
+			t.Fatalf("expected invalid directive error, got %v", err)
+		}
+	})
+}
+
+func TestValidateSQLDenyPolicy(t *testing.T) {
+	d := postgres.Dialect{}
+	deny := []string{"drop table", "TRUNCATE"}
+	for _, stmt := range []string{"DROP TABLE users;", "-- cleanup\ntruncate   audit;"} {
+		withMockDB(t, func(mock sqlmock.Sqlmock) {
+			mock.ExpectBegin()
+			mock.ExpectRollback()
+			ok, err := validate.ValidateSQL(stmt, map[string]string{"dsn": "mock"}, validate.ValidateOptions{
+				Deny:               deny,
+				SkipOnConfirmation: true,
+				ConfirmFn:          func(string) (bool, error) { return true, nil },
+			}, d)
+			var verr *validate.ValidationError
+			if ok || !errors.As(err, &verr) || !strings.Contains(verr.Reason, "denied by policy") {
+				t.Fatalf("%q: expected policy denial, got ok=%v err=%v", stmt, ok, err)
+			}
+		})
+	}
+
+	withMockDB(t, func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectExec("DROP TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+		ok, err := validate.ValidateSQL("DROP TABLE users;", map[string]string{"dsn": "mock"}, validate.ValidateOptions{
+			Deny:        deny,
+			AllowDenied: true,
+		}, d)
+		if err != nil || !ok {
+			t.Fatalf("override should allow the statement, got ok=%v err=%v", ok, err)
+		}
+	})
+}